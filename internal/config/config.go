@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync/atomic"
 
 	"github.com/syscc/Emby-Go/internal/web/webport"
 	"gopkg.in/yaml.v3"
@@ -19,6 +20,12 @@ type Config struct {
 	VideoPreview *VideoPreview `yaml:"video-preview"`
 	// Path 路径相关配置
 	Path *Path `yaml:"path"`
+	// Subtitle 外挂字幕转换配置
+	Subtitle *Subtitle `yaml:"subtitle"`
+	// SkipMarker 片头片尾跳过标记配置
+	SkipMarker *SkipMarker `yaml:"skip-marker"`
+	// Progress 播放进度保护配置
+	Progress *Progress `yaml:"progress"`
 	// Cache 缓存相关配置
 	Cache *Cache `yaml:"cache"`
 	// Ssl ssl 相关配置
@@ -27,8 +34,33 @@ type Config struct {
 	Log *Log `yaml:"log"`
 }
 
-// C 全局唯一配置对象
-var C *Config
+// current 全局唯一配置对象
+//
+// 重新加载配置时整体替换为新的对象, 已经加载的配置对象不会再被修改
+var current atomic.Pointer[Config]
+
+// C 获取当前的全局配置对象
+//
+// 同一个请求中多次读取配置时, 可能分别读取到重新加载前后的配置
+func C() *Config {
+	return current.Load()
+}
+
+// Set 替换全局配置对象, 并将配置应用到进程全局状态
+func Set(c *Config) {
+	current.Store(c)
+
+	cVal := reflect.ValueOf(c).Elem()
+	for i := 0; i < cVal.NumField(); i++ {
+		field := cVal.Field(i)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			continue
+		}
+		if a, ok := field.Interface().(Applier); ok {
+			a.Apply()
+		}
+	}
+}
 
 // BasePath 配置文件所在的基础路径
 var BasePath string
@@ -38,26 +70,49 @@ var DataRoot string
 
 type Initializer interface {
 	// Init 配置初始化
+	//
+	// 只解析和校验配置, 不能修改进程全局状态, 未通过校验或需要重启才能生效的配置同样会执行 Init
 	Init() error
 }
 
-// ReadFromFile 从指定文件中读取配置
+// Applier 配置替换为全局配置后, 需要同步到进程全局状态的配置项
+type Applier interface {
+	// Apply 应用配置
+	Apply()
+}
+
+// ReadFromFile 从指定文件中读取配置, 并将 BasePath 设置为配置文件所在目录
 func ReadFromFile(path string) error {
-	bytes, err := os.ReadFile(path)
+	base, err := resolveBasePath(path)
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %v", err)
+		return fmt.Errorf("初始化 BasePath 失败: %v", err)
 	}
 
-	if err = initBasePath(path); err != nil {
-		return fmt.Errorf("初始化 BasePath 失败: %v", err)
+	// 配置项初始化时需要使用 BasePath 查找证书等文件, 读取失败时还原
+	old := BasePath
+	BasePath = base
+	c, err := Load(path)
+	if err != nil {
+		BasePath = old
+		return err
+	}
+	Set(c)
+	return nil
+}
+
+// Load 从指定文件中读取并初始化配置, 不修改全局配置对象和 BasePath
+func Load(path string) (*Config, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
 	}
 
-	C = new(Config)
-	if err := yaml.Unmarshal(bytes, C); err != nil {
-		return fmt.Errorf("解析配置文件失败: %v", err)
+	c := new(Config)
+	if err := yaml.Unmarshal(bytes, c); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
 
-	cVal := reflect.ValueOf(C).Elem()
+	cVal := reflect.ValueOf(c).Elem()
 	for i := 0; i < cVal.NumField(); i++ {
 		field := cVal.Field(i)
 
@@ -70,38 +125,90 @@ func ReadFromFile(path string) error {
 		// 配置项初始化
 		if i, ok := field.Interface().(Initializer); ok {
 			if err := i.Init(); err != nil {
-				return fmt.Errorf("初始化配置文件失败: %v", err)
+				return nil, fmt.Errorf("初始化配置文件失败: %v", err)
 			}
 		}
 	}
 
-	return nil
+	return c, nil
+}
+
+// Reload 重新读取配置文件, 校验通过后整体替换全局配置对象, 不影响正在处理的请求
+//
+// 新配置修改了只在启动时生效的配置项时不替换, 返回这些配置项的名称, 需要重启内核才能生效,
+// 校验失败或需要重启时新配置不会应用到进程全局状态
+//
+// 配置文件所在目录发生变化时, 新配置需要在新的 BasePath 中初始化, 同样需要重启内核
+func Reload(path string) ([]string, error) {
+	base, err := resolveBasePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 BasePath 失败: %v", err)
+	}
+	if base != BasePath {
+		return []string{"base-path"}, nil
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if fields := RestartFields(C(), c); len(fields) > 0 {
+		return fields, nil
+	}
+	Set(c)
+	return nil, nil
+}
+
+// RestartFields 比较两份配置, 返回修改后需要重启内核才能生效的配置项名称
+//
+// 监听端口和 ssl 证书、websocket 代理、缓存中间件、进度追踪器、本地目录树和转码播放列表存储都在内核启动时初始化
+func RestartFields(old, c *Config) []string {
+	var fields []string
+	if *old.Ssl != *c.Ssl {
+		fields = append(fields, "ssl")
+	}
+	if old.Emby.Host != c.Emby.Host {
+		fields = append(fields, "emby.host")
+	}
+	if old.Cache.Enable != c.Cache.Enable {
+		fields = append(fields, "cache.enable")
+	}
+	if !reflect.DeepEqual(old.Openlist.LocalTreeGen, c.Openlist.LocalTreeGen) {
+		fields = append(fields, "openlist.local-tree-gen")
+	}
+	if old.VideoPreview.PlaylistCapacity != c.VideoPreview.PlaylistCapacity ||
+		old.VideoPreview.PlaylistPersist != c.VideoPreview.PlaylistPersist {
+		fields = append(fields, "video-preview.playlist")
+	}
+	if old.Progress.HistorySize != c.Progress.HistorySize || old.Progress.BackwardJump != c.Progress.BackwardJump {
+		fields = append(fields, "progress")
+	}
+	return fields
 }
 
 // ServerInternalRequestHost 服务内部自请求 host
 func ServerInternalRequestHost() string {
 	p := "http://127.0.0.1:" + webport.HTTP
-	if C == nil {
+	c := C()
+	if c == nil {
 		return p
 	}
 
 	// 只开启了 https 端口
-	if C.Ssl.Enable && C.Ssl.SinglePort {
+	if c.Ssl.Enable && c.Ssl.SinglePort {
 		p = "https://127.0.0.1:" + webport.HTTPS
 	}
 	return p
 }
 
-// initBasePath 初始化 BasePath
-func initBasePath(path string) error {
+// resolveBasePath 获取配置文件所在目录的绝对路径
+func resolveBasePath(path string) (string, error) {
 	if filepath.IsAbs(path) {
-		BasePath = filepath.Dir(path)
-		return nil
+		return filepath.Dir(path), nil
 	}
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return err
+		return "", err
 	}
-	BasePath = filepath.Dir(absPath)
-	return nil
+	return filepath.Dir(absPath), nil
 }
@@ -0,0 +1,218 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/util/logs/colors"
+)
+
+// baseYaml 测试使用的最小配置
+const baseYaml = `emby:
+  host: http://127.0.0.1:8096
+  proxy-error-strategy: origin
+openlist:
+  host: http://127.0.0.1:5244
+  token: token
+`
+
+// writeConfig 将配置写入 dir 下的 config.yml, 返回文件路径
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	fp := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	fp := writeConfig(t, dir, baseYaml)
+	if err := config.ReadFromFile(fp); err != nil {
+		t.Fatal(err)
+	}
+	if config.BasePath != dir {
+		t.Fatalf("BasePath = %s, want %s", config.BasePath, dir)
+	}
+	old := config.C()
+
+	// 可以热加载的配置项直接替换
+	writeConfig(t, dir, strings.Replace(baseYaml, "origin", "reject", 1))
+	fields, err := config.Reload(fp)
+	if err != nil || len(fields) > 0 {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if config.C() == old || config.C().Emby.ProxyErrorStrategy != config.PeStrategyReject {
+		t.Fatalf("config not replaced: %+v", config.C().Emby)
+	}
+	if old.Emby.ProxyErrorStrategy != config.PeStrategyOrigin {
+		t.Error("old config should not be modified")
+	}
+	cur := config.C()
+
+	// 需要重启的配置项不替换
+	writeConfig(t, dir, strings.Replace(baseYaml, "8096", "8097", 1))
+	fields, err = config.Reload(fp)
+	if err != nil || !reflect.DeepEqual(fields, []string{"emby.host"}) {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if config.C() != cur {
+		t.Error("config should not be replaced when restart is required")
+	}
+
+	// 校验失败时不替换
+	writeConfig(t, dir, strings.Replace(baseYaml, "origin", "invalid", 1))
+	if _, err := config.Reload(fp); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if config.C() != cur {
+		t.Error("config should not be replaced on validation error")
+	}
+
+	// 配置文件目录变化时需要重启, 不修改 BasePath
+	other := t.TempDir()
+	fields, err = config.Reload(writeConfig(t, other, baseYaml))
+	if err != nil || !reflect.DeepEqual(fields, []string{"base-path"}) {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if config.BasePath != dir || config.C() != cur {
+		t.Errorf("BasePath = %s, config replaced: %v", config.BasePath, config.C() != cur)
+	}
+
+	// 启动时读取失败也不修改 BasePath
+	if err := config.ReadFromFile(writeConfig(t, other, "emby:\n  host: \"\"\n")); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if config.BasePath != dir {
+		t.Errorf("BasePath = %s, want %s", config.BasePath, dir)
+	}
+}
+
+func TestReloadApply(t *testing.T) {
+	dir := t.TempDir()
+	fp := writeConfig(t, dir, baseYaml)
+	if err := config.ReadFromFile(fp); err != nil {
+		t.Fatal(err)
+	}
+	colored := func() bool { return colors.WrapColor(colors.Red, "a") != "a" }
+	if !colored() {
+		t.Fatal("color should be enabled")
+	}
+
+	// 需要重启的配置不应用到进程全局状态, 也不下载 ffmpeg
+	noColor := "log:\n  disable-color: true\n"
+	ltg := "  local-tree-gen:\n    enable: true\n    refresh-interval: 60\n    ffmpeg-enable: true\n    ffmpeg-fallback: true\n"
+	writeConfig(t, dir, baseYaml+ltg+noColor)
+	fields, err := config.Reload(fp)
+	if err != nil || !reflect.DeepEqual(fields, []string{"openlist.local-tree-gen"}) {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if !colored() {
+		t.Error("color should not be disabled when restart is required")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib")); !os.IsNotExist(err) {
+		t.Errorf("ffmpeg should not be downloaded on reload: %v", err)
+	}
+
+	// 校验失败的配置同样不应用
+	writeConfig(t, dir, strings.Replace(baseYaml, "origin", "invalid", 1)+noColor)
+	if _, err := config.Reload(fp); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !colored() {
+		t.Error("color should not be disabled on validation error")
+	}
+
+	// 热加载成功后应用
+	writeConfig(t, dir, baseYaml+noColor)
+	if fields, err := config.Reload(fp); err != nil || len(fields) > 0 {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if colored() {
+		t.Error("color should be disabled after reload")
+	}
+
+	writeConfig(t, dir, baseYaml)
+	if fields, err := config.Reload(fp); err != nil || len(fields) > 0 {
+		t.Fatalf("Reload() = %v, %v", fields, err)
+	}
+	if !colored() {
+		t.Error("color should be enabled after reload")
+	}
+}
+
+func TestRestartFields(t *testing.T) {
+	dir := t.TempDir()
+	load := func(content string) *config.Config {
+		t.Helper()
+		c, err := config.Load(writeConfig(t, dir, content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	old := load(baseYaml)
+
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{"unchanged", baseYaml, nil},
+		{"hot reload", strings.Replace(baseYaml, "origin", "reject", 1), nil},
+		{"emby host", strings.Replace(baseYaml, "8096", "8097", 1), []string{"emby.host"}},
+		{"ssl", baseYaml + "ssl:\n  single-port: true\n", []string{"ssl"}},
+		{"cache", baseYaml + "cache:\n  enable: true\n", []string{"cache.enable"}},
+		{"local tree", baseYaml + "  local-tree-gen:\n    threads: 4\n", []string{"openlist.local-tree-gen"}},
+		{"playlist", baseYaml + "video-preview:\n  playlist-capacity: 5\n", []string{"video-preview.playlist"}},
+		{"progress", baseYaml + "progress:\n  history-size: 5\n", []string{"progress"}},
+		{
+			"multiple",
+			strings.Replace(baseYaml, "8096", "8097", 1) + "cache:\n  enable: true\n",
+			[]string{"emby.host", "cache.enable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.RestartFields(old, load(tt.yaml)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestartFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFFmpegWorkers(t *testing.T) {
+	dir := t.TempDir()
+	workers := ffmpeg.Workers()
+	ltg := baseYaml + "  local-tree-gen:\n    enable: true\n    refresh-interval: 60\n"
+
+	c, err := config.Load(writeConfig(t, dir, ltg+"    ffmpeg-workers: 4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Openlist.LocalTreeGen.FFmpegWorkers; got != 4 {
+		t.Errorf("FFmpegWorkers = %d, want 4", got)
+	}
+	// 加载配置不修改 ffmpeg 工作池, 由本地目录树在内核启动时设置
+	if got := ffmpeg.Workers(); got != workers {
+		t.Errorf("ffmpeg.Workers() = %d, want %d", got, workers)
+	}
+
+	c, err = config.Load(writeConfig(t, dir, ltg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Openlist.LocalTreeGen.FFmpegWorkers; got != 1 {
+		t.Errorf("default FFmpegWorkers = %d, want 1", got)
+	}
+
+	if _, err := config.Load(writeConfig(t, dir, ltg+"    ffmpeg-workers: -1\n")); err == nil {
+		t.Error("expected validation error")
+	}
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DeviceProfile 客户端设备配置
+//
+// 代理 PlaybackInfo 请求时, 根据客户端的 X-Emby-Client 或 User-Agent 选择对应的配置,
+// 代替客户端发送给 emby, 不同客户端支持的编码和容器不同
+type DeviceProfile struct {
+	// Name 配置名称
+	Name string `yaml:"name"`
+	// Match 匹配 X-Emby-Client 或 User-Agent 的关键字, 不区分大小写; 为空时作为兜底配置
+	Match []string `yaml:"match"`
+	// Payload 请求 PlaybackInfo 时使用的请求体, 需要包含 DeviceProfile 字段
+	Payload string `yaml:"payload"`
+
+	// hlsParams 从 Payload 中解析出的 hls 转码参数
+	hlsParams url.Values
+}
+
+// devicePayload 用于解析 DeviceProfile 中需要的字段
+type devicePayload struct {
+	DeviceProfile *struct {
+		MaxStreamingBitrate json.Number `json:"MaxStreamingBitrate"`
+		TranscodingProfiles []struct {
+			Container         string `json:"Container"`
+			Type              string `json:"Type"`
+			Protocol          string `json:"Protocol"`
+			VideoCodec        string `json:"VideoCodec"`
+			AudioCodec        string `json:"AudioCodec"`
+			MaxAudioChannels  any    `json:"MaxAudioChannels"`
+			MinSegments       any    `json:"MinSegments"`
+			ManifestSubtitles string `json:"ManifestSubtitles"`
+		} `json:"TranscodingProfiles"`
+	} `json:"DeviceProfile"`
+}
+
+// Init 配置初始化
+func (dp *DeviceProfile) Init() error {
+	dp.Name = strings.TrimSpace(dp.Name)
+	if dp.Name == "" {
+		return errors.New("name 不能为空")
+	}
+
+	match := dp.Match[:0]
+	for _, m := range dp.Match {
+		if m = strings.TrimSpace(m); m != "" {
+			match = append(match, m)
+		}
+	}
+	dp.Match = match
+
+	var p devicePayload
+	if err := json.Unmarshal([]byte(dp.Payload), &p); err != nil {
+		return fmt.Errorf("payload 不是合法的 json: %v", err)
+	}
+	if p.DeviceProfile == nil {
+		return errors.New("payload 缺少 DeviceProfile 字段")
+	}
+
+	dp.hlsParams = url.Values{}
+	if p.DeviceProfile.MaxStreamingBitrate != "" {
+		dp.hlsParams.Set("MaxStreamingBitrate", p.DeviceProfile.MaxStreamingBitrate.String())
+	}
+	for _, tp := range p.DeviceProfile.TranscodingProfiles {
+		if !strings.EqualFold(tp.Type, "Video") || !strings.EqualFold(tp.Protocol, "hls") {
+			continue
+		}
+		setNotEmpty(dp.hlsParams, "VideoCodec", tp.VideoCodec)
+		setNotEmpty(dp.hlsParams, "AudioCodec", tp.AudioCodec)
+		setNotEmpty(dp.hlsParams, "SegmentContainer", tp.Container)
+		setNotEmpty(dp.hlsParams, "TranscodingMaxAudioChannels", anyString(tp.MaxAudioChannels))
+		setNotEmpty(dp.hlsParams, "MinSegments", anyString(tp.MinSegments))
+		setNotEmpty(dp.hlsParams, "ManifestSubtitles", tp.ManifestSubtitles)
+		break
+	}
+	return nil
+}
+
+// MatchClient 判断配置是否匹配指定的客户端名称或 User-Agent
+func (dp *DeviceProfile) MatchClient(client, userAgent string) bool {
+	client, userAgent = strings.ToLower(client), strings.ToLower(userAgent)
+	for _, m := range dp.Match {
+		m = strings.ToLower(m)
+		if (client != "" && strings.Contains(client, m)) || strings.Contains(userAgent, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// HlsParams 返回配置中 hls 转码使用的编码参数, 用于构造 master.m3u8 地址
+func (dp *DeviceProfile) HlsParams() url.Values {
+	res := url.Values{}
+	for k, v := range dp.hlsParams {
+		res[k] = append([]string(nil), v...)
+	}
+	return res
+}
+
+// MatchDeviceProfile 根据客户端名称和 User-Agent 选择设备配置
+//
+// 按配置顺序匹配, 都不匹配时使用第一个没有关键字的兜底配置
+func (e *Emby) MatchDeviceProfile(client, userAgent string) *DeviceProfile {
+	var fallback *DeviceProfile
+	for _, dp := range e.DeviceProfiles {
+		if len(dp.Match) == 0 {
+			if fallback == nil {
+				fallback = dp
+			}
+			continue
+		}
+		if dp.MatchClient(client, userAgent) {
+			return dp
+		}
+	}
+	return fallback
+}
+
+// initDeviceProfiles 初始化设备配置, 没有配置时使用内置配置, 并确保存在兜底配置
+func (e *Emby) initDeviceProfiles() error {
+	if len(e.DeviceProfiles) == 0 {
+		e.DeviceProfiles = BuiltinDeviceProfiles()
+	}
+
+	hasFallback := false
+	for _, dp := range e.DeviceProfiles {
+		if dp == nil {
+			return errors.New("存在空配置")
+		}
+		if err := dp.Init(); err != nil {
+			return fmt.Errorf("%s: %v", dp.Name, err)
+		}
+		if len(dp.Match) == 0 {
+			hasFallback = true
+		}
+	}
+	if hasFallback {
+		return nil
+	}
+
+	fallback := &DeviceProfile{Name: DefaultDeviceProfileName, Payload: webDevicePayload}
+	if err := fallback.Init(); err != nil {
+		return err
+	}
+	e.DeviceProfiles = append(e.DeviceProfiles, fallback)
+	return nil
+}
+
+// setNotEmpty 仅在 value 不为空时设置参数
+func setNotEmpty(v url.Values, key, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		v.Set(key, value)
+	}
+}
+
+// anyString 将 json 中可能为字符串或数字的字段转换为字符串
+func anyString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// DefaultDeviceProfileName 兜底设备配置的名称
+const DefaultDeviceProfileName = "default"
+
+// BuiltinDeviceProfiles 内置的设备配置, 按匹配优先级排序
+func BuiltinDeviceProfiles() []*DeviceProfile {
+	return []*DeviceProfile{
+		{Name: "android-tv", Match: []string{"Android TV", "AndroidTV"}, Payload: androidTvDevicePayload},
+		{Name: "infuse", Match: []string{"Infuse"}, Payload: infuseDevicePayload},
+		{Name: "kodi", Match: []string{"Kodi"}, Payload: kodiDevicePayload},
+		{Name: "ios", Match: []string{"Emby for iOS", "iPhone", "iPad", "AppleTV"}, Payload: iosDevicePayload},
+		{Name: "web", Match: []string{"Emby Web", "Mozilla"}, Payload: webDevicePayload},
+		{Name: DefaultDeviceProfileName, Payload: webDevicePayload},
+	}
+}
+
+// webDevicePayload 浏览器使用的设备配置, 只能播放浏览器原生支持的编码
+const webDevicePayload = `{"DeviceProfile":{"MaxStaticBitrate":140000000,"MaxStreamingBitrate":140000000,"MusicStreamingTranscodingBitrate":192000,"DirectPlayProfiles":[{"Container":"mp4,m4v","Type":"Video","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"mkv","Type":"Video","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"flv","Type":"Video","VideoCodec":"h264","AudioCodec":"aac,mp3"},{"Container":"3gp","Type":"Video","VideoCodec":"","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"mov","Type":"Video","VideoCodec":"h264","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"opus","Type":"Audio"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3"},{"Container":"mp2,mp3","Type":"Audio","AudioCodec":"mp2"},{"Container":"m4a","AudioCodec":"aac","Type":"Audio"},{"Container":"mp4","AudioCodec":"aac","Type":"Audio"},{"Container":"flac","Type":"Audio"},{"Container":"webma,webm","Type":"Audio"},{"Container":"wav","Type":"Audio","AudioCodec":"PCM_S16LE,PCM_S24LE"},{"Container":"ogg","Type":"Audio"},{"Container":"webm","Type":"Video","AudioCodec":"vorbis,opus","VideoCodec":"av1,VP8,VP9"}],"TranscodingProfiles":[{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"2","MinSegments":"1","BreakOnNonKeyFrames":true},{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"opus","Type":"Audio","AudioCodec":"opus","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"wav","Type":"Audio","AudioCodec":"wav","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"opus","Type":"Audio","AudioCodec":"opus","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"wav","Type":"Audio","AudioCodec":"wav","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mkv","Type":"Video","AudioCodec":"mp3,aac,opus,flac,vorbis","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","Context":"Static","MaxAudioChannels":"2","CopyTimestamps":true},{"Container":"ts","Type":"Video","AudioCodec":"mp3,aac","VideoCodec":"h264,h265,hevc,av1","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"2","MinSegments":"1","BreakOnNonKeyFrames":true,"ManifestSubtitles":"vtt"},{"Container":"webm","Type":"Video","AudioCodec":"vorbis","VideoCodec":"vpx","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp4","Type":"Video","AudioCodec":"mp3,aac,opus,flac,vorbis","VideoCodec":"h264","Context":"Static","Protocol":"http"}],"ContainerProfiles":[],"CodecProfiles":[{"Type":"VideoAudio","Codec":"aac","Conditions":[{"Condition":"Equals","Property":"IsSecondaryAudio","Value":"false","IsRequired":"false"}]},{"Type":"VideoAudio","Conditions":[{"Condition":"Equals","Property":"IsSecondaryAudio","Value":"false","IsRequired":"false"}]},{"Type":"Video","Codec":"h264","Conditions":[{"Condition":"EqualsAny","Property":"VideoProfile","Value":"high|main|baseline|constrained baseline|high 10","IsRequired":false},{"Condition":"LessThanEqual","Property":"VideoLevel","Value":"62","IsRequired":false}]},{"Type":"Video","Codec":"hevc","Conditions":[{"Condition":"EqualsAny","Property":"VideoCodecTag","Value":"hvc1|hev1|hevc|hdmv","IsRequired":false}]}],"SubtitleProfiles":[{"Format":"vtt","Method":"Hls"},{"Format":"eia_608","Method":"VideoSideData","Protocol":"hls"},{"Format":"eia_708","Method":"VideoSideData","Protocol":"hls"},{"Format":"vtt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"}],"ResponseProfiles":[{"Type":"Video","Container":"m4v","MimeType":"video/mp4"}]}}`
+
+// androidTvDevicePayload Android TV 客户端 (ExoPlayer) 使用的设备配置, 支持硬解 hevc 和多声道音频
+const androidTvDevicePayload = `{"DeviceProfile":{"MaxStaticBitrate":200000000,"MaxStreamingBitrate":200000000,"MusicStreamingTranscodingBitrate":320000,"DirectPlayProfiles":[{"Container":"mkv,mp4,m4v,mov,ts,m2ts,webm","Type":"Video","VideoCodec":"h264,h265,hevc,av1,vp8,vp9,mpeg2video,mpeg4","AudioCodec":"aac,mp3,ac3,eac3,dts,truehd,flac,opus,vorbis,pcm_s16le,pcm_s24le"},{"Container":"mp3,flac,aac,m4a,ogg,opus,wav","Type":"Audio"}],"TranscodingProfiles":[{"Container":"ts","Type":"Video","AudioCodec":"aac,ac3,eac3","VideoCodec":"h264,hevc","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"6","MinSegments":"1","BreakOnNonKeyFrames":true,"ManifestSubtitles":"vtt"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"}],"ContainerProfiles":[],"CodecProfiles":[],"SubtitleProfiles":[{"Format":"vtt","Method":"Hls"},{"Format":"srt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"},{"Format":"vtt","Method":"External"},{"Format":"srt","Method":"Embed"},{"Format":"ass","Method":"Embed"},{"Format":"ssa","Method":"Embed"},{"Format":"pgssub","Method":"Embed"},{"Format":"dvdsub","Method":"Embed"}],"ResponseProfiles":[]}}`
+
+// infuseDevicePayload Infuse 使用的设备配置, 几乎支持所有容器和编码
+const infuseDevicePayload = `{"DeviceProfile":{"MaxStaticBitrate":400000000,"MaxStreamingBitrate":400000000,"MusicStreamingTranscodingBitrate":320000,"DirectPlayProfiles":[{"Container":"mkv,mp4,m4v,mov,ts,m2ts,avi,wmv,webm,flv,iso","Type":"Video"},{"Container":"mp3,flac,aac,m4a,alac,ogg,opus,wav,dsf,dff,ape","Type":"Audio"}],"TranscodingProfiles":[{"Container":"ts","Type":"Video","AudioCodec":"aac,ac3,eac3","VideoCodec":"h264,hevc","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"8","MinSegments":"1","BreakOnNonKeyFrames":true}],"ContainerProfiles":[],"CodecProfiles":[],"SubtitleProfiles":[{"Format":"srt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"},{"Format":"vtt","Method":"External"},{"Format":"sub","Method":"External"},{"Format":"srt","Method":"Embed"},{"Format":"ass","Method":"Embed"},{"Format":"ssa","Method":"Embed"},{"Format":"pgssub","Method":"Embed"},{"Format":"dvdsub","Method":"Embed"},{"Format":"vtt","Method":"Embed"}],"ResponseProfiles":[]}}`
+
+// kodiDevicePayload Kodi 使用的设备配置, 由 Kodi 自行解码, 转码时保留多声道音频
+const kodiDevicePayload = `{"DeviceProfile":{"MaxStaticBitrate":400000000,"MaxStreamingBitrate":400000000,"MusicStreamingTranscodingBitrate":320000,"DirectPlayProfiles":[{"Container":"","Type":"Video"},{"Container":"","Type":"Audio"}],"TranscodingProfiles":[{"Container":"ts","Type":"Video","AudioCodec":"aac,ac3,eac3,dts","VideoCodec":"h264,hevc","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"8","MinSegments":"1","BreakOnNonKeyFrames":true}],"ContainerProfiles":[],"CodecProfiles":[],"SubtitleProfiles":[{"Format":"srt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"},{"Format":"vtt","Method":"External"},{"Format":"sub","Method":"External"},{"Format":"srt","Method":"Embed"},{"Format":"ass","Method":"Embed"},{"Format":"ssa","Method":"Embed"},{"Format":"pgssub","Method":"Embed"},{"Format":"dvdsub","Method":"Embed"}],"ResponseProfiles":[]}}`
+
+// iosDevicePayload iOS/tvOS 官方客户端 (AVPlayer) 使用的设备配置
+const iosDevicePayload = `{"DeviceProfile":{"MaxStaticBitrate":140000000,"MaxStreamingBitrate":140000000,"MusicStreamingTranscodingBitrate":192000,"DirectPlayProfiles":[{"Container":"mp4,m4v,mov","Type":"Video","VideoCodec":"h264,hevc,h265","AudioCodec":"aac,mp3,ac3,eac3,flac,alac"},{"Container":"ts","Type":"Video","VideoCodec":"h264,hevc,h265","AudioCodec":"aac,mp3,ac3,eac3"},{"Container":"mp3,aac,m4a,flac,alac,wav","Type":"Audio"}],"TranscodingProfiles":[{"Container":"ts","Type":"Video","AudioCodec":"aac,ac3,eac3","VideoCodec":"h264,hevc","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"6","MinSegments":"1","BreakOnNonKeyFrames":true,"ManifestSubtitles":"vtt"},{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"2","MinSegments":"1","BreakOnNonKeyFrames":true}],"ContainerProfiles":[],"CodecProfiles":[{"Type":"Video","Codec":"hevc","Conditions":[{"Condition":"EqualsAny","Property":"VideoCodecTag","Value":"hvc1|hev1|hevc","IsRequired":false}]}],"SubtitleProfiles":[{"Format":"vtt","Method":"Hls"},{"Format":"vtt","Method":"External"},{"Format":"srt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"}],"ResponseProfiles":[{"Type":"Video","Container":"m4v","MimeType":"video/mp4"}]}}`
@@ -42,6 +42,8 @@ var validDlStrategy = map[DlStrategy]struct{}{
 type Emby struct {
 	// Emby 源服务器地址
 	Host string `yaml:"host"`
+	// Token emby api key, 用于校验配置时访问需要认证的接口
+	Token string `yaml:"token"`
 	// rclone 或者 cd 的挂载目录
 	MountPath string `yaml:"mount-path"`
 	// EpisodesUnplayPrior 在获取剧集列表时是否将未播资源优先展示
@@ -64,6 +66,10 @@ type Emby struct {
 	DlCacheIgnore []string `yaml:"dl-cache-ignore"`
 	// DlCacheIgnoreMode 直链缓存忽略模式 (blacklist: 黑名单, whitelist: 白名单)
 	DlCacheIgnoreMode string `yaml:"dl-cache-ignore-mode"`
+	// DeviceProfiles 客户端设备配置, 按顺序匹配客户端
+	DeviceProfiles []*DeviceProfile `yaml:"device-profiles"`
+	// WatchSync 是否向管理进程上报停止播放事件, 用于多个服务器之间同步观看状态
+	WatchSync bool `yaml:"watch-sync"`
 }
 
 func (e *Emby) Init() error {
@@ -114,6 +120,10 @@ func (e *Emby) Init() error {
 	}
 	e.DlCacheIgnoreMode = strings.ToLower(e.DlCacheIgnoreMode)
 
+	if err := e.initDeviceProfiles(); err != nil {
+		return fmt.Errorf("emby.device-profiles 配置错误: %v", err)
+	}
+
 	return nil
 }
 
@@ -7,10 +7,9 @@ type Log struct {
 	DisableColor bool `yaml:"disable-color"` // 是否禁用彩色日志输出
 }
 
-// Init 配置初始化
-func (lc *Log) Init() error {
+// Apply 应用日志颜色配置
+func (lc *Log) Apply() {
 	colors.SetEnabler(lc)
-	return nil
 }
 
 // EnableColor 标记是否启用颜色输出
@@ -3,8 +3,6 @@ package config
 import (
 	"fmt"
 	"strings"
-
-	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
 )
 
 type Openlist struct {
@@ -33,9 +31,15 @@ type LocalTreeGen struct {
 	// Enable 是否启用
 	Enable bool `yaml:"enable"`
 
-	// FFmpegEnable 是否启用 ffmpeg
+	// FFmpegEnable 是否启用媒体元数据解析 (历史原因沿用 ffmpeg 命名)
 	FFmpegEnable bool `yaml:"ffmpeg-enable"`
 
+	// FFmpegFallback 原生解析失败时是否回退到 ffmpeg, 开启后会自动下载 ffmpeg
+	FFmpegFallback bool `yaml:"ffmpeg-fallback"`
+
+	// FFmpegWorkers 同时运行的 ffmpeg 进程数
+	FFmpegWorkers int `yaml:"ffmpeg-workers"`
+
 	// VirtualContainers 虚拟媒体容器, 原始串, 以英文逗号分割
 	VirtualContainers string `yaml:"virtual-containers"`
 
@@ -60,6 +64,9 @@ type LocalTreeGen struct {
 	// Threads 同步线程数
 	Threads int `yaml:"threads"`
 
+	// SidecarEnable 是否同步 nfo、海报等刮削附属文件, 开启 ffmpeg 时还会为虚拟文件生成简易 nfo
+	SidecarEnable bool `yaml:"sidecar-enable"`
+
 	// virtualContainers 虚拟媒体容器集合 便于快速查询
 	virtualContainers map[string]struct{}
 
@@ -79,12 +86,6 @@ func (ltg *LocalTreeGen) Init() error {
 		return nil
 	}
 
-	if ltg.FFmpegEnable {
-		if err := ffmpeg.AutoDownloadExec(BasePath); err != nil {
-			return fmt.Errorf("ffmpeg 初始化失败: %w", err)
-		}
-	}
-
 	if ltg.AutoRemoveMaxCount < 0 {
 		ltg.AutoRemoveMaxCount = 0
 	}
@@ -112,6 +113,14 @@ func (ltg *LocalTreeGen) Init() error {
 		return fmt.Errorf("无效同步线程数: [%d], 必须配置为大于 0 的值", ltg.Threads)
 	}
 
+	if ltg.FFmpegWorkers == 0 {
+		// 默认逐个执行
+		ltg.FFmpegWorkers = 1
+	}
+	if ltg.FFmpegWorkers < 0 {
+		return fmt.Errorf("无效 ffmpeg 进程数: [%d], 必须配置为大于 0 的值", ltg.FFmpegWorkers)
+	}
+
 	ss := strings.Split(strings.TrimSpace(ltg.VirtualContainers), ",")
 	ltg.virtualContainers = make(map[string]struct{}, len(ss))
 	for _, s := range ss {
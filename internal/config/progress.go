@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultProgressType 未单独配置阈值的 item 类型使用的兜底配置名称
+const DefaultProgressType = "default"
+
+// Progress 播放进度保护配置
+type Progress struct {
+	// Thresholds 按 item 类型 (Movie, Episode, Audio 等) 配置的进度阈值, default 为兜底配置
+	Thresholds map[string]*ProgressThreshold `yaml:"thresholds"`
+	// BackwardJump 进度回退超过该值时视为可疑回跳, 需要后续上报确认后才会生效, 单位: 秒, 0 表示不检测
+	BackwardJump int `yaml:"backward-jump"`
+	// HistorySize 每个播放会话保留的进度记录个数
+	HistorySize int `yaml:"history-size"`
+
+	// thresholds 类型名称统一转换为小写后的阈值配置
+	thresholds map[string]*ProgressThreshold
+}
+
+// ProgressThreshold 一种 item 类型的进度阈值, 单位: 秒
+type ProgressThreshold struct {
+	// MinStopped 停止播放时至少播放多长时间才补发进度记录
+	MinStopped int `yaml:"min-stopped"`
+	// MinReport 低于该位置的进度上报视为无效请求并丢弃
+	MinReport int `yaml:"min-report"`
+}
+
+// Init 配置初始化
+func (p *Progress) Init() error {
+	if p.BackwardJump < 0 {
+		return fmt.Errorf("progress.backward-jump 不能为负数: %d", p.BackwardJump)
+	}
+	if p.HistorySize <= 0 {
+		p.HistorySize = 10
+	}
+
+	p.thresholds = make(map[string]*ProgressThreshold)
+	for name, t := range p.Thresholds {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || t == nil {
+			return fmt.Errorf("progress.thresholds 配置错误: %q", name)
+		}
+		if t.MinStopped < 0 || t.MinReport < 0 {
+			return fmt.Errorf("progress.thresholds.%s 阈值不能为负数", name)
+		}
+		p.thresholds[name] = t
+	}
+	if _, ok := p.thresholds[DefaultProgressType]; !ok {
+		p.thresholds[DefaultProgressType] = &ProgressThreshold{MinStopped: 300, MinReport: 1}
+	}
+	return nil
+}
+
+// Threshold 获取 item 类型对应的阈值, 没有单独配置时返回兜底配置
+func (p *Progress) Threshold(itemType string) *ProgressThreshold {
+	if t, ok := p.thresholds[strings.ToLower(itemType)]; ok {
+		return t
+	}
+	return p.thresholds[DefaultProgressType]
+}
+
+// TypeSpecific 是否配置了除兜底配置外的类型阈值, 为 false 时不需要查询 item 类型
+func (p *Progress) TypeSpecific() bool {
+	return len(p.thresholds) > 1
+}
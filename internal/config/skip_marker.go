@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SkipMarker 片头片尾跳过标记配置
+type SkipMarker struct {
+	// Rules 按剧集或季配置的片头片尾时间
+	Rules []*SkipMarkerRule `yaml:"rules"`
+}
+
+// SkipMarkerRule 一个剧集或季的片头片尾时间, 单位: 秒
+type SkipMarkerRule struct {
+	// Series 剧集名称或 emby 中的剧集 id
+	Series string `yaml:"series"`
+	// Season 季号, 0 表示剧集的所有季
+	Season int `yaml:"season"`
+	// IntroStart 片头开始时间
+	IntroStart int `yaml:"intro-start"`
+	// IntroEnd 片头结束时间, 为 0 表示不设置片头
+	IntroEnd int `yaml:"intro-end"`
+	// CreditsStart 片尾开始时间, 负数表示距离结尾的时间, 为 0 表示不设置片尾
+	CreditsStart int `yaml:"credits-start"`
+}
+
+// Init 配置初始化
+func (sm *SkipMarker) Init() error {
+	for i, r := range sm.Rules {
+		if r == nil {
+			return fmt.Errorf("skip-marker.rules[%d] 配置不能为空", i)
+		}
+		if err := r.Init(); err != nil {
+			return fmt.Errorf("skip-marker.rules[%d] 配置错误: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Init 配置初始化
+func (r *SkipMarkerRule) Init() error {
+	r.Series = strings.TrimSpace(r.Series)
+	if r.Series == "" {
+		return errors.New("series 不能为空")
+	}
+	if r.Season < 0 {
+		return fmt.Errorf("season 不能为负数: %d", r.Season)
+	}
+	if r.IntroStart < 0 || r.IntroEnd < 0 {
+		return errors.New("片头时间不能为负数")
+	}
+	if r.IntroEnd != 0 && r.IntroEnd <= r.IntroStart {
+		return fmt.Errorf("片头结束时间 %d 必须大于开始时间 %d", r.IntroEnd, r.IntroStart)
+	}
+	if r.IntroEnd == 0 && r.CreditsStart == 0 {
+		return errors.New("至少需要设置片头或片尾")
+	}
+	return nil
+}
+
+// Match 查找剧集对应的规则, 指定季的规则优先于整部剧集的规则
+//
+// seriesId 和 seriesName 任意一个与规则的 Series 相同即视为同一剧集, 名称不区分大小写
+func (sm *SkipMarker) Match(seriesId, seriesName string, season int) *SkipMarkerRule {
+	var seriesRule *SkipMarkerRule
+	for _, r := range sm.Rules {
+		if r.Series != seriesId && !strings.EqualFold(r.Series, strings.TrimSpace(seriesName)) {
+			continue
+		}
+		if r.Season == season {
+			return r
+		}
+		if r.Season == 0 && seriesRule == nil {
+			seriesRule = r
+		}
+	}
+	return seriesRule
+}
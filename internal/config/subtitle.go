@@ -0,0 +1,22 @@
+package config
+
+import "strings"
+
+// Subtitle 外挂字幕转换配置
+type Subtitle struct {
+	// Convert 是否将外挂的 srt/ass/ssa 字幕转换为 WebVTT
+	Convert bool `yaml:"convert"`
+	// Charset 字幕原始编码, auto 表示自动识别 UTF-8/GBK/Big5
+	Charset string `yaml:"charset"`
+	// Offset 字幕时间轴偏移, 单位: 毫秒, 负数表示提前
+	Offset int `yaml:"offset"`
+}
+
+// Init 配置初始化
+func (s *Subtitle) Init() error {
+	s.Charset = strings.ToLower(strings.TrimSpace(s.Charset))
+	if s.Charset == "" {
+		s.Charset = "auto"
+	}
+	return nil
+}
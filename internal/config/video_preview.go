@@ -7,6 +7,14 @@ type VideoPreview struct {
 	Containers []string `yaml:"containers"`
 	// IgnoreTemplateIds 忽略的转码清晰度
 	IgnoreTemplateIds []string `yaml:"ignore-template-ids"`
+	// Adaptive 是否返回包含所有清晰度的多码率 m3u8
+	Adaptive bool `yaml:"adaptive"`
+	// PlaylistCapacity 内存中最多维护的转码播放列表个数, 开启 Adaptive 时每个观看者占用所有清晰度的个数
+	PlaylistCapacity int `yaml:"playlist-capacity"`
+	// PlaylistPersist 是否将转码播放列表持久化到磁盘, 重启后恢复
+	PlaylistPersist bool `yaml:"playlist-persist"`
+	// SegmentProxy 是否由本地代理 ts 分片, 而不是重定向到直链
+	SegmentProxy bool `yaml:"segment-proxy"`
 
 	// containerMap 依据 Containers 初始化该 map, 便于后续快速判断
 	containerMap map[string]struct{}
@@ -15,6 +23,9 @@ type VideoPreview struct {
 }
 
 func (vp *VideoPreview) Init() error {
+	if vp.PlaylistCapacity <= 0 {
+		vp.PlaylistCapacity = 10
+	}
 	vp.containerMap = make(map[string]struct{})
 	for _, container := range vp.Containers {
 		vp.containerMap[container] = struct{}{}
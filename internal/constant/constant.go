@@ -30,6 +30,9 @@ const (
 	Reg_ProxyPlaylist = `(?i)^/.*videos/proxy_playlist\??`
 	Reg_ProxyTs       = `(?i)^/.*videos/proxy_ts\??`
 	Reg_ProxySubtitle = `(?i)^/.*videos/proxy_subtitle\??`
+	Reg_ProxyVtt      = `(?i)^/.*videos/proxy_vtt\??`
+
+	Reg_ProxySiblingSubtitle = `(?i)^/.*videos/proxy_sibling_subtitle\??`
 
 	Reg_ItemDownload     = `(?i)^/.*items/\d+/download($|\?)`
 	Reg_ItemSyncDownload = `(?i)^/.*sync/jobitems/\d+/file($|\?)`
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix API token 的前缀, 用于和登录会话 token 区分
+const APITokenPrefix = "gep_"
+
+// API token 的权限范围
+const (
+	ScopeServersRead  = "servers:read"  // 查看服务器列表和全局配置
+	ScopeServersWrite = "servers:write" // 增删改服务器、修改全局配置、重启服务器
+	ScopeSync         = "sync"          // 触发本地目录树同步
+	ScopeLogsRead     = "logs:read"     // 查看日志
+)
+
+// Scopes 所有可用的权限范围
+var Scopes = []string{ScopeServersRead, ScopeServersWrite, ScopeSync, ScopeLogsRead}
+
+// APIToken 用于脚本调用管理接口的个人 token, 数据库中只保存 token 的哈希
+//
+// token 以创建者的身份访问接口, 同时受创建者角色和 token 权限范围的限制
+type APIToken struct {
+	ID         uint      `gorm:"primaryKey" json:"ID"`
+	UserID     uint      `gorm:"index" json:"UserID"`
+	Name       string    `json:"Name"`
+	TokenHash  string    `gorm:"uniqueIndex" json:"-"`
+	Hint       string    `json:"Hint"`       // token 的前几位, 用于在列表中区分
+	Scopes     string    `json:"Scopes"`     // 逗号分隔的权限范围
+	ExpiresAt  time.Time `json:"ExpiresAt"`  // 过期时间, 零值表示永不过期
+	LastUsedAt time.Time `json:"LastUsedAt"` // 最后使用时间
+	CreatedAt  time.Time `json:"CreatedAt"`
+}
+
+// HasScope 判断 token 是否拥有 scope 权限
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(strings.Split(t.Scopes, ","), scope)
+}
+
+// Expired 判断 token 是否已过期
+func (t *APIToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(now)
+}
+
+// CreateAPIToken 为用户创建 API token, expiresAt 为零值表示永不过期, 返回明文 token
+func CreateAPIToken(userID uint, name string, scopes []string, expiresAt time.Time) (string, *APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	if !expiresAt.IsZero() && expiresAt.Before(time.Now()) {
+		return "", nil, errors.New("expiry time must be in the future")
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := APITokenPrefix + hex.EncodeToString(buf)
+	t := APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Hint:      token[:len(APITokenPrefix)+6],
+		Scopes:    strings.Join(slices.Compact(slices.Sorted(slices.Values(scopes))), ","),
+		ExpiresAt: expiresAt,
+	}
+	if err := DB.Create(&t).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &t, nil
+}
+
+// GetAPITokens 获取用户的所有 API token
+func GetAPITokens(userID uint) ([]APIToken, error) {
+	var list []APIToken
+	if err := DB.Where("user_id = ?", userID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetAPIToken 获取用户的某个 API token
+func GetAPIToken(userID, id uint) (*APIToken, error) {
+	var t APIToken
+	if err := DB.Where("user_id = ?", userID).First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteAPIToken 撤销用户的某个 API token
+func DeleteAPIToken(userID, id uint) error {
+	return DB.Where("user_id = ?", userID).Delete(&APIToken{}, id).Error
+}
+
+// GetAPITokenUser 校验 API token 并返回 token 及其所属的用户
+func GetAPITokenUser(token string) (*User, *APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, nil, ErrSessionInvalid
+	}
+	var t APIToken
+	res := DB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&t)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, nil, ErrSessionInvalid
+	}
+	now := time.Now()
+	if t.Expired(now) {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	var user User
+	if err := DB.First(&user, t.UserID).Error; err != nil {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	if now.Sub(t.LastUsedAt) > sessionTouchInterval {
+		DB.Model(&t).Update("last_used_at", now)
+	}
+	return &user, &t, nil
+}
+
+// deleteUserAPITokens 删除用户的所有 API token
+func deleteUserAPITokens(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ?", userID).Delete(&APIToken{}).Error
+}
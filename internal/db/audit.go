@@ -0,0 +1,159 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 审计日志的操作类型
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore" // 恢复备份
+)
+
+// auditMask 敏感字段在审计日志中的显示值
+const auditMask = "******"
+
+// auditIgnoreFields 不记录变更的字段
+var auditIgnoreFields = map[string]struct{}{"CreatedAt": {}, "UpdatedAt": {}}
+
+// AuditLog 配置变更审计日志
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"ID"`
+	Username  string    `gorm:"index" json:"Username"` // 操作的 WebUI 用户
+	Action    string    `json:"Action"`                // create / update / delete / restore
+	Target    string    `gorm:"index" json:"Target"`   // 变更的数据类型, 如 EmbyServer
+	TargetID  uint      `json:"TargetID"`              // 变更的数据 id
+	Changes   string    `json:"Changes"`               // 字段变更列表, AuditChange 数组的 json
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// AuditChange 单个字段的变更
+type AuditChange struct {
+	Field  string `json:"Field"`
+	Before any    `json:"Before"`
+	After  any    `json:"After"`
+}
+
+// RecordAudit 记录一次数据变更, before 或 after 为 nil 分别表示新增和删除
+//
+// 只记录发生变化的字段, 名称包含 Token 或 Password 的字段会被脱敏
+func RecordAudit(username, action string, targetID uint, before, after any) error {
+	target := auditTarget(before, after)
+	changes := DiffFields(before, after)
+	if action == AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+	bytes, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("序列化审计变更失败: %v", err)
+	}
+	return DB.Create(&AuditLog{
+		Username: username,
+		Action:   action,
+		Target:   target,
+		TargetID: targetID,
+		Changes:  string(bytes),
+	}).Error
+}
+
+// GetAuditLogs 按时间倒序获取审计日志, target 不为空时只返回该类型的日志
+func GetAuditLogs(target string, limit int) ([]AuditLog, error) {
+	var list []AuditLog
+	q := DB.Order("id desc").Limit(limit)
+	if target != "" {
+		q = q.Where("target = ?", target)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DiffFields 比较两个同类型结构体的字段, 返回发生变化的字段
+func DiffFields(before, after any) []AuditChange {
+	bm, am := auditFields(before), auditFields(after)
+	names := make(map[string]struct{})
+	for name := range bm {
+		names[name] = struct{}{}
+	}
+	for name := range am {
+		names[name] = struct{}{}
+	}
+
+	changes := make([]AuditChange, 0)
+	for name := range names {
+		if _, ignore := auditIgnoreFields[name]; ignore {
+			continue
+		}
+		b, a := bm[name], am[name]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		if sensitiveField(name) {
+			b, a = maskValue(b), maskValue(a)
+		}
+		changes = append(changes, AuditChange{Field: name, Before: b, After: a})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// auditFields 将结构体转换为字段名到值的映射
+func auditFields(v any) map[string]any {
+	res := make(map[string]any)
+	if isNil(v) {
+		return res
+	}
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return res
+	}
+	_ = json.Unmarshal(bytes, &res)
+	return res
+}
+
+// auditTarget 获取变更数据的类型名称
+func auditTarget(before, after any) string {
+	v := after
+	if isNil(v) {
+		v = before
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
+// isNil 判断 v 是否为 nil 或 nil 指针
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// sensitiveField 判断字段是否需要脱敏
+func sensitiveField(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "token") || strings.Contains(lower, "password") || strings.Contains(lower, "secret")
+}
+
+// maskValue 脱敏字段值, 保留是否为空的信息
+func maskValue(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return auditMask
+}
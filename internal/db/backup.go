@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot 可以导出和恢复的配置数据
+//
+// 不包含 WebUI 用户、会话、API token 和审计日志, 恢复备份不会影响当前的登录状态
+type Snapshot struct {
+	Servers        []EmbyServer    `json:"servers"`
+	GlobalConfig   GlobalConfig    `json:"globalConfig"`
+	Notifies       []Notify        `json:"notifies"`
+	DeviceProfiles []DeviceProfile `json:"deviceProfiles"`
+	SkipMarkers    []SkipMarker    `json:"skipMarkers"`
+}
+
+// ExportSnapshot 导出当前的配置数据
+func ExportSnapshot() (*Snapshot, error) {
+	var s Snapshot
+	var err error
+	if s.Servers, err = GetServers(); err != nil {
+		return nil, err
+	}
+	if s.GlobalConfig, err = GetGlobalConfig(); err != nil {
+		return nil, err
+	}
+	if s.Notifies, err = GetNotifies(); err != nil {
+		return nil, err
+	}
+	if s.DeviceProfiles, err = GetDeviceProfiles(); err != nil {
+		return nil, err
+	}
+	if s.SkipMarkers, err = GetSkipMarkers(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RestoreSnapshot 在一个事务中使用快照替换当前的配置数据, 数据 id 保持不变
+func RestoreSnapshot(s *Snapshot) error {
+	if s == nil {
+		return errors.New("snapshot is nil")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := replaceAll(tx, &EmbyServer{}, s.Servers); err != nil {
+			return err
+		}
+		if err := replaceAll(tx, &GlobalConfig{}, []GlobalConfig{s.GlobalConfig}); err != nil {
+			return err
+		}
+		if err := replaceAll(tx, &Notify{}, s.Notifies); err != nil {
+			return err
+		}
+		if err := replaceAll(tx, &DeviceProfile{}, s.DeviceProfiles); err != nil {
+			return err
+		}
+		return replaceAll(tx, &SkipMarker{}, s.SkipMarkers)
+	})
+}
+
+// replaceAll 删除 model 表中的所有数据后写入 rows
+func replaceAll[T any](tx *gorm.DB, model *T, rows []T) error {
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
@@ -1,25 +1,60 @@
 package db
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
 	"gopkg.in/yaml.v3"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// WebUI 用户角色
+const (
+	RoleAdmin    = "admin"    // 管理员, 拥有所有权限
+	RoleOperator = "operator" // 运维, 可以查看状态日志并重启服务
+	RoleViewer   = "viewer"   // 只读, 只能查看状态和日志
+)
+
+// roleLevels 角色的权限等级, 等级高的角色拥有等级低的角色的所有权限
+var roleLevels = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
 type User struct {
-	ID       uint   `gorm:"primaryKey"`
-	Username string `gorm:"uniqueIndex"`
-	Password string
+	ID           uint   `gorm:"primaryKey"`
+	Username     string `gorm:"uniqueIndex"`
+	Password     string
+	Role         string    `gorm:"default:admin"` // 用户角色
+	FailedLogins int       // 连续登录失败次数
+	LockedUntil  time.Time // 登录锁定截止时间
+	TOTPSecret   string    // 两步验证密钥, 启用前为待确认的密钥
+	TOTPEnabled  bool      // 是否已启用两步验证
+	TOTPLastStep int64     // 最后一次使用的验证码周期, 防止验证码重放
+	Provider     string    `gorm:"index"` // 用户来源, 为空表示本地用户, oidc 表示单点登录用户
+	Subject      string    `gorm:"index"` // 单点登录用户在身份提供方中的 sub
+	CreatedAt    time.Time
+}
+
+// UserProviderOIDC 通过 OIDC 单点登录自动创建的用户
+const UserProviderOIDC = "oidc"
+
+// HasRole 判断用户是否拥有 role 角色的权限
+func (u *User) HasRole(role string) bool {
+	return roleLevels[u.Role] >= roleLevels[role] && roleLevels[role] > 0
+}
+
+// ValidRole 判断角色名称是否有效
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
 }
 
 type Notify struct {
@@ -50,10 +85,34 @@ type EmbyServer struct {
 	DirectLinkCacheIgnore     string    `json:"DirectLinkCacheIgnore"`
 	DirectLinkCacheIgnoreMode int       `json:"DirectLinkCacheIgnoreMode"` // 0: blacklist, 1: whitelist
 	DisableProxy              bool      `json:"DisableProxy"`              // If true, only serve as config holder, don't start proxy
+	Overrides                 ServerOverrides `gorm:"serializer:json" json:"Overrides"` // 覆盖的全局配置
 	CreatedAt                 time.Time
 	UpdatedAt                 time.Time
 }
 
+// DeviceProfile 客户端设备配置, 按 Sort 升序匹配客户端
+type DeviceProfile struct {
+	ID        uint      `gorm:"primaryKey" json:"ID"`
+	Name      string    `gorm:"uniqueIndex" json:"Name"`
+	Match     string    `json:"Match"`   // 匹配 X-Emby-Client 或 User-Agent 的关键字, 逗号分隔, 为空时作为兜底配置
+	Payload   string    `json:"Payload"` // PlaybackInfo 请求体 JSON
+	Sort      int       `json:"Sort"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// SkipMarker 剧集或季的片头片尾时间, 单位: 秒
+type SkipMarker struct {
+	ID           uint      `gorm:"primaryKey" json:"ID"`
+	Series       string    `json:"Series"`       // 剧集名称或 emby 剧集 id
+	Season       int       `json:"Season"`       // 季号, 0 表示所有季
+	IntroStart   int       `json:"IntroStart"`   // 片头开始
+	IntroEnd     int       `json:"IntroEnd"`     // 片头结束, 0 表示不设置片头
+	CreditsStart int       `json:"CreditsStart"` // 片尾开始, 负数表示距离结尾的时间, 0 表示不设置片尾
+	CreatedAt    time.Time `json:"CreatedAt"`
+	UpdatedAt    time.Time `json:"UpdatedAt"`
+}
+
 type GlobalConfig struct {
 	ID                            uint `gorm:"primaryKey"`
 	EpisodesUnplayPrior           bool
@@ -66,12 +125,25 @@ type GlobalConfig struct {
 	VideoPreviewEnable            bool
 	VideoPreviewContainers        string
 	VideoPreviewIgnoreTemplateIds string
+	VideoPreviewAdaptive          bool
+	VideoPreviewPlaylistCapacity  int
+	VideoPreviewPlaylistPersist   bool
+	VideoPreviewSegmentProxy      bool
+	SubtitleConvert               bool
+	SubtitleCharset               string
+	SubtitleOffset                int
+	ProgressThresholds            string // 每行一个 item 类型的进度阈值, 格式: 类型=停止阈值,上报阈值, 单位: 秒
+	ProgressBackwardJump          int
+	ProgressHistorySize           int
+	WatchSyncEnable               bool // 是否在多个服务器之间同步观看状态
 	PathEmby2Openlist             string
 	LogDisableColor               bool
 	StrmPathMap                   string
 	CacheWhiteList                string
 	LTGEnable                     bool
 	LTGFFmpegEnable               bool
+	LTGFFmpegFallback             bool
+	LTGFFmpegWorkers              int
 	LTGVirtualContainers          string
 	LTGStrmContainers             string
 	LTGMusicContainers            string
@@ -80,6 +152,7 @@ type GlobalConfig struct {
 	LTGScanPrefixes               string
 	LTGIgnoreContainers           string
 	LTGThreads                    int
+	LTGSidecarEnable              bool
 	SslEnable                     bool
 	SslSinglePort                 bool
 	SslKey                        string
@@ -90,6 +163,9 @@ type GlobalConfig struct {
 	NotifyContentType             string
 	NotifyTitleKey                string
 	NotifyContentKey              string
+	BackupEnable                  bool // 是否定时自动备份配置
+	BackupInterval                int  // 自动备份间隔, 单位: 小时
+	BackupRetention               int  // 保留的自动备份数量
 }
 
 func Init(path string) error {
@@ -99,7 +175,10 @@ func Init(path string) error {
 		return err
 	}
 
-	if err := DB.AutoMigrate(&User{}, &EmbyServer{}, &GlobalConfig{}, &Notify{}); err != nil {
+	if err := DB.AutoMigrate(&User{}, &EmbyServer{}, &GlobalConfig{}, &Notify{}, &DeviceProfile{}, &SkipMarker{}, &Session{}, &AuditLog{}, &OIDCConfig{}, &APIToken{}); err != nil {
+		return err
+	}
+	if err := ensureDeviceProfiles(); err != nil {
 		return err
 	}
 	return ensureGlobalDefaults()
@@ -111,19 +190,98 @@ func CreateUser(username, password string) error {
 	if count > 0 {
 		return errors.New("admin user already exists")
 	}
-	return DB.Create(&User{Username: username, Password: hashMD5(password)}).Error
+	hash, err := encrypts.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return DB.Create(&User{Username: username, Password: hash, Role: RoleAdmin}).Error
 }
 
-func GetUser(username, password string) (*User, error) {
+// GetUsers 获取所有 WebUI 用户
+func GetUsers() ([]User, error) {
+	var list []User
+	if err := DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetUserById 根据 id 获取用户
+func GetUserById(id uint) (*User, error) {
 	var user User
-	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := DB.First(&user, id).Error; err != nil {
 		return nil, err
 	}
-	h := hashMD5(password)
-	if user.Password == h {
-		return &user, nil
+	return &user, nil
+}
+
+// AddUser 添加一个指定角色的 WebUI 用户
+func AddUser(username, password, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, errors.New("username and password are required")
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+	hash, err := encrypts.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := User{Username: username, Password: hash, Role: role}
+	if err := DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserRole 修改用户角色, 不允许移除最后一个管理员
+func UpdateUserRole(id uint, role string) error {
+	if !ValidRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+	user, err := GetUserById(id)
+	if err != nil {
+		return err
+	}
+	if user.Role == RoleAdmin && role != RoleAdmin {
+		if err := ensureOtherAdmin(id); err != nil {
+			return err
+		}
+	}
+	return DB.Model(user).Update("role", role).Error
+}
+
+// DeleteUser 删除用户及其所有会话和 API token, 不允许删除最后一个管理员
+func DeleteUser(id uint) error {
+	user, err := GetUserById(id)
+	if err != nil {
+		return err
+	}
+	if user.Role == RoleAdmin {
+		if err := ensureOtherAdmin(id); err != nil {
+			return err
+		}
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+		if err := deleteUserAPITokens(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&User{}, id).Error
+	})
+}
+
+// ensureOtherAdmin 确认除 id 外还有其他管理员
+func ensureOtherAdmin(id uint) error {
+	var count int64
+	DB.Model(&User{}).Where("role = ? AND id <> ?", RoleAdmin, id).Count(&count)
+	if count == 0 {
+		return errors.New("at least one admin user is required")
 	}
-	return nil, gorm.ErrRecordNotFound
+	return nil
 }
 
 func CheckInit() bool {
@@ -143,6 +301,15 @@ func GetServers() ([]EmbyServer, error) {
 	return servers, nil
 }
 
+// GetServer 根据 id 获取服务器配置
+func GetServer(id uint) (*EmbyServer, error) {
+	var s EmbyServer
+	if err := DB.First(&s, id).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
 func AddServer(s *EmbyServer) error {
 	return DB.Create(s).Error
 }
@@ -155,13 +322,36 @@ func UpdateServer(s *EmbyServer) error {
 	return DB.Save(s).Error
 }
 
+// ResetAdminPassword 离线重置第一个本地管理员的密码, 同时解除锁定、关闭两步验证并撤销所有会话
+func ResetAdminPassword(newPassword string) (string, error) {
+	var user User
+	if err := DB.Where("role = ? AND provider = ?", RoleAdmin, "").Order("id").First(&user).Error; err != nil {
+		return "", errors.New("admin user not found")
+	}
+	if err := DisableTOTP(user.ID); err != nil {
+		return "", err
+	}
+	return user.Username, UpdatePassword(user.Username, newPassword)
+}
+
+// UpdatePassword 修改用户密码, 并使该用户的所有会话失效
 func UpdatePassword(username, newPassword string) error {
 	var user User
 	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
 		return err
 	}
-	user.Password = hashMD5(newPassword)
-	return DB.Save(&user).Error
+	hash, err := encrypts.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	user.FailedLogins, user.LockedUntil = 0, time.Time{}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&Session{}).Error
+	})
 }
 
 func GetNotifies() ([]Notify, error) {
@@ -172,6 +362,15 @@ func GetNotifies() ([]Notify, error) {
 	return list, nil
 }
 
+// GetNotify 根据 id 获取通知配置
+func GetNotify(id uint) (*Notify, error) {
+	var n Notify
+	if err := DB.First(&n, id).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
 func AddNotify(n *Notify) error {
 	return DB.Create(n).Error
 }
@@ -184,9 +383,64 @@ func DeleteNotify(id uint) error {
 	return DB.Delete(&Notify{}, id).Error
 }
 
-func hashMD5(s string) string {
-	sum := md5.Sum([]byte(s))
-	return hex.EncodeToString(sum[:])
+func GetDeviceProfiles() ([]DeviceProfile, error) {
+	var list []DeviceProfile
+	if err := DB.Order("sort, id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func AddDeviceProfile(p *DeviceProfile) error {
+	return DB.Create(p).Error
+}
+
+func UpdateDeviceProfile(p *DeviceProfile) error {
+	return DB.Save(p).Error
+}
+
+func DeleteDeviceProfile(id uint) error {
+	return DB.Delete(&DeviceProfile{}, id).Error
+}
+
+func GetSkipMarkers() ([]SkipMarker, error) {
+	var list []SkipMarker
+	if err := DB.Order("series, season").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func AddSkipMarker(m *SkipMarker) error {
+	return DB.Create(m).Error
+}
+
+func UpdateSkipMarker(m *SkipMarker) error {
+	return DB.Save(m).Error
+}
+
+func DeleteSkipMarker(id uint) error {
+	return DB.Delete(&SkipMarker{}, id).Error
+}
+
+// ensureDeviceProfiles 没有任何设备配置时, 写入内置的设备配置
+func ensureDeviceProfiles() error {
+	var count int64
+	DB.Model(&DeviceProfile{}).Count(&count)
+	if count > 0 {
+		return nil
+	}
+	for i, p := range config.BuiltinDeviceProfiles() {
+		if err := DB.Create(&DeviceProfile{
+			Name:    p.Name,
+			Match:   strings.Join(p.Match, ","),
+			Payload: p.Payload,
+			Sort:    i * 10,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func ensureGlobalDefaults() error {
@@ -210,6 +464,11 @@ func ensureGlobalDefaults() error {
 			VideoPreviewEnable:            true,
 			VideoPreviewContainers:        "mp4,mkv",
 			VideoPreviewIgnoreTemplateIds: "LD,SD",
+			VideoPreviewPlaylistCapacity:  10,
+			SubtitleCharset:               "auto",
+			ProgressThresholds:            "default=300,1",
+			ProgressBackwardJump:          300,
+			ProgressHistorySize:           10,
 			PathEmby2Openlist:             "/movie:/电影\n/music:/音乐\n/show:/综艺\n/series:/电视剧\n/sport:/运动\n/animation:/动漫",
 			LogDisableColor:               true,
 			NotifyEnable:                  false,
@@ -218,6 +477,9 @@ func ensureGlobalDefaults() error {
 			NotifyContentType:             "application/json",
 			NotifyTitleKey:                "title",
 			NotifyContentKey:              "text",
+			BackupEnable:                  true,
+			BackupInterval:                24,
+			BackupRetention:               7,
 		}).Error
 	}
 	var m map[string]any
@@ -225,6 +487,8 @@ func ensureGlobalDefaults() error {
 	emby := getMap(m, "emby")
 	cache := getMap(m, "cache")
 	vp := getMap(m, "video-preview")
+	sub := getMap(m, "subtitle")
+	progress := getMap(m, "progress")
 	path := getMap(m, "path")
 	openlist := getMap(m, "openlist")
 	ltg := getMap(openlist, "local-tree-gen")
@@ -241,12 +505,25 @@ func ensureGlobalDefaults() error {
 		VideoPreviewEnable:            boolVal(vp, "enable", true),
 		VideoPreviewContainers:        strings.Join(sliceStr(vp, "containers"), ","),
 		VideoPreviewIgnoreTemplateIds: strings.Join(sliceStr(vp, "ignore-template-ids"), ","),
+		VideoPreviewAdaptive:          boolVal(vp, "adaptive", false),
+		VideoPreviewPlaylistCapacity:  intVal(vp, "playlist-capacity", 10),
+		VideoPreviewPlaylistPersist:   boolVal(vp, "playlist-persist", false),
+		VideoPreviewSegmentProxy:      boolVal(vp, "segment-proxy", false),
+		SubtitleConvert:               boolVal(sub, "convert", false),
+		SubtitleCharset:               strVal(sub, "charset", "auto"),
+		SubtitleOffset:                intVal(sub, "offset", 0),
+		ProgressThresholds:            progressThresholdsVal(getMap(progress, "thresholds")),
+		ProgressBackwardJump:          intVal(progress, "backward-jump", 0),
+		ProgressHistorySize:           intVal(progress, "history-size", 10),
+		WatchSyncEnable:               boolVal(emby, "watch-sync", false),
 		PathEmby2Openlist:             strings.Join(sliceStr(path, "emby2openlist"), "\n"),
 		LogDisableColor:               boolVal(getMap(m, "log"), "disable-color", true),
 		StrmPathMap:                   strings.Join(sliceStr(strm, "path-map"), "\n"),
 		CacheWhiteList:                strings.Join(sliceStr(cache, "whitelist"), "\n"),
 		LTGEnable:                     boolVal(ltg, "enable", false),
 		LTGFFmpegEnable:               boolVal(ltg, "ffmpeg-enable", false),
+		LTGFFmpegFallback:             boolVal(ltg, "ffmpeg-fallback", false),
+		LTGFFmpegWorkers:              intVal(ltg, "ffmpeg-workers", 1),
 		LTGVirtualContainers:          strVal(ltg, "virtual-containers", "mp4,mkv"),
 		LTGStrmContainers:             strVal(ltg, "strm-containers", "ts"),
 		LTGMusicContainers:            strVal(ltg, "music-containers", "mp3,flac"),
@@ -255,6 +532,7 @@ func ensureGlobalDefaults() error {
 		LTGScanPrefixes:               strings.Join(sliceStr(ltg, "scan-prefixes"), "\n"),
 		LTGIgnoreContainers:           strVal(ltg, "ignore-containers", "jpg,jpeg,png,txt,nfo,md"),
 		LTGThreads:                    intVal(ltg, "threads", 8),
+		LTGSidecarEnable:              boolVal(ltg, "sidecar-enable", false),
 		SslEnable:                     boolVal(ssl, "enable", false),
 		SslSinglePort:                 boolVal(ssl, "single-port", false),
 		SslKey:                        strVal(ssl, "key", ""),
@@ -265,6 +543,9 @@ func ensureGlobalDefaults() error {
 		NotifyContentType:             "application/json",
 		NotifyTitleKey:                "title",
 		NotifyContentKey:              "text",
+		BackupEnable:                  true,
+		BackupInterval:                24,
+		BackupRetention:               7,
 	}
 	return DB.Create(&g).Error
 }
@@ -288,6 +569,24 @@ func getMap(m map[string]any, k string) map[string]any {
 	return mv
 }
 
+// progressThresholdsVal 将配置文件中的进度阈值转换为每行一个的文本格式
+func progressThresholdsVal(m map[string]any) string {
+	if len(m) == 0 {
+		return "default=300,1"
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		t := getMap(m, name)
+		lines = append(lines, fmt.Sprintf("%s=%d,%d", name, intVal(t, "min-stopped", 0), intVal(t, "min-report", 0)))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func strVal(m map[string]any, k string, def string) string {
 	if v, ok := m[k]; ok {
 		if s, ok := v.(string); ok {
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OIDC 单点登录配置的默认声明名称
+const (
+	DefaultOIDCUsernameClaim = "preferred_username"
+	DefaultOIDCRoleClaim     = "groups"
+)
+
+// OIDCConfig WebUI 单点登录配置, 表中只有一条记录
+type OIDCConfig struct {
+	ID            uint      `gorm:"primaryKey" json:"ID"`
+	Enable        bool      `json:"Enable"`
+	Name          string    `json:"Name"`          // 登录页按钮显示的身份提供方名称
+	Issuer        string    `json:"Issuer"`        // 身份提供方地址
+	ClientID      string    `json:"ClientID"`      // 客户端 id
+	ClientSecret  string    `json:"ClientSecret"`  // 客户端密钥
+	RedirectURL   string    `json:"RedirectURL"`   // 回调地址, 为空时根据请求地址生成
+	Scopes        string    `json:"Scopes"`        // 空格分隔的 scope, 为空时使用 openid profile email
+	UsernameClaim string    `json:"UsernameClaim"` // 作为用户名的声明
+	RoleClaim     string    `json:"RoleClaim"`     // 用于映射角色的声明, 可以是字符串或字符串数组
+	RoleMapping   string    `json:"RoleMapping"`   // 每行一个映射, 格式: 声明值=角色
+	DefaultRole   string    `json:"DefaultRole"`   // 没有匹配的映射时使用的角色, 为空表示拒绝登录
+	UpdatedAt     time.Time `json:"UpdatedAt"`
+}
+
+// GetOIDCConfig 获取单点登录配置, 未配置时返回默认值
+func GetOIDCConfig() (OIDCConfig, error) {
+	var c OIDCConfig
+	res := DB.Limit(1).Find(&c)
+	if res.Error != nil {
+		return c, res.Error
+	}
+	if res.RowsAffected == 0 {
+		c = OIDCConfig{UsernameClaim: DefaultOIDCUsernameClaim, RoleClaim: DefaultOIDCRoleClaim}
+	}
+	return c, nil
+}
+
+// UpdateOIDCConfig 校验并保存单点登录配置
+func UpdateOIDCConfig(c *OIDCConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c.ID = 1
+	return DB.Save(c).Error
+}
+
+// Validate 校验单点登录配置
+func (c *OIDCConfig) Validate() error {
+	c.Issuer = strings.TrimSpace(c.Issuer)
+	c.ClientID = strings.TrimSpace(c.ClientID)
+	c.RedirectURL = strings.TrimSpace(c.RedirectURL)
+	if c.UsernameClaim = strings.TrimSpace(c.UsernameClaim); c.UsernameClaim == "" {
+		c.UsernameClaim = DefaultOIDCUsernameClaim
+	}
+	if c.RoleClaim = strings.TrimSpace(c.RoleClaim); c.RoleClaim == "" {
+		c.RoleClaim = DefaultOIDCRoleClaim
+	}
+	if c.Enable && (c.Issuer == "" || c.ClientID == "") {
+		return errors.New("issuer and client id are required")
+	}
+	if c.DefaultRole != "" && !ValidRole(c.DefaultRole) {
+		return fmt.Errorf("invalid default role: %s", c.DefaultRole)
+	}
+	_, err := parseRoleMapping(c.RoleMapping)
+	return err
+}
+
+// MapRole 根据角色声明的值匹配 WebUI 角色, 多个值匹配时使用权限最高的角色,
+// 都不匹配时使用 DefaultRole, ok 为 false 表示该用户不允许登录
+func (c *OIDCConfig) MapRole(values []string) (role string, ok bool) {
+	mapping, err := parseRoleMapping(c.RoleMapping)
+	if err != nil {
+		return "", false
+	}
+	for _, v := range values {
+		if r, found := mapping[strings.TrimSpace(v)]; found && roleLevels[r] > roleLevels[role] {
+			role = r
+		}
+	}
+	if role == "" {
+		role = c.DefaultRole
+	}
+	return role, role != ""
+}
+
+// parseRoleMapping 解析角色映射, 每行格式: 声明值=角色
+func parseRoleMapping(text string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.LastIndex(line, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid role mapping: %s", line)
+		}
+		value, role := strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:])
+		if !ValidRole(role) {
+			return nil, fmt.Errorf("invalid role in mapping: %s", line)
+		}
+		mapping[value] = role
+	}
+	return mapping, nil
+}
+
+// UpsertOIDCUser 根据身份提供方的 sub 查找或创建单点登录用户, 每次登录都会同步用户名和角色
+//
+// 用户名与其他用户冲突时拒绝登录, 避免单点登录用户接管同名的本地用户
+func UpsertOIDCUser(subject, username, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if subject == "" || username == "" {
+		return nil, errors.New("subject and username are required")
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	var user User
+	res := DB.Where("provider = ? AND subject = ?", UserProviderOIDC, subject).Limit(1).Find(&user)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var count int64
+	DB.Model(&User{}).Where("username = ? AND id <> ?", username, user.ID).Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("username %s is already used by another user", username)
+	}
+
+	if res.RowsAffected == 0 {
+		user = User{Username: username, Role: role, Provider: UserProviderOIDC, Subject: subject}
+		if err := DB.Create(&user).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	user.Username, user.Role = username, role
+	if err := DB.Model(&user).Select("Username", "Role").Updates(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"reflect"
+
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// ServerOverrides 服务器单独覆盖的全局配置, 字段名称与 GlobalConfig 一致, 为 nil 的字段继承全局配置
+//
+// 以 json 文档保存在 EmbyServer 中, 新增可覆盖的配置时只需要在这里添加同名的指针字段
+type ServerOverrides struct {
+	EpisodesUnplayPrior           *bool   `json:",omitempty"`
+	ProxyErrorStrategy            *string `json:",omitempty"`
+	DownloadStrategy              *string `json:",omitempty"`
+	CacheEnable                   *bool   `json:",omitempty"`
+	VideoPreviewEnable            *bool   `json:",omitempty"`
+	VideoPreviewContainers        *string `json:",omitempty"`
+	VideoPreviewIgnoreTemplateIds *string `json:",omitempty"`
+	VideoPreviewAdaptive          *bool   `json:",omitempty"`
+	VideoPreviewPlaylistCapacity  *int    `json:",omitempty"`
+	VideoPreviewPlaylistPersist   *bool   `json:",omitempty"`
+	VideoPreviewSegmentProxy      *bool   `json:",omitempty"`
+	PathEmby2Openlist             *string `json:",omitempty"`
+	LTGEnable                     *bool   `json:",omitempty"`
+	LTGFFmpegEnable               *bool   `json:",omitempty"`
+	LTGFFmpegFallback             *bool   `json:",omitempty"`
+	LTGFFmpegWorkers              *int    `json:",omitempty"`
+	LTGVirtualContainers          *string `json:",omitempty"`
+	LTGStrmContainers             *string `json:",omitempty"`
+	LTGMusicContainers            *string `json:",omitempty"`
+	LTGAutoRemoveMaxCount         *int    `json:",omitempty"`
+	LTGRefreshInterval            *int    `json:",omitempty"`
+	LTGScanPrefixes               *string `json:",omitempty"`
+	LTGIgnoreContainers           *string `json:",omitempty"`
+	LTGThreads                    *int    `json:",omitempty"`
+	LTGSidecarEnable              *bool   `json:",omitempty"`
+}
+
+// Apply 返回使用覆盖值替换后的全局配置, 不修改 g
+//
+// GlobalConfig 中不存在同名字段或类型不一致的覆盖值会被忽略
+func (o ServerOverrides) Apply(g GlobalConfig) GlobalConfig {
+	ov, gv := reflect.ValueOf(o), reflect.ValueOf(&g).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		f := ov.Field(i)
+		if f.IsNil() {
+			continue
+		}
+		name := ov.Type().Field(i).Name
+		target := gv.FieldByName(name)
+		if !target.IsValid() || target.Type() != f.Elem().Type() {
+			logs.Warn("忽略无效的服务器覆盖配置: %s", name)
+			continue
+		}
+		target.Set(f.Elem())
+	}
+	return g
+}
+
+// EffectiveConfig 获取服务器实际使用的全局配置
+func (s *EmbyServer) EffectiveConfig() (GlobalConfig, error) {
+	g, err := GetGlobalConfig()
+	if err != nil {
+		return g, err
+	}
+	return s.Overrides.Apply(g), nil
+}
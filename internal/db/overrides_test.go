@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/db"
+)
+
+func TestServerOverridesApply(t *testing.T) {
+	// 为每个覆盖字段设置非零值, 字段改名或类型不一致时覆盖值会被忽略
+	var o db.ServerOverrides
+	ov := reflect.ValueOf(&o).Elem()
+	want := make(map[string]any)
+	for i := 0; i < ov.NumField(); i++ {
+		name := ov.Type().Field(i).Name
+		v := reflect.New(ov.Field(i).Type().Elem())
+		switch v.Elem().Kind() {
+		case reflect.Bool:
+			v.Elem().SetBool(true)
+		case reflect.String:
+			v.Elem().SetString("override-" + name)
+		case reflect.Int:
+			v.Elem().SetInt(int64(i + 1))
+		default:
+			t.Fatalf("unsupported override type: %s %s", name, v.Elem().Kind())
+		}
+		ov.Field(i).Set(v)
+		want[name] = v.Elem().Interface()
+	}
+
+	g := db.GlobalConfig{CacheExpired: "1d", LTGThreads: 8}
+	got := reflect.ValueOf(o.Apply(g))
+	for name, w := range want {
+		f := got.FieldByName(name)
+		if !f.IsValid() {
+			t.Errorf("GlobalConfig has no field %s", name)
+			continue
+		}
+		if f.Interface() != w {
+			t.Errorf("%s = %v, want %v", name, f.Interface(), w)
+		}
+	}
+	if got.FieldByName("CacheExpired").String() != "1d" {
+		t.Error("fields without override should be kept")
+	}
+	if g.LTGThreads != 8 {
+		t.Error("Apply should not modify the global config")
+	}
+}
+
+func TestServerOverridesInherit(t *testing.T) {
+	threads := 2
+	g := db.GlobalConfig{CacheEnable: true, LTGThreads: 8, LTGEnable: true}
+	got := db.ServerOverrides{LTGThreads: &threads}.Apply(g)
+
+	want := g
+	want.LTGThreads = 2
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Apply() = %+v, want %+v", got, want)
+	}
+	if got := (db.ServerOverrides{}).Apply(g); fmt.Sprint(got) != fmt.Sprint(g) {
+		t.Errorf("empty overrides should inherit everything: %+v", got)
+	}
+}
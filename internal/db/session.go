@@ -0,0 +1,213 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SessionTTL 会话在没有任何请求时的有效期
+	SessionTTL = 7 * 24 * time.Hour
+
+	// sessionTouchInterval 会话续期的最小间隔, 避免每次请求都写库
+	sessionTouchInterval = time.Hour
+
+	// MaxFailedLogins 连续登录失败达到该次数后锁定账号
+	MaxFailedLogins = 5
+
+	// LockoutDuration 账号锁定时长
+	LockoutDuration = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrUserLocked 连续登录失败次数过多, 账号被临时锁定
+	ErrUserLocked = errors.New("too many failed attempts, account locked")
+
+	// ErrSessionInvalid 会话不存在、已过期或已被撤销
+	ErrSessionInvalid = errors.New("session invalid")
+
+	// ErrTOTPRequired 用户已启用两步验证, 需要提供验证码
+	ErrTOTPRequired = errors.New("two-factor code required")
+
+	// ErrTOTPInvalid 两步验证码错误或已被使用
+	ErrTOTPInvalid = errors.New("invalid two-factor code")
+)
+
+// Session WebUI 登录会话, 数据库中只保存 token 的哈希
+type Session struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenHash string    `gorm:"uniqueIndex"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Authenticate 校验用户名和密码, 用于修改密码等已登录状态下的身份确认, 不校验两步验证码
+//
+// 连续失败 MaxFailedLogins 次后账号锁定 LockoutDuration, 锁定期间即使密码正确也返回 ErrUserLocked
+func Authenticate(username, password string) (*User, error) {
+	return authenticate(username, password, false, "")
+}
+
+// Login 校验用户名、密码和两步验证码
+//
+// 用户启用了两步验证而 code 为空时返回 ErrTOTPRequired, 不计入失败次数;
+// 验证码错误或被重复使用时返回 ErrTOTPInvalid, 与密码错误一样计入失败次数
+func Login(username, password, code string) (*User, error) {
+	return authenticate(username, password, true, code)
+}
+
+// authenticate 校验本地用户的登录凭证, checkTOTP 为 true 时同时校验两步验证码
+func authenticate(username, password string, checkTOTP bool, code string) (*User, error) {
+	var user User
+	res := DB.Where("username = ?", username).Limit(1).Find(&user)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || user.Provider != "" {
+		// 单点登录用户没有本地密码
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	if user.LockedUntil.After(now) {
+		return nil, ErrUserLocked
+	}
+
+	ok, needRehash, err := encrypts.VerifyPassword(user.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, recordFailedLogin(&user, now, ErrInvalidCredentials)
+	}
+
+	if checkTOTP && user.TOTPEnabled {
+		if strings.TrimSpace(code) == "" {
+			return nil, ErrTOTPRequired
+		}
+		step, ok := encrypts.ValidateTOTP(user.TOTPSecret, code, now)
+		if !ok || step <= user.TOTPLastStep {
+			return nil, recordFailedLogin(&user, now, ErrTOTPInvalid)
+		}
+		user.TOTPLastStep = step
+	}
+
+	if needRehash {
+		// 旧版本的 md5 或参数过时的哈希, 登录成功后透明升级
+		hash, err := encrypts.HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = hash
+	}
+	user.FailedLogins, user.LockedUntil = 0, time.Time{}
+	if err := DB.Model(&user).Select("Password", "FailedLogins", "LockedUntil", "TOTPLastStep").Updates(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// recordFailedLogin 记录一次登录失败, 达到 MaxFailedLogins 次时锁定账号并返回 ErrUserLocked, 否则返回 cause
+//
+// 失败次数在数据库中原子累加, 并发的错误尝试不会互相覆盖计数
+func recordFailedLogin(user *User, now time.Time, cause error) error {
+	var counted User
+	res := DB.Model(&counted).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_logins"}}}).
+		Where("id = ?", user.ID).
+		UpdateColumn("failed_logins", gorm.Expr("failed_logins + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	user.FailedLogins = counted.FailedLogins
+	if user.FailedLogins < MaxFailedLogins {
+		return cause
+	}
+
+	// 并发请求同时达到上限时只锁定一次
+	user.FailedLogins, user.LockedUntil = 0, now.Add(LockoutDuration)
+	err := DB.Model(&User{}).
+		Where("id = ? AND failed_logins >= ?", user.ID, MaxFailedLogins).
+		UpdateColumns(map[string]any{"failed_logins": 0, "locked_until": user.LockedUntil}).Error
+	if err != nil {
+		return err
+	}
+	return ErrUserLocked
+}
+
+// CreateSession 为用户创建会话, 返回明文 token
+func CreateSession(userID uint) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	DB.Where("expires_at < ?", time.Now()).Delete(&Session{})
+	err := DB.Create(&Session{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(SessionTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetSessionUser 校验 token 并返回会话所属的用户, 有效的会话会自动续期
+func GetSessionUser(token string) (*User, error) {
+	if token == "" {
+		return nil, ErrSessionInvalid
+	}
+	var session Session
+	res := DB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&session)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, ErrSessionInvalid
+	}
+	now := time.Now()
+	if session.ExpiresAt.Before(now) {
+		DB.Delete(&session)
+		return nil, ErrSessionInvalid
+	}
+
+	var user User
+	if err := DB.First(&user, session.UserID).Error; err != nil {
+		return nil, ErrSessionInvalid
+	}
+
+	if now.Sub(session.UpdatedAt) > sessionTouchInterval {
+		DB.Model(&session).Update("expires_at", now.Add(SessionTTL))
+	}
+	return &user, nil
+}
+
+// DeleteSession 撤销一个会话
+func DeleteSession(token string) error {
+	return DB.Where("token_hash = ?", hashToken(token)).Delete(&Session{}).Error
+}
+
+// DeleteUserSessions 撤销用户的所有会话
+func DeleteUserSessions(userID uint) error {
+	return DB.Where("user_id = ?", userID).Delete(&Session{}).Error
+}
+
+// hashToken 计算 token 的哈希, 数据库泄露时无法直接使用其中的 token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package db_test
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/db"
+)
+
+// initDB 在临时目录中初始化数据库并添加一个用户
+func initDB(t *testing.T, role string) *db.User {
+	t.Helper()
+	if err := db.Init(filepath.Join(t.TempDir(), "Go-Emby.db")); err != nil {
+		t.Fatal(err)
+	}
+	user, err := db.AddUser("alice", "secret", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestSession(t *testing.T) {
+	user := initDB(t, db.RoleAdmin)
+
+	token, err := db.CreateSession(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.GetSessionUser(token); err != nil || got.ID != user.ID {
+		t.Fatalf("GetSessionUser() = %+v, %v", got, err)
+	}
+	if _, err := db.GetSessionUser(""); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("empty token: err = %v", err)
+	}
+	if _, err := db.GetSessionUser(token + "0"); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("unknown token: err = %v", err)
+	}
+
+	// 撤销后的会话不能再使用
+	if err := db.DeleteSession(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetSessionUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("revoked session: err = %v", err)
+	}
+
+	// 撤销用户的所有会话
+	a, _ := db.CreateSession(user.ID)
+	b, _ := db.CreateSession(user.ID)
+	if err := db.DeleteUserSessions(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{a, b} {
+		if _, err := db.GetSessionUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+			t.Errorf("revoked user session: err = %v", err)
+		}
+	}
+
+	// 过期的会话被拒绝并从数据库中删除
+	token, _ = db.CreateSession(user.ID)
+	db.DB.Model(&db.Session{}).Where("user_id = ?", user.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := db.GetSessionUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("expired session: err = %v", err)
+	}
+	var count int64
+	db.DB.Model(&db.Session{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expired sessions = %d, want 0", count)
+	}
+
+	// 删除用户后会话失效
+	token, _ = db.CreateSession(user.ID)
+	db.DB.Delete(&db.User{}, user.ID)
+	if _, err := db.GetSessionUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("deleted user: err = %v", err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	user := initDB(t, db.RoleAdmin)
+
+	for i := 1; i < db.MaxFailedLogins; i++ {
+		if _, err := db.Login("alice", "wrong", ""); !errors.Is(err, db.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+	if _, err := db.Login("alice", "wrong", ""); !errors.Is(err, db.ErrUserLocked) {
+		t.Fatalf("attempt %d: err = %v, want locked", db.MaxFailedLogins, err)
+	}
+
+	// 锁定期间密码正确也不能登录
+	if _, err := db.Login("alice", "secret", ""); !errors.Is(err, db.ErrUserLocked) {
+		t.Errorf("locked login: err = %v", err)
+	}
+	if _, err := db.Authenticate("alice", "secret"); !errors.Is(err, db.ErrUserLocked) {
+		t.Errorf("locked authenticate: err = %v", err)
+	}
+
+	// 锁定结束后可以登录, 登录成功清零失败次数
+	db.DB.Model(&db.User{}).Where("id = ?", user.ID).Update("locked_until", time.Now().Add(-time.Second))
+	if _, err := db.Login("alice", "wrong", ""); !errors.Is(err, db.ErrInvalidCredentials) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := db.Login("alice", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetUserById(user.ID)
+	if err != nil || got.FailedLogins != 0 || !got.LockedUntil.Before(time.Now()) {
+		t.Errorf("user = %+v, err = %v", got, err)
+	}
+
+	if _, err := db.Login("bob", "secret", ""); !errors.Is(err, db.ErrInvalidCredentials) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
+
+func TestLoginLockoutConcurrent(t *testing.T) {
+	user := initDB(t, db.RoleAdmin)
+
+	// 并发的错误尝试不会互相覆盖失败次数
+	var wg sync.WaitGroup
+	for i := 0; i < db.MaxFailedLogins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Login("alice", "wrong", "")
+		}()
+	}
+	wg.Wait()
+
+	got, err := db.GetUserById(user.ID)
+	if err != nil || !got.LockedUntil.After(time.Now()) {
+		t.Fatalf("user should be locked: %+v, err = %v", got, err)
+	}
+}
+
+func TestAPITokenUser(t *testing.T) {
+	user := initDB(t, db.RoleAdmin)
+
+	token, created, err := db.CreateAPIToken(user.ID, "ci", []string{db.ScopeServersRead}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, tk, err := db.GetAPITokenUser(token)
+	if err != nil || got.ID != user.ID || !tk.HasScope(db.ScopeServersRead) || tk.HasScope(db.ScopeServersWrite) {
+		t.Fatalf("GetAPITokenUser() = %+v, %+v, %v", got, tk, err)
+	}
+
+	// 过期的 token 被拒绝
+	db.DB.Model(&db.APIToken{}).Where("id = ?", created.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, _, err := db.GetAPITokenUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("expired token: err = %v", err)
+	}
+
+	// 撤销的 token 被拒绝
+	token, created, _ = db.CreateAPIToken(user.ID, "ci", []string{db.ScopeLogsRead}, time.Time{})
+	if err := db.DeleteAPIToken(user.ID, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.GetAPITokenUser(token); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("revoked token: err = %v", err)
+	}
+
+	// 会话 token 不能作为 API token 使用
+	session, _ := db.CreateSession(user.ID)
+	if _, _, err := db.GetAPITokenUser(session); !errors.Is(err, db.ErrSessionInvalid) {
+		t.Errorf("session token: err = %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
+)
+
+// SetupTOTP 为本地用户生成一个待确认的两步验证密钥, 调用 EnableTOTP 校验验证码后才会生效
+func SetupTOTP(userID uint) (string, error) {
+	user, err := GetUserById(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.Provider != "" {
+		return "", errors.New("two-factor authentication of SSO users is managed by the identity provider")
+	}
+	if user.TOTPEnabled {
+		return "", errors.New("two-factor authentication is already enabled")
+	}
+	secret, err := encrypts.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	return secret, DB.Model(user).Update("totp_secret", secret).Error
+}
+
+// EnableTOTP 使用验证码确认待启用的密钥, 确认后登录时需要提供验证码
+func EnableTOTP(userID uint, code string) error {
+	user, err := GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return errors.New("two-factor authentication has not been set up")
+	}
+	step, ok := encrypts.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrTOTPInvalid
+	}
+	return DB.Model(user).Select("TOTPEnabled", "TOTPLastStep").
+		Updates(&User{TOTPEnabled: true, TOTPLastStep: step}).Error
+}
+
+// DisableTOTP 关闭用户的两步验证并清除密钥
+func DisableTOTP(userID uint) error {
+	return DB.Model(&User{ID: userID}).Select("TOTPSecret", "TOTPEnabled", "TOTPLastStep").
+		Updates(&User{}).Error
+}
@@ -0,0 +1,547 @@
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/constant"
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+const (
+	// BackupFormat 备份文件 manifest 中的格式标识
+	BackupFormat = "go-emby-backup"
+
+	// BackupVersion 当前的备份格式版本, 只能恢复不高于该版本的备份
+	BackupVersion = 1
+
+	// BackupDirName 备份文件存放目录, 位于程序数据根目录下
+	BackupDirName = "backups"
+
+	// MaxBackupSize 导入的备份文件大小上限
+	MaxBackupSize = 64 << 20
+
+	// backupManifestName 备份文件中的 manifest 文件名
+	backupManifestName = "manifest.json"
+
+	// backupDataName 备份文件中的配置数据文件名
+	backupDataName = "data.json"
+
+	// backupCheckInterval 定时备份的检查间隔
+	backupCheckInterval = 10 * time.Minute
+)
+
+// 备份的类型, 记录在备份文件名中
+const (
+	BackupAuto       = "auto"        // 定时备份
+	BackupManual     = "manual"      // 手动创建
+	BackupPreRestore = "pre-restore" // 恢复前自动创建
+)
+
+// backupNameRegex 备份文件名格式: go-emby-backup-20060102-150405-auto.zip
+var backupNameRegex = regexp.MustCompile(`^go-emby-backup-(\d{8}-\d{6})-(auto|manual|pre-restore)\.zip$`)
+
+// backupMu 保证同一时间只有一个备份或恢复操作
+var backupMu sync.Mutex
+
+// BackupManifest 备份文件的描述信息
+type BackupManifest struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	AppVersion string            `json:"appVersion"` // 创建备份的程序版本
+	CreatedAt  time.Time         `json:"createdAt"`
+	Files      map[string]string `json:"files"` // 备份中的文件及其 sha256
+}
+
+// BackupInfo 备份目录中的备份文件
+type BackupInfo struct {
+	Name      string    `json:"Name"`
+	Kind      string    `json:"Kind"`
+	Size      int64     `json:"Size"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// Backup 解析并校验后的备份内容
+type Backup struct {
+	Manifest BackupManifest
+	Snapshot *db.Snapshot
+	Files    map[string][]byte // 自定义脚本、样式和证书文件, key 为备份中的路径
+}
+
+// WriteBackup 将当前的配置数据、自定义脚本样式和 ssl 证书打包写入 w
+func WriteBackup(w io.Writer) error {
+	snapshot, err := db.ExportSnapshot()
+	if err != nil {
+		return fmt.Errorf("导出配置数据失败: %v", err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化配置数据失败: %v", err)
+	}
+	files := map[string][]byte{backupDataName: data}
+	if err := collectBackupFiles(snapshot.Servers, files); err != nil {
+		return err
+	}
+
+	manifest := BackupManifest{
+		Format:     BackupFormat,
+		Version:    BackupVersion,
+		AppVersion: constant.CurrentVersion,
+		CreatedAt:  time.Now(),
+		Files:      make(map[string]string, len(files)),
+	}
+	names := make([]string, 0, len(files))
+	for name, b := range files {
+		manifest.Files[name] = sha256Hex(b)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mb, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 manifest 失败: %v", err)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, name := range append([]string{backupManifestName}, names...) {
+		b := mb
+		if name != backupManifestName {
+			b = files[name]
+		}
+		fw, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("写入备份文件失败: %v", err)
+		}
+		if _, err := fw.Write(b); err != nil {
+			return fmt.Errorf("写入备份文件失败: %v", err)
+		}
+	}
+	return zw.Close()
+}
+
+// collectBackupFiles 读取自定义脚本样式目录和各服务 ssl 目录下的文件
+func collectBackupFiles(servers []db.EmbyServer, files map[string][]byte) error {
+	dirs := []string{constant.CustomJsDirName, constant.CustomCssDirName}
+	for _, s := range servers {
+		dirs = append(dirs, path.Join("servers", s.Name, config.SslDir))
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(filepath.Join(DataRoot, filepath.FromSlash(dir)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("读取目录 %s 失败: %v", dir, err)
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(DataRoot, filepath.FromSlash(dir), e.Name()))
+			if err != nil {
+				return fmt.Errorf("读取文件 %s 失败: %v", e.Name(), err)
+			}
+			files[path.Join(dir, e.Name())] = b
+		}
+	}
+	return nil
+}
+
+// ReadBackup 解析备份文件, 校验格式版本、文件哈希和配置数据, 不会修改当前配置
+func ReadBackup(b []byte) (*Backup, error) {
+	if len(b) > MaxBackupSize {
+		return nil, fmt.Errorf("备份文件超过 %d MB", MaxBackupSize>>20)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份文件: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Name != backupManifestName && f.Name != backupDataName && !backupFileAllowed(f.Name) {
+			return nil, fmt.Errorf("备份中包含不允许的文件: %s", f.Name)
+		}
+		if _, ok := files[f.Name]; ok {
+			return nil, fmt.Errorf("备份中包含重复的文件: %s", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取备份文件 %s 失败: %v", f.Name, err)
+		}
+		// 限制解压后的大小, 防止压缩炸弹
+		data, err := io.ReadAll(io.LimitReader(rc, MaxBackupSize-total+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取备份文件 %s 失败: %v", f.Name, err)
+		}
+		if total += int64(len(data)); total > MaxBackupSize {
+			return nil, fmt.Errorf("备份解压后超过 %d MB", MaxBackupSize>>20)
+		}
+		files[f.Name] = data
+	}
+
+	mb, ok := files[backupManifestName]
+	if !ok {
+		return nil, errors.New("备份中缺少 manifest.json")
+	}
+	delete(files, backupManifestName)
+	var manifest BackupManifest
+	if err := json.Unmarshal(mb, &manifest); err != nil {
+		return nil, fmt.Errorf("解析 manifest 失败: %v", err)
+	}
+	if manifest.Format != BackupFormat {
+		return nil, fmt.Errorf("不支持的备份格式: %s", manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > BackupVersion {
+		return nil, fmt.Errorf("不支持的备份版本: %d, 当前程序支持的最高版本: %d", manifest.Version, BackupVersion)
+	}
+	if len(manifest.Files) != len(files) {
+		return nil, errors.New("备份中的文件与 manifest 不一致")
+	}
+	for name, data := range files {
+		if sum, ok := manifest.Files[name]; !ok || sum != sha256Hex(data) {
+			return nil, fmt.Errorf("备份文件 %s 校验失败", name)
+		}
+	}
+
+	data, ok := files[backupDataName]
+	if !ok {
+		return nil, errors.New("备份中缺少 data.json")
+	}
+	delete(files, backupDataName)
+	var snapshot db.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("解析配置数据失败: %v", err)
+	}
+	if err := validateSnapshot(&snapshot, files); err != nil {
+		return nil, err
+	}
+	return &Backup{Manifest: manifest, Snapshot: &snapshot, Files: files}, nil
+}
+
+// backupFileAllowed 判断备份中的文件路径是否允许恢复
+//
+// 只允许自定义脚本样式目录和 servers/<服务名>/ssl 目录下的文件, 不允许子目录和 ..
+func backupFileAllowed(name string) bool {
+	if name != path.Clean(name) || path.IsAbs(name) || strings.Contains(name, `\`) {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." {
+			return false
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return parts[0] == constant.CustomJsDirName || parts[0] == constant.CustomCssDirName
+	case 4:
+		return parts[0] == "servers" && parts[2] == config.SslDir
+	}
+	return false
+}
+
+// validateSnapshot 校验备份中的配置数据, 避免恢复后服务无法启动
+func validateSnapshot(s *db.Snapshot, files map[string][]byte) error {
+	names := make(map[string]bool)
+	ports := make(map[int]bool)
+	for _, srv := range s.Servers {
+		if !validServerName(srv.Name) {
+			return fmt.Errorf("服务名称不合法: %q", srv.Name)
+		}
+		if names[srv.Name] {
+			return fmt.Errorf("服务名称重复: %s", srv.Name)
+		}
+		if srv.HTTPPort <= 1 || srv.HTTPPort > 65535 {
+			return fmt.Errorf("服务 %s 的端口不合法: %d", srv.Name, srv.HTTPPort)
+		}
+		if ports[srv.HTTPPort] {
+			return fmt.Errorf("服务端口重复: %d", srv.HTTPPort)
+		}
+		names[srv.Name], ports[srv.HTTPPort] = true, true
+	}
+	for name := range files {
+		parts := strings.Split(name, "/")
+		if parts[0] == "servers" && !names[parts[1]] {
+			return fmt.Errorf("证书文件 %s 没有对应的服务", name)
+		}
+	}
+	for _, p := range s.DeviceProfiles {
+		dp := config.DeviceProfile{Name: p.Name, Payload: p.Payload}
+		if err := dp.Init(); err != nil {
+			return fmt.Errorf("设备配置 %s 校验失败: %v", p.Name, err)
+		}
+	}
+	for _, m := range s.SkipMarkers {
+		rule := config.SkipMarkerRule{
+			Series:       m.Series,
+			Season:       m.Season,
+			IntroStart:   m.IntroStart,
+			IntroEnd:     m.IntroEnd,
+			CreditsStart: m.CreditsStart,
+		}
+		if err := rule.Init(); err != nil {
+			return fmt.Errorf("片头片尾配置 %s 校验失败: %v", m.Series, err)
+		}
+	}
+	return nil
+}
+
+// validServerName 判断服务名称能否安全地作为配置目录名
+func validServerName(name string) bool {
+	return strings.TrimSpace(name) != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// RestoreBackup 使用备份替换当前配置并重启所有服务
+//
+// 恢复前会先创建一个 pre-restore 备份, 恢复失败时可以使用它回滚
+func RestoreBackup(b *Backup) error {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	if _, err := createBackup(BackupPreRestore); err != nil {
+		return fmt.Errorf("创建恢复前备份失败: %v", err)
+	}
+
+	StopAll()
+	defer func() {
+		if err := LoadAll(); err != nil {
+			logs.Error("恢复备份后启动服务失败: %v", err)
+		}
+	}()
+
+	if err := db.RestoreSnapshot(b.Snapshot); err != nil {
+		return fmt.Errorf("恢复配置数据失败: %v", err)
+	}
+	for _, dir := range []string{constant.CustomJsDirName, constant.CustomCssDirName} {
+		if err := clearDir(filepath.Join(DataRoot, dir)); err != nil {
+			return err
+		}
+	}
+	for name, data := range b.Files {
+		fp := filepath.Join(DataRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			return fmt.Errorf("创建目录失败: %v", err)
+		}
+		// 证书私钥只允许当前用户读取, 已存在的文件也需要修正权限
+		perm := os.FileMode(0644)
+		if strings.HasPrefix(name, "servers/") {
+			perm = 0600
+		}
+		if err := os.WriteFile(fp, data, perm); err != nil {
+			return fmt.Errorf("写入文件 %s 失败: %v", name, err)
+		}
+		if err := os.Chmod(fp, perm); err != nil {
+			return fmt.Errorf("修改文件 %s 权限失败: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// clearDir 删除目录下的所有文件, 保留目录本身
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(dir, 0755)
+	}
+	if err != nil {
+		return fmt.Errorf("读取目录 %s 失败: %v", dir, err)
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("删除文件 %s 失败: %v", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// StopAll 停止所有运行中的服务
+func StopAll() {
+	mu.Lock()
+	ids := make([]uint, 0, len(procs))
+	for id := range procs {
+		ids = append(ids, id)
+	}
+	mu.Unlock()
+	for _, id := range ids {
+		Stop(id)
+	}
+}
+
+// CreateBackup 在备份目录中创建一个备份
+func CreateBackup(kind string) (*BackupInfo, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	return createBackup(kind)
+}
+
+// createBackup 创建备份, 调用方需要持有 backupMu
+func createBackup(kind string) (*BackupInfo, error) {
+	dir := filepath.Join(DataRoot, BackupDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	now := time.Now()
+	name := fmt.Sprintf("go-emby-backup-%s-%s.zip", now.Format("20060102-150405"), kind)
+	if !backupNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("不支持的备份类型: %s", kind)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf); err != nil {
+		return nil, err
+	}
+	// 先写入临时文件再重命名, 避免留下不完整的备份
+	fp := filepath.Join(dir, name)
+	if err := os.WriteFile(fp+".tmp", buf.Bytes(), 0600); err != nil {
+		return nil, fmt.Errorf("写入备份失败: %v", err)
+	}
+	if err := os.Rename(fp+".tmp", fp); err != nil {
+		os.Remove(fp + ".tmp")
+		return nil, fmt.Errorf("写入备份失败: %v", err)
+	}
+	return &BackupInfo{Name: name, Kind: kind, Size: int64(buf.Len()), CreatedAt: now}, nil
+}
+
+// ListBackups 按创建时间倒序获取备份目录中的备份
+func ListBackups() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(DataRoot, BackupDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []BackupInfo{}
+	for _, e := range entries {
+		m := backupNameRegex.FindStringSubmatch(e.Name())
+		if m == nil || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		createdAt, _ := time.ParseInLocation("20060102-150405", m[1], time.Local)
+		list = append(list, BackupInfo{Name: e.Name(), Kind: m[2], Size: info.Size(), CreatedAt: createdAt})
+	}
+	slices.SortFunc(list, func(a, b BackupInfo) int { return strings.Compare(b.Name, a.Name) })
+	return list, nil
+}
+
+// BackupPath 获取备份文件的路径, 名称不合法时返回错误
+func BackupPath(name string) (string, error) {
+	if !backupNameRegex.MatchString(name) {
+		return "", fmt.Errorf("备份名称不合法: %s", name)
+	}
+	return filepath.Join(DataRoot, BackupDirName, name), nil
+}
+
+// DeleteBackup 删除备份目录中的备份
+func DeleteBackup(name string) error {
+	fp, err := BackupPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fp)
+}
+
+// pruneBackups 只保留最新的 retention 个定时备份, 手动和恢复前备份不会被自动删除
+func pruneBackups(retention int) {
+	list, err := ListBackups()
+	if err != nil {
+		logs.Warn("读取备份目录失败: %v", err)
+		return
+	}
+	kept := 0
+	for _, b := range list {
+		if b.Kind != BackupAuto {
+			continue
+		}
+		if kept++; kept <= retention {
+			continue
+		}
+		if err := DeleteBackup(b.Name); err != nil {
+			logs.Warn("删除过期备份 %s 失败: %v", b.Name, err)
+		}
+	}
+}
+
+// StartBackupScheduler 按全局配置的间隔定时创建备份
+func StartBackupScheduler() {
+	go func() {
+		t := time.NewTicker(backupCheckInterval)
+		defer t.Stop()
+		runScheduledBackup()
+		for range t.C {
+			runScheduledBackup()
+		}
+	}()
+}
+
+// runScheduledBackup 距离上一次定时备份超过配置的间隔时创建新的备份
+func runScheduledBackup() {
+	gc, err := db.GetGlobalConfig()
+	if err != nil || !gc.BackupEnable {
+		return
+	}
+	interval, retention := gc.BackupInterval, gc.BackupRetention
+	if interval <= 0 {
+		interval = 24
+	}
+	if retention <= 0 {
+		retention = 7
+	}
+
+	list, err := ListBackups()
+	if err != nil {
+		logs.Warn("读取备份目录失败: %v", err)
+		return
+	}
+	for _, b := range list {
+		if b.Kind == BackupAuto {
+			if time.Since(b.CreatedAt) < time.Duration(interval)*time.Hour {
+				return
+			}
+			break
+		}
+	}
+
+	info, err := CreateBackup(BackupAuto)
+	if err != nil {
+		logs.Error("定时备份失败: %v", err)
+		return
+	}
+	logs.Info("已创建定时备份: %s", info.Name)
+	pruneBackups(retention)
+}
+
+// sha256Hex 计算数据的 sha256
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/db"
+)
+
+// buildBackup 按 files 生成备份文件, manifest 中的哈希由 sums 覆盖, sums 中值为空的文件不写入 manifest
+func buildBackup(t *testing.T, files map[string][]byte, sums map[string]string) []byte {
+	t.Helper()
+	manifest := BackupManifest{Format: BackupFormat, Version: BackupVersion, Files: map[string]string{}}
+	for name, b := range files {
+		manifest.Files[name] = sha256Hex(b)
+	}
+	for name, sum := range sums {
+		if sum == "" {
+			delete(manifest.Files, name)
+			continue
+		}
+		manifest.Files[name] = sum
+	}
+	mb, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, b []byte) {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(b)
+	}
+	write(backupManifestName, mb)
+	for name, b := range files {
+		write(name, b)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// backupFiles 合法的备份内容
+func backupFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	data, err := json.Marshal(db.Snapshot{Servers: []db.EmbyServer{{Name: "emby", HTTPPort: 8095, DisableProxy: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		backupDataName:              data,
+		"custom-js/a.js":            []byte("console.log(1)"),
+		"servers/emby/ssl/cert.key": []byte("private key"),
+	}
+}
+
+func TestBackupFileAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"custom-js/a.js":            true,
+		"custom-css/a.css":          true,
+		"servers/emby/ssl/cert.pem": true,
+		"custom-js/../a.js":         false,
+		"../custom-js/a.js":         false,
+		"servers/../ssl/cert.pem":   false,
+		"servers/emby/ssl/../a.pem": false,
+		"/custom-js/a.js":           false,
+		"/etc/passwd":               false,
+		`custom-js\a.js`:            false,
+		"custom-js/sub/a.js":        false,
+		"custom-js//a.js":           false,
+		"./custom-js/a.js":          false,
+		"servers/emby/conf/a.yml":   false,
+		"Go-Emby.db":                false,
+		"lib/ffmpeg":                false,
+	}
+	for name, want := range tests {
+		if got := backupFileAllowed(name); got != want {
+			t.Errorf("backupFileAllowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReadBackup(t *testing.T) {
+	b, err := ReadBackup(buildBackup(t, backupFiles(t), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Snapshot.Servers) != 1 || len(b.Files) != 2 || string(b.Files["custom-js/a.js"]) != "console.log(1)" {
+		t.Errorf("backup: %+v", b)
+	}
+
+	tests := []struct {
+		name  string
+		files func(map[string][]byte)
+		sums  map[string]string
+		want  string
+	}{
+		{
+			name:  "parent dir",
+			files: func(m map[string][]byte) { m["custom-js/../Go-Emby.db"] = []byte("db") },
+			want:  "不允许的文件",
+		},
+		{
+			name:  "absolute path",
+			files: func(m map[string][]byte) { m["/etc/passwd"] = []byte("root") },
+			want:  "不允许的文件",
+		},
+		{
+			name:  "extra file",
+			files: func(m map[string][]byte) { m["custom-css/extra.css"] = []byte("body{}") },
+			sums:  map[string]string{"custom-css/extra.css": ""},
+			want:  "与 manifest 不一致",
+		},
+		{
+			name: "hash mismatch",
+			sums: map[string]string{"custom-js/a.js": sha256Hex([]byte("tampered"))},
+			want: "校验失败",
+		},
+		{
+			name:  "ssl without server",
+			files: func(m map[string][]byte) { m["servers/other/ssl/cert.key"] = []byte("key") },
+			want:  "没有对应的服务",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := backupFiles(t)
+			if tt.files != nil {
+				tt.files(files)
+			}
+			_, err := ReadBackup(buildBackup(t, files, tt.sums))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	DataRoot = t.TempDir()
+	if err := db.Init(filepath.Join(DataRoot, "Go-Emby.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复前已存在的证书也需要修正权限
+	key := filepath.Join(DataRoot, "servers", "emby", "ssl", "cert.key")
+	os.MkdirAll(filepath.Dir(key), 0755)
+	if err := os.WriteFile(key, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ReadBackup(buildBackup(t, backupFiles(t), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBackup(b); err != nil {
+		t.Fatal(err)
+	}
+
+	perms := map[string]os.FileMode{
+		key: 0600,
+		filepath.Join(DataRoot, "custom-js", "a.js"): 0644,
+	}
+	for fp, want := range perms {
+		info, err := os.Stat(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s perm = %o, want %o", fp, got, want)
+		}
+	}
+	if data, _ := os.ReadFile(key); string(data) != "private key" {
+		t.Errorf("cert.key = %q", data)
+	}
+	servers, err := db.GetServers()
+	if err != nil || len(servers) != 1 || servers[0].Name != "emby" {
+		t.Errorf("servers = %+v, err = %v", servers, err)
+	}
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/events"
+)
+
+// checkTimeout 校验配置或测试路径的最长时间
+const checkTimeout = 90 * time.Second
+
+// CheckServer 在不保存、不重启服务的情况下校验服务器配置
+//
+// 使用与启动服务相同的方式生成配置文件, 以校验模式启动内核执行配置初始化,
+// 并检查 emby 和 openlist 的连通性以及媒体库路径的转换结果
+func CheckServer(s db.EmbyServer) (*events.ConfigCheck, error) {
+	res := new(events.ConfigCheck)
+	if !checkServerRow(res, s) {
+		return res, nil
+	}
+
+	var kernel events.ConfigCheck
+	if err := runKernelOnce(s, events.TypeConfigCheck, &kernel, "-check"); err != nil {
+		return nil, err
+	}
+	res.Items = append(res.Items, kernel.Items...)
+	return res, nil
+}
+
+// TracePath 使用服务器当前保存的配置测试 emby item id 或资源路径的转换过程
+func TracePath(s db.EmbyServer, input string) (*events.PathTrace, error) {
+	var res events.PathTrace
+	if err := runKernelOnce(s, events.TypePathTrace, &res, "-trace-path="+input); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// runKernelOnce 使用服务器配置以一次性模式启动内核, 将内核上报的 typ 类型事件解析到 out 中
+//
+// 配置文件使用与启动服务相同的方式生成, 放在服务目录下, 与正式启动时的 ssl 证书目录保持一致
+func runKernelOnce(s db.EmbyServer, typ string, out any, args ...string) error {
+	if !validServerName(s.Name) {
+		return fmt.Errorf("服务名称不合法: %q", s.Name)
+	}
+	data, err := buildConfig(s)
+	if err != nil {
+		return fmt.Errorf("生成配置失败: %v", err)
+	}
+	dir := filepath.Join(DataRoot, "servers", s.Name)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		defer os.RemoveAll(dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	f, err := os.CreateTemp(dir, "config-check-*.yml")
+	if err != nil {
+		return fmt.Errorf("写入配置失败: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("写入配置失败: %v", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, append([]string{"-dr", DataRoot, "-kernel-only", "-config", f.Name()}, args...)...)
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, runErr := cmd.Output()
+
+	sc := bufio.NewScanner(bytes.NewReader(stdout))
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	for sc.Scan() {
+		t, payload, ok := events.Parse(sc.Text())
+		if !ok || t != typ {
+			continue
+		}
+		if err := json.Unmarshal(payload, out); err != nil {
+			return fmt.Errorf("解析内核结果失败: %v", err)
+		}
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("内核执行超时 (%v)", checkTimeout)
+	}
+	return fmt.Errorf("内核执行失败: %v %s", runErr, strings.TrimSpace(stderr.String()))
+}
+
+// checkServerRow 校验服务器名称和端口, 名称和端口与其他服务器冲突时服务无法启动
+func checkServerRow(res *events.ConfigCheck, s db.EmbyServer) bool {
+	ok := true
+	if !validServerName(s.Name) {
+		res.Add("server", events.CheckError, "服务名称不合法: %q", s.Name)
+		ok = false
+	}
+	if s.HTTPPort <= 1 || s.HTTPPort > 65535 {
+		res.Add("server", events.CheckError, "端口不合法: %d", s.HTTPPort)
+		ok = false
+	}
+	servers, err := db.GetServers()
+	if err != nil {
+		res.Add("server", events.CheckWarn, "读取服务器列表失败: %v", err)
+		return ok
+	}
+	gc, _ := db.GetGlobalConfig()
+	sslEnable := gc.SslEnable
+	for _, other := range servers {
+		if other.ID == s.ID {
+			continue
+		}
+		if other.Name == s.Name {
+			res.Add("server", events.CheckError, "服务名称与 %s 重复", other.Name)
+			ok = false
+		}
+		if other.HTTPPort == s.HTTPPort {
+			res.Add("server", events.CheckError, "端口 %d 已被服务 %s 使用", s.HTTPPort, other.Name)
+			ok = false
+		}
+		// 启用 https 时, https 端口为 http 端口减一
+		if sslEnable && (other.HTTPPort-1 == s.HTTPPort || other.HTTPPort == s.HTTPPort-1) {
+			res.Add("server", events.CheckError, "端口 %d 与服务 %s 的 https 端口冲突", s.HTTPPort, other.Name)
+			ok = false
+		}
+	}
+	if ok {
+		res.Add("server", events.CheckOK, "服务名称和端口可用")
+	}
+	return ok
+}
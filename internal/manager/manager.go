@@ -8,18 +8,26 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/events"
 	"github.com/syscc/Emby-Go/internal/util/logs"
 	"gopkg.in/yaml.v3"
 )
 
 type serverProc struct {
-	cmd    *exec.Cmd
-	cancel context.CancelFunc
+	cmd      *exec.Cmd
+	cancel   context.CancelFunc
+	stdin    io.WriteCloser            // 向内核发送命令
+	server   db.EmbyServer             // 启动或最后一次重新加载时的服务器配置
+	reloadMu sync.Mutex                // 同一时间只处理一个重新加载请求
+	reloaded chan events.ConfigReload  // 内核上报的重新加载结果
+	statsMu  sync.Mutex                // 同一时间只处理一个统计信息请求
+	stats    chan events.PlaylistStats // 内核上报的 playlist 缓存统计信息
 }
 
 var (
@@ -29,9 +37,27 @@ var (
 )
 
 func writeConfig(_ string, s db.EmbyServer) (string, error) {
+	data, err := buildConfig(s)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(DataRoot, "servers", s.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// buildConfig 根据服务器和全局配置生成内核使用的 yaml 配置
+func buildConfig(s db.EmbyServer) ([]byte, error) {
 	var root map[string]any
 	root = map[string]any{}
-	gc, _ := db.GetGlobalConfig()
+	gc, _ := s.EffectiveConfig() // 全局配置和服务器覆盖的配置
 
 	emby := getMap(root, "emby")
 	openlist := getMap(root, "openlist")
@@ -39,6 +65,9 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	path := getMap(root, "path")
 	cache := getMap(root, "cache")
 	vp := getMap(root, "video-preview")
+	subtitle := getMap(root, "subtitle")
+	skipMarker := getMap(root, "skip-marker")
+	progress := getMap(root, "progress")
 	log := getMap(root, "log")
 	ssl := getMap(root, "ssl")
 	ltg := getMap(openlist, "local-tree-gen")
@@ -54,6 +83,7 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	emby["proxy-error-strategy"] = gc.ProxyErrorStrategy
 	emby["images-quality"] = gc.ImagesQuality
 	emby["download-strategy"] = gc.DownloadStrategy
+	emby["watch-sync"] = gc.WatchSyncEnable
 	if s.MountPath != "" {
 		paths := splitMounts(s.MountPath)
 		if len(paths) > 0 {
@@ -74,6 +104,17 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	} else {
 		emby["dl-cache-ignore-mode"] = "blacklist"
 	}
+	if profiles, err := db.GetDeviceProfiles(); err == nil && len(profiles) > 0 {
+		list := make([]any, 0, len(profiles))
+		for _, p := range profiles {
+			list = append(list, map[string]any{
+				"name":    p.Name,
+				"match":   splitMounts(p.Match),
+				"payload": p.Payload,
+			})
+		}
+		emby["device-profiles"] = list
+	}
 
 	// Strm Config
 	strm["internal-redirect-enable"] = s.InternalRedirectEnable
@@ -93,6 +134,8 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	// Local Tree Gen Config (Missing in old manager)
 	ltg["enable"] = gc.LTGEnable
 	ltg["ffmpeg-enable"] = gc.LTGFFmpegEnable
+	ltg["ffmpeg-fallback"] = gc.LTGFFmpegFallback
+	ltg["ffmpeg-workers"] = gc.LTGFFmpegWorkers
 	ltg["virtual-containers"] = gc.LTGVirtualContainers
 	ltg["strm-containers"] = gc.LTGStrmContainers
 	ltg["music-containers"] = gc.LTGMusicContainers
@@ -103,6 +146,7 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	}
 	ltg["ignore-containers"] = gc.LTGIgnoreContainers
 	ltg["threads"] = gc.LTGThreads
+	ltg["sidecar-enable"] = gc.LTGSidecarEnable
 
 	// Cache Config
 	cache["enable"] = gc.CacheEnable
@@ -119,6 +163,37 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	if gc.VideoPreviewIgnoreTemplateIds != "" {
 		vp["ignore-template-ids"] = strings.Split(gc.VideoPreviewIgnoreTemplateIds, ",")
 	}
+	vp["adaptive"] = gc.VideoPreviewAdaptive
+	vp["playlist-capacity"] = gc.VideoPreviewPlaylistCapacity
+	vp["playlist-persist"] = gc.VideoPreviewPlaylistPersist
+	vp["segment-proxy"] = gc.VideoPreviewSegmentProxy
+
+	// Subtitle Config
+	subtitle["convert"] = gc.SubtitleConvert
+	subtitle["charset"] = gc.SubtitleCharset
+	subtitle["offset"] = gc.SubtitleOffset
+
+	// Skip Marker Config
+	if markers, err := db.GetSkipMarkers(); err == nil && len(markers) > 0 {
+		rules := make([]any, 0, len(markers))
+		for _, m := range markers {
+			rules = append(rules, map[string]any{
+				"series":        m.Series,
+				"season":        m.Season,
+				"intro-start":   m.IntroStart,
+				"intro-end":     m.IntroEnd,
+				"credits-start": m.CreditsStart,
+			})
+		}
+		skipMarker["rules"] = rules
+	}
+
+	// Progress Config
+	if thresholds := parseProgressThresholds(gc.ProgressThresholds); len(thresholds) > 0 {
+		progress["thresholds"] = thresholds
+	}
+	progress["backward-jump"] = gc.ProgressBackwardJump
+	progress["history-size"] = gc.ProgressHistorySize
 
 	// Path Config
 	if gc.PathEmby2Openlist != "" {
@@ -140,20 +215,7 @@ func writeConfig(_ string, s db.EmbyServer) (string, error) {
 	ssl["key"] = gc.SslKey
 	ssl["crt"] = gc.SslCrt
 
-	data, err := yaml.Marshal(root)
-	if err != nil {
-		return "", err
-	}
-
-	dir := filepath.Join(DataRoot, "servers", s.Name)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
-	}
-	p := filepath.Join(dir, "config.yml")
-	if err := os.WriteFile(p, data, 0o644); err != nil {
-		return "", err
-	}
-	return p, nil
+	return yaml.Marshal(root)
 }
 
 func getMap(m map[string]any, k string) map[string]any {
@@ -184,6 +246,28 @@ func splitMounts(s string) []string {
 	return out
 }
 
+// parseProgressThresholds 解析每行一个的进度阈值, 格式: 类型=停止阈值,上报阈值, 格式错误的行会被忽略
+func parseProgressThresholds(s string) map[string]any {
+	out := map[string]any{}
+	for _, line := range strings.Split(s, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		stopped, report, _ := strings.Cut(value, ",")
+		minStopped, err1 := strconv.Atoi(strings.TrimSpace(stopped))
+		minReport, err2 := strconv.Atoi(strings.TrimSpace(report))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out[strings.TrimSpace(name)] = map[string]any{
+			"min-stopped": minStopped,
+			"min-report":  minReport,
+		}
+	}
+	return out
+}
+
 func ensurePathMap(m map[string]any, k string, entry string) {
 	v, ok := m[k]
 	if !ok || v == nil {
@@ -255,6 +339,7 @@ func Start(s db.EmbyServer) {
 
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
+	stdin, _ := cmd.StdinPipe()
 
 	if err := cmd.Start(); err != nil {
 		logs.Error("启动内核失败: %v", err)
@@ -265,7 +350,7 @@ func Start(s db.EmbyServer) {
 	go captureLogs(s, stdout)
 	go captureLogs(s, stderr)
 
-	procs[s.ID] = &serverProc{cmd: cmd, cancel: cancel}
+	procs[s.ID] = &serverProc{cmd: cmd, cancel: cancel, stdin: stdin, server: s, reloaded: make(chan events.ConfigReload, 1), stats: make(chan events.PlaylistStats, 1)}
 	logs.Info("已启动 %s, 端口: %d, 配置: %s", s.Name, s.HTTPPort, cfgPath)
 }
 
@@ -276,6 +361,11 @@ func captureLogs(s db.EmbyServer, r io.ReadCloser) {
 		line, err := br.ReadString('\n')
 		if len(line) > 0 {
 			line = strings.TrimSpace(line)
+			// Kernel events
+			if typ, payload, ok := events.Parse(line); ok {
+				go handleEvent(s, typ, payload)
+				continue
+			}
 			// Skip gin default request log
 			if strings.Contains(line, "[ge2o:v") {
 				continue
@@ -306,6 +396,18 @@ func captureLogs(s db.EmbyServer, r io.ReadCloser) {
 	}
 }
 
+// handleEvent 处理内核上报的事件
+func handleEvent(s db.EmbyServer, typ string, payload []byte) {
+	switch typ {
+	case events.TypePlaybackStopped:
+		handlePlaybackStopped(s, payload)
+	case events.TypeConfigReloaded:
+		handleConfigReloaded(s, payload)
+	case events.TypePlaylistStats:
+		handlePlaylistStats(s, payload)
+	}
+}
+
 func Stop(id uint) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -325,6 +427,17 @@ func Stop(id uint) {
 	}
 }
 
+// SendCommand 通过标准输入向服务的内核发送命令
+func SendCommand(id uint, typ string, payload any) error {
+	mu.Lock()
+	defer mu.Unlock()
+	sp, ok := procs[id]
+	if !ok || sp.stdin == nil {
+		return fmt.Errorf("服务未运行: %d", id)
+	}
+	return events.Write(sp.stdin, typ, payload)
+}
+
 func Restart(id uint) error {
 	Stop(id)
 	list, err := db.GetServers()
@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/db"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildConfigOverrides(t *testing.T) {
+	if err := db.Init(filepath.Join(t.TempDir(), "Go-Emby.db")); err != nil {
+		t.Fatal(err)
+	}
+	g, err := db.GetGlobalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.CacheEnable, g.LTGThreads, g.ProxyErrorStrategy = true, 8, "origin"
+	if err := db.UpdateGlobalConfig(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	threads, strategy := 2, "reject"
+	servers := map[string]db.EmbyServer{
+		"inherit":  {Name: "inherit", HTTPPort: 8095},
+		"override": {Name: "override", HTTPPort: 8097, Overrides: db.ServerOverrides{LTGThreads: &threads, ProxyErrorStrategy: &strategy}},
+	}
+	want := map[string]struct {
+		threads  int
+		strategy string
+	}{
+		"inherit":  {8, "origin"},
+		"override": {2, "reject"},
+	}
+	for name, s := range servers {
+		data, err := buildConfig(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c struct {
+			Emby struct {
+				ProxyErrorStrategy string `yaml:"proxy-error-strategy"`
+			}
+			Openlist struct {
+				LocalTreeGen struct {
+					Threads int
+				} `yaml:"local-tree-gen"`
+			}
+			Cache struct {
+				Enable bool
+			}
+		}
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			t.Fatal(err)
+		}
+		w := want[name]
+		if c.Openlist.LocalTreeGen.Threads != w.threads || c.Emby.ProxyErrorStrategy != w.strategy {
+			t.Errorf("%s: threads = %d, strategy = %s, want %d, %s", name, c.Openlist.LocalTreeGen.Threads, c.Emby.ProxyErrorStrategy, w.threads, w.strategy)
+		}
+		// 未覆盖的配置继承全局配置
+		if !c.Cache.Enable {
+			t.Errorf("%s: cache.enable should be inherited", name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/events"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// reloadTimeout 等待内核重新加载配置的最长时间
+const reloadTimeout = 30 * time.Second
+
+// ErrConfigRejected 内核校验新配置失败, 服务和配置文件都继续使用原配置
+var ErrConfigRejected = errors.New("新配置校验失败, 服务继续使用原配置")
+
+// Reload 重新生成服务配置并通知内核热加载, 不中断正在处理的连接
+//
+// 服务未运行、修改了名称或端口、内核报告修改了需要重启才能生效的配置项时重启服务;
+// 新配置校验失败时内核继续使用原配置, 配置文件也还原为原配置, 返回包装了 ErrConfigRejected 的校验错误,
+// 调用方需要还原数据库中的配置, 避免下次启动时重新生成校验失败的配置
+func Reload(id uint) error {
+	s, err := db.GetServer(id)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	sp, ok := procs[id]
+	mu.Unlock()
+	if !ok || s.DisableProxy {
+		return Restart(id)
+	}
+
+	sp.reloadMu.Lock()
+	defer sp.reloadMu.Unlock()
+	if sp.server.Name != s.Name || sp.server.HTTPPort != s.HTTPPort {
+		logs.Info("[%s] 修改了服务名称或端口, 重启服务", s.Name)
+		return Restart(id)
+	}
+
+	// 丢弃上一次超时后才上报的结果
+	select {
+	case <-sp.reloaded:
+	default:
+	}
+	cfgPath := filepath.Join(DataRoot, "servers", s.Name, "config.yml")
+	oldData, err := os.ReadFile(cfgPath)
+	if err != nil {
+		logs.Warn("[%s] 读取原配置失败, 重启服务: %v", s.Name, err)
+		return Restart(id)
+	}
+	if _, err := writeConfig(DataRoot, *s); err != nil {
+		return fmt.Errorf("写入配置失败: %v", err)
+	}
+	if err := SendCommand(id, events.TypeReloadConfig, struct{}{}); err != nil {
+		logs.Warn("[%s] 发送重新加载配置命令失败, 重启服务: %v", s.Name, err)
+		return Restart(id)
+	}
+
+	select {
+	case res := <-sp.reloaded:
+		if res.Error != "" {
+			if err := os.WriteFile(cfgPath, oldData, 0o644); err != nil {
+				logs.Warn("[%s] 还原配置文件失败: %v", s.Name, err)
+			}
+			return fmt.Errorf("%w: %s", ErrConfigRejected, res.Error)
+		}
+		if len(res.Restart) > 0 {
+			logs.Info("[%s] 修改了 %s, 重启服务", s.Name, strings.Join(res.Restart, ", "))
+			return Restart(id)
+		}
+		sp.server = *s
+		logs.Success("[%s] 配置已重新加载", s.Name)
+		return nil
+	case <-time.After(reloadTimeout):
+		logs.Warn("[%s] 等待内核重新加载配置超时, 重启服务", s.Name)
+		return Restart(id)
+	}
+}
+
+// handleConfigReloaded 将内核上报的重新加载结果交给等待中的 Reload
+func handleConfigReloaded(s db.EmbyServer, payload []byte) {
+	var res events.ConfigReload
+	if err := json.Unmarshal(payload, &res); err != nil {
+		logs.Warn("[%s] 解析配置加载结果失败: %v", s.Name, err)
+		return
+	}
+	mu.Lock()
+	sp, ok := procs[s.ID]
+	mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case sp.reloaded <- res:
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/events"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// statsTimeout 等待内核上报统计信息的最长时间
+const statsTimeout = 5 * time.Second
+
+// PlaylistStats 获取服务内核中 playlist 缓存的统计信息
+func PlaylistStats(id uint) (events.PlaylistStats, error) {
+	mu.Lock()
+	sp, ok := procs[id]
+	mu.Unlock()
+	if !ok {
+		return events.PlaylistStats{}, fmt.Errorf("服务未运行: %d", id)
+	}
+
+	sp.statsMu.Lock()
+	defer sp.statsMu.Unlock()
+	// 丢弃上一次超时后才上报的结果
+	select {
+	case <-sp.stats:
+	default:
+	}
+	if err := SendCommand(id, events.TypePlaylistStats, struct{}{}); err != nil {
+		return events.PlaylistStats{}, err
+	}
+
+	select {
+	case res := <-sp.stats:
+		return res, nil
+	case <-time.After(statsTimeout):
+		return events.PlaylistStats{}, fmt.Errorf("等待内核上报统计信息超时: %d", id)
+	}
+}
+
+// handlePlaylistStats 将内核上报的统计信息交给等待中的 PlaylistStats
+func handlePlaylistStats(s db.EmbyServer, payload []byte) {
+	var res events.PlaylistStats
+	if err := json.Unmarshal(payload, &res); err != nil {
+		logs.Warn("[%s] 解析 playlist 统计信息失败: %v", s.Name, err)
+		return
+	}
+	mu.Lock()
+	sp, ok := procs[s.ID]
+	mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case sp.stats <- res:
+	default:
+	}
+}
@@ -0,0 +1,217 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/events"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// watchSyncPlayedPercent 播放进度达到时长的该百分比时视为已播放
+const watchSyncPlayedPercent = 90
+
+// watchSyncProviders 用于在不同服务器之间匹配 item 的外部 id
+var watchSyncProviders = []string{"Tmdb", "Imdb", "Tvdb"}
+
+// syncItem emby item 中同步观看状态需要的字段
+type syncItem struct {
+	Id           string
+	Name         string
+	Type         string
+	RunTimeTicks int64
+	ProviderIds  map[string]string
+}
+
+// syncUser emby 用户
+type syncUser struct {
+	Id   string
+	Name string
+}
+
+// handlePlaybackStopped 处理内核上报的停止播放事件
+func handlePlaybackStopped(s db.EmbyServer, payload []byte) {
+	var e events.PlaybackStopped
+	if err := json.Unmarshal(payload, &e); err != nil {
+		logs.Warn("[%s] 解析停止播放事件失败: %v", s.Name, err)
+		return
+	}
+	syncWatchState(s, e)
+}
+
+// syncWatchState 将一次停止播放的观看状态同步到其他服务器
+//
+// 通过 Tmdb/Imdb/Tvdb 外部 id 匹配 item, 通过用户名匹配用户,
+// 播放进度超过 watchSyncPlayedPercent 时标记为已播放, 否则同步续播位置
+func syncWatchState(s db.EmbyServer, e events.PlaybackStopped) {
+	gc, err := db.GetGlobalConfig()
+	if err != nil || !gc.WatchSyncEnable {
+		return
+	}
+	// 内核重新加载配置后不会重新启动, 使用数据库中最新的服务器配置
+	if cur, err := db.GetServer(s.ID); err == nil {
+		s = *cur
+	}
+	if s.EmbyToken == "" {
+		logs.Warn("观看状态同步: %s 没有配置 emby api key, 无法查询 item 信息", s.Name)
+		return
+	}
+
+	source, err := findSyncUser(s, e.UserName)
+	if err != nil {
+		logs.Warn("观看状态同步: %v", err)
+		return
+	}
+	var res struct{ Items []syncItem }
+	q := url.Values{"Ids": {e.ItemId}, "Fields": {"ProviderIds"}}
+	if err := embyRequest(s, http.MethodGet, "/Users/"+source.Id+"/Items?"+q.Encode(), nil, &res); err != nil {
+		logs.Warn("观看状态同步: 查询 %s 的 item 信息失败: %v", s.Name, err)
+		return
+	}
+	if len(res.Items) == 0 || len(syncProviderIds(res.Items[0].ProviderIds)) == 0 {
+		return
+	}
+	item := res.Items[0]
+	played := item.RunTimeTicks > 0 && e.PositionTicks*100 >= item.RunTimeTicks*watchSyncPlayedPercent
+
+	servers, err := db.GetServers()
+	if err != nil {
+		logs.Warn("观看状态同步: 读取服务器列表失败: %v", err)
+		return
+	}
+	for _, target := range servers {
+		if target.ID == s.ID || target.EmbyHost == "" || target.EmbyToken == "" {
+			continue
+		}
+		if err := applyWatchState(target, e.UserName, item, played, e.PositionTicks); err != nil {
+			logs.Warn("观看状态同步: %s -> %s 同步 [%s] 失败: %v", s.Name, target.Name, item.Name, err)
+			continue
+		}
+		logs.Success("观看状态同步: %s -> %s 同步 [%s] 成功", s.Name, target.Name, item.Name)
+	}
+}
+
+// applyWatchState 在目标服务器上标记已播放或设置续播位置
+func applyWatchState(s db.EmbyServer, userName string, item syncItem, played bool, positionTicks int64) error {
+	user, err := findSyncUser(s, userName)
+	if err != nil {
+		return err
+	}
+
+	ids := syncProviderIds(item.ProviderIds)
+	conds := make([]string, 0, len(ids))
+	for name, id := range ids {
+		conds = append(conds, strings.ToLower(name)+"."+id)
+	}
+	q := url.Values{
+		"Recursive":           {"true"},
+		"IncludeItemTypes":    {item.Type},
+		"AnyProviderIdEquals": {strings.Join(conds, ",")},
+		"Fields":              {"ProviderIds"},
+		"Limit":               {"50"},
+	}
+	var res struct{ Items []syncItem }
+	if err := embyRequest(s, http.MethodGet, "/Users/"+user.Id+"/Items?"+q.Encode(), nil, &res); err != nil {
+		return err
+	}
+
+	matched := 0
+	for _, target := range res.Items {
+		// 部分版本的 emby 会忽略 AnyProviderIdEquals 参数, 需要再次校验外部 id
+		if !matchProviderIds(ids, target.ProviderIds) {
+			continue
+		}
+		matched++
+		if played {
+			err = embyRequest(s, http.MethodPost, "/Users/"+user.Id+"/PlayedItems/"+target.Id, nil, nil)
+		} else {
+			body := map[string]any{"PlaybackPositionTicks": positionTicks, "Played": false}
+			err = embyRequest(s, http.MethodPost, "/Users/"+user.Id+"/Items/"+target.Id+"/UserData", body, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if matched == 0 {
+		return errors.New("没有找到外部 id 相同的 item")
+	}
+	return nil
+}
+
+// findSyncUser 根据用户名查找服务器上的用户, 不区分大小写
+func findSyncUser(s db.EmbyServer, name string) (syncUser, error) {
+	var users []syncUser
+	if err := embyRequest(s, http.MethodGet, "/Users", nil, &users); err != nil {
+		return syncUser{}, fmt.Errorf("查询 %s 的用户列表失败: %v", s.Name, err)
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Name, name) {
+			return u, nil
+		}
+	}
+	return syncUser{}, fmt.Errorf("%s 中不存在用户: %s", s.Name, name)
+}
+
+// syncProviderIds 提取用于匹配的外部 id, 统一 key 的大小写
+func syncProviderIds(ids map[string]string) map[string]string {
+	res := make(map[string]string)
+	for key, value := range ids {
+		for _, name := range watchSyncProviders {
+			if strings.EqualFold(key, name) && strings.TrimSpace(value) != "" {
+				res[name] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return res
+}
+
+// matchProviderIds 判断两个 item 是否有相同的 Tmdb/Imdb/Tvdb 外部 id
+func matchProviderIds(want, ids map[string]string) bool {
+	got := syncProviderIds(ids)
+	for name, id := range syncProviderIds(want) {
+		if strings.EqualFold(got[name], id) {
+			return true
+		}
+	}
+	return false
+}
+
+// embyRequest 使用服务器的 api key 请求 emby 接口, out 不为 nil 时解析 json 响应
+func embyRequest(s db.EmbyServer, method, uri string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(s.EmbyHost, "/")+"/emby"+uri, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Emby-Token", s.EmbyToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("源服务器返回错误状态: %v", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
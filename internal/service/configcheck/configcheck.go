@@ -0,0 +1,277 @@
+// Package configcheck 在内核校验模式下检查配置能否正常工作
+//
+// 配置文件的格式校验由 config.ReadFromFile 完成, 这里负责检查 emby 和 openlist 的连通性,
+// 并从 emby 媒体库中抽样几个资源, 检查 mount-path 和路径映射的结果在 openlist 中是否存在;
+// 也用于在路径测试模式下输出单个资源的路径转换过程
+package configcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+	"github.com/syscc/Emby-Go/internal/service/path"
+	"github.com/syscc/Emby-Go/internal/util/events"
+)
+
+const (
+	// SampleSize 抽样检查路径映射的资源数量
+	SampleSize = 5
+
+	// requestTimeout 单个检查请求的超时时间
+	requestTimeout = 10 * time.Second
+)
+
+// 校验项名称
+const (
+	NameConfig   = "config"
+	NameEmby     = "emby"
+	NameLibrary  = "library"
+	NameOpenlist = "openlist"
+	NamePath     = "path"
+)
+
+// libraryFolder emby 媒体库
+type libraryFolder struct {
+	Name      string   `json:"Name"`
+	Locations []string `json:"Locations"`
+}
+
+// sampleItems emby 媒体库中抽样的资源
+type sampleItems struct {
+	Items []struct {
+		Name string `json:"Name"`
+		Path string `json:"Path"`
+	} `json:"Items"`
+}
+
+// Run 使用已经加载的全局配置执行检查, 调用前需要先执行 config.ReadFromFile
+func Run() *events.ConfigCheck {
+	res := new(events.ConfigCheck)
+	res.Add(NameConfig, events.CheckOK, "配置文件校验通过")
+
+	embyOK := checkEmby(res)
+	openlistOK := checkOpenlist(res)
+	if !embyOK {
+		return res
+	}
+	if strings.TrimSpace(config.C().Emby.Token) == "" {
+		res.Add(NameLibrary, events.CheckWarn, "未配置 Emby API Key, 跳过媒体库路径检查")
+		return res
+	}
+	checkLibrary(res)
+	checkSamples(res, openlistOK)
+	return res
+}
+
+// checkEmby 检查 emby 是否可以访问, api key 是否有效
+func checkEmby(res *events.ConfigCheck) bool {
+	var info struct {
+		ServerName string `json:"ServerName"`
+		Version    string `json:"Version"`
+	}
+	if err := embyGet("/System/Info/Public", false, &info); err != nil {
+		res.Add(NameEmby, events.CheckError, "无法访问 Emby %s: %v", config.C().Emby.Host, err)
+		return false
+	}
+	if strings.TrimSpace(config.C().Emby.Token) == "" {
+		res.Add(NameEmby, events.CheckOK, "Emby 可以访问: %s %s", info.ServerName, info.Version)
+		return true
+	}
+	if err := embyGet("/System/Info", true, nil); err != nil {
+		res.Add(NameEmby, events.CheckError, "Emby %s %s 可以访问, 但 API Key 校验失败: %v", info.ServerName, info.Version, err)
+		return false
+	}
+	res.Add(NameEmby, events.CheckOK, "Emby 可以访问, API Key 有效: %s %s", info.ServerName, info.Version)
+	return true
+}
+
+// checkOpenlist 检查 openlist 是否可以访问, token 是否有效
+func checkOpenlist(res *events.ConfigCheck) bool {
+	if config.C().Openlist.Host == "" || config.C().Openlist.Token == "" {
+		res.Add(NameOpenlist, events.CheckWarn, "未配置 OpenList 地址或 Token, 无法重定向到网盘直链")
+		return false
+	}
+	fr, err := withTimeout(func() openlistResult { return openlistGet("/") })
+	if err != nil {
+		res.Add(NameOpenlist, events.CheckError, "无法访问 OpenList %s: %v", config.C().Openlist.Host, err)
+		return false
+	}
+	if !fr.ok {
+		res.Add(NameOpenlist, events.CheckError, "OpenList %s 请求失败, 请检查地址和 Token: %s", config.C().Openlist.Host, fr.msg)
+		return false
+	}
+	res.Add(NameOpenlist, events.CheckOK, "OpenList 可以访问, Token 有效")
+	return true
+}
+
+// checkLibrary 检查每个 mount-path 是否是某个 emby 媒体库路径的前缀
+func checkLibrary(res *events.ConfigCheck) {
+	mounts := mountPaths()
+	if len(mounts) == 0 {
+		res.Add(NameLibrary, events.CheckWarn, "未配置 mount-path, 所有资源都不会重定向到 OpenList")
+		return
+	}
+	var folders []libraryFolder
+	if err := embyGet("/Library/VirtualFolders", true, &folders); err != nil {
+		res.Add(NameLibrary, events.CheckWarn, "获取 Emby 媒体库失败: %v", err)
+		return
+	}
+	for _, mount := range mounts {
+		var libs []string
+		for _, f := range folders {
+			for _, loc := range f.Locations {
+				if strings.HasPrefix(loc, mount) {
+					libs = append(libs, f.Name)
+					break
+				}
+			}
+		}
+		if len(libs) == 0 {
+			res.Add(NameLibrary, events.CheckWarn, "mount-path %s 不是任何媒体库路径的前缀", mount)
+			continue
+		}
+		res.Add(NameLibrary, events.CheckOK, "mount-path %s 匹配媒体库: %s", mount, strings.Join(libs, ", "))
+	}
+}
+
+// checkSamples 从 emby 中抽样最近添加的资源, 检查路径转换后在 openlist 中是否存在
+func checkSamples(res *events.ConfigCheck, openlistOK bool) {
+	q := url.Values{
+		"Recursive":        {"true"},
+		"IncludeItemTypes": {"Movie,Episode"},
+		"Fields":           {"Path"},
+		"SortBy":           {"DateCreated"},
+		"SortOrder":        {"Descending"},
+		"Limit":            {fmt.Sprint(SampleSize)},
+	}
+	var items sampleItems
+	if err := embyGet("/Items?"+q.Encode(), true, &items); err != nil {
+		res.Add(NamePath, events.CheckWarn, "获取 Emby 资源失败: %v", err)
+		return
+	}
+	if len(items.Items) == 0 {
+		res.Add(NamePath, events.CheckWarn, "Emby 媒体库中没有可以抽样的电影或剧集")
+		return
+	}
+
+	mounts := mountPaths()
+	for _, item := range items.Items {
+		if item.Path == "" {
+			continue
+		}
+		if !hasMountPrefix(item.Path, mounts) {
+			res.Add(NamePath, events.CheckWarn, "%s: %s 不在任何 mount-path 下, 不会重定向到 OpenList", item.Name, item.Path)
+			continue
+		}
+		olPath := path.Emby2Openlist(item.Path)
+		if !openlistOK {
+			res.Add(NamePath, events.CheckOK, "%s: %s => %s", item.Name, item.Path, olPath.Path)
+			continue
+		}
+		if found, ok := openlistFind(olPath); ok {
+			res.Add(NamePath, events.CheckOK, "%s: %s => %s", item.Name, item.Path, found)
+			continue
+		}
+		res.Add(NamePath, events.CheckWarn, "%s: %s => %s, OpenList 中不存在该文件, 请检查 mount-path 和路径映射", item.Name, item.Path, olPath.Path)
+	}
+}
+
+// openlistFind 查找转换后的路径在 openlist 中是否存在, 与重定向时一致, 找不到时遍历所有根目录
+func openlistFind(p path.OpenlistPathRes) (string, bool) {
+	found, err := withTimeout(func() string {
+		if openlistGet(p.Path).ok {
+			return p.Path
+		}
+		paths, err := p.Range()
+		if err != nil {
+			return ""
+		}
+		for _, sp := range paths {
+			if openlistGet(sp).ok {
+				return sp
+			}
+		}
+		return ""
+	})
+	return found, err == nil && found != ""
+}
+
+// openlistResult openlist fs get 请求结果
+type openlistResult struct {
+	ok  bool
+	msg string
+}
+
+// openlistGet 请求 openlist fs get 接口
+func openlistGet(p string) openlistResult {
+	r := openlist.FetchFsGet(p, nil)
+	return openlistResult{ok: r.Code == http.StatusOK, msg: r.Msg}
+}
+
+// mountPaths 获取配置的所有 mount-path, 与路径转换时的分割规则一致
+func mountPaths() []string {
+	var list []string
+	for _, m := range strings.FieldsFunc(config.C().Emby.MountPath, func(r rune) bool { return r == ',' || r == ';' }) {
+		if m = strings.TrimSpace(m); m != "" {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// hasMountPrefix 判断 emby 路径是否在某个 mount-path 下
+func hasMountPrefix(p string, mounts []string) bool {
+	p = strings.ReplaceAll(p, `\`, "/")
+	for _, m := range mounts {
+		if strings.HasPrefix(p, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// embyGet 请求 emby 接口, auth 为 true 时携带 api key, out 不为 nil 时解析 json 响应
+func embyGet(uri string, auth bool, out any) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(config.C().Emby.Host, "/")+"/emby"+uri, nil)
+	if err != nil {
+		return err
+	}
+	if auth {
+		req.Header.Set("X-Emby-Token", config.C().Emby.Token)
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("响应状态异常: %v", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// withTimeout 执行 fn 并返回其结果, 超过 requestTimeout 时返回错误
+//
+// openlist 请求使用的全局 http 客户端超时时间较长, 超时后 fn 会继续在后台执行,
+// 结果只通过 channel 传回, 调用方不会与后台的 fn 同时读写同一个变量
+func withTimeout[T any](fn func() T) (T, error) {
+	done := make(chan T, 1)
+	go func() { done <- fn() }()
+	select {
+	case v := <-done:
+		return v, nil
+	case <-time.After(requestTimeout):
+		var zero T
+		return zero, fmt.Errorf("请求超时 (%v)", requestTimeout)
+	}
+}
@@ -0,0 +1,177 @@
+package configcheck_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/configcheck"
+	"github.com/syscc/Emby-Go/internal/util/events"
+)
+
+// newFakeServer 同时模拟 emby 和 openlist 接口
+func newFakeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			var body struct{ Path string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			code := 200
+			if r.Header.Get("Authorization") != "ol-token" {
+				code = 401
+			} else if body.Path != "/" && body.Path != "/movies/a.mkv" && body.Path != "/backup/d.mkv" {
+				code = 500
+			}
+			content := []any{
+				map[string]any{"name": "movies", "is_dir": true},
+				map[string]any{"name": "backup", "is_dir": true},
+			}
+			json.NewEncoder(w).Encode(map[string]any{"code": code, "message": "", "data": map[string]any{"name": body.Path, "content": content}})
+			return
+		}
+
+		if r.URL.Path == "/emby/System/Info/Public" {
+			json.NewEncoder(w).Encode(map[string]any{"ServerName": "fake", "Version": "4.8"})
+			return
+		}
+		if r.Header.Get("X-Emby-Token") != "emby-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/emby/System/Info":
+			w.Write([]byte("{}"))
+		case "/emby/Library/VirtualFolders":
+			json.NewEncoder(w).Encode([]any{map[string]any{"Name": "Movies", "Locations": []string{"/mnt/cloud/movies"}}})
+		case "/emby/Items":
+			json.NewEncoder(w).Encode(map[string]any{"Items": []any{
+				map[string]any{"Name": "A", "Path": "/mnt/cloud/movies/a.mkv"},
+				map[string]any{"Name": "B", "Path": "/mnt/cloud/movies/b.mkv"},
+				map[string]any{"Name": "C", "Path": "/local/c.mkv"},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// loadConfig 初始化校验使用的全局配置
+func loadConfig(t *testing.T, host, embyToken, openlistToken string) {
+	p := &config.Path{Emby2Openlist: []string{"/mnt/cloud:/"}}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	config.Set(&config.Config{
+		Emby:     &config.Emby{Host: host, Token: embyToken, MountPath: "/mnt/cloud"},
+		Openlist: &config.Openlist{Host: host, Token: openlistToken},
+		Path:     p,
+	})
+}
+
+// levels 按校验项名称汇总结果级别
+func levels(res *events.ConfigCheck) map[string][]string {
+	m := make(map[string][]string)
+	for _, it := range res.Items {
+		m[it.Name] = append(m[it.Name], it.Level)
+	}
+	return m
+}
+
+func TestRun(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.Close()
+
+	loadConfig(t, srv.URL, "emby-token", "ol-token")
+	res := configcheck.Run()
+	if res.HasError() {
+		t.Fatalf("Run() 存在错误: %+v", res.Items)
+	}
+	got := levels(res)
+	if l := got[configcheck.NameLibrary]; len(l) != 1 || l[0] != events.CheckOK {
+		t.Errorf("library = %v", l)
+	}
+	want := []string{events.CheckOK, events.CheckWarn, events.CheckWarn}
+	if l := got[configcheck.NamePath]; strings.Join(l, ",") != strings.Join(want, ",") {
+		t.Errorf("path = %v, want %v", l, want)
+	}
+}
+
+func TestRunInvalidTokens(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.Close()
+
+	loadConfig(t, srv.URL, "wrong", "wrong")
+	got := levels(configcheck.Run())
+	if l := got[configcheck.NameEmby]; len(l) != 1 || l[0] != events.CheckError {
+		t.Errorf("emby = %v", l)
+	}
+	if l := got[configcheck.NameOpenlist]; len(l) != 1 || l[0] != events.CheckError {
+		t.Errorf("openlist = %v", l)
+	}
+	if _, ok := got[configcheck.NamePath]; ok {
+		t.Error("emby 不可用时不应抽样检查路径")
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	srv := newFakeServer()
+	srv.Close()
+
+	loadConfig(t, srv.URL, "", "")
+	got := levels(configcheck.Run())
+	if l := got[configcheck.NameEmby]; len(l) != 1 || l[0] != events.CheckError {
+		t.Errorf("emby = %v", l)
+	}
+	if l := got[configcheck.NameOpenlist]; len(l) != 1 || l[0] != events.CheckWarn {
+		t.Errorf("openlist = %v", l)
+	}
+}
+
+func TestTracePath(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.Close()
+	loadConfig(t, srv.URL, "emby-token", "ol-token")
+
+	res := configcheck.TracePath("/mnt/cloud/movies/d.mkv")
+	if res.Error != "" {
+		t.Fatalf("TracePath() error = %s", res.Error)
+	}
+	if n := len(res.Steps); n != 4 || res.Steps[n-1].Path != "/movies/d.mkv" {
+		t.Errorf("Steps = %+v", res.Steps)
+	}
+	var got []string
+	for _, c := range res.Candidates {
+		got = append(got, fmt.Sprintf("%s %s %v %v", c.Source, c.Path, c.Exists, c.Used))
+	}
+	want := []string{
+		"mapped /movies/d.mkv false false",
+		"range /movies/d.mkv false false",
+		"range /backup/d.mkv true true",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Candidates = %q, want %q", got, want)
+	}
+}
+
+func TestTracePathItemId(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.Close()
+	loadConfig(t, srv.URL, "emby-token", "ol-token")
+
+	res := configcheck.TracePath("12345")
+	if res.ItemName != "A" || res.EmbyPath != "/mnt/cloud/movies/a.mkv" {
+		t.Errorf("ItemName = %q, EmbyPath = %q", res.ItemName, res.EmbyPath)
+	}
+	if len(res.Candidates) == 0 || !res.Candidates[0].Used {
+		t.Errorf("Candidates = %+v", res.Candidates)
+	}
+
+	loadConfig(t, srv.URL, "", "ol-token")
+	if res := configcheck.TracePath("12345"); res.Error == "" {
+		t.Error("未配置 api key 时通过 item id 测试应返回错误")
+	}
+}
@@ -0,0 +1,114 @@
+package configcheck
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/model"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+	"github.com/syscc/Emby-Go/internal/service/path"
+	"github.com/syscc/Emby-Go/internal/util/events"
+)
+
+// maxRangeCandidates 遍历 openlist 根目录时最多检查的路径数量
+const maxRangeCandidates = 50
+
+// itemIdRegex emby item id 的格式, 不包含路径分隔符
+var itemIdRegex = regexp.MustCompile(`^[0-9a-fA-F-]+$`)
+
+// TracePath 按重定向时的顺序测试 emby item 或路径转换后的 openlist 路径
+//
+// input 为 emby item id 时先通过 api key 查询资源路径, 否则作为 emby 中的资源路径处理
+func TracePath(input string) *events.PathTrace {
+	input = strings.TrimSpace(input)
+	res := &events.PathTrace{Input: input, EmbyPath: input}
+	if input == "" {
+		res.Error = "请输入 Emby item id 或资源路径"
+		return res
+	}
+
+	if itemIdRegex.MatchString(input) {
+		name, p, err := itemPath(input)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.ItemName, res.EmbyPath = name, p
+	}
+
+	olPath := path.Emby2Openlist(res.EmbyPath)
+	for _, s := range olPath.Steps {
+		res.Steps = append(res.Steps, events.PathStep{Name: s.Name, Path: s.Path})
+	}
+	if config.C().Openlist.Host == "" || config.C().Openlist.Token == "" {
+		res.Error = "未配置 OpenList 地址或 Token, 无法检查转换后的路径"
+		return res
+	}
+
+	used := false
+	addCandidate := func(p, source string) {
+		c := events.PathCandidate{Path: p, Source: source}
+		r, err := withTimeout(func() model.HttpRes[openlist.FsGet] { return openlist.FetchFsGet(p, nil) })
+		if err != nil {
+			c.Message = err.Error()
+		} else if r.Code == http.StatusOK {
+			c.Exists, c.Used = true, !used
+			c.Message = fmt.Sprintf("%s, %d bytes", r.Data.Name, r.Data.Size)
+			used = true
+		} else {
+			c.Message = r.Msg
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+	addCandidate(olPath.Path, "mapped")
+
+	rr, err := withTimeout(func() rangeResult {
+		paths, err := olPath.Range()
+		return rangeResult{paths: paths, err: err}
+	})
+	if err == nil {
+		err = rr.err
+	}
+	if err != nil {
+		res.Error = fmt.Sprintf("遍历 OpenList 根目录失败: %v", err)
+		return res
+	}
+	for i, p := range rr.paths {
+		if i >= maxRangeCandidates {
+			res.Error = fmt.Sprintf("OpenList 根目录过多, 只检查了前 %d 个候选路径", maxRangeCandidates)
+			break
+		}
+		addCandidate(p, "range")
+	}
+	return res
+}
+
+// rangeResult 遍历 openlist 根目录的结果
+type rangeResult struct {
+	paths []string
+	err   error
+}
+
+// itemPath 通过 emby api 查询 item 的名称和资源路径
+func itemPath(id string) (name, p string, err error) {
+	if strings.TrimSpace(config.C().Emby.Token) == "" {
+		return "", "", fmt.Errorf("未配置 Emby API Key, 无法通过 item id 查询资源路径, 请直接输入资源路径")
+	}
+	q := url.Values{"Ids": {id}, "Fields": {"Path"}}
+	var items sampleItems
+	if err := embyGet("/Items?"+q.Encode(), true, &items); err != nil {
+		return "", "", fmt.Errorf("查询 Emby item %s 失败: %v", id, err)
+	}
+	if len(items.Items) == 0 {
+		return "", "", fmt.Errorf("Emby 中不存在 item: %s", id)
+	}
+	item := items.Items[0]
+	if item.Path == "" {
+		return "", "", fmt.Errorf("Emby item %s (%s) 没有资源路径", id, item.Name)
+	}
+	return item.Name, item.Path, nil
+}
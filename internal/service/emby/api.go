@@ -35,7 +35,7 @@ func Fetch(uri, method string, header http.Header, body map[string]any) (model.H
 
 // RawFetch 请求 emby api 接口, 使用流式请求体
 func RawFetch(uri, method string, header http.Header, body io.ReadCloser) (model.HttpRes[*jsons.Item], http.Header) {
-	u := config.C.Emby.Host + uri
+	u := config.C().Emby.Host + uri
 
 	// 构造请求头, 发出请求
 	if header == nil {
@@ -0,0 +1,103 @@
+package emby
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/syscc/Emby-Go/internal/util/jsons"
+)
+
+// transcodeVideoStream 转码资源的虚拟视频流
+//
+// 使用客户端不支持直接播放的编码, 确保客户端选择转码播放
+const transcodeVideoStream = `{"AspectRatio":"16:9","AttachmentSize":0,"AverageFrameRate":25,"BitDepth":8,"BitRate":4838626,"Codec":"prores","CodecTag":"hev1","DisplayTitle":"4K HEVC","ExtendedVideoSubType":"None","ExtendedVideoSubTypeDescription":"None","ExtendedVideoType":"None","Height":2160,"Index":0,"IsDefault":true,"IsExternal":false,"IsForced":false,"IsHearingImpaired":false,"IsInterlaced":false,"IsTextSubtitleStream":false,"Language":"und","Level":150,"PixelFormat":"yuv420p","Profile":"Main","Protocol":"File","RealFrameRate":25,"RefFrames":1,"SupportsExternalStream":false,"TimeBase":"1/90000","Type":"Video","VideoRange":"SDR","Width":3840}`
+
+// transcodeAudioStream 原始资源没有音轨信息时, 转码资源使用的默认音轨
+const transcodeAudioStream = `{"AttachmentSize":0,"BitRate":124573,"ChannelLayout":"stereo","Channels":2,"Codec":"aac","CodecTag":"mp4a","DisplayTitle":"AAC stereo (默认)","ExtendedVideoSubType":"None","ExtendedVideoSubTypeDescription":"None","ExtendedVideoType":"None","Index":1,"IsDefault":true,"IsExternal":false,"IsForced":false,"IsHearingImpaired":false,"IsInterlaced":false,"IsTextSubtitleStream":false,"Language":"und","Profile":"LC","Protocol":"File","SampleRate":44100,"SupportsExternalStream":false,"TimeBase":"1/44100","Type":"Audio"}`
+
+// audioStreams 获取 MediaSource 中的所有音轨
+func audioStreams(source *jsons.Item) []*jsons.Item {
+	streams, ok := source.Attr("MediaStreams").Done()
+	if !ok || streams.Type() != jsons.JsonTypeArr {
+		return nil
+	}
+	var res []*jsons.Item
+	streams.RangeArr(func(_ int, stream *jsons.Item) error {
+		if t, _ := stream.Attr("Type").String(); t == "Audio" {
+			res = append(res, stream)
+		}
+		return nil
+	})
+	return res
+}
+
+// TranscodedAudioIndex 获取网盘转码资源中实际包含的音轨索引
+//
+// 网盘转码只保留原始资源的默认音轨, 没有默认音轨时取第一条, 没有音轨时返回 -1
+func TranscodedAudioIndex(source *jsons.Item) int {
+	audios := audioStreams(source)
+	if len(audios) == 0 {
+		return -1
+	}
+	target := audios[0]
+	for _, audio := range audios {
+		if isDefault, _ := audio.Attr("IsDefault").Bool(); isDefault {
+			target = audio
+			break
+		}
+	}
+	idx, ok := target.Attr("Index").Int()
+	if !ok {
+		return -1
+	}
+	return idx
+}
+
+// TranscodeMediaStreams 生成转码资源的 MediaStreams
+//
+// 视频流使用虚拟的视频流, 音轨使用原始资源的真实音轨, 供客户端选择
+func TranscodeMediaStreams(source *jsons.Item) *jsons.Item {
+	res := jsons.NewEmptyArr()
+	video, _ := jsons.New(transcodeVideoStream)
+	res.Append(video)
+
+	audios := audioStreams(source)
+	if len(audios) == 0 {
+		audio, _ := jsons.New(transcodeAudioStream)
+		res.Append(audio)
+		return res
+	}
+	for _, audio := range audios {
+		res.Append(jsons.FromValue(audio.Struct()))
+	}
+	return res
+}
+
+// needAudioFallback 判断客户端选择的音轨是否不在转码资源中
+//
+// audioStreamIndex 为客户端请求的 AudioStreamIndex 参数, 为空时不需要回退
+func needAudioFallback(source *jsons.Item, audioStreamIndex string) bool {
+	idx, err := strconv.Atoi(audioStreamIndex)
+	if err != nil {
+		return false
+	}
+	transcoded := TranscodedAudioIndex(source)
+	return transcoded != -1 && idx != transcoded
+}
+
+// useOriginalStream 将 MediaSource 修改为原画直链播放
+//
+// 客户端选择了转码资源中不存在的音轨时使用, 由客户端从原始资源中选择音轨
+func useOriginalStream(source *jsons.Item, itemInfo ItemInfo) {
+	newUrl := fmt.Sprintf(
+		"/videos/%s/stream?MediaSourceId=%s&%s=%s&Static=true",
+		itemInfo.Id, itemInfo.MsInfo.OriginId, itemInfo.ApiKeyName, itemInfo.ApiKey,
+	)
+	source.Put("SupportsDirectPlay", jsons.FromValue(true))
+	source.Put("SupportsDirectStream", jsons.FromValue(true))
+	source.Put("DirectStreamUrl", jsons.FromValue(newUrl))
+	source.Put("SupportsTranscoding", jsons.FromValue(false))
+	source.DelKey("TranscodingUrl")
+	source.DelKey("TranscodingSubProtocol")
+	source.DelKey("TranscodingContainer")
+}
@@ -0,0 +1,53 @@
+package emby_test
+
+import (
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/service/emby"
+	"github.com/syscc/Emby-Go/internal/util/jsons"
+)
+
+func TestTranscodedAudioIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{"default audio", `{"MediaStreams":[{"Type":"Video","Index":0},{"Type":"Audio","Index":1},{"Type":"Audio","Index":2,"IsDefault":true}]}`, 2},
+		{"first audio", `{"MediaStreams":[{"Type":"Video","Index":0},{"Type":"Audio","Index":1},{"Type":"Audio","Index":2}]}`, 1},
+		{"no audio", `{"MediaStreams":[{"Type":"Video","Index":0}]}`, -1},
+		{"no streams", `{}`, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := jsons.New(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := emby.TranscodedAudioIndex(source); got != tt.want {
+				t.Errorf("TranscodedAudioIndex() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscodeMediaStreams(t *testing.T) {
+	source, _ := jsons.New(`{"MediaStreams":[{"Type":"Video","Index":0,"Codec":"h264"},{"Type":"Audio","Index":1,"Language":"jpn"},{"Type":"Audio","Index":2,"Language":"chi"},{"Type":"Subtitle","Index":3}]}`)
+	streams := emby.TranscodeMediaStreams(source)
+	if streams.Len() != 3 {
+		t.Fatalf("streams: %s", streams)
+	}
+	if codec, _ := streams.Idx(0).Attr("Codec").String(); codec != "prores" {
+		t.Errorf("video codec: %s", codec)
+	}
+	if lang, _ := streams.Idx(2).Attr("Language").String(); lang != "chi" {
+		t.Errorf("audio language: %s", lang)
+	}
+
+	// 没有音轨信息时使用默认音轨
+	empty, _ := jsons.New(`{}`)
+	streams = emby.TranscodeMediaStreams(empty)
+	if codec, _ := streams.Idx(1).Attr("Codec").String(); streams.Len() != 2 || codec != "aac" {
+		t.Errorf("fallback streams: %s", streams)
+	}
+}
@@ -62,6 +62,8 @@ func ApiKeyChecker() gin.HandlerFunc {
 		regexp.MustCompile(constant.Reg_ProxyPlaylist),
 		regexp.MustCompile(constant.Reg_ProxyTs),
 		regexp.MustCompile(constant.Reg_ProxySubtitle),
+		regexp.MustCompile(constant.Reg_ProxyVtt),
+		regexp.MustCompile(constant.Reg_ProxySiblingSubtitle),
 		regexp.MustCompile(constant.Reg_ShowEpisodes),
 		regexp.MustCompile(constant.Reg_UserItems),
 	}
@@ -88,7 +90,7 @@ func ApiKeyChecker() gin.HandlerFunc {
 		}
 
 		// 4 发出请求, 验证 api_key
-		u := config.C.Emby.Host + AuthUri
+		u := config.C().Emby.Host + AuthUri
 		var header http.Header
 		if kType == Query {
 			u = urls.AppendArgs(u, kName, apiKey)
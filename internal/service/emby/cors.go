@@ -16,7 +16,7 @@ func ChangeBaseVideoModuleCorsDefined(c *gin.Context) {
 	// 1 代理请求
 	c.Request.Header.Del("If-Modified-Since")
 	c.Request.Header.Del("If-None-Match")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
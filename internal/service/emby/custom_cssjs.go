@@ -132,7 +132,7 @@ var loadAllCustomCssJs = sync.OnceFunc(func() {
 
 // ProxyIndexHtml 代理 index.html 注入自定义脚本样式文件
 func ProxyIndexHtml(c *gin.Context) {
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
@@ -0,0 +1,110 @@
+package emby
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/syscc/Emby-Go/internal/config"
+)
+
+const (
+	HeaderClientName = "X-Emby-Client"    // 客户端名称请求头
+	HeaderDeviceId   = "X-Emby-Device-Id" // 客户端设备 id 请求头
+)
+
+// forwardClientHeaders 内部请求 PlaybackInfo 时需要透传的客户端请求头, 用于匹配设备配置
+var forwardClientHeaders = []string{"User-Agent", HeaderClientName, HeaderDeviceId, HeaderFullAuthName}
+
+// authClientReg 匹配 Authorization 头中的 Client 字段
+var authClientReg = regexp.MustCompile(`(?i)\bclient="([^"]+)"`)
+
+// authDeviceIdReg 匹配 Authorization 头中的 DeviceId 字段
+var authDeviceIdReg = regexp.MustCompile(`(?i)\bdeviceid="([^"]+)"`)
+
+// MasterParams 构造 master.m3u8 地址时使用的客户端参数
+type MasterParams struct {
+	DeviceId      string // 客户端设备 id
+	MediaSourceId string // 播放的 MediaSource id
+	PlaySessionId string // 播放会话 id
+	ApiKey        string // 客户端的 api_key
+}
+
+// MatchDeviceProfile 根据请求的 X-Emby-Client 或 User-Agent 选择设备配置
+func MatchDeviceProfile(r *http.Request) *config.DeviceProfile {
+	return config.C().Emby.MatchDeviceProfile(clientName(r), r.UserAgent())
+}
+
+// MasterM3U8Url 根据设备配置和客户端参数构造转码资源的 master.m3u8 地址
+func MasterM3U8Url(itemId string, profile *config.DeviceProfile, mp MasterParams) *url.URL {
+	u, _ := url.Parse(fmt.Sprintf("/videos/%s/master.m3u8", itemId))
+	q := url.Values{}
+	if profile != nil {
+		q = profile.HlsParams()
+	}
+	for key, value := range map[string]string{
+		"DeviceId":      mp.DeviceId,
+		"MediaSourceId": mp.MediaSourceId,
+		"PlaySessionId": mp.PlaySessionId,
+		QueryApiKeyName: mp.ApiKey,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// playbackPayload 获取请求 PlaybackInfo 时使用的请求体
+func playbackPayload(profile *config.DeviceProfile) io.ReadCloser {
+	return io.NopCloser(bytes.NewBufferString(profile.Payload))
+}
+
+// clientName 获取请求的客户端名称
+func clientName(r *http.Request) string {
+	if name := r.Header.Get(HeaderClientName); name != "" {
+		return name
+	}
+	if name := r.URL.Query().Get(HeaderClientName); name != "" {
+		return name
+	}
+	return matchAuthField(r, authClientReg)
+}
+
+// deviceId 获取请求的客户端设备 id
+func deviceId(r *http.Request) string {
+	q := r.URL.Query()
+	if id := q.Get("DeviceId"); id != "" {
+		return id
+	}
+	if id := r.Header.Get(HeaderDeviceId); id != "" {
+		return id
+	}
+	if id := q.Get(HeaderDeviceId); id != "" {
+		return id
+	}
+	return matchAuthField(r, authDeviceIdReg)
+}
+
+// matchAuthField 从 Authorization 相关请求头中提取指定字段
+func matchAuthField(r *http.Request, reg *regexp.Regexp) string {
+	for _, name := range []string{HeaderFullAuthName, HeaderAuthName} {
+		if res := reg.FindStringSubmatch(r.Header.Get(name)); len(res) == 2 {
+			return res[1]
+		}
+	}
+	return ""
+}
+
+// copyClientHeaders 将客户端标识相关的请求头复制到 dst
+func copyClientHeaders(dst, src http.Header) {
+	for _, key := range forwardClientHeaders {
+		if value := src.Get(key); value != "" {
+			dst.Set(key, value)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package emby_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/emby"
+)
+
+func builtinEmby(t *testing.T) *config.Emby {
+	profiles := config.BuiltinDeviceProfiles()
+	for _, dp := range profiles {
+		if err := dp.Init(); err != nil {
+			t.Fatalf("内置设备配置 %s 初始化失败: %v", dp.Name, err)
+		}
+	}
+	return &config.Emby{DeviceProfiles: profiles}
+}
+
+func TestMatchDeviceProfile(t *testing.T) {
+	e := builtinEmby(t)
+	tests := []struct {
+		client, ua, want string
+	}{
+		{"Emby for Android TV", "okhttp/4.9.0", "android-tv"},
+		{"", "Infuse-Direct/7.7", "infuse"},
+		{"Kodi", "Kodi/21.0", "kodi"},
+		{"Emby Web", "Mozilla/5.0 (Windows NT 10.0)", "web"},
+		{"", "curl/8.0", config.DefaultDeviceProfileName},
+	}
+	for _, tt := range tests {
+		if got := e.MatchDeviceProfile(tt.client, tt.ua); got == nil || got.Name != tt.want {
+			t.Errorf("MatchDeviceProfile(%q, %q) = %v, want %s", tt.client, tt.ua, got, tt.want)
+		}
+	}
+}
+
+func TestMasterM3U8Url(t *testing.T) {
+	e := builtinEmby(t)
+	profile := e.MatchDeviceProfile("Emby for Android TV", "")
+	u := emby.MasterM3U8Url("2008", profile, emby.MasterParams{
+		DeviceId:      "device-1",
+		MediaSourceId: "ms-1",
+		PlaySessionId: "session-1",
+		ApiKey:        "key-1",
+	})
+
+	if u.Path != "/videos/2008/master.m3u8" {
+		t.Fatalf("unexpected path: %s", u.Path)
+	}
+	want := url.Values{
+		"DeviceId":                    {"device-1"},
+		"MediaSourceId":               {"ms-1"},
+		"PlaySessionId":               {"session-1"},
+		"api_key":                     {"key-1"},
+		"VideoCodec":                  {"h264,hevc"},
+		"AudioCodec":                  {"aac,ac3,eac3"},
+		"SegmentContainer":            {"ts"},
+		"TranscodingMaxAudioChannels": {"6"},
+	}
+	q := u.Query()
+	for k, v := range want {
+		if q.Get(k) != v[0] {
+			t.Errorf("query %s = %q, want %q", k, q.Get(k), v[0])
+		}
+	}
+}
@@ -124,7 +124,7 @@ func DownloadStrategyChecker() gin.HandlerFunc {
 			return
 		}
 
-		strategy := config.C.Emby.DownloadStrategy
+		strategy := config.C().Emby.DownloadStrategy
 
 		if strategy == config.DlStrategyDirect {
 			return
@@ -137,7 +137,7 @@ func DownloadStrategyChecker() gin.HandlerFunc {
 		}
 
 		if strategy == config.DlStrategyOrigin {
-			if err := https.ProxyPass(c.Request, c.Writer, config.C.Emby.Host); err != nil {
+			if err := https.ProxyPass(c.Request, c.Writer, config.C().Emby.Host); err != nil {
 				logs.Error("下载接口代理失败: %v", err)
 			}
 		}
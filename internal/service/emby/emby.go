@@ -25,7 +25,7 @@ func ProxySocket() func(*gin.Context) {
 	var once = sync.Once{}
 
 	initFunc := func() {
-		origin := config.C.Emby.Host
+		origin := config.C().Emby.Host
 		u, err := url.Parse(origin)
 		if err != nil {
 			panic("转换 emby host 异常: " + err.Error())
@@ -52,7 +52,7 @@ func HandleImages(c *gin.Context) {
 	q := c.Request.URL.Query()
 	q.Del("quality")
 	q.Del("Quality")
-	q.Set("Quality", strconv.Itoa(config.C.Emby.ImagesQuality))
+	q.Set("Quality", strconv.Itoa(config.C().Emby.ImagesQuality))
 	c.Request.RequestURI = c.Request.URL.Path + "?" + q.Encode()
 	ProxyOrigin(c)
 }
@@ -62,7 +62,7 @@ func ProxyOrigin(c *gin.Context) {
 	if c == nil {
 		return
 	}
-	origin := config.C.Emby.Host
+	origin := config.C().Emby.Host
 
 	// 传递客户端 IP 到 emby
 	origXFF := strings.TrimSpace(c.Request.Header.Get("X-Forwarded-For"))
@@ -147,7 +147,7 @@ func TestProxyUri(c *gin.Context) bool {
 	}
 	infos.Body = string(bodyBytes)
 
-	origin := config.C.Emby.Host
+	origin := config.C().Emby.Host
 	resp, err := https.Request(infos.Method, origin+infos.Uri).
 		Header(c.Request.Header).
 		Body(io.NopCloser(bytes.NewBuffer(bodyBytes))).
@@ -182,7 +182,7 @@ func TestProxyUri(c *gin.Context) bool {
 
 // ProxyRoot web 首页代理
 func ProxyRoot(c *gin.Context) {
-	resp, err := https.Request(c.Request.Method, config.C.Emby.Host+c.Request.URL.String()).
+	resp, err := https.Request(c.Request.Method, config.C().Emby.Host+c.Request.URL.String()).
 		Header(c.Request.Header).
 		Body(c.Request.Body).
 		DoSingle()
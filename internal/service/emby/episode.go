@@ -20,8 +20,8 @@ import (
 // 则会将未播剧集排在前面位置
 func ResortEpisodes(c *gin.Context) {
 	// 1 检查配置是否开启
-	if !config.C.Emby.EpisodesUnplayPrior {
-		checkErr(c, https.ProxyPass(c.Request, c.Writer, config.C.Emby.Host))
+	if !config.C().Emby.EpisodesUnplayPrior {
+		checkErr(c, https.ProxyPass(c.Request, c.Writer, config.C().Emby.Host))
 		return
 	}
 
@@ -37,7 +37,7 @@ func ResortEpisodes(c *gin.Context) {
 
 	// 3 代理请求
 	c.Request.Header.Del("Accept-Encoding")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
@@ -2,10 +2,12 @@ package emby
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strconv"
 	"strings"
@@ -34,7 +36,7 @@ const (
 // ResortRandomItems 对随机的 items 列表进行重排序
 func ResortRandomItems(c *gin.Context) {
 	// 如果没有开启配置, 代理原请求并返回
-	if !config.C.Emby.ResortRandomItems {
+	if !config.C().Emby.ResortRandomItems {
 		ProxyOrigin(c)
 		return
 	}
@@ -99,7 +101,7 @@ func RandomItemsWithLimit(c *gin.Context) {
 	q.Set("Limit", "500")
 	q.Del("SortOrder")
 	u.RawQuery = q.Encode()
-	embyHost := config.C.Emby.Host
+	embyHost := config.C().Emby.Host
 	c.Request.Header.Del("Accept-Encoding")
 	resp, err := https.Request(c.Request.Method, embyHost+u.String()).
 		Header(c.Request.Header).
@@ -144,7 +146,7 @@ func calcRandomItemsCacheKey(c *gin.Context) string {
 func ProxyAddItemsPreviewInfo(c *gin.Context) {
 	// 代理请求
 	c.Request.Header.Del("Accept-Encoding")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
@@ -174,7 +176,6 @@ func ProxyAddItemsPreviewInfo(c *gin.Context) {
 	}
 
 	// 遍历每个 Item, 修改 MediaSource 信息
-	proresMediaStreams, _ := jsons.New(`[{"AspectRatio":"16:9","AttachmentSize":0,"AverageFrameRate":25,"BitDepth":8,"BitRate":4838626,"Codec":"prores","CodecTag":"hev1","DisplayTitle":"4K HEVC","ExtendedVideoSubType":"None","ExtendedVideoSubTypeDescription":"None","ExtendedVideoType":"None","Height":2160,"Index":0,"IsDefault":true,"IsExternal":false,"IsForced":false,"IsHearingImpaired":false,"IsInterlaced":false,"IsTextSubtitleStream":false,"Language":"und","Level":150,"PixelFormat":"yuv420p","Profile":"Main","Protocol":"File","RealFrameRate":25,"RefFrames":1,"SupportsExternalStream":false,"TimeBase":"1/90000","Type":"Video","VideoRange":"SDR","Width":3840},{"AttachmentSize":0,"BitRate":124573,"ChannelLayout":"stereo","Channels":2,"Codec":"aac","CodecTag":"mp4a","DisplayTitle":"AAC stereo (默认)","ExtendedVideoSubType":"None","ExtendedVideoSubTypeDescription":"None","ExtendedVideoType":"None","Index":1,"IsDefault":true,"IsExternal":false,"IsForced":false,"IsHearingImpaired":false,"IsInterlaced":false,"IsTextSubtitleStream":false,"Language":"und","Profile":"LC","Protocol":"File","SampleRate":44100,"SupportsExternalStream":false,"TimeBase":"1/44100","Type":"Audio"}]`)
 	allTplIds := getAllPreviewTemplateIds()
 	toAdd := make([]*jsons.Item, 0, len(allTplIds))
 	itemsArr.RangeArr(func(index int, item *jsons.Item) error {
@@ -193,7 +194,7 @@ func ProxyAddItemsPreviewInfo(c *gin.Context) {
 			}
 
 			// 检查用户是否启用了转码版本获取
-			if !config.C.VideoPreview.Enable {
+			if !config.C().VideoPreview.Enable {
 				return nil
 			}
 
@@ -207,11 +208,13 @@ func ProxyAddItemsPreviewInfo(c *gin.Context) {
 			ctn, _ := ms.Attr("Container").Done()
 
 			originName, _ := ms.Attr("Name").String()
+			// 转码资源使用虚拟视频流和原始资源的真实音轨
+			mediaStreams := TranscodeMediaStreams(ms)
 			for _, tplId := range allTplIds {
 				copyMs := jsons.NewEmptyObj()
 				copyMs.Put("Name", jsons.FromValue(fmt.Sprintf("(%s) %s", tplId, originName)))
 				copyMs.Put("Id", jsons.FromValue(fmt.Sprintf("%s%s%s", originId, MediaSourceIdSegment, tplId)))
-				copyMs.Put("MediaStreams", proresMediaStreams)
+				copyMs.Put("MediaStreams", mediaStreams)
 				copyMs.Put("Path", path)
 				copyMs.Put("SupportsTranscoding", st)
 				copyMs.Put("Type", t)
@@ -234,7 +237,7 @@ func ProxyAddItemsPreviewInfo(c *gin.Context) {
 func ProxyLatestItems(c *gin.Context) {
 	// 代理请求
 	c.Request.Header.Del("Accept-Encoding")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
@@ -275,3 +278,27 @@ func ProxyLatestItems(c *gin.Context) {
 	})
 
 }
+
+// fetchItem 通过 /Items 接口请求单个 item 的信息, fields 为需要额外返回的字段
+func fetchItem(itemInfo ItemInfo, fields ...string) (*jsons.Item, error) {
+	q := url.Values{"Ids": {itemInfo.Id}}
+	if len(fields) > 0 {
+		q.Set("Fields", strings.Join(fields, ","))
+	}
+	header := make(http.Header)
+	if itemInfo.ApiKeyType == Header {
+		header.Set(itemInfo.ApiKeyName, itemInfo.ApiKey)
+	} else {
+		q.Set(itemInfo.ApiKeyName, itemInfo.ApiKey)
+	}
+
+	res, _ := Fetch("/Items?"+q.Encode(), http.MethodGet, header, nil)
+	if res.Code != http.StatusOK {
+		return nil, errors.New("请求 item 信息失败: " + res.Msg)
+	}
+	item, ok := res.Data.Attr("Items").Idx(0).Done()
+	if !ok {
+		return nil, errors.New("item 不存在: " + itemInfo.Id)
+	}
+	return item, nil
+}
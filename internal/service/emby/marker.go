@@ -0,0 +1,174 @@
+package emby
+
+import (
+	"slices"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/util/jsons"
+)
+
+// 片头片尾标记类型, 对应 emby 章节的 MarkerType 字段
+const (
+	MarkerIntroStart   = "IntroStart"
+	MarkerIntroEnd     = "IntroEnd"
+	MarkerCreditsStart = "CreditsStart"
+)
+
+// TicksPerSecond emby 时间刻度, 1 tick = 100ns
+const TicksPerSecond = 10_000_000
+
+// markerNames 标记章节的显示名称
+var markerNames = map[string]string{
+	MarkerIntroStart:   "Intro",
+	MarkerIntroEnd:     "Intro End",
+	MarkerCreditsStart: "Credits",
+}
+
+// MarkerChapter 片头片尾标记章节
+type MarkerChapter struct {
+	MarkerType         string
+	StartPositionTicks int64
+}
+
+// MarkerChapters 根据规则生成片头片尾标记
+//
+// runTimeTicks 为视频时长, 用于计算相对结尾的片尾时间, 超出视频时长的标记会被忽略
+func MarkerChapters(rule *config.SkipMarkerRule, runTimeTicks int64) []MarkerChapter {
+	if rule == nil {
+		return nil
+	}
+	inRange := func(ticks int64) bool {
+		return ticks >= 0 && (runTimeTicks <= 0 || ticks < runTimeTicks)
+	}
+
+	var res []MarkerChapter
+	if rule.IntroEnd > 0 {
+		start, end := int64(rule.IntroStart)*TicksPerSecond, int64(rule.IntroEnd)*TicksPerSecond
+		if inRange(start) && inRange(end) {
+			res = append(res,
+				MarkerChapter{MarkerType: MarkerIntroStart, StartPositionTicks: start},
+				MarkerChapter{MarkerType: MarkerIntroEnd, StartPositionTicks: end},
+			)
+		}
+	}
+
+	if rule.CreditsStart != 0 {
+		start := int64(rule.CreditsStart) * TicksPerSecond
+		if rule.CreditsStart < 0 {
+			// 相对结尾的时间需要知道视频时长
+			start = -1
+			if runTimeTicks > 0 {
+				start = runTimeTicks + int64(rule.CreditsStart)*TicksPerSecond
+			}
+		}
+		if start > 0 && inRange(start) {
+			res = append(res, MarkerChapter{MarkerType: MarkerCreditsStart, StartPositionTicks: start})
+		}
+	}
+	return res
+}
+
+// matchSkipMarkerRule 查找剧集 item 对应的片头片尾规则, 不是剧集或没有规则时返回 nil
+func matchSkipMarkerRule(item *jsons.Item) *config.SkipMarkerRule {
+	if item == nil || item.Type() != jsons.JsonTypeObj || len(config.C().SkipMarker.Rules) == 0 {
+		return nil
+	}
+	if itemType, _ := item.Attr("Type").String(); itemType != "Episode" {
+		return nil
+	}
+	seriesId, _ := item.Attr("SeriesId").String()
+	seriesName, _ := item.Attr("SeriesName").String()
+	season, _ := item.Attr("ParentIndexNumber").Int()
+	return config.C().SkipMarker.Match(seriesId, seriesName, season)
+}
+
+// injectItemMarkers 将片头片尾标记注入到 item 及其 MediaSources 的 Chapters 中
+func injectItemMarkers(item *jsons.Item) {
+	rule := matchSkipMarkerRule(item)
+	if rule == nil {
+		return
+	}
+
+	runTimeTicks, _ := item.Attr("RunTimeTicks").Int64()
+	MergeMarkers(item, MarkerChapters(rule, runTimeTicks))
+	if mediaSources, ok := item.Attr("MediaSources").Done(); ok && mediaSources.Type() == jsons.JsonTypeArr {
+		injectSourcesMarkers(mediaSources, rule, runTimeTicks)
+	}
+}
+
+// injectPlaybackMarkers 将片头片尾标记注入到 PlaybackInfo 的所有 MediaSources 中
+//
+// PlaybackInfo 响应中没有剧集信息, 需要额外请求一次 item 信息
+func injectPlaybackMarkers(itemInfo ItemInfo, mediaSources *jsons.Item) error {
+	if len(config.C().SkipMarker.Rules) == 0 || mediaSources == nil || mediaSources.Empty() {
+		return nil
+	}
+	item, err := fetchItem(itemInfo, "Chapters")
+	if err != nil {
+		return err
+	}
+	rule := matchSkipMarkerRule(item)
+	if rule == nil {
+		return nil
+	}
+	runTimeTicks, _ := item.Attr("RunTimeTicks").Int64()
+	injectSourcesMarkers(mediaSources, rule, runTimeTicks)
+	return nil
+}
+
+// injectSourcesMarkers 将标记注入到每个 MediaSource, 优先使用 MediaSource 自身的时长
+func injectSourcesMarkers(mediaSources *jsons.Item, rule *config.SkipMarkerRule, runTimeTicks int64) {
+	mediaSources.RangeArr(func(_ int, source *jsons.Item) error {
+		ticks := runTimeTicks
+		if t, ok := source.Attr("RunTimeTicks").Int64(); ok && t > 0 {
+			ticks = t
+		}
+		MergeMarkers(source, MarkerChapters(rule, ticks))
+		return nil
+	})
+}
+
+// MergeMarkers 将标记合并到 target 原有的章节中
+//
+// 原有的片头片尾标记会被替换, 合并后按开始时间排序并重新编号;
+// 规则没有生成任何标记时保留 emby 原有的章节不变
+func MergeMarkers(target *jsons.Item, markers []MarkerChapter) {
+	if len(markers) == 0 {
+		return
+	}
+	var chapters []*jsons.Item
+	if origin, ok := target.Attr("Chapters").Done(); ok && origin.Type() == jsons.JsonTypeArr {
+		for _, c := range origin.ValuesArr() {
+			mt, _ := c.Attr("MarkerType").String()
+			if _, isMarker := markerNames[mt]; !isMarker {
+				chapters = append(chapters, c)
+			}
+		}
+	}
+	for _, m := range markers {
+		c := jsons.NewEmptyObj()
+		c.Put("Name", jsons.FromValue(markerNames[m.MarkerType]))
+		c.Put("StartPositionTicks", jsons.FromValue(m.StartPositionTicks))
+		c.Put("MarkerType", jsons.FromValue(m.MarkerType))
+		chapters = append(chapters, c)
+	}
+
+	slices.SortStableFunc(chapters, func(a, b *jsons.Item) int {
+		at, _ := a.Attr("StartPositionTicks").Int64()
+		bt, _ := b.Attr("StartPositionTicks").Int64()
+		switch {
+		case at < bt:
+			return -1
+		case at > bt:
+			return 1
+		}
+		return 0
+	})
+
+	res := jsons.NewEmptyArr()
+	for i, c := range chapters {
+		c.Put("ChapterIndex", jsons.FromValue(i))
+		res.Append(c)
+	}
+	target.Put("Chapters", res)
+}
@@ -0,0 +1,98 @@
+package emby_test
+
+import (
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/emby"
+	"github.com/syscc/Emby-Go/internal/util/jsons"
+)
+
+func TestMarkerChapters(t *testing.T) {
+	const runTime = 1440 * emby.TicksPerSecond
+	tests := []struct {
+		name string
+		rule config.SkipMarkerRule
+		want []emby.MarkerChapter
+	}{
+		{
+			name: "片头和相对结尾的片尾",
+			rule: config.SkipMarkerRule{IntroStart: 30, IntroEnd: 120, CreditsStart: -90},
+			want: []emby.MarkerChapter{
+				{MarkerType: emby.MarkerIntroStart, StartPositionTicks: 30 * emby.TicksPerSecond},
+				{MarkerType: emby.MarkerIntroEnd, StartPositionTicks: 120 * emby.TicksPerSecond},
+				{MarkerType: emby.MarkerCreditsStart, StartPositionTicks: 1350 * emby.TicksPerSecond},
+			},
+		},
+		{
+			name: "只有片尾",
+			rule: config.SkipMarkerRule{CreditsStart: 1300},
+			want: []emby.MarkerChapter{
+				{MarkerType: emby.MarkerCreditsStart, StartPositionTicks: 1300 * emby.TicksPerSecond},
+			},
+		},
+		{
+			name: "超出视频时长",
+			rule: config.SkipMarkerRule{IntroEnd: 90, CreditsStart: 1500},
+			want: []emby.MarkerChapter{
+				{MarkerType: emby.MarkerIntroStart, StartPositionTicks: 0},
+				{MarkerType: emby.MarkerIntroEnd, StartPositionTicks: 90 * emby.TicksPerSecond},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := emby.MarkerChapters(&tt.rule, runTime)
+			if len(got) != len(tt.want) {
+				t.Fatalf("MarkerChapters() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("MarkerChapters()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if got := emby.MarkerChapters(&config.SkipMarkerRule{CreditsStart: -90}, 0); len(got) != 0 {
+		t.Errorf("未知时长时不应生成相对结尾的片尾: %v", got)
+	}
+}
+
+func TestMergeMarkers(t *testing.T) {
+	const origin = `{"Chapters":[` +
+		`{"Name":"Intro","StartPositionTicks":100,"MarkerType":"IntroStart","ChapterIndex":0},` +
+		`{"Name":"Chapter 1","StartPositionTicks":500,"MarkerType":"Chapter","ChapterIndex":1},` +
+		`{"Name":"Credits","StartPositionTicks":900,"MarkerType":"CreditsStart","ChapterIndex":2}]}`
+
+	// 规则没有生成标记时保留 emby 原有的片头片尾
+	item, err := jsons.New(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emby.MergeMarkers(item, nil)
+	if chapters, _ := item.Attr("Chapters").Done(); chapters.Len() != 3 {
+		t.Fatalf("Chapters = %s, want origin chapters", chapters)
+	}
+
+	// 生成标记时替换原有的片头片尾并重新排序编号
+	item, _ = jsons.New(origin)
+	emby.MergeMarkers(item, []emby.MarkerChapter{
+		{MarkerType: emby.MarkerIntroEnd, StartPositionTicks: 800},
+		{MarkerType: emby.MarkerIntroStart, StartPositionTicks: 0},
+	})
+	want := []string{emby.MarkerIntroStart, "Chapter", emby.MarkerIntroEnd}
+	chapters, _ := item.Attr("Chapters").Done()
+	if chapters.Len() != len(want) {
+		t.Fatalf("Chapters = %s", chapters)
+	}
+	for i, mt := range want {
+		c, _ := chapters.Idx(i).Done()
+		if got, _ := c.Attr("MarkerType").String(); got != mt {
+			t.Errorf("Chapters[%d].MarkerType = %s, want %s", i, got, mt)
+		}
+		if idx, _ := c.Attr("ChapterIndex").Int(); idx != i {
+			t.Errorf("Chapters[%d].ChapterIndex = %d", i, idx)
+		}
+	}
+}
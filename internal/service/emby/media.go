@@ -45,7 +45,7 @@ func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
 	}
 
 	innerRequest := func(method string) (*http.Response, error) {
-		resp, err := https.Request(method, config.C.Emby.Host+itemInfo.PlaybackInfoUri).Header(header).Do()
+		resp, err := https.Request(method, config.C().Emby.Host+itemInfo.PlaybackInfoUri).Header(header).Do()
 		if err != nil {
 			return nil, fmt.Errorf("请求 Emby 接口异常, error: %v", err)
 		}
@@ -122,8 +122,9 @@ func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
 
 // findVideoPreviewInfos 查找 source 的所有转码资源
 //
-// 传递 resChan 进行异步查询, 通过监听 resChan 获取查询结果
-func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan []*jsons.Item) {
+// 传递 resChan 进行异步查询, 通过监听 resChan 获取查询结果;
+// 转码资源的 master 地址根据客户端的设备配置 profile 和播放参数 mp 生成
+func findVideoPreviewInfos(source *jsons.Item, profile *config.DeviceProfile, mp MasterParams, resChan chan []*jsons.Item) {
 	if resChan == nil {
 		return
 	}
@@ -135,7 +136,7 @@ func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan
 	}
 
 	// 未启用配置
-	cfg := config.C.VideoPreview
+	cfg := config.C().VideoPreview
 	srcContainer, _ := source.Attr("Container").String()
 	if !cfg.Enable || !cfg.ContainerValid(srcContainer) {
 		resChan <- nil
@@ -195,7 +196,7 @@ func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if config.C.VideoPreview.IsTemplateIgnore(transcode.TemplateId) {
+			if config.C().VideoPreview.IsTemplateIgnore(transcode.TemplateId) {
 				// 当前清晰度被忽略
 				return
 			}
@@ -215,11 +216,12 @@ func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan
 			copySource.Attr("Id").Set(newId)
 
 			// 设置转码代理播放链接
-			tu, _ := url.Parse(strings.ReplaceAll(MasterM3U8UrlTemplate, "${itemId}", itemId))
+			mp := mp
+			mp.MediaSourceId = newId
+			tu := MasterM3U8Url(itemId, profile, mp)
 			q := tu.Query()
 			q.Set("openlist_path", openlist.PathEncode(openlistPathRes.Path))
 			q.Set("template_id", transcode.TemplateId)
-			q.Set(QueryApiKeyName, clientApiKey)
 			tu.RawQuery = q.Encode()
 
 			// 标记转码资源使用转码容器
@@ -231,8 +233,13 @@ func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan
 			copySource.Put("SupportsDirectPlay", jsons.FromValue(false))
 			copySource.Put("SupportsDirectStream", jsons.FromValue(false))
 
+			// 网盘转码只保留默认音轨, 将其设置为转码资源的默认音轨
+			if idx := TranscodedAudioIndex(copySource); idx != -1 {
+				copySource.Put("DefaultAudioStreamIndex", jsons.FromValue(idx))
+			}
+
 			// 设置转码字幕
-			addSubtitles2MediaStreams(copySource, subtitleList, openlistPathRes.Path, transcode.TemplateId, clientApiKey)
+			addSubtitles2MediaStreams(copySource, subtitleList, openlistPathRes.Path, transcode.TemplateId, mp.ApiKey)
 
 			res[idx] = copySource
 		}()
@@ -376,6 +383,19 @@ func detectSubtitleStreamsDeliveryUrl(source *jsons.Item, apiKey string) {
 			return nil
 		}
 
+		subIndex, _ := value.Attr("Index").Int()
+
+		// 开启字幕转换时, srt/ass/ssa 字幕由本程序转换为 WebVTT
+		if config.C().Subtitle.Convert {
+			codec, _ := value.Attr("Codec").String()
+			if vttUrl, ok := VttDeliveryUrl(itemId, id, subIndex, codec, apiKey); ok {
+				value.Put("DeliveryMethod", jsons.FromValue("External"))
+				value.Put("DeliveryUrl", jsons.FromValue(vttUrl))
+				value.Put("Codec", jsons.FromValue("vtt"))
+				return nil
+			}
+		}
+
 		// DeliveryMethod 为 External 时, Emby 默认会提供 DeliveryUrl 字段, 无需手动修改
 		deliveryMethod, _ := value.Attr("DeliveryMethod").String()
 		if deliveryMethod == "External" {
@@ -383,7 +403,6 @@ func detectSubtitleStreamsDeliveryUrl(source *jsons.Item, apiKey string) {
 		}
 		value.Put("DeliveryMethod", jsons.FromValue("External"))
 
-		subIndex, _ := value.Attr("Index").Int()
 		u, _ := url.Parse(fmt.Sprintf("/Videos/%s/%s/Subtitles/%d/0/Stream.vtt?api_key=%s", itemId, id, subIndex, apiKey))
 		value.Put("DeliveryUrl", jsons.FromValue(u.String()))
 		return nil
@@ -391,6 +410,81 @@ func detectSubtitleStreamsDeliveryUrl(source *jsons.Item, apiKey string) {
 
 }
 
+// addSiblingSubtitles 将网盘中与视频同名的外挂字幕注入到 MediaStreams
+//
+// 已经被 emby 扫描到的同名外挂字幕不会重复添加
+func addSiblingSubtitles(source *jsons.Item, apiKey string) {
+	if source == nil || source.Type() != jsons.JsonTypeObj {
+		return
+	}
+	mediaStreams, ok := source.Attr("MediaStreams").Done()
+	if !ok || mediaStreams.Type() != jsons.JsonTypeArr {
+		return
+	}
+
+	embyPath, _ := source.Attr("Path").String()
+	openlistPathRes := path.Emby2Openlist(embyPath)
+	if !openlistPathRes.Success {
+		return
+	}
+	subs, err := openlist.FetchSiblingSubtitles(openlistPathRes.Path)
+	if err != nil {
+		logs.Warn("获取同目录外挂字幕失败, path: %s, err: %v", openlistPathRes.Path, err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	// 记录已存在的外挂字幕和最大索引
+	existNames := map[string]struct{}{}
+	nextIndex := 0
+	mediaStreams.RangeArr(func(_ int, value *jsons.Item) error {
+		if idx, ok := value.Attr("Index").Int(); ok && idx >= nextIndex {
+			nextIndex = idx + 1
+		}
+		if isExternal, _ := value.Attr("IsExternal").Bool(); isExternal {
+			subPath, _ := value.Attr("Path").String()
+			existNames[filepath.Base(strings.ReplaceAll(subPath, "\\", "/"))] = struct{}{}
+		}
+		return nil
+	})
+
+	for _, sub := range subs {
+		if _, ok := existNames[sub.Name]; ok {
+			continue
+		}
+
+		codec := sub.Ext
+		if config.C().Subtitle.Convert {
+			codec = "vtt"
+		}
+		displayTitle := fmt.Sprintf("(%s)", strings.ToUpper(sub.Ext))
+		if sub.Lang != "" {
+			displayTitle = openlist.SubLangDisplayName(sub.Lang) + " " + displayTitle
+		}
+
+		subStream := jsons.NewEmptyObj()
+		subStream.Put("Index", jsons.FromValue(nextIndex))
+		subStream.Put("Type", jsons.FromValue("Subtitle"))
+		subStream.Put("Codec", jsons.FromValue(codec))
+		subStream.Put("Language", jsons.FromValue(sub.Lang))
+		subStream.Put("DisplayTitle", jsons.FromValue(displayTitle))
+		subStream.Put("Title", jsons.FromValue(sub.Name))
+		subStream.Put("Path", jsons.FromValue(sub.Path))
+		subStream.Put("IsExternal", jsons.FromValue(true))
+		subStream.Put("IsTextSubtitleStream", jsons.FromValue(true))
+		subStream.Put("SupportsExternalStream", jsons.FromValue(true))
+		subStream.Put("IsDefault", jsons.FromValue(false))
+		subStream.Put("IsForced", jsons.FromValue(false))
+		subStream.Put("Protocol", jsons.FromValue("File"))
+		subStream.Put("DeliveryMethod", jsons.FromValue("External"))
+		subStream.Put("DeliveryUrl", jsons.FromValue(SiblingSubtitleUrl(sub.Path, apiKey)))
+		mediaStreams.Append(subStream)
+		nextIndex++
+	}
+}
+
 // simplifyMediaName 简化 MediaSource 中的视频名称, 如 '1080p HEVC'
 func simplifyMediaName(source *jsons.Item) {
 	if source == nil || source.Type() != jsons.JsonTypeObj {
@@ -540,7 +634,7 @@ func getAllPreviewTemplateIds() []string {
 
 	res := make([]string, 0, len(allIds))
 	for _, id := range allIds {
-		if config.C.VideoPreview.IsTemplateIgnore(id) {
+		if config.C().VideoPreview.IsTemplateIgnore(id) {
 			continue
 		}
 		res = append(res, id)
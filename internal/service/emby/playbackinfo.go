@@ -1,10 +1,8 @@
 package emby
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -22,17 +20,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
-
-	// PlaybackCacheSpace PlaybackInfo 的缓存空间 key
-	PlaybackCacheSpace = "PlaybackInfo"
-
-	// MasterM3U8UrlTemplate 转码 m3u8 地址模板
-	MasterM3U8UrlTemplate = `/videos/${itemId}/master.m3u8?DeviceId=a690fc29-1f3e-423b-ba23-f03049361a3b\u0026MediaSourceId=83ed6e4e3d820864a3d07d2ef9efab2e\u0026PlaySessionId=9f01e60a22c74ad0847319175912663b\u0026api_key=f53f3bf34c0543ed81415b86576058f2\u0026LiveStreamId=06044cf0e6f93cdae5f285c9ecfaaeb4_01413a525b3a9622ce6fdf19f7dde354_83ed6e4e3d820864a3d07d2ef9efab2e\u0026VideoCodec=h264,h265,hevc,av1\u0026AudioCodec=mp3,aac\u0026VideoBitrate=6808000\u0026AudioBitrate=192000\u0026AudioStreamIndex=1\u0026TranscodingMaxAudioChannels=2\u0026SegmentContainer=ts\u0026MinSegments=1\u0026BreakOnNonKeyFrames=True\u0026SubtitleStreamIndexes=-1\u0026ManifestSubtitles=vtt\u0026h264-profile=high,main,baseline,constrainedbaseline,high10\u0026h264-level=62\u0026hevc-codectag=hvc1,hev1,hevc,hdmv`
-
-	// PlaybackCommonPayload 请求 PlaybackInfo 的通用请求体
-	PlaybackCommonPayload = `{"DeviceProfile":{"MaxStaticBitrate":140000000,"MaxStreamingBitrate":140000000,"MusicStreamingTranscodingBitrate":192000,"DirectPlayProfiles":[{"Container":"mp4,m4v","Type":"Video","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"mkv","Type":"Video","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"flv","Type":"Video","VideoCodec":"h264","AudioCodec":"aac,mp3"},{"Container":"3gp","Type":"Video","VideoCodec":"","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"mov","Type":"Video","VideoCodec":"h264","AudioCodec":"mp3,aac,opus,flac,vorbis"},{"Container":"opus","Type":"Audio"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3"},{"Container":"mp2,mp3","Type":"Audio","AudioCodec":"mp2"},{"Container":"m4a","AudioCodec":"aac","Type":"Audio"},{"Container":"mp4","AudioCodec":"aac","Type":"Audio"},{"Container":"flac","Type":"Audio"},{"Container":"webma,webm","Type":"Audio"},{"Container":"wav","Type":"Audio","AudioCodec":"PCM_S16LE,PCM_S24LE"},{"Container":"ogg","Type":"Audio"},{"Container":"webm","Type":"Video","AudioCodec":"vorbis,opus","VideoCodec":"av1,VP8,VP9"}],"TranscodingProfiles":[{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"2","MinSegments":"1","BreakOnNonKeyFrames":true},{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"opus","Type":"Audio","AudioCodec":"opus","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"wav","Type":"Audio","AudioCodec":"wav","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"opus","Type":"Audio","AudioCodec":"opus","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp3","Type":"Audio","AudioCodec":"mp3","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"aac","Type":"Audio","AudioCodec":"aac","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"wav","Type":"Audio","AudioCodec":"wav","Context":"Static","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mkv","Type":"Video","AudioCodec":"mp3,aac,opus,flac,vorbis","VideoCodec":"h264,h265,hevc,av1,vp8,vp9","Context":"Static","MaxAudioChannels":"2","CopyTimestamps":true},{"Container":"ts","Type":"Video","AudioCodec":"mp3,aac","VideoCodec":"h264,h265,hevc,av1","Context":"Streaming","Protocol":"hls","MaxAudioChannels":"2","MinSegments":"1","BreakOnNonKeyFrames":true,"ManifestSubtitles":"vtt"},{"Container":"webm","Type":"Video","AudioCodec":"vorbis","VideoCodec":"vpx","Context":"Streaming","Protocol":"http","MaxAudioChannels":"2"},{"Container":"mp4","Type":"Video","AudioCodec":"mp3,aac,opus,flac,vorbis","VideoCodec":"h264","Context":"Static","Protocol":"http"}],"ContainerProfiles":[],"CodecProfiles":[{"Type":"VideoAudio","Codec":"aac","Conditions":[{"Condition":"Equals","Property":"IsSecondaryAudio","Value":"false","IsRequired":"false"}]},{"Type":"VideoAudio","Conditions":[{"Condition":"Equals","Property":"IsSecondaryAudio","Value":"false","IsRequired":"false"}]},{"Type":"Video","Codec":"h264","Conditions":[{"Condition":"EqualsAny","Property":"VideoProfile","Value":"high|main|baseline|constrained baseline|high 10","IsRequired":false},{"Condition":"LessThanEqual","Property":"VideoLevel","Value":"62","IsRequired":false}]},{"Type":"Video","Codec":"hevc","Conditions":[{"Condition":"EqualsAny","Property":"VideoCodecTag","Value":"hvc1|hev1|hevc|hdmv","IsRequired":false}]}],"SubtitleProfiles":[{"Format":"vtt","Method":"Hls"},{"Format":"eia_608","Method":"VideoSideData","Protocol":"hls"},{"Format":"eia_708","Method":"VideoSideData","Protocol":"hls"},{"Format":"vtt","Method":"External"},{"Format":"ass","Method":"External"},{"Format":"ssa","Method":"External"}],"ResponseProfiles":[{"Type":"Video","Container":"m4v","MimeType":"video/mp4"}]}}`
-)
+// PlaybackCacheSpace PlaybackInfo 的缓存空间 key
+const PlaybackCacheSpace = "PlaybackInfo"
 
 var (
 
@@ -65,10 +54,12 @@ func TransferPlaybackInfo(c *gin.Context) {
 		return
 	}
 
-	// 2 请求 emby 源服务器的 PlaybackInfo 信息
+	// 2 使用客户端对应的设备配置, 请求 emby 源服务器的 PlaybackInfo 信息
+	profile := MatchDeviceProfile(c.Request)
+	logs.Info("使用设备配置: %s", profile.Name)
 	c.Request.Header.Del("Accept-Encoding")
 	originRequestBody := c.Request.Body
-	c.Request.Body = io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))
+	c.Request.Body = playbackPayload(profile)
 	res, respHeader := RawFetch(itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
 	if res.Code != http.StatusOK {
 		checkErr(c, errors.New(res.Msg))
@@ -88,6 +79,10 @@ func TransferPlaybackInfo(c *gin.Context) {
 		return
 	}
 
+	// 转码资源的 master 地址使用客户端真实的播放参数
+	playSessionId, _ := resJson.Attr("PlaySessionId").String()
+	masterParams := MasterParams{DeviceId: deviceId(c.Request), PlaySessionId: playSessionId, ApiKey: itemInfo.ApiKey}
+
 	var haveReturned = errors.New("have returned")
 	resChans := make([]chan []*jsons.Item, 0, mediaSources.Len())
 	err = mediaSources.RangeArr(func(_ int, source *jsons.Item) error {
@@ -116,9 +111,9 @@ func TransferPlaybackInfo(c *gin.Context) {
 		// 优先进行 URL 解码，防止因编码问题导致路径匹配失败
 		embyPathUnescaped := urls.Unescape(embyPath)
 
-		if config.C.Emby.LocalMediaRoot != "" {
+		if config.C().Emby.LocalMediaRoot != "" {
 			// 支持多路径配置，使用逗号或分号分隔
-			localRoots := strings.FieldsFunc(config.C.Emby.LocalMediaRoot, func(r rune) bool {
+			localRoots := strings.FieldsFunc(config.C().Emby.LocalMediaRoot, func(r rune) bool {
 				return r == ',' || r == ';'
 			})
 			for _, root := range localRoots {
@@ -142,6 +137,12 @@ func TransferPlaybackInfo(c *gin.Context) {
 		)
 		source.Put("DirectStreamUrl", jsons.FromValue(newUrl))
 
+		// 客户端选择了转码资源中不存在的音轨, 回退到原画直链
+		if msInfo.Transcode && needAudioFallback(source, c.Query("AudioStreamIndex")) {
+			logs.Info("转码资源不包含音轨 [%s], 回退到原画播放", c.Query("AudioStreamIndex"))
+			useOriginalStream(source, itemInfo)
+		}
+
 		// path 解码 (上面已经解码过了，这里更新回去，或者保持解码后的状态)
 		source.Attr("Path").Set(embyPath)
 
@@ -150,6 +151,9 @@ func TransferPlaybackInfo(c *gin.Context) {
 		source.DelKey("TranscodingSubProtocol")
 		source.DelKey("TranscodingContainer")
 
+		// 注入网盘中与视频同名的外挂字幕, 需要在获取转码资源前完成, 转码资源会复制这些字幕
+		addSiblingSubtitles(source, itemInfo.ApiKey)
+
 		// 如果是远程资源, 不获取转码地址
 		ir, _ := source.Attr("IsRemote").Bool()
 		if ir {
@@ -157,12 +161,12 @@ func TransferPlaybackInfo(c *gin.Context) {
 		}
 
 		// 添加转码 MediaSource 获取
-		cfg := config.C.VideoPreview
+		cfg := config.C().VideoPreview
 		if !msInfo.Empty || !cfg.Enable || !cfg.ContainerValid(source.Attr("Container").Val().(string)) {
 			return nil
 		}
 		resChan := make(chan []*jsons.Item, 1)
-		go findVideoPreviewInfos(source, itemInfo.ApiKey, resChan)
+		go findVideoPreviewInfos(source, profile, masterParams, resChan)
 		resChans = append(resChans, resChan)
 		return nil
 	})
@@ -187,6 +191,11 @@ func TransferPlaybackInfo(c *gin.Context) {
 		}
 	}
 
+	// 注入片头片尾跳过标记
+	if err := injectPlaybackMarkers(itemInfo, mediaSources); err != nil {
+		logs.Warn("注入片头片尾标记失败: %v", err)
+	}
+
 	https.CloneHeader(c.Writer, respHeader)
 	jsons.OkResp(c.Writer, resJson)
 }
@@ -200,7 +209,7 @@ func handleSpecialPlayback(c *gin.Context, itemInfo ItemInfo) bool {
 
 	c.Request.Header.Del("Accept-Encoding")
 	originRequestBody := c.Request.Body
-	c.Request.Body = io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))
+	c.Request.Body = playbackPayload(MatchDeviceProfile(c.Request))
 	res, _ := RawFetch(itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
 	if res.Code != http.StatusOK {
 		return false
@@ -219,7 +228,7 @@ func handleSpecialPlayback(c *gin.Context, itemInfo ItemInfo) bool {
 
 		// 本地媒体
 		path, _ := value.Attr("Path").String()
-		if strings.HasPrefix(path, config.C.Emby.LocalMediaRoot) {
+		if strings.HasPrefix(path, config.C().Emby.LocalMediaRoot) {
 			logs.Info("本地媒体: %s, 回源处理", path)
 			flag = true
 		}
@@ -253,7 +262,7 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 	}
 	reqId := itemInfo.MsInfo.RawId
 
-	if !config.C.Cache.Enable {
+	if !config.C().Cache.Enable {
 		// 未开启缓存功能
 		return false
 	}
@@ -335,8 +344,14 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 		mediaSources.RangeArr(func(index int, value *jsons.Item) error {
 			cacheId := value.Attr("Id").Val().(string)
 			if err == nil && cacheId == reqId {
-				newMediaSources.Append(value)
 				updateCache(spaceCache, jsonBody, index)
+				// 客户端选择了转码资源中不存在的音轨, 回退到原画直链, 不影响缓存
+				if itemInfo.MsInfo.Transcode && needAudioFallback(value, c.Query("AudioStreamIndex")) {
+					logs.Info("转码资源不包含音轨 [%s], 回退到原画播放", c.Query("AudioStreamIndex"))
+					value = jsons.FromValue(value.Struct())
+					useOriginalStream(value, itemInfo)
+				}
+				newMediaSources.Append(value)
 				return jsons.ErrBreakRange
 			}
 			return nil
@@ -377,7 +392,7 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 	}
 
 	// 如果是单个查询, 则手动请求一次全量
-	if _, err := fetchFullPlaybackInfo(itemInfo); err != nil {
+	if _, err := fetchFullPlaybackInfo(itemInfo, c.Request.Header); err != nil {
 		logs.Error("更新缓存空间 PlaybackInfo 信息异常: %v", err)
 		c.String(http.StatusInternalServerError, "查无缓存, 请稍后尝试重新播放")
 		return true
@@ -406,11 +421,13 @@ func LoadCacheItems(c *gin.Context) {
 	}
 
 	defer func() {
+		// 注入片头片尾跳过标记
+		injectItemMarkers(resJson)
 		jsons.OkResp(c.Writer, resJson)
 	}()
 
 	// 未开启转码资源获取功能
-	if !config.C.VideoPreview.Enable {
+	if !config.C().VideoPreview.Enable {
 		return
 	}
 
@@ -455,7 +472,7 @@ func LoadCacheItems(c *gin.Context) {
 	}
 
 	// 缓存空间中没有当前 Item 的 PlaybackInfo 数据, 手动请求
-	bodyJson, err := fetchFullPlaybackInfo(itemInfo)
+	bodyJson, err := fetchFullPlaybackInfo(itemInfo, c.Request.Header)
 	if err != nil {
 		logs.Warn("更新 Items 缓存异常: %v", err)
 		return
@@ -464,7 +481,9 @@ func LoadCacheItems(c *gin.Context) {
 }
 
 // fetchFullPlaybackInfo 请求全量的 PlaybackInfo 信息
-func fetchFullPlaybackInfo(itemInfo ItemInfo) (*jsons.Item, error) {
+//
+// 会透传 clientHeader 中的客户端标识, 保证使用与客户端一致的设备配置
+func fetchFullPlaybackInfo(itemInfo ItemInfo, clientHeader http.Header) (*jsons.Item, error) {
 	u, err := url.Parse(config.ServerInternalRequestHost() + itemInfo.PlaybackInfoUri)
 	if err != nil {
 		return nil, fmt.Errorf("PlaybackInfo 地址异常: %v, uri: %s", err, itemInfo.PlaybackInfoUri)
@@ -473,8 +492,9 @@ func fetchFullPlaybackInfo(itemInfo ItemInfo) (*jsons.Item, error) {
 	q.Del("MediaSourceId")
 	u.RawQuery = q.Encode()
 
-	reqBody := io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))
 	header := make(http.Header)
+	copyClientHeaders(header, clientHeader)
+	reqBody := playbackPayload(MatchDeviceProfile(&http.Request{Header: header, URL: u}))
 	header.Set("Content-Type", "text/plain")
 	if itemInfo.ApiKeyType == Header {
 		header.Set(itemInfo.ApiKeyName, itemInfo.ApiKey)
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/util/events"
 	"github.com/syscc/Emby-Go/internal/util/https"
 	"github.com/syscc/Emby-Go/internal/util/jsons"
 	"github.com/syscc/Emby-Go/internal/util/logs"
@@ -17,6 +19,8 @@ import (
 )
 
 // PlayingStoppedHelper 拦截停止播放接口, 然后手动请求一次 Progress 接口记录进度
+//
+// 停止时上报的进度无效或属于可疑回跳时, 使用会话历史中最后一次有效的进度
 func PlayingStoppedHelper(c *gin.Context) {
 	// 取出原始请求体信息
 	bodyBytes, newBody, err := https.ExtractReqBody(c.Request.Body)
@@ -35,29 +39,41 @@ func PlayingStoppedHelper(c *gin.Context) {
 	// 提取 api apiKey
 	kType, kName, apiKey := getApiKey(c)
 
-	// 至少播放 5 分钟才记录进度
-	positionTicks, ok := bodyJson.Attr("PositionTicks").Int64()
-	var minPos int64 = 5 * 60 * 10_000_000
-	if !ok || positionTicks < minPos {
+	itemId := playingItemId(bodyJson)
+	if strs.AnyEmpty(itemId) {
 		return
 	}
+	key := progressKey(c, itemId, apiKey)
+	tracker := progressTracker()
 
-	// 发送辅助请求记录播放进度
-	itemId, _ := bodyJson.Attr("ItemId").String()
-	if itemIdNum, ok := bodyJson.Attr("ItemId").Int(); ok {
-		itemId = strconv.Itoa(itemIdNum)
+	positionTicks, ok := bodyJson.Attr("PositionTicks").Int64()
+	positionTicks, restored := tracker.Reconcile(key, positionTicks, ok)
+	if restored {
+		logs.Warn("停止播放时上报的进度异常, 使用历史进度: %d, item: %s", positionTicks, itemId)
 	}
-	if strs.AnyEmpty(itemId) {
+
+	// 播放时长达到阈值才记录进度
+	threshold := progressThreshold(key, ItemInfo{Id: itemId, ApiKeyType: kType, ApiKeyName: kName, ApiKey: apiKey})
+	if positionTicks < int64(threshold.MinStopped)*TicksPerSecond {
 		return
 	}
+
+	// 发送辅助请求记录播放进度
 	body := jsons.NewEmptyObj()
 	body.Put("ItemId", jsons.FromValue(itemId))
 	body.Put("PlaySessionId", jsons.FromValue(randoms.RandomHex(32)))
-	body.Put("PositionTicks", jsons.FromValue(bodyJson.Attr("PositionTicks").Val()))
+	body.Put("PositionTicks", jsons.FromValue(positionTicks))
 	go sendPlayingProgress(kType, kName, apiKey, body)
+
+	if config.C().Emby.WatchSync {
+		itemInfo := ItemInfo{Id: itemId, ApiKeyType: kType, ApiKeyName: kName, ApiKey: apiKey}
+		go emitPlaybackStopped(itemInfo, deviceId(c.Request), positionTicks)
+	}
 }
 
-// PlayingProgressHelper 拦截 Progress 请求, 如果进度报告为 0, 认为是无效请求
+// PlayingProgressHelper 拦截 Progress 请求, 过滤无效的进度报告
+//
+// 低于阈值的进度和未经确认的可疑回跳都会被丢弃, 不转发给源服务器
 func PlayingProgressHelper(c *gin.Context) {
 	// 取出原始请求体信息
 	bodyBytes, newBody, err := https.ExtractReqBody(c.Request.Body)
@@ -70,13 +86,100 @@ func PlayingProgressHelper(c *gin.Context) {
 		return
 	}
 
-	if pt, ok := bodyJson.Attr("PositionTicks").Int64(); ok && pt <= 10_000_000 {
+	pt, ok := bodyJson.Attr("PositionTicks").Int64()
+	itemId := playingItemId(bodyJson)
+	if !ok || itemId == "" {
+		ProxyOrigin(c)
+		return
+	}
+
+	kType, kName, apiKey := getApiKey(c)
+	key := progressKey(c, itemId, apiKey)
+	threshold := progressThreshold(key, ItemInfo{Id: itemId, ApiKeyType: kType, ApiKeyName: kName, ApiKey: apiKey})
+	if pt <= int64(threshold.MinReport)*TicksPerSecond {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if !progressTracker().Report(key, pt) {
+		logs.Warn("忽略可疑的进度回跳: %d, item: %s", pt, itemId)
 		c.Status(http.StatusNoContent)
 		return
 	}
 	ProxyOrigin(c)
 }
 
+// playingItemId 从播放上报的请求体中提取 item id
+func playingItemId(bodyJson *jsons.Item) string {
+	if itemIdNum, ok := bodyJson.Attr("ItemId").Int(); ok {
+		return strconv.Itoa(itemIdNum)
+	}
+	itemId, _ := bodyJson.Attr("ItemId").String()
+	return itemId
+}
+
+// progressKey 生成播放会话的标识
+//
+// 使用设备 id 和 item id 而不是 PlaySessionId, 客户端崩溃重启后仍能找到之前的进度
+func progressKey(c *gin.Context, itemId, apiKey string) string {
+	device := deviceId(c.Request)
+	if device == "" {
+		device = apiKey
+	}
+	return device + ":" + itemId
+}
+
+// progressThreshold 获取 item 类型对应的进度阈值
+//
+// 只有配置了类型阈值时才会查询 item 类型, 查询结果缓存在会话中
+func progressThreshold(key string, itemInfo ItemInfo) *config.ProgressThreshold {
+	pc := config.C().Progress
+	if !pc.TypeSpecific() {
+		return pc.Threshold(config.DefaultProgressType)
+	}
+	itemType := progressTracker().ItemType(key, func() string {
+		item, err := fetchItem(itemInfo)
+		if err != nil {
+			logs.Warn("查询 item 类型失败: %v", err)
+			return ""
+		}
+		itemType, _ := item.Attr("Type").String()
+		return itemType
+	})
+	return pc.Threshold(itemType)
+}
+
+// emitPlaybackStopped 查询播放用户并向管理进程上报停止播放事件
+func emitPlaybackStopped(itemInfo ItemInfo, device string, positionTicks int64) {
+	if device == "" {
+		return
+	}
+	q := url.Values{"DeviceId": {device}}
+	header := make(http.Header)
+	if itemInfo.ApiKeyType == Header {
+		header.Set(itemInfo.ApiKeyName, itemInfo.ApiKey)
+	} else {
+		q.Set(itemInfo.ApiKeyName, itemInfo.ApiKey)
+	}
+	res, _ := Fetch("/Sessions?"+q.Encode(), http.MethodGet, header, nil)
+	if res.Code != http.StatusOK {
+		logs.Warn("查询播放会话失败: %s", res.Msg)
+		return
+	}
+	userName, ok := res.Data.Idx(0).Attr("UserName").String()
+	if !ok || userName == "" {
+		return
+	}
+
+	err := events.Emit(events.TypePlaybackStopped, events.PlaybackStopped{
+		UserName:      userName,
+		ItemId:        itemInfo.Id,
+		PositionTicks: positionTicks,
+	})
+	if err != nil {
+		logs.Warn("上报停止播放事件失败: %v", err)
+	}
+}
+
 // sendPlayingProgress 发送辅助播放进度请求
 func sendPlayingProgress(kType ApiKeyType, kName, apiKey string, body *jsons.Item) {
 	if body == nil {
@@ -103,11 +206,11 @@ func sendPlayingProgress(kType ApiKeyType, kName, apiKey string, body *jsons.Ite
 	}
 
 	logs.Tip("开始发送辅助 Progress 进度记录, 内容: %v", body)
-	if err := inner(config.C.Emby.Host + "/emby/Sessions/Playing/Progress"); err != nil {
+	if err := inner(config.C().Emby.Host + "/emby/Sessions/Playing/Progress"); err != nil {
 		logs.Warn("辅助发送 Progress 进度记录失败: %v", err)
 		return
 	}
-	if err := inner(config.C.Emby.Host + "/emby/Sessions/Playing/Stopped"); err != nil {
+	if err := inner(config.C().Emby.Host + "/emby/Sessions/Playing/Stopped"); err != nil {
 		logs.Warn("辅助发送 Progress 进度记录失败: %v", err)
 		return
 	}
@@ -0,0 +1,189 @@
+package emby
+
+import (
+	"sync"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/config"
+)
+
+const (
+	// progressSessionTTL 播放会话超过该时间没有上报进度时被清理
+	progressSessionTTL = 12 * time.Hour
+
+	// progressSweepInterval 清理过期播放会话的最小间隔
+	progressSweepInterval = 10 * time.Minute
+
+	// progressJumpTolerance 确认回跳时允许的进度误差
+	progressJumpTolerance = 30 * time.Second
+)
+
+// ProgressRecord 一次被接受的进度上报
+type ProgressRecord struct {
+	PositionTicks int64     // 播放位置
+	Time          time.Time // 上报时间
+}
+
+// progressSession 一个播放会话的进度历史
+type progressSession struct {
+	itemType string           // item 类型, 首次需要时查询
+	history  []ProgressRecord // 最近被接受的进度, 按上报顺序排列
+	pending  *ProgressRecord  // 等待确认的可疑回跳
+	updated  time.Time        // 最后一次上报的时间
+}
+
+// ProgressTracker 维护每个播放会话的进度历史, 用于识别可疑的进度回跳
+//
+// 客户端崩溃或重定向失败后, 可以从历史中恢复最后一次有效的播放位置
+type ProgressTracker struct {
+	mu        sync.Mutex
+	sessions  map[string]*progressSession
+	size      int
+	jump      int64
+	lastSweep time.Time
+
+	// Now 获取当前时间, 测试时可替换
+	Now func() time.Time
+}
+
+// NewProgressTracker 创建进度追踪器
+//
+// size 为每个会话保留的历史个数, 回退超过 backwardJump 的进度需要后续上报确认, 为 0 时不检测回跳
+func NewProgressTracker(size int, backwardJump time.Duration) *ProgressTracker {
+	if size <= 0 {
+		size = 1
+	}
+	return &ProgressTracker{
+		sessions: make(map[string]*progressSession),
+		size:     size,
+		jump:     durationTicks(backwardJump),
+		Now:      time.Now,
+	}
+}
+
+// progressTracker 全局进度追踪器, 首次使用时根据配置初始化
+var progressTracker = sync.OnceValue(func() *ProgressTracker {
+	pc := config.C().Progress
+	return NewProgressTracker(pc.HistorySize, time.Duration(pc.BackwardJump)*time.Second)
+})
+
+// Report 记录一次进度上报, 返回该上报是否有效
+//
+// 相比最后一次有效进度回退超过阈值的上报会被暂存, 只有后续上报从回跳位置继续播放时才会生效,
+// 以此过滤遥控器误触等偶发的进度跳变
+func (pt *ProgressTracker) Report(key string, positionTicks int64) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := pt.Now()
+	pt.sweep(now)
+	s := pt.session(key, now)
+
+	if last, ok := s.last(); ok && pt.jump > 0 && last.PositionTicks-positionTicks > pt.jump {
+		if p := s.pending; p == nil || !continuesFrom(*p, positionTicks, now) {
+			s.pending = &ProgressRecord{PositionTicks: positionTicks, Time: now}
+			return false
+		}
+	}
+
+	s.pending = nil
+	s.history = append(s.history, ProgressRecord{PositionTicks: positionTicks, Time: now})
+	if len(s.history) > pt.size {
+		s.history = s.history[len(s.history)-pt.size:]
+	}
+	return true
+}
+
+// Reconcile 校验停止播放时上报的进度
+//
+// 上报的进度无效 (ok 为 false) 或属于可疑回跳时, 返回会话中最后一次有效的进度,
+// 第二个返回值表示返回的进度是否来自历史记录
+func (pt *ProgressTracker) Reconcile(key string, positionTicks int64, ok bool) (int64, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	s, exist := pt.sessions[key]
+	if !exist {
+		return positionTicks, false
+	}
+	last, exist := s.last()
+	if !exist {
+		return positionTicks, false
+	}
+	if !ok || positionTicks <= 0 || (pt.jump > 0 && last.PositionTicks-positionTicks > pt.jump) {
+		return last.PositionTicks, true
+	}
+	return positionTicks, false
+}
+
+// History 返回会话最近被接受的进度记录
+func (pt *ProgressTracker) History(key string) []ProgressRecord {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if s, ok := pt.sessions[key]; ok {
+		return append([]ProgressRecord(nil), s.history...)
+	}
+	return nil
+}
+
+// ItemType 返回会话记录的 item 类型, 未记录时调用 fetch 查询并缓存
+func (pt *ProgressTracker) ItemType(key string, fetch func() string) string {
+	pt.mu.Lock()
+	if s, ok := pt.sessions[key]; ok && s.itemType != "" {
+		pt.mu.Unlock()
+		return s.itemType
+	}
+	pt.mu.Unlock()
+
+	itemType := fetch()
+	if itemType == "" {
+		return ""
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.session(key, pt.Now()).itemType = itemType
+	return itemType
+}
+
+// session 获取或创建会话, 需要在持有锁时调用
+func (pt *ProgressTracker) session(key string, now time.Time) *progressSession {
+	s, ok := pt.sessions[key]
+	if !ok {
+		s = new(progressSession)
+		pt.sessions[key] = s
+	}
+	s.updated = now
+	return s
+}
+
+// sweep 清理过期的会话, 需要在持有锁时调用
+func (pt *ProgressTracker) sweep(now time.Time) {
+	if now.Sub(pt.lastSweep) < progressSweepInterval {
+		return
+	}
+	pt.lastSweep = now
+	for key, s := range pt.sessions {
+		if now.Sub(s.updated) > progressSessionTTL {
+			delete(pt.sessions, key)
+		}
+	}
+}
+
+// last 返回最后一次有效的进度
+func (s *progressSession) last() (ProgressRecord, bool) {
+	if len(s.history) == 0 {
+		return ProgressRecord{}, false
+	}
+	return s.history[len(s.history)-1], true
+}
+
+// continuesFrom 判断 positionTicks 是否是从暂存的回跳位置继续播放的结果
+func continuesFrom(p ProgressRecord, positionTicks int64, now time.Time) bool {
+	maxTicks := p.PositionTicks + durationTicks(now.Sub(p.Time)+progressJumpTolerance)
+	return positionTicks >= p.PositionTicks && positionTicks <= maxTicks
+}
+
+// durationTicks 将时长转换为 emby 时间刻度
+func durationTicks(d time.Duration) int64 {
+	return int64(d / 100)
+}
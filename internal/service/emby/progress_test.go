@@ -0,0 +1,91 @@
+package emby_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/emby"
+)
+
+// ticks 将秒转换为 emby 时间刻度
+func ticks(sec int64) int64 {
+	return sec * emby.TicksPerSecond
+}
+
+func TestProgressTrackerBackwardJump(t *testing.T) {
+	now := time.Unix(0, 0)
+	pt := emby.NewProgressTracker(5, 5*time.Minute)
+	pt.Now = func() time.Time { return now }
+	const key = "device:1"
+
+	advance := func(d time.Duration) { now = now.Add(d) }
+
+	if !pt.Report(key, ticks(1800)) {
+		t.Fatal("首次上报应当被接受")
+	}
+	advance(10 * time.Second)
+	if pt.Report(key, ticks(5)) {
+		t.Fatal("大幅回跳应当被暂存")
+	}
+	advance(10 * time.Second)
+	if !pt.Report(key, ticks(1820)) {
+		t.Fatal("回到原进度的上报应当被接受")
+	}
+
+	// 用户主动回退, 后续上报从回跳位置继续播放
+	advance(10 * time.Second)
+	if pt.Report(key, ticks(60)) {
+		t.Fatal("大幅回跳应当被暂存")
+	}
+	advance(10 * time.Second)
+	if !pt.Report(key, ticks(70)) {
+		t.Fatal("从回跳位置继续播放的上报应当被接受")
+	}
+
+	history := pt.History(key)
+	if len(history) != 3 || history[len(history)-1].PositionTicks != ticks(70) {
+		t.Fatalf("history = %v", history)
+	}
+}
+
+func TestProgressTrackerHistorySize(t *testing.T) {
+	pt := emby.NewProgressTracker(3, 0)
+	for i := range int64(10) {
+		pt.Report("k", ticks(i*10))
+	}
+	history := pt.History("k")
+	if len(history) != 3 || history[0].PositionTicks != ticks(70) {
+		t.Fatalf("history = %v", history)
+	}
+}
+
+func TestProgressTrackerReconcile(t *testing.T) {
+	pt := emby.NewProgressTracker(5, 5*time.Minute)
+	pt.Report("k", ticks(2400))
+
+	tests := []struct {
+		name         string
+		pos          int64
+		ok           bool
+		want         int64
+		wantRestored bool
+	}{
+		{"正常停止", ticks(2410), true, ticks(2410), false},
+		{"缺少进度", 0, false, ticks(2400), true},
+		{"进度归零", 0, true, ticks(2400), true},
+		{"可疑回跳", ticks(10), true, ticks(2400), true},
+		{"小幅回退", ticks(2300), true, ticks(2300), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, restored := pt.Reconcile("k", tt.pos, tt.ok)
+			if got != tt.want || restored != tt.wantRestored {
+				t.Errorf("Reconcile() = %d, %v, want %d, %v", got, restored, tt.want, tt.wantRestored)
+			}
+		})
+	}
+
+	if got, restored := pt.Reconcile("unknown", ticks(10), true); got != ticks(10) || restored {
+		t.Errorf("没有历史的会话不应恢复进度: %d, %v", got, restored)
+	}
+}
@@ -1,9 +1,7 @@
 package emby
 
 import (
-	"bytes"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"slices"
@@ -27,7 +25,7 @@ import (
 
 // isLocalMedia 检查路径是否为本地媒体路径
 func isLocalMedia(embyPath string) bool {
-	roots := strings.FieldsFunc(config.C.Emby.LocalMediaRoot, func(r rune) bool {
+	roots := strings.FieldsFunc(config.C().Emby.LocalMediaRoot, func(r rune) bool {
 		return r == ',' || r == ';'
 	})
 	for _, root := range roots {
@@ -113,10 +111,14 @@ func Redirect2OpenlistLink(c *gin.Context) {
 	msInfo := itemInfo.MsInfo
 	useTranscode := !msInfo.Empty && msInfo.Transcode
 	if useTranscode && msInfo.OpenlistPath != "" {
-		u, _ := url.Parse(strings.ReplaceAll(MasterM3U8UrlTemplate, "${itemId}", itemInfo.Id))
+		u := MasterM3U8Url(itemInfo.Id, MatchDeviceProfile(c.Request), MasterParams{
+			DeviceId:      deviceId(c.Request),
+			MediaSourceId: c.Query("MediaSourceId"),
+			PlaySessionId: c.Query("PlaySessionId"),
+			ApiKey:        itemInfo.ApiKey,
+		})
 		q := u.Query()
 		q.Set("template_id", itemInfo.MsInfo.TemplateId)
-		q.Set(QueryApiKeyName, itemInfo.ApiKey)
 		q.Set("openlist_path", itemInfo.MsInfo.OpenlistPath)
 		u.RawQuery = q.Encode()
 		logs.Success("重定向 playlist: %s", u.String())
@@ -132,12 +134,12 @@ func Redirect2OpenlistLink(c *gin.Context) {
 
 	// 4 如果是远程地址 (strm), 重定向处理
 	if urls.IsRemote(embyPath) {
-		finalPath := config.C.Emby.Strm.MapPath(embyPath)
+		finalPath := config.C().Emby.Strm.MapPath(embyPath)
 		finalPath = getFinalRedirectLink(finalPath, c.Request.Header.Clone())
 
 		// 解析配置的缓存时间
-		duration, err := time.ParseDuration(config.C.Emby.DlCacheTime)
-		durationStr := config.C.Emby.DlCacheTime
+		duration, err := time.ParseDuration(config.C().Emby.DlCacheTime)
+		durationStr := config.C().Emby.DlCacheTime
 		// 处理天单位 (d)
 		if err != nil && strings.HasSuffix(durationStr, "d") {
 			var days int
@@ -162,8 +164,9 @@ func Redirect2OpenlistLink(c *gin.Context) {
 		c.Redirect(http.StatusFound, finalPath)
 
 		// 异步发送一个播放 Playback 请求, 触发 emby 解析 strm 视频格式
+		payload := playbackPayload(MatchDeviceProfile(c.Request))
 		go func() {
-			originUrl, err := url.Parse(config.C.Emby.Host + itemInfo.PlaybackInfoUri)
+			originUrl, err := url.Parse(config.C().Emby.Host + itemInfo.PlaybackInfoUri)
 			if err != nil {
 				return
 			}
@@ -171,7 +174,7 @@ func Redirect2OpenlistLink(c *gin.Context) {
 			q.Set("IsPlayback", "true")
 			q.Set("AutoOpenLiveStream", "true")
 			originUrl.RawQuery = q.Encode()
-			resp, err := https.Post(originUrl.String()).Body(io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))).Do()
+			resp, err := https.Post(originUrl.String()).Body(payload).Do()
 			if err != nil {
 				return
 			}
@@ -182,7 +185,7 @@ func Redirect2OpenlistLink(c *gin.Context) {
 	}
 
 	// 5 如果是本地地址, 回源处理
-	if strings.HasPrefix(embyPath, config.C.Emby.LocalMediaRoot) {
+	if strings.HasPrefix(embyPath, config.C().Emby.LocalMediaRoot) {
 		logs.Success("本地媒体直连(Direct): %s", embyPath)
 		newUri := strings.Replace(c.Request.RequestURI, "stream", "original", 1)
 		c.Redirect(http.StatusTemporaryRedirect, newUri)
@@ -211,11 +214,11 @@ func Redirect2OpenlistLink(c *gin.Context) {
 
 		// 处理直链
 		if !fi.UseTranscode {
-			res.Data.Url = config.C.Emby.Strm.MapPath(res.Data.Url)
+			res.Data.Url = config.C().Emby.Strm.MapPath(res.Data.Url)
 
 			// 解析配置的缓存时间
-			duration, err := time.ParseDuration(config.C.Emby.DlCacheTime)
-			durationStr := config.C.Emby.DlCacheTime
+			duration, err := time.ParseDuration(config.C().Emby.DlCacheTime)
+			durationStr := config.C().Emby.DlCacheTime
 			// 处理天单位 (d)
 			if err != nil && strings.HasSuffix(durationStr, "d") {
 				var days int
@@ -306,7 +309,7 @@ func checkErr(c *gin.Context, err error) bool {
 	c.Header(cache.HeaderKeyExpired, "-1")
 
 	// 采用拒绝策略, 直接返回错误
-	if config.C.Emby.ProxyErrorStrategy == config.PeStrategyReject {
+	if config.C().Emby.ProxyErrorStrategy == config.PeStrategyReject {
 		logs.Error("代理接口失败: %v", err)
 		c.String(http.StatusInternalServerError, "代理接口失败, 请检查日志")
 		return true
@@ -324,7 +327,7 @@ func checkErr(c *gin.Context, err error) bool {
 // 请求中途出现任何失败都会返回原始链接
 func getFinalRedirectLink(originLink string, header http.Header) string {
 
-	if !config.C.Emby.Strm.InternalRedirectEnable {
+	if !config.C().Emby.Strm.InternalRedirectEnable {
 		logs.Info("internal-redirect-enable 未启用, 使用原始链接")
 		return originLink
 	}
@@ -370,7 +373,7 @@ func isCacheIgnored(u string) bool {
 	domainLower := strings.ToLower(domain)
 
 	matched := false
-	for _, pattern := range config.C.Emby.DlCacheIgnore {
+	for _, pattern := range config.C().Emby.DlCacheIgnore {
 		p := strings.TrimSpace(pattern)
 		if p == "" {
 			continue
@@ -393,7 +396,7 @@ func isCacheIgnored(u string) bool {
 	// 默认为 blacklist 模式
 	// blacklist: 命中规则 -> 忽略(true); 未命中 -> 不忽略(false)
 	// whitelist: 命中规则 -> 不忽略(false); 未命中 -> 忽略(true)
-	if config.C.Emby.DlCacheIgnoreMode == "whitelist" {
+	if config.C().Emby.DlCacheIgnoreMode == "whitelist" {
 		return !matched
 	}
 	return matched
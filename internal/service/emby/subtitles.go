@@ -1,15 +1,34 @@
 package emby
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/constant"
+	"github.com/syscc/Emby-Go/internal/service/lib/subtitle"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+	"github.com/syscc/Emby-Go/internal/util/https"
+	"github.com/syscc/Emby-Go/internal/util/logs"
 	"github.com/syscc/Emby-Go/internal/util/strs"
 	"github.com/syscc/Emby-Go/internal/web/cache"
+
 	"github.com/gin-gonic/gin"
 )
 
+// MaxSubtitleSize 转换字幕时允许读取的最大字幕大小
+const MaxSubtitleSize = 16 << 20
+
+// videoSubtitlesReg 校验 emby 外挂字幕地址, 避免通过转换接口请求任意 emby 接口
+var videoSubtitlesReg = regexp.MustCompile(constant.Reg_VideoSubtitles)
+
 // ProxySubtitles 字幕代理, 过期时间设置为 30 天
 func ProxySubtitles(c *gin.Context) {
 	if c == nil {
@@ -31,3 +50,160 @@ func ProxySubtitles(c *gin.Context) {
 	c.Header(cache.HeaderKeyExpired, cache.Duration(time.Hour*24*30))
 	ProxyOrigin(c)
 }
+
+// ProxyVtt 将外挂字幕转换为 WebVTT 后返回
+//
+// 字幕来源通过 query 参数指定, remote: emby 外挂字幕地址, openlist_path: openlist 中的字幕文件;
+// offset 参数 (毫秒) 会覆盖配置中的时间轴偏移
+func ProxyVtt(c *gin.Context) {
+	remote := c.Query("remote")
+	openlistPath := openlist.PathDecode(c.Query("openlist_path"))
+	apiKey := c.Query(QueryApiKeyName)
+
+	offset := time.Duration(config.C().Subtitle.Offset) * time.Millisecond
+	if v := c.Query("offset"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			c.String(http.StatusBadRequest, "无效 offset")
+			return
+		}
+		offset = time.Duration(ms) * time.Millisecond
+	}
+
+	var data []byte
+	var name string
+	var err error
+	switch {
+	case remote != "":
+		name = remote
+		data, err = fetchEmbySubtitle(remote, apiKey)
+	case openlistPath != "":
+		name = openlistPath
+		data, err = fetchOpenlistSubtitle(openlistPath)
+	default:
+		c.String(http.StatusBadRequest, "缺少字幕来源参数")
+		return
+	}
+	if err != nil {
+		logs.Error("获取字幕失败, name: %s, err: %v", name, err)
+		c.String(http.StatusBadGateway, "获取字幕失败, 请检查日志")
+		return
+	}
+
+	vtt, err := subtitle.ToVTT(data, subtitle.FormatOf(name), config.C().Subtitle.Charset, offset)
+	if err != nil {
+		logs.Error("转换字幕失败, name: %s, err: %v", name, err)
+		c.String(http.StatusInternalServerError, "转换字幕失败, 请检查日志")
+		return
+	}
+
+	logs.Success("转换字幕: %s", name)
+	c.Header(cache.HeaderKeyExpired, cache.Duration(time.Hour*24*30))
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", vtt)
+}
+
+// VttDeliveryUrl 生成 emby 外挂字幕的 WebVTT 转换地址
+//
+// 字幕格式不支持转换时返回 false
+func VttDeliveryUrl(itemId, mediaSourceId string, subIndex int, codec, apiKey string) (string, bool) {
+	format := subtitle.FormatOf("." + strings.ToLower(codec))
+	if format == "" || format == subtitle.FormatVTT {
+		return "", false
+	}
+	remote := fmt.Sprintf("/Videos/%s/%s/Subtitles/%d/0/Stream.%s", itemId, mediaSourceId, subIndex, format)
+
+	u, _ := url.Parse("/videos/proxy_vtt")
+	q := u.Query()
+	q.Set("remote", remote)
+	q.Set(QueryApiKeyName, apiKey)
+	u.RawQuery = q.Encode()
+	return u.String(), true
+}
+
+// OpenlistVttUrl 生成 openlist 字幕文件的 WebVTT 转换地址
+func OpenlistVttUrl(openlistPath, apiKey string) string {
+	u, _ := url.Parse("/videos/proxy_vtt")
+	q := u.Query()
+	q.Set("openlist_path", openlist.PathEncode(openlistPath))
+	q.Set(QueryApiKeyName, apiKey)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ProxySiblingSubtitle 将网盘中与视频同名的外挂字幕重定向到直链
+func ProxySiblingSubtitle(c *gin.Context) {
+	openlistPath := openlist.PathDecode(c.Query("openlist_path"))
+	if subtitle.FormatOf(openlistPath) == "" {
+		c.String(http.StatusBadRequest, "不是有效的字幕文件")
+		return
+	}
+
+	res := openlist.FetchResource(openlist.FetchInfo{Path: openlistPath})
+	if res.Code != http.StatusOK {
+		logs.Error("获取字幕直链失败, path: %s, err: %s", openlistPath, res.Msg)
+		c.String(http.StatusBadGateway, "获取字幕直链失败, 请检查日志")
+		return
+	}
+
+	logs.Success("字幕重定向至: %s", res.Data.Url)
+	c.Redirect(http.StatusTemporaryRedirect, res.Data.Url)
+}
+
+// SiblingSubtitleUrl 生成网盘同名外挂字幕的访问地址
+//
+// 开启字幕转换时使用 WebVTT 转换地址, 否则重定向到直链
+func SiblingSubtitleUrl(openlistPath, apiKey string) string {
+	if config.C().Subtitle.Convert {
+		return OpenlistVttUrl(openlistPath, apiKey)
+	}
+	u, _ := url.Parse("/videos/proxy_sibling_subtitle")
+	q := u.Query()
+	q.Set("openlist_path", openlist.PathEncode(openlistPath))
+	q.Set(QueryApiKeyName, apiKey)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// fetchEmbySubtitle 请求 emby 外挂字幕的原始内容
+func fetchEmbySubtitle(remote, apiKey string) ([]byte, error) {
+	if !strings.HasPrefix(remote, "/") || !videoSubtitlesReg.MatchString(remote) {
+		return nil, errors.New("不是有效的字幕地址")
+	}
+	resp, err := https.Get(config.C().Emby.Host+remote).AddHeader(QueryTokenName, apiKey).Do()
+	if err != nil {
+		return nil, fmt.Errorf("请求 emby 失败: %w", err)
+	}
+	return readSubtitle(resp)
+}
+
+// fetchOpenlistSubtitle 请求 openlist 字幕文件的原始内容
+func fetchOpenlistSubtitle(openlistPath string) ([]byte, error) {
+	if subtitle.FormatOf(openlistPath) == "" {
+		return nil, errors.New("不是有效的字幕文件")
+	}
+	res := openlist.FetchResource(openlist.FetchInfo{Path: openlistPath})
+	if res.Code != http.StatusOK {
+		return nil, errors.New("请求 openlist 失败: " + res.Msg)
+	}
+	resp, err := https.Get(res.Data.Url).Do()
+	if err != nil {
+		return nil, fmt.Errorf("请求字幕直链失败: %w", err)
+	}
+	return readSubtitle(resp)
+}
+
+// readSubtitle 读取字幕响应, 并关闭响应体
+func readSubtitle(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	if https.IsErrorCode(resp.StatusCode) {
+		return nil, fmt.Errorf("远程响应异常: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxSubtitleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取字幕失败: %w", err)
+	}
+	if len(data) > MaxSubtitleSize {
+		return nil, errors.New("字幕文件过大")
+	}
+	return data, nil
+}
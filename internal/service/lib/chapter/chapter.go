@@ -0,0 +1,244 @@
+package chapter
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoChapters 文件中没有解析到章节
+var ErrNoChapters = errors.New("没有解析到章节信息")
+
+// Chapter 一个章节
+type Chapter struct {
+	Start time.Duration // 开始时间
+	End   time.Duration // 结束时间, 文件中没有结束时间时为 0
+	Title string        // 章节名称
+}
+
+// Markers 从章节中识别出的片头片尾位置
+type Markers struct {
+	IntroStart   time.Duration // 片头开始
+	IntroEnd     time.Duration // 片头结束, 为 0 表示没有识别到片头
+	CreditsStart time.Duration // 片尾开始, 为 0 表示没有识别到片尾
+}
+
+var (
+	// ogmLineReg 匹配 OGM 章节文件的时间行, 如 CHAPTER01=00:01:30.000
+	ogmLineReg = regexp.MustCompile(`(?i)^CHAPTER(\d+)=(.+)$`)
+	// ogmNameReg 匹配 OGM 章节文件的名称行, 如 CHAPTER01NAME=Opening
+	ogmNameReg = regexp.MustCompile(`(?i)^CHAPTER(\d+)NAME=(.*)$`)
+	// xmlAtomReg 匹配 Matroska 章节 xml 中的 ChapterAtom
+	xmlAtomReg = regexp.MustCompile(`(?s)<ChapterAtom>(.*?)</ChapterAtom>`)
+	// xmlTagReg 匹配 xml 中的简单标签
+	xmlTagReg = regexp.MustCompile(`(?s)<(ChapterTimeStart|ChapterTimeEnd|ChapterString)>(.*?)</`)
+
+	// introReg 片头章节名称
+	introReg = regexp.MustCompile(`(?i)(intro|opening|^op$|^op\d*\b|片头|オープニング)`)
+	// creditsReg 片尾章节名称
+	creditsReg = regexp.MustCompile(`(?i)(credits|ending|outro|^ed$|^ed\d*\b|片尾|エンディング)`)
+)
+
+// Parse 解析章节文件, 支持 OGM 文本、ffmpeg 元数据和 Matroska xml 三种格式
+//
+// 返回的章节按开始时间排序, 缺少结束时间的章节使用下一章节的开始时间补全
+func Parse(text string) ([]Chapter, error) {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var chapters []Chapter
+	switch {
+	case strings.Contains(text, "<ChapterAtom>"):
+		chapters = parseXML(text)
+	case strings.Contains(strings.ToUpper(text), "[CHAPTER]"):
+		chapters = parseFFMetadata(text)
+	default:
+		chapters = parseOGM(text)
+	}
+	if len(chapters) == 0 {
+		return nil, ErrNoChapters
+	}
+
+	slices.SortStableFunc(chapters, func(a, b Chapter) int {
+		return int(a.Start - b.Start)
+	})
+	for i := range chapters {
+		if chapters[i].End == 0 && i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		}
+	}
+	return chapters, nil
+}
+
+// DetectMarkers 根据章节名称识别片头片尾
+//
+// 第一个名称匹配片头的章节作为片头, 最后一个名称匹配片尾的章节作为片尾
+func DetectMarkers(chapters []Chapter) (Markers, bool) {
+	var m Markers
+	found := false
+	for _, c := range chapters {
+		title := strings.TrimSpace(c.Title)
+		if m.IntroEnd == 0 && introReg.MatchString(title) && c.End > c.Start {
+			m.IntroStart, m.IntroEnd = c.Start, c.End
+			found = true
+			continue
+		}
+		if creditsReg.MatchString(title) {
+			m.CreditsStart = c.Start
+			found = true
+		}
+	}
+	return m, found
+}
+
+// parseOGM 解析 OGM 章节文本
+func parseOGM(text string) []Chapter {
+	byNum := map[string]*Chapter{}
+	var order []string
+	get := func(num string) *Chapter {
+		if c, ok := byNum[num]; ok {
+			return c
+		}
+		byNum[num] = &Chapter{}
+		order = append(order, num)
+		return byNum[num]
+	}
+
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if res := ogmNameReg.FindStringSubmatch(line); res != nil {
+			get(res[1]).Title = strings.TrimSpace(res[2])
+			continue
+		}
+		if res := ogmLineReg.FindStringSubmatch(line); res != nil {
+			if d, ok := parseClock(res[2]); ok {
+				get(res[1]).Start = d
+			}
+		}
+	}
+
+	res := make([]Chapter, 0, len(order))
+	for _, num := range order {
+		res = append(res, *byNum[num])
+	}
+	return res
+}
+
+// parseFFMetadata 解析 ffmpeg 元数据中的 [CHAPTER] 段
+func parseFFMetadata(text string) []Chapter {
+	var res []Chapter
+	var cur *Chapter
+	var start, end int64
+	num, den := int64(1), int64(1000)
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.Start = ticksToDuration(start, num, den)
+		cur.End = ticksToDuration(end, num, den)
+		res = append(res, *cur)
+		cur = nil
+	}
+
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			flush()
+			if strings.EqualFold(line, "[CHAPTER]") {
+				cur = &Chapter{}
+				start, end, num, den = 0, 0, 1, 1000
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "TIMEBASE":
+			n, d, _ := strings.Cut(value, "/")
+			num, _ = strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+			den, _ = strconv.ParseInt(strings.TrimSpace(d), 10, 64)
+		case "START":
+			start, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		case "END":
+			end, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		case "TITLE":
+			cur.Title = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return res
+}
+
+// parseXML 解析 Matroska 章节 xml
+func parseXML(text string) []Chapter {
+	var res []Chapter
+	for _, atom := range xmlAtomReg.FindAllStringSubmatch(text, -1) {
+		var c Chapter
+		valid := false
+		for _, tag := range xmlTagReg.FindAllStringSubmatch(atom[1], -1) {
+			value := strings.TrimSpace(tag[2])
+			switch tag[1] {
+			case "ChapterTimeStart":
+				c.Start, valid = parseClock(value)
+			case "ChapterTimeEnd":
+				c.End, _ = parseClock(value)
+			case "ChapterString":
+				if c.Title == "" {
+					c.Title = value
+				}
+			}
+		}
+		if valid {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// parseClock 解析 hh:mm:ss.fff 格式的时间, 小数部分按位数换算
+func parseClock(s string) (time.Duration, bool) {
+	main, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	parts := strings.Split(main, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	var d time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+
+	if frac != "" {
+		n, err := strconv.Atoi(frac)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		unit := time.Second
+		for range len(frac) {
+			unit /= 10
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// ticksToDuration 将 TIMEBASE 为 num/den 的时间刻度转换为时长
+func ticksToDuration(ticks, num, den int64) time.Duration {
+	if num <= 0 || den <= 0 {
+		return 0
+	}
+	return time.Duration(float64(ticks) * float64(num) / float64(den) * float64(time.Second))
+}
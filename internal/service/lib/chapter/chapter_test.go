@@ -0,0 +1,65 @@
+package chapter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/chapter"
+)
+
+func TestParseOGM(t *testing.T) {
+	text := "CHAPTER01=00:00:00.000\r\nCHAPTER01NAME=Prologue\r\nCHAPTER02=00:01:30.500\r\nCHAPTER02NAME=Opening\r\nCHAPTER03=00:03:00.000\r\nCHAPTER03NAME=Part A\r\nCHAPTER04=00:22:10.000\r\nCHAPTER04NAME=Ending\r\n"
+	chapters, err := chapter.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 4 {
+		t.Fatalf("got %d chapters, want 4", len(chapters))
+	}
+	if chapters[1].Start != 90*time.Second+500*time.Millisecond || chapters[1].End != 3*time.Minute {
+		t.Fatalf("unexpected opening chapter: %+v", chapters[1])
+	}
+
+	m, ok := chapter.DetectMarkers(chapters)
+	if !ok {
+		t.Fatal("markers not detected")
+	}
+	want := chapter.Markers{IntroStart: 90*time.Second + 500*time.Millisecond, IntroEnd: 3 * time.Minute, CreditsStart: 22*time.Minute + 10*time.Second}
+	if m != want {
+		t.Fatalf("DetectMarkers() = %+v, want %+v", m, want)
+	}
+}
+
+func TestParseFFMetadata(t *testing.T) {
+	text := ";FFMETADATA1\ntitle=demo\n\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=85000\ntitle=片头\n\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=85000\nEND=1300000\ntitle=正片\n\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=1300000\nEND=1400000\ntitle=片尾\n"
+	chapters, err := chapter.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := chapter.DetectMarkers(chapters)
+	if !ok || m.IntroEnd != 85*time.Second || m.CreditsStart != 1300*time.Second {
+		t.Fatalf("DetectMarkers() = %+v, %v", m, ok)
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	text := `<?xml version="1.0"?>
+<Chapters><EditionEntry>
+<ChapterAtom><ChapterTimeStart>00:00:05.000000000</ChapterTimeStart><ChapterTimeEnd>00:01:35.000000000</ChapterTimeEnd><ChapterDisplay><ChapterString>Intro</ChapterString></ChapterDisplay></ChapterAtom>
+<ChapterAtom><ChapterTimeStart>00:01:35.000000000</ChapterTimeStart><ChapterDisplay><ChapterString>Episode</ChapterString></ChapterDisplay></ChapterAtom>
+</EditionEntry></Chapters>`
+	chapters, err := chapter.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := chapter.DetectMarkers(chapters)
+	if !ok || m.IntroStart != 5*time.Second || m.IntroEnd != 95*time.Second || m.CreditsStart != 0 {
+		t.Fatalf("DetectMarkers() = %+v, %v", m, ok)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := chapter.Parse("hello"); err != chapter.ErrNoChapters {
+		t.Fatalf("Parse() err = %v, want ErrNoChapters", err)
+	}
+}
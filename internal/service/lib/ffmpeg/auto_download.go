@@ -43,6 +43,11 @@ func ExecPath() string {
 	return execPath
 }
 
+// Ready 判断 ffmpeg 环境是否已就绪
+func Ready() bool {
+	return execOk
+}
+
 type progressWriter struct {
 	Reader     io.Reader
 	Total      int64
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"os/exec"
 	"sync"
 
 	"github.com/syscc/Emby-Go/internal/constant"
@@ -13,42 +12,28 @@ import (
 // OpenError ffmpeg 打开文件失败
 const OpenError = "Error opening input:"
 
-// mu 任务逐个执行
-var mu sync.Mutex
-
 // InspectInfo 检查指定路径文件的元信息
 func InspectInfo(path string) (Info, error) {
-	if !execOk {
-		return Info{}, errors.New("ffmpeg 未初始化")
+	stdout, stderr, err := run("-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path)
+	if err != nil {
+		return Info{}, err
 	}
-	mu.Lock()
-	defer mu.Unlock()
 
-	cmd := exec.Command(execPath, "-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path)
-
-	outputBytes, _ := cmd.CombinedOutput()
+	outputBytes := append(stdout, stderr...)
 	if bytes.Contains(outputBytes, []byte(OpenError)) {
 		return Info{}, errors.New(string(outputBytes[bytes.Index(outputBytes, []byte(OpenError)):]))
 	}
 
-	i := Info{}
-	if durationReg.Match(outputBytes) {
-		i.Duration = resolveDuration(string(outputBytes))
-	}
-
-	return i, nil
+	return ResolveInfo(string(outputBytes)), nil
 }
 
 // InspectMusic 检查指定音乐文件的元信息
 func InspectMusic(path string) (Music, error) {
-	if !execOk {
-		return Music{}, errors.New("ffmpeg 未初始化")
+	stdout, stderr, err := run("-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path)
+	if err != nil {
+		return Music{}, err
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	cmd := exec.Command(execPath, "-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path)
-	outputBytes, _ := cmd.CombinedOutput()
+	outputBytes := append(stdout, stderr...)
 
 	if bytes.Contains(outputBytes, []byte(OpenError)) {
 		return Music{}, errors.New(string(outputBytes[bytes.Index(outputBytes, []byte(OpenError)):]))
@@ -132,25 +117,15 @@ func InspectMusic(path string) (Music, error) {
 
 // ExtractMusicCover 解析音乐海报
 func ExtractMusicCover(path string) ([]byte, error) {
-	if !execOk {
-		return nil, errors.New("ffmpeg 未初始化")
-	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	cmd := exec.Command(execPath, "-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path, "-an", "-vframes", "1", "-f", "image2", "-vcodec", "mjpeg", "pipe:1")
-
-	outputBytes, err := cmd.Output()
+	outputBytes, stderr, err := run("-user_agent", constant.CommonDlUserAgent, "-threads", "1", "-i", path, "-an", "-vframes", "1", "-f", "image2", "-vcodec", "mjpeg", "pipe:1")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr := exitErr.Stderr
-			if bytes.Contains(stderr, []byte(OpenError)) {
-				return nil, errors.New(string(stderr[bytes.Index(stderr, []byte(OpenError)):]))
-			}
-		}
+		return nil, err
+	}
+	if bytes.Contains(stderr, []byte(OpenError)) {
+		return nil, errors.New(string(stderr[bytes.Index(stderr, []byte(OpenError)):]))
 	}
 
-	return append([]byte(nil), outputBytes...), nil
+	return outputBytes, nil
 }
 
 // GenSilentMP3Bytes 使用 ffmpeg 生成静音 MP3 并返回字节内容
@@ -167,8 +142,6 @@ func GenSilentMP3Bytes(durationSec float64) ([]byte, error) {
 		"pipe:1",
 	}
 
-	cmd := exec.Command(execPath, args...)
-	outputBytes, _ := cmd.Output()
-
-	return append([]byte(nil), outputBytes...), nil
+	outputBytes, _, err := run(args...)
+	return outputBytes, err
 }
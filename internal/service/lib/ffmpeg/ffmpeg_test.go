@@ -68,3 +68,51 @@ func TestExtractMusicCover(t *testing.T) {
 	}
 	os.WriteFile("cover.jpg", bytes, 0644)
 }
+
+func TestResolveInfo(t *testing.T) {
+	raw := `Input #0, matroska,webm, from 'http://127.0.0.1/d/test.mkv':
+  Metadata:
+    encoder         : libebml v1.4.2 + libmatroska v1.6.4
+  Duration: 00:42:10.05, start: 0.000000, bitrate: 18302 kb/s
+  Stream #0:0: Video: hevc (Main 10), yuv420p10le(tv, bt2020nc/bt2020/smpte2084), 3840x2160 [SAR 1:1 DAR 16:9], 23.98 fps, 23.98 tbr, 1k tbn (default)
+      Metadata:
+        BPS             : 16120184
+      Side data:
+        DOVI configuration record: version: 1.0, profile: 8, level: 6, rpu flag: 1, el flag: 0, bl flag: 1, compatibility id: 1
+  Stream #0:1(chi): Audio: eac3, 48000 Hz, 5.1(side), fltp, 640 kb/s (default)
+  Stream #0:2[0x2](eng): Audio: aac (LC) (mp4a / 0x6134706D), 44100 Hz, stereo, fltp, 128 kb/s
+  Stream #0:3(chi): Subtitle: subrip (default)
+  Stream #0:4(und): Subtitle: ass (forced)
+  Stream #0:5: Video: mjpeg (Baseline), yuvj420p(pc, bt470bg/unknown/unknown), 600x900, 90k tbr, 90k tbn (attached pic)
+At least one output file must be specified`
+
+	i := ffmpeg.ResolveInfo(raw)
+	if i.Duration.Seconds() < 2530 || i.Duration.Seconds() > 2531 {
+		t.Errorf("unexpected duration: %v", i.Duration)
+	}
+
+	v := i.Video
+	if v == nil || v.Codec != "hevc" || v.Width != 3840 || v.Height != 2160 || v.FrameRate != 23.98 {
+		t.Fatalf("unexpected video stream: %+v", v)
+	}
+	if v.ColorTransfer != "smpte2084" || v.HDR != ffmpeg.DolbyVision || v.DVProfile != 8 || v.DVLevel != 6 {
+		t.Errorf("unexpected hdr info: %+v", v)
+	}
+
+	if len(i.Audios) != 2 {
+		t.Fatalf("unexpected audio streams: %+v", i.Audios)
+	}
+	if a := i.Audios[0]; a.Codec != "eac3" || a.Language != "chi" || a.Channels != 6 || a.SampleRate != 48000 || !a.Default {
+		t.Errorf("unexpected audio stream: %+v", a)
+	}
+	if a := i.Audios[1]; a.Codec != "aac" || a.Language != "eng" || a.Channels != 2 || a.Default {
+		t.Errorf("unexpected audio stream: %+v", a)
+	}
+
+	if len(i.Subtitles) != 2 {
+		t.Fatalf("unexpected subtitle streams: %+v", i.Subtitles)
+	}
+	if s := i.Subtitles[1]; s.Codec != "ass" || s.Language != "" || !s.Forced {
+		t.Errorf("unexpected subtitle stream: %+v", s)
+	}
+}
@@ -0,0 +1,67 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync/atomic"
+	"time"
+)
+
+// execTimeout 单次调用 ffmpeg 的超时时间
+const execTimeout = time.Minute * 2
+
+// ErrTimeout ffmpeg 执行超时
+var ErrTimeout = errors.New("ffmpeg 执行超时")
+
+// workers 限制同时运行的 ffmpeg 进程数, 默认只允许一个
+var workers atomic.Pointer[chan struct{}]
+
+func init() {
+	SetWorkers(1)
+}
+
+// SetWorkers 设置同时运行的 ffmpeg 进程数, 小于 1 时按 1 处理
+//
+// 只应在启动时调用, 运行中的任务仍会归还到旧的工作位, 替换后同时运行的进程数可能超过 n
+func SetWorkers(n int) {
+	sem := make(chan struct{}, max(n, 1))
+	workers.Store(&sem)
+}
+
+// Workers 获取同时运行的 ffmpeg 进程数
+func Workers() int {
+	return cap(*workers.Load())
+}
+
+// run 占用一个工作位执行 ffmpeg, 返回标准输出和标准错误
+//
+// 进程的非零退出码不视为错误, 由调用方根据输出判断执行结果
+func run(args ...string) (stdout, stderr []byte, err error) {
+	if !execOk {
+		return nil, nil, errors.New("ffmpeg 未初始化")
+	}
+
+	// 排队等待工作位, 超时的进程会被终止, 队列不会无限阻塞
+	sem := *workers.Load()
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, execPath, args...)
+	cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+	runErr := cmd.Run()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, nil, ErrTimeout
+	}
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return nil, nil, fmt.Errorf("启动 ffmpeg 失败: %w", runErr)
+	}
+	return outBuf.Bytes(), errBuf.Bytes(), nil
+}
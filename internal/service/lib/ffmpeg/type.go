@@ -2,9 +2,48 @@ package ffmpeg
 
 import "time"
 
+// HDR 格式
+const (
+	HDR10       = "HDR10"
+	HLG         = "HLG"
+	DolbyVision = "DolbyVision"
+)
+
 // Info 记录文件元信息
 type Info struct {
-	Duration time.Duration
+	Duration  time.Duration
+	Video     *VideoStream     // 首个视频流, 不存在时为 nil
+	Audios    []AudioStream    // 音频流
+	Subtitles []SubtitleStream // 字幕流
+}
+
+// VideoStream 视频流信息
+type VideoStream struct {
+	Codec         string  // 编码, 如 h264, hevc
+	Width         int     // 宽度
+	Height        int     // 高度
+	FrameRate     float64 // 帧率
+	ColorTransfer string  // 色彩传输特性, 如 smpte2084, arib-std-b67
+	HDR           string  // HDR 格式, 为空表示 SDR
+	DVProfile     int     // 杜比视界 profile, 0 表示不存在
+	DVLevel       int     // 杜比视界 level
+}
+
+// AudioStream 音频流信息
+type AudioStream struct {
+	Codec      string // 编码, 如 aac, eac3
+	Language   string // 语言, ISO-639-2 三字母代码
+	Channels   int    // 声道数
+	SampleRate int    // 采样率
+	Default    bool   // 是否是默认轨道
+}
+
+// SubtitleStream 内封字幕流信息
+type SubtitleStream struct {
+	Codec    string // 编码, 如 subrip, ass, hdmv_pgs_subtitle
+	Language string // 语言, ISO-639-2 三字母代码
+	Default  bool   // 是否是默认轨道
+	Forced   bool   // 是否是强制字幕
 }
 
 // Music 记录音乐元信息
@@ -23,6 +23,13 @@ var (
 	genreReg      = regexp.MustCompile(`(?mi)^[ \t]*genre\s*:\s*(.+?)\s*$`)
 	tdorReg       = regexp.MustCompile(`(?mi)^[ \t]*tdor\s*:\s*(.+?)\s*$`)
 	lyricsReg     = regexp.MustCompile(`(?mi):\s*(\[.*?\].*?)\s*$`)
+	streamReg     = regexp.MustCompile(`^\s*Stream #\d+:\d+(?:\[\w+\])?(?:\((\w+)\))?: (Video|Audio|Subtitle): (\w+)(.*)$`)
+	resolutionReg = regexp.MustCompile(`, (\d{2,5})x(\d{2,5})`)
+	fpsReg        = regexp.MustCompile(`, ([\d.]+) fps`)
+	sampleRateReg = regexp.MustCompile(`(\d+) Hz, ([^,]+)`)
+	layoutReg     = regexp.MustCompile(`^(\d+)\.(\d+)`)
+	channelsReg   = regexp.MustCompile(`^(\d+) channels`)
+	doviReg       = regexp.MustCompile(`DOVI configuration record:.*?profile: (\d+), level: (\d+)`)
 )
 
 // resolveDuration 解析 ffmpeg 的 Duration 参数
@@ -126,3 +133,109 @@ func resolveTitle(raw string) string {
 
 	return ""
 }
+
+// ResolveInfo 解析 ffmpeg -i 输出的文件元信息
+func ResolveInfo(raw string) Info {
+	i := Info{Duration: resolveDuration(raw)}
+
+	// lastVideo 标记最近解析的流是否是视频流, 用于关联后续的附加数据
+	lastVideo := false
+	for line := range strings.SplitSeq(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if res := streamReg.FindStringSubmatch(line); len(res) == 5 {
+			lang, kind, codec, detail := res[1], res[2], res[3], res[4]
+			lastVideo = false
+			switch kind {
+			case "Video":
+				// 跳过封面图
+				if i.Video != nil || strings.Contains(detail, "(attached pic)") {
+					continue
+				}
+				i.Video = resolveVideoStream(codec, detail)
+				lastVideo = true
+			case "Audio":
+				i.Audios = append(i.Audios, resolveAudioStream(lang, codec, detail))
+			case "Subtitle":
+				i.Subtitles = append(i.Subtitles, SubtitleStream{
+					Codec:    codec,
+					Language: normalizeLanguage(lang),
+					Default:  strings.Contains(detail, "(default)"),
+					Forced:   strings.Contains(detail, "(forced)"),
+				})
+			}
+			continue
+		}
+
+		if !lastVideo || i.Video == nil {
+			continue
+		}
+		if res := doviReg.FindStringSubmatch(line); len(res) == 3 {
+			i.Video.DVProfile, _ = strconv.Atoi(res[1])
+			i.Video.DVLevel, _ = strconv.Atoi(res[2])
+			i.Video.HDR = DolbyVision
+		}
+	}
+
+	return i
+}
+
+// resolveVideoStream 解析视频流描述
+func resolveVideoStream(codec, detail string) *VideoStream {
+	v := VideoStream{Codec: codec}
+	if res := resolutionReg.FindStringSubmatch(detail); len(res) == 3 {
+		v.Width, _ = strconv.Atoi(res[1])
+		v.Height, _ = strconv.Atoi(res[2])
+	}
+	if res := fpsReg.FindStringSubmatch(detail); len(res) == 2 {
+		v.FrameRate, _ = strconv.ParseFloat(res[1], 64)
+	}
+
+	switch {
+	case strings.Contains(detail, "smpte2084"):
+		v.ColorTransfer, v.HDR = "smpte2084", HDR10
+	case strings.Contains(detail, "arib-std-b67"):
+		v.ColorTransfer, v.HDR = "arib-std-b67", HLG
+	}
+	return &v
+}
+
+// resolveAudioStream 解析音频流描述
+func resolveAudioStream(lang, codec, detail string) AudioStream {
+	a := AudioStream{
+		Codec:    codec,
+		Language: normalizeLanguage(lang),
+		Default:  strings.Contains(detail, "(default)"),
+	}
+
+	res := sampleRateReg.FindStringSubmatch(detail)
+	if len(res) != 3 {
+		return a
+	}
+	a.SampleRate, _ = strconv.Atoi(res[1])
+
+	layout := strings.TrimSpace(res[2])
+	switch {
+	case layout == "mono":
+		a.Channels = 1
+	case layout == "stereo":
+		a.Channels = 2
+	case layoutReg.MatchString(layout):
+		sub := layoutReg.FindStringSubmatch(layout)
+		main, _ := strconv.Atoi(sub[1])
+		lfe, _ := strconv.Atoi(sub[2])
+		a.Channels = main + lfe
+	case channelsReg.MatchString(layout):
+		a.Channels, _ = strconv.Atoi(channelsReg.FindStringSubmatch(layout)[1])
+	}
+	return a
+}
+
+// normalizeLanguage 规范化 ffmpeg 输出的语言代码, 未知语言返回空串
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "und" || len(lang) != 3 {
+		return ""
+	}
+	return lang
+}
@@ -0,0 +1,163 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// FLAC 元数据块类型
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+// flacFrontCover PICTURE 块中表示正面封面的图片类型
+const flacFrontCover = 3
+
+// inspectFLAC 解析 flac 文件, 只读取音频帧之前的元数据块
+func inspectFLAC(src Source) (ffmpeg.Music, []byte, error) {
+	m := ffmpeg.Music{}
+	var pic []byte
+	picType := -1
+
+	off := int64(4)
+	for {
+		hdr, err := readAt(src, off, 4)
+		if err != nil {
+			return ffmpeg.Music{}, nil, fmt.Errorf("读取 flac 元数据块失败: %w", err)
+		}
+		last, typ := hdr[0]&0x80 != 0, hdr[0]&0x7f
+		size := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+
+		switch typ {
+		case flacStreamInfo, flacVorbisComment, flacPicture:
+			data, err := readAt(src, off+4, size)
+			if err != nil {
+				return ffmpeg.Music{}, nil, fmt.Errorf("读取 flac 元数据块失败: %w", err)
+			}
+			switch typ {
+			case flacStreamInfo:
+				parseFlacStreamInfo(data, &m)
+			case flacVorbisComment:
+				applyVorbisComments(&m, parseVorbisComments(data))
+			case flacPicture:
+				// 优先使用正面封面
+				if t, p := parseFlacPicture(data); p != nil && picType != flacFrontCover {
+					pic, picType = p, t
+				}
+			}
+		}
+
+		off += 4 + int64(size)
+		if last {
+			break
+		}
+	}
+
+	return m, pic, nil
+}
+
+// parseFlacStreamInfo 解析 STREAMINFO 中的采样率、声道数和总采样数
+func parseFlacStreamInfo(data []byte, m *ffmpeg.Music) {
+	if len(data) < 18 {
+		return
+	}
+	sampleRate := int(data[10])<<12 | int(data[11])<<4 | int(data[12])>>4
+	channels := int(data[12]>>1&0x07) + 1
+	samples := uint64(data[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(data[14:]))
+
+	if sampleRate > 0 {
+		m.Duration = time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+	}
+	m.Audios = append(m.Audios, ffmpeg.AudioStream{
+		Codec:      "flac",
+		Channels:   channels,
+		SampleRate: sampleRate,
+		Default:    true,
+	})
+}
+
+// parseVorbisComments 解析 Vorbis 注释, 键统一转为大写
+func parseVorbisComments(data []byte) map[string]string {
+	res := make(map[string]string)
+	if len(data) < 4 {
+		return res
+	}
+
+	vendorLen := int(binary.LittleEndian.Uint32(data))
+	data = data[min(4+vendorLen, len(data)):]
+	if len(data) < 4 {
+		return res
+	}
+
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	for i := 0; i < count && len(data) >= 4; i++ {
+		size := int(binary.LittleEndian.Uint32(data))
+		if 4+size > len(data) {
+			break
+		}
+		kv := string(data[4 : 4+size])
+		data = data[4+size:]
+
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToUpper(k)
+		if old, exists := res[k]; exists {
+			// 重复的键使用分号拼接, 与 ffmpeg 行为一致
+			v = old + ";" + v
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// parseFlacPicture 解析 PICTURE 块, 返回图片类型和图片数据
+func parseFlacPicture(data []byte) (int, []byte) {
+	// next 读取一个 32 位长度前缀的字段
+	next := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		if 4+size > len(data) {
+			return nil, false
+		}
+		res := data[4 : 4+size]
+		data = data[4+size:]
+		return res, true
+	}
+
+	if len(data) < 4 {
+		return 0, nil
+	}
+	typ := int(binary.BigEndian.Uint32(data))
+	data = data[4:]
+
+	// mime + description
+	if _, ok := next(); !ok {
+		return 0, nil
+	}
+	if _, ok := next(); !ok {
+		return 0, nil
+	}
+
+	// width, height, depth, colors
+	if len(data) < 16 {
+		return 0, nil
+	}
+	data = data[16:]
+
+	pic, ok := next()
+	if !ok {
+		return 0, nil
+	}
+	return typ, append([]byte(nil), pic...)
+}
@@ -0,0 +1,455 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// Matroska 元素 ID
+const (
+	mkvEBML                 = 0x1a45dfa3
+	mkvSegment              = 0x18538067
+	mkvSeekHead             = 0x114d9b74
+	mkvSeek                 = 0x4dbb
+	mkvSeekID               = 0x53ab
+	mkvSeekPosition         = 0x53ac
+	mkvInfo                 = 0x1549a966
+	mkvTimestampScale       = 0x2ad7b1
+	mkvDuration             = 0x4489
+	mkvTracks               = 0x1654ae6b
+	mkvTrackEntry           = 0xae
+	mkvTrackType            = 0x83
+	mkvCodecID              = 0x86
+	mkvLanguage             = 0x22b59c
+	mkvFlagDefault          = 0x88
+	mkvFlagForced           = 0x55aa
+	mkvDefaultDuration      = 0x23e383
+	mkvVideo                = 0xe0
+	mkvPixelWidth           = 0xb0
+	mkvPixelHeight          = 0xba
+	mkvColour               = 0x55b0
+	mkvTransfer             = 0x55ba
+	mkvAudio                = 0xe1
+	mkvSamplingFrequency    = 0xb5
+	mkvChannels             = 0x9f
+	mkvBlockAdditionMapping = 0x41e4
+	mkvBlockAddIDType       = 0x41e7
+	mkvBlockAddIDExtraData  = 0x41ed
+	mkvCluster              = 0x1f43b675
+	mkvTags                 = 0x1254c367
+	mkvTag                  = 0x7373
+	mkvSimpleTag            = 0x67c8
+	mkvTagName              = 0x45a3
+	mkvTagString            = 0x4487
+)
+
+// Matroska 轨道类型
+const (
+	mkvTrackVideo    = 1
+	mkvTrackAudio    = 2
+	mkvTrackSubtitle = 17
+)
+
+// mkvMaxElementSize 读取单个顶层元素的最大大小
+const mkvMaxElementSize = 16 * 1024 * 1024
+
+// mkvCodecs Matroska CodecID 与 ffmpeg 编码名称的映射, 按前缀匹配
+var mkvCodecs = []struct{ prefix, codec string }{
+	{"V_MPEG4/ISO/AVC", "h264"},
+	{"V_MPEGH/ISO/HEVC", "hevc"},
+	{"V_AV1", "av1"},
+	{"V_VP9", "vp9"},
+	{"V_VP8", "vp8"},
+	{"V_MPEG4/", "mpeg4"},
+	{"V_MPEG2", "mpeg2video"},
+	{"V_MPEG1", "mpeg1video"},
+	{"V_MS/VFW/FOURCC", "mpeg4"},
+	{"V_PRORES", "prores"},
+	{"A_AAC", "aac"},
+	{"A_AC3", "ac3"},
+	{"A_EAC3", "eac3"},
+	{"A_DTS", "dts"},
+	{"A_TRUEHD", "truehd"},
+	{"A_FLAC", "flac"},
+	{"A_OPUS", "opus"},
+	{"A_VORBIS", "vorbis"},
+	{"A_MPEG/L3", "mp3"},
+	{"A_MPEG/L2", "mp2"},
+	{"A_PCM/INT/LIT", "pcm_s16le"},
+	{"A_PCM/INT/BIG", "pcm_s16be"},
+	{"S_TEXT/UTF8", "subrip"},
+	{"S_TEXT/ASS", "ass"},
+	{"S_TEXT/SSA", "ass"},
+	{"S_ASS", "ass"},
+	{"S_SSA", "ass"},
+	{"S_TEXT/WEBVTT", "webvtt"},
+	{"S_HDMV/PGS", "hdmv_pgs_subtitle"},
+	{"S_VOBSUB", "dvd_subtitle"},
+	{"S_DVBSUB", "dvb_subtitle"},
+}
+
+// inspectMKV 解析 mkv/webm 文件, 只读取 Info、Tracks 和 Tags 元素
+func inspectMKV(src Source) (ffmpeg.Music, []byte, error) {
+	// 跳过 EBML 头
+	id, size, dataOff, err := readElementHeader(src, 0)
+	if err != nil || id != mkvEBML {
+		return ffmpeg.Music{}, nil, errors.New("非法的 EBML 头")
+	}
+
+	id, size, segStart, err := readElementHeader(src, dataOff+size)
+	if err != nil || id != mkvSegment {
+		return ffmpeg.Music{}, nil, errors.New("找不到 Segment")
+	}
+	segEnd := src.Size()
+	if size >= 0 {
+		segEnd = min(segEnd, segStart+size)
+	}
+
+	elements := make(map[uint32][]byte)
+	seeks := make(map[uint32]int64)
+
+	// readElement 读取顶层元素的完整数据
+	readElement := func(id uint32, off, size int64) error {
+		if size > mkvMaxElementSize {
+			return fmt.Errorf("元素过大: %x", id)
+		}
+		data, err := readAt(src, off, int(size))
+		if err != nil {
+			return err
+		}
+		elements[id] = data
+		return nil
+	}
+
+	// 顺序扫描顶层元素, 遇到 Cluster 时停止
+	for off := segStart; off < segEnd; {
+		id, size, dataOff, err := readElementHeader(src, off)
+		if err != nil || id == mkvCluster || size < 0 {
+			break
+		}
+
+		switch id {
+		case mkvSeekHead:
+			if err := readElement(id, dataOff, size); err != nil {
+				return ffmpeg.Music{}, nil, fmt.Errorf("读取 SeekHead 失败: %w", err)
+			}
+			parseSeekHead(elements[id], segStart, seeks)
+		case mkvInfo, mkvTracks:
+			if err := readElement(id, dataOff, size); err != nil {
+				return ffmpeg.Music{}, nil, fmt.Errorf("读取元素 [%x] 失败: %w", id, err)
+			}
+		case mkvTags:
+			// 标签读取失败不影响媒体流解析
+			_ = readElement(id, dataOff, size)
+		}
+		off = dataOff + size
+	}
+
+	// 位于 Cluster 之后的元素通过 SeekHead 定位
+	for _, id := range []uint32{mkvInfo, mkvTracks, mkvTags} {
+		pos, ok := seeks[id]
+		if _, exists := elements[id]; exists || !ok {
+			continue
+		}
+		eid, size, dataOff, err := readElementHeader(src, pos)
+		if err != nil || eid != id || size < 0 {
+			continue
+		}
+		if err := readElement(id, dataOff, size); err != nil && id != mkvTags {
+			return ffmpeg.Music{}, nil, fmt.Errorf("读取元素 [%x] 失败: %w", id, err)
+		}
+	}
+
+	if elements[mkvTracks] == nil {
+		return ffmpeg.Music{}, nil, errors.New("找不到 Tracks")
+	}
+
+	m := ffmpeg.Music{}
+	m.Duration = parseMkvInfo(elements[mkvInfo])
+	eachElement(elements[mkvTracks], func(id uint32, data []byte) {
+		if id == mkvTrackEntry {
+			parseMkvTrack(data, &m.Info)
+		}
+	})
+	if tags := elements[mkvTags]; tags != nil {
+		applyVorbisComments(&m, parseMkvTags(tags))
+	}
+	return m, nil, nil
+}
+
+// readElementHeader 读取元素头, 返回 ID、数据大小 (-1 表示未知大小) 和数据起始位置
+func readElementHeader(src Source, off int64) (uint32, int64, int64, error) {
+	buf, err := readAt(src, off, int(min(16, src.Size()-off)))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	id, idLen, ok := readVint(buf, false)
+	if !ok {
+		return 0, 0, 0, errors.New("非法的元素 ID")
+	}
+	size, sizeLen, ok := readVint(buf[idLen:], true)
+	if !ok {
+		return 0, 0, 0, errors.New("非法的元素大小")
+	}
+	return uint32(id), size, off + int64(idLen+sizeLen), nil
+}
+
+// readVint 读取 EBML 变长整数, stripMarker 为 true 时去除长度标记位
+//
+// 数据大小全为 1 时表示未知大小, 返回 -1
+func readVint(b []byte, stripMarker bool) (int64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 || len(b) < n {
+		return 0, 0, false
+	}
+
+	var v uint64
+	allOnes := true
+	for i := 0; i < n; i++ {
+		c := b[i]
+		if i == 0 && stripMarker {
+			c &= byte(0xff >> n)
+			if c != byte(0xff>>n) {
+				allOnes = false
+			}
+		} else if c != 0xff {
+			allOnes = false
+		}
+		v = v<<8 | uint64(c)
+	}
+	if stripMarker && allOnes {
+		return -1, n, true
+	}
+	return int64(v), n, true
+}
+
+// eachElement 遍历内存中的子元素
+func eachElement(b []byte, fn func(id uint32, data []byte)) {
+	for len(b) > 0 {
+		id, idLen, ok := readVint(b, false)
+		if !ok {
+			return
+		}
+		size, sizeLen, ok := readVint(b[idLen:], true)
+		if !ok || size < 0 || int64(idLen+sizeLen)+size > int64(len(b)) {
+			return
+		}
+		start := idLen + sizeLen
+		fn(uint32(id), b[start:start+int(size)])
+		b = b[start+int(size):]
+	}
+}
+
+// parseSeekHead 解析 SeekHead, 记录各顶层元素的绝对位置
+func parseSeekHead(data []byte, segStart int64, seeks map[uint32]int64) {
+	eachElement(data, func(id uint32, seek []byte) {
+		if id != mkvSeek {
+			return
+		}
+		var seekId uint32
+		pos := int64(-1)
+		eachElement(seek, func(id uint32, v []byte) {
+			switch id {
+			case mkvSeekID:
+				seekId = uint32(readUint(v))
+			case mkvSeekPosition:
+				pos = int64(readUint(v))
+			}
+		})
+		if seekId != 0 && pos >= 0 {
+			seeks[seekId] = segStart + pos
+		}
+	})
+}
+
+// parseMkvInfo 解析 Info 中的时长
+func parseMkvInfo(data []byte) time.Duration {
+	scale := uint64(1000000)
+	var duration float64
+	eachElement(data, func(id uint32, v []byte) {
+		switch id {
+		case mkvTimestampScale:
+			scale = readUint(v)
+		case mkvDuration:
+			duration = readFloat(v)
+		}
+	})
+	return time.Duration(duration * float64(scale))
+}
+
+// parseMkvTrack 解析单个 TrackEntry
+func parseMkvTrack(data []byte, info *ffmpeg.Info) {
+	var trackType uint64
+	codecId := ""
+	lang := "eng" // Matroska 规范中 Language 的默认值
+	isDefault, forced := true, false
+	var defaultDuration uint64
+	var video, audio []byte
+	var mappings [][]byte
+
+	eachElement(data, func(id uint32, v []byte) {
+		switch id {
+		case mkvTrackType:
+			trackType = readUint(v)
+		case mkvCodecID:
+			codecId = readString(v)
+		case mkvLanguage:
+			lang = readString(v)
+		case mkvFlagDefault:
+			isDefault = readUint(v) != 0
+		case mkvFlagForced:
+			forced = readUint(v) != 0
+		case mkvDefaultDuration:
+			defaultDuration = readUint(v)
+		case mkvVideo:
+			video = v
+		case mkvAudio:
+			audio = v
+		case mkvBlockAdditionMapping:
+			mappings = append(mappings, v)
+		}
+	})
+
+	codec := mkvCodec(codecId)
+	switch trackType {
+	case mkvTrackVideo:
+		if info.Video != nil {
+			return
+		}
+		v := ffmpeg.VideoStream{Codec: codec}
+		if defaultDuration > 0 {
+			v.FrameRate = math.Round(1e9/float64(defaultDuration)*1000) / 1000
+		}
+		eachElement(video, func(id uint32, b []byte) {
+			switch id {
+			case mkvPixelWidth:
+				v.Width = int(readUint(b))
+			case mkvPixelHeight:
+				v.Height = int(readUint(b))
+			case mkvColour:
+				eachElement(b, func(id uint32, c []byte) {
+					if id == mkvTransfer {
+						setTransfer(&v, int(readUint(c)))
+					}
+				})
+			}
+		})
+		for _, mapping := range mappings {
+			var addType uint64
+			var extra []byte
+			eachElement(mapping, func(id uint32, b []byte) {
+				switch id {
+				case mkvBlockAddIDType:
+					addType = readUint(b)
+				case mkvBlockAddIDExtraData:
+					extra = b
+				}
+			})
+			// dvcC / dvvC
+			if addType == 0x64766343 || addType == 0x64767643 {
+				setDoviRecord(&v, extra)
+			}
+		}
+		info.Video = &v
+
+	case mkvTrackAudio:
+		a := ffmpeg.AudioStream{
+			Codec:    codec,
+			Language: normalizeLanguage(lang),
+			Default:  isDefault,
+			Channels: 1,
+		}
+		eachElement(audio, func(id uint32, b []byte) {
+			switch id {
+			case mkvSamplingFrequency:
+				a.SampleRate = int(readFloat(b))
+			case mkvChannels:
+				a.Channels = int(readUint(b))
+			}
+		})
+		info.Audios = append(info.Audios, a)
+
+	case mkvTrackSubtitle:
+		info.Subtitles = append(info.Subtitles, ffmpeg.SubtitleStream{
+			Codec:    codec,
+			Language: normalizeLanguage(lang),
+			Default:  isDefault,
+			Forced:   forced,
+		})
+	}
+}
+
+// parseMkvTags 解析 Tags 中的 SimpleTag, 键统一转为大写
+func parseMkvTags(data []byte) map[string]string {
+	res := make(map[string]string)
+	eachElement(data, func(id uint32, tag []byte) {
+		if id != mkvTag {
+			return
+		}
+		eachElement(tag, func(id uint32, simple []byte) {
+			if id != mkvSimpleTag {
+				return
+			}
+			var name, value string
+			eachElement(simple, func(id uint32, v []byte) {
+				switch id {
+				case mkvTagName:
+					name = strings.ToUpper(readString(v))
+				case mkvTagString:
+					value = readString(v)
+				}
+			})
+			if name != "" && res[name] == "" {
+				res[name] = value
+			}
+		})
+	})
+	return res
+}
+
+// mkvCodec 将 Matroska CodecID 转换为 ffmpeg 编码名称
+func mkvCodec(codecId string) string {
+	for _, c := range mkvCodecs {
+		if strings.HasPrefix(codecId, c.prefix) {
+			return c.codec
+		}
+	}
+	return strings.ToLower(codecId)
+}
+
+// readUint 读取大端无符号整数
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// readFloat 读取 4 或 8 字节的浮点数
+func readFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// readString 读取字符串, 去除末尾的填充字节
+func readString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
@@ -0,0 +1,239 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bogem/id3v2"
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// mp3SyncScanSize 查找首个音频帧时最多扫描的字节数
+const mp3SyncScanSize = 64 * 1024
+
+var (
+	// mp3Bitrates 比特率表 [是否是 MPEG1][layer-1][index], 单位: kbps
+	mp3Bitrates = [2][3][16]int{
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		},
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		},
+	}
+
+	// mp3SampleRates 采样率表 [version 位][index]
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},  // MPEG 2.5
+		{0, 0, 0},             // reserved
+		{22050, 24000, 16000}, // MPEG 2
+		{44100, 48000, 32000}, // MPEG 1
+	}
+)
+
+// mp3Frame mpeg 音频帧头信息
+type mp3Frame struct {
+	mpeg1      bool
+	layer      int
+	bitrate    int // kbps
+	sampleRate int
+	channels   int
+	size       int // 帧长度
+	samples    int // 每帧采样数
+	sideInfo   int // layer3 side info 长度
+}
+
+// parseMp3Frame 解析 4 字节的 mpeg 音频帧头
+func parseMp3Frame(h []byte) (mp3Frame, bool) {
+	if len(h) < 4 || h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+	version, layerBits := h[1]>>3&0x03, h[1]>>1&0x03
+	brIdx, srIdx := h[2]>>4, h[2]>>2&0x03
+	if version == 1 || layerBits == 0 || brIdx == 0 || brIdx == 0x0f || srIdx == 0x03 {
+		return mp3Frame{}, false
+	}
+
+	f := mp3Frame{mpeg1: version == 3, layer: 4 - int(layerBits)}
+	v := 0
+	if f.mpeg1 {
+		v = 1
+	}
+	f.bitrate = mp3Bitrates[v][f.layer-1][brIdx]
+	f.sampleRate = mp3SampleRates[version][srIdx]
+	padding := int(h[2] >> 1 & 0x01)
+	mono := h[3]>>6 == 0x03
+	f.channels = 2
+	if mono {
+		f.channels = 1
+	}
+
+	switch {
+	case f.layer == 1:
+		f.samples = 384
+		f.size = (12*f.bitrate*1000/f.sampleRate + padding) * 4
+	case f.layer == 2 || f.mpeg1:
+		f.samples = 1152
+		f.size = 144*f.bitrate*1000/f.sampleRate + padding
+	default:
+		f.samples = 576
+		f.size = 72*f.bitrate*1000/f.sampleRate + padding
+	}
+
+	switch {
+	case f.mpeg1 && mono:
+		f.sideInfo = 17
+	case f.mpeg1:
+		f.sideInfo = 32
+	case mono:
+		f.sideInfo = 9
+	default:
+		f.sideInfo = 17
+	}
+	return f, f.size > 4
+}
+
+// inspectMP3 解析 mp3 文件的 ID3v2 标签和首个音频帧
+func inspectMP3(src Source) (ffmpeg.Music, []byte, error) {
+	m := ffmpeg.Music{}
+	var pic []byte
+
+	var audioStart int64
+	head, err := readAt(src, 0, 10)
+	if err != nil {
+		return ffmpeg.Music{}, nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+	if string(head[:3]) == "ID3" {
+		audioStart = 10 + (int64(head[6])<<21 | int64(head[7])<<14 | int64(head[8])<<7 | int64(head[9]))
+		if head[5]&0x10 != 0 {
+			// footer
+			audioStart += 10
+		}
+
+		tag, err := id3v2.ParseReader(io.NewSectionReader(src, 0, audioStart), id3v2.Options{Parse: true})
+		if err != nil {
+			return ffmpeg.Music{}, nil, fmt.Errorf("解析 ID3 标签失败: %w", err)
+		}
+		pic = applyID3Tag(tag, &m)
+	}
+
+	f, frameOff, err := findMp3Frame(src, audioStart)
+	if err != nil {
+		return ffmpeg.Music{}, nil, err
+	}
+
+	codec := "mp3"
+	if f.layer != 3 {
+		codec = fmt.Sprintf("mp%d", f.layer)
+	}
+	m.Audios = append(m.Audios, ffmpeg.AudioStream{
+		Codec:      codec,
+		Channels:   f.channels,
+		SampleRate: f.sampleRate,
+		Default:    true,
+	})
+	m.Duration = mp3Duration(src, f, frameOff)
+	return m, pic, nil
+}
+
+// findMp3Frame 从指定位置开始查找首个有效的音频帧
+//
+// 要求紧随其后的下一帧也有效, 避免误判
+func findMp3Frame(src Source, start int64) (mp3Frame, int64, error) {
+	n := int(min(mp3SyncScanSize, src.Size()-start))
+	buf, err := readAt(src, start, n)
+	if err != nil {
+		return mp3Frame{}, 0, fmt.Errorf("读取音频帧失败: %w", err)
+	}
+
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseMp3Frame(buf[i:])
+		if !ok {
+			continue
+		}
+		next := i + f.size
+		if next+4 <= len(buf) {
+			if _, ok := parseMp3Frame(buf[next:]); !ok {
+				continue
+			}
+		}
+		return f, start + int64(i), nil
+	}
+	return mp3Frame{}, 0, errors.New("找不到有效的 mp3 音频帧")
+}
+
+// mp3Duration 计算音频时长, 优先使用 Xing/VBRI 中记录的总帧数
+func mp3Duration(src Source, f mp3Frame, frameOff int64) time.Duration {
+	frame, err := readAt(src, frameOff, int(min(int64(f.size), src.Size()-frameOff)))
+	if err == nil {
+		var frames uint32
+		if xing := 4 + f.sideInfo; xing+12 <= len(frame) {
+			tag := string(frame[xing : xing+4])
+			if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(frame[xing+4:])&0x01 != 0 {
+				frames = binary.BigEndian.Uint32(frame[xing+8:])
+			}
+		}
+		if vbri := 36; frames == 0 && vbri+18 <= len(frame) && bytes.Equal(frame[vbri:vbri+4], []byte("VBRI")) {
+			frames = binary.BigEndian.Uint32(frame[vbri+14:])
+		}
+		if frames > 0 {
+			return time.Duration(float64(frames) * float64(f.samples) / float64(f.sampleRate) * float64(time.Second))
+		}
+	}
+
+	// CBR 根据文件大小估算
+	audioBytes := src.Size() - frameOff
+	return time.Duration(float64(audioBytes) * 8 / float64(f.bitrate*1000) * float64(time.Second))
+}
+
+// applyID3Tag 将 ID3v2 标签写入音乐元信息, 返回封面
+func applyID3Tag(tag *id3v2.Tag, m *ffmpeg.Music) []byte {
+	m.Title = tag.Title()
+	m.Artist = tag.Artist()
+	m.Album = tag.Album()
+	m.Date = tag.Year()
+	m.Genre = tag.Genre()
+	m.Track = tag.GetTextFrame("TRCK").Text
+	m.Disc = tag.GetTextFrame("TPOS").Text
+	if tdor := tag.GetTextFrame("TDOR").Text; tdor != "" {
+		m.Date = tdor
+	}
+
+	for _, f := range tag.GetFrames(tag.CommonID("Comments")) {
+		if cf, ok := f.(id3v2.CommentFrame); ok && cf.Text != "" {
+			m.Comment = cf.Text
+			break
+		}
+	}
+	for _, f := range tag.GetFrames(tag.CommonID("Unsynchronised lyrics/text transcription")) {
+		if lf, ok := f.(id3v2.UnsynchronisedLyricsFrame); ok && lf.Lyrics != "" {
+			m.Lyrics = lf.Lyrics
+			break
+		}
+	}
+	fillTitleFromLyrics(m)
+
+	// 优先使用正面封面
+	var pic []byte
+	for _, f := range tag.GetFrames(tag.CommonID("Attached picture")) {
+		pf, ok := f.(id3v2.PictureFrame)
+		if !ok || len(pf.Picture) == 0 {
+			continue
+		}
+		if pic == nil || pf.PictureType == id3v2.PTFrontCover {
+			pic = pf.Picture
+		}
+		if pf.PictureType == id3v2.PTFrontCover {
+			break
+		}
+	}
+	return pic
+}
@@ -0,0 +1,433 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// maxMoovSize moov 的最大读取大小, 防止异常文件读取过多数据
+const maxMoovSize = 64 * 1024 * 1024
+
+// mp4VideoCodecs mp4 视频样本描述类型与 ffmpeg 编码名称的映射
+var mp4VideoCodecs = map[string]string{
+	"avc1": "h264", "avc3": "h264",
+	"hvc1": "hevc", "hev1": "hevc", "dvh1": "hevc", "dvhe": "hevc",
+	"av01": "av1", "vp09": "vp9", "vp08": "vp8",
+	"mp4v": "mpeg4", "m2v1": "mpeg2video", "vc-1": "vc1", "jpeg": "mjpeg",
+	"apcn": "prores", "apch": "prores", "apcs": "prores", "apco": "prores", "ap4h": "prores",
+}
+
+// mp4AudioCodecs mp4 音频样本描述类型与 ffmpeg 编码名称的映射
+var mp4AudioCodecs = map[string]string{
+	"mp4a": "aac", "ac-3": "ac3", "ec-3": "eac3", "mlpa": "truehd",
+	"dtsc": "dts", "dtsh": "dts", "dtsl": "dts", "fLaC": "flac", "Opus": "opus",
+	".mp3": "mp3", "alac": "alac", "sowt": "pcm_s16le", "twos": "pcm_s16be",
+}
+
+// mp4SubtitleCodecs mp4 字幕样本描述类型与 ffmpeg 编码名称的映射
+var mp4SubtitleCodecs = map[string]string{
+	"tx3g": "mov_text", "text": "mov_text", "wvtt": "webvtt",
+	"stpp": "ttml", "mp4s": "dvd_subtitle", "c608": "eia_608",
+}
+
+// inspectMP4 解析 mp4/mov 文件, 只读取 moov 部分
+func inspectMP4(src Source) (ffmpeg.Music, []byte, error) {
+	var off int64
+	for off+8 <= src.Size() {
+		hdr, err := readAt(src, off, 8)
+		if err != nil {
+			return ffmpeg.Music{}, nil, fmt.Errorf("读取 box 头失败: %w", err)
+		}
+
+		size, hdrLen := int64(binary.BigEndian.Uint32(hdr)), int64(8)
+		switch size {
+		case 0:
+			size = src.Size() - off
+		case 1:
+			ext, err := readAt(src, off+8, 8)
+			if err != nil {
+				return ffmpeg.Music{}, nil, fmt.Errorf("读取 box 头失败: %w", err)
+			}
+			size, hdrLen = int64(binary.BigEndian.Uint64(ext)), 16
+		}
+		if size < hdrLen {
+			return ffmpeg.Music{}, nil, fmt.Errorf("非法的 box 大小: %d", size)
+		}
+
+		if string(hdr[4:8]) != "moov" {
+			off += size
+			continue
+		}
+
+		if size > maxMoovSize {
+			return ffmpeg.Music{}, nil, fmt.Errorf("moov 过大: %d", size)
+		}
+		moov, err := readAt(src, off+hdrLen, int(size-hdrLen))
+		if err != nil {
+			return ffmpeg.Music{}, nil, fmt.Errorf("读取 moov 失败: %w", err)
+		}
+		m, pic := parseMoov(moov)
+		return m, pic, nil
+	}
+
+	return ffmpeg.Music{}, nil, errors.New("找不到 moov")
+}
+
+// eachBox 遍历内存中的 box 列表
+func eachBox(b []byte, fn func(typ string, payload []byte)) {
+	for len(b) >= 8 {
+		size, hdrLen := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		if size == 1 {
+			if len(b) < 16 {
+				return
+			}
+			size, hdrLen = binary.BigEndian.Uint64(b[8:]), 16
+		} else if size == 0 {
+			size = uint64(len(b))
+		}
+		if size < hdrLen || size > uint64(len(b)) {
+			return
+		}
+		fn(string(b[4:8]), b[hdrLen:size])
+		b = b[size:]
+	}
+}
+
+// findBox 按路径查找内存中的 box
+func findBox(b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var found []byte
+		eachBox(b, func(t string, payload []byte) {
+			if found == nil && t == typ {
+				found = payload
+			}
+		})
+		if found == nil {
+			return nil
+		}
+		b = found
+	}
+	return b
+}
+
+// parseMoov 解析 moov 中的时长、轨道和标签
+func parseMoov(moov []byte) (ffmpeg.Music, []byte) {
+	m := ffmpeg.Music{}
+
+	if mvhd := findBox(moov, "mvhd"); len(mvhd) >= 32 {
+		var timescale, duration uint64
+		if mvhd[0] == 1 {
+			timescale, duration = uint64(binary.BigEndian.Uint32(mvhd[20:])), binary.BigEndian.Uint64(mvhd[24:])
+		} else {
+			timescale, duration = uint64(binary.BigEndian.Uint32(mvhd[12:])), uint64(binary.BigEndian.Uint32(mvhd[16:]))
+		}
+		m.Duration = scaleDuration(duration, timescale)
+	}
+
+	eachBox(moov, func(typ string, payload []byte) {
+		if typ == "trak" {
+			parseTrak(payload, &m.Info)
+		}
+	})
+
+	var pic []byte
+	if meta := findBox(moov, "udta", "meta"); meta != nil {
+		// iso 的 meta 为 full box, quicktime 则没有 version 和 flags
+		if len(meta) >= 8 && string(meta[4:8]) != "hdlr" {
+			meta = meta[4:]
+		}
+		pic = parseIlst(findBox(meta, "ilst"), &m)
+		fillTitleFromLyrics(&m)
+	}
+	return m, pic
+}
+
+// parseTrak 解析单条轨道
+func parseTrak(trak []byte, info *ffmpeg.Info) {
+	enabled := false
+	if tkhd := findBox(trak, "tkhd"); len(tkhd) >= 4 {
+		enabled = tkhd[3]&0x01 != 0
+	}
+
+	mdia := findBox(trak, "mdia")
+	var timescale, duration uint64
+	lang := ""
+	if mdhd := findBox(mdia, "mdhd"); len(mdhd) >= 24 {
+		langOff := 20
+		if mdhd[0] == 1 && len(mdhd) >= 34 {
+			timescale, duration = uint64(binary.BigEndian.Uint32(mdhd[20:])), binary.BigEndian.Uint64(mdhd[24:])
+			langOff = 32
+		} else {
+			timescale, duration = uint64(binary.BigEndian.Uint32(mdhd[12:])), uint64(binary.BigEndian.Uint32(mdhd[16:]))
+		}
+		lang = unpackLanguage(binary.BigEndian.Uint16(mdhd[langOff:]))
+	}
+
+	handler := ""
+	if hdlr := findBox(mdia, "hdlr"); len(hdlr) >= 12 {
+		handler = string(hdlr[8:12])
+	}
+
+	stbl := findBox(mdia, "minf", "stbl")
+	stsd := findBox(stbl, "stsd")
+	if len(stsd) < 16 {
+		return
+	}
+	entrySize := binary.BigEndian.Uint32(stsd[8:])
+	if entrySize < 16 || int(entrySize) > len(stsd)-8 {
+		return
+	}
+	entryType, entry := string(stsd[12:16]), stsd[16:8+entrySize]
+
+	// mvhd 缺失时长时以轨道时长为准
+	if info.Duration == 0 {
+		info.Duration = scaleDuration(duration, timescale)
+	}
+
+	switch handler {
+	case "vide":
+		if info.Video != nil || len(entry) < 78 {
+			return
+		}
+		v := ffmpeg.VideoStream{
+			Codec:  mp4VideoCodecs[entryType],
+			Width:  int(binary.BigEndian.Uint16(entry[24:])),
+			Height: int(binary.BigEndian.Uint16(entry[26:])),
+		}
+		if v.Codec == "" {
+			v.Codec = entryType
+		}
+		v.FrameRate = sttsFrameRate(findBox(stbl, "stts"), timescale)
+
+		eachBox(entry[78:], func(typ string, payload []byte) {
+			switch typ {
+			case "colr":
+				if len(payload) >= 10 && string(payload[:4]) == "nclx" {
+					setTransfer(&v, int(binary.BigEndian.Uint16(payload[6:])))
+				}
+			case "dvcC", "dvvC":
+				setDoviRecord(&v, payload)
+			}
+		})
+		info.Video = &v
+
+	case "soun":
+		if len(entry) < 28 {
+			return
+		}
+		a := ffmpeg.AudioStream{
+			Codec:      mp4AudioCodecs[entryType],
+			Language:   lang,
+			Channels:   int(binary.BigEndian.Uint16(entry[16:])),
+			SampleRate: int(binary.BigEndian.Uint32(entry[24:]) >> 16),
+			Default:    enabled,
+		}
+		if a.Codec == "" {
+			a.Codec = entryType
+		}
+		if a.SampleRate == 0 {
+			a.SampleRate = int(timescale)
+		}
+
+		// quicktime 的 v1 和 v2 声音描述带有额外字段
+		childOff := 28
+		switch binary.BigEndian.Uint16(entry[8:]) {
+		case 1:
+			childOff += 16
+		case 2:
+			childOff += 36
+		}
+		if entryType == "mp4a" && len(entry) > childOff {
+			if esds := findBox(entry[childOff:], "esds"); esds != nil {
+				a.Codec = esdsCodec(esds, a.Codec)
+			}
+		}
+		info.Audios = append(info.Audios, a)
+
+	case "sbtl", "text", "subt", "subp", "clcp":
+		codec, ok := mp4SubtitleCodecs[entryType]
+		if !ok {
+			codec = entryType
+		}
+		info.Subtitles = append(info.Subtitles, ffmpeg.SubtitleStream{
+			Codec:    codec,
+			Language: lang,
+			Default:  enabled,
+		})
+	}
+}
+
+// sttsFrameRate 根据 stts 计算平均帧率
+func sttsFrameRate(stts []byte, timescale uint64) float64 {
+	if len(stts) < 8 || timescale == 0 {
+		return 0
+	}
+	n := int(binary.BigEndian.Uint32(stts[4:]))
+	var frames, total uint64
+	for i := 0; i < n && 8+i*8+8 <= len(stts); i++ {
+		count := uint64(binary.BigEndian.Uint32(stts[8+i*8:]))
+		delta := uint64(binary.BigEndian.Uint32(stts[12+i*8:]))
+		frames += count
+		total += count * delta
+	}
+	if total == 0 {
+		return 0
+	}
+	fps := float64(frames) * float64(timescale) / float64(total)
+	res, _ := strconv.ParseFloat(strconv.FormatFloat(fps, 'f', 3, 64), 64)
+	return res
+}
+
+// esdsCodec 根据 esds 中的 objectTypeIndication 识别编码
+func esdsCodec(esds []byte, dft string) string {
+	if len(esds) < 4 {
+		return dft
+	}
+	b := esds[4:]
+
+	// readDescriptor 读取描述符的 tag 和长度
+	readDescriptor := func(b []byte) (tag byte, body []byte, ok bool) {
+		if len(b) < 2 {
+			return 0, nil, false
+		}
+		tag, b = b[0], b[1:]
+		size := 0
+		for i := 0; i < 4 && len(b) > 0; i++ {
+			c := b[0]
+			b = b[1:]
+			size = size<<7 | int(c&0x7f)
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		if size > len(b) {
+			size = len(b)
+		}
+		return tag, b[:size], true
+	}
+
+	tag, es, ok := readDescriptor(b)
+	if !ok || tag != 0x03 || len(es) < 3 {
+		return dft
+	}
+	flags := es[2]
+	es = es[3:]
+	if flags&0x80 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	if flags&0x40 != 0 {
+		if len(es) < 1 || 1+int(es[0]) > len(es) {
+			return dft
+		}
+		es = es[1+int(es[0]):]
+	}
+	if flags&0x20 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+
+	tag, dc, ok := readDescriptor(es)
+	if !ok || tag != 0x04 || len(dc) < 1 {
+		return dft
+	}
+	switch dc[0] {
+	case 0x40, 0x66, 0x67, 0x68:
+		return "aac"
+	case 0x69, 0x6b:
+		return "mp3"
+	case 0xa5:
+		return "ac3"
+	case 0xa6:
+		return "eac3"
+	case 0xa9:
+		return "dts"
+	case 0xad:
+		return "opus"
+	}
+	return dft
+}
+
+// parseIlst 解析 iTunes 风格的元数据标签, 返回封面
+func parseIlst(ilst []byte, m *ffmpeg.Music) []byte {
+	var pic []byte
+	var track, trackTotal, disc, discTotal int
+
+	eachBox(ilst, func(typ string, item []byte) {
+		data := findBox(item, "data")
+		if len(data) < 8 {
+			return
+		}
+		value := data[8:]
+
+		switch typ {
+		case "\xa9nam":
+			m.Title = string(value)
+		case "\xa9ART":
+			m.Artist = string(value)
+		case "\xa9alb":
+			m.Album = string(value)
+		case "\xa9day":
+			m.Date = string(value)
+		case "\xa9gen":
+			m.Genre = string(value)
+		case "\xa9cmt":
+			m.Comment = string(value)
+		case "\xa9lyr":
+			m.Lyrics = string(value)
+		case "trkn":
+			if len(value) >= 6 {
+				track, trackTotal = int(binary.BigEndian.Uint16(value[2:])), int(binary.BigEndian.Uint16(value[4:]))
+			}
+		case "disk":
+			if len(value) >= 6 {
+				disc, discTotal = int(binary.BigEndian.Uint16(value[2:])), int(binary.BigEndian.Uint16(value[4:]))
+			}
+		case "covr":
+			if pic == nil {
+				pic = append([]byte(nil), value...)
+			}
+		}
+	})
+
+	m.Track = formatPart(track, trackTotal)
+	m.Disc = formatPart(disc, discTotal)
+	return pic
+}
+
+// formatPart 格式化轨道号或光盘号, 如 1/12
+func formatPart(n, total int) string {
+	if n <= 0 {
+		return ""
+	}
+	if total <= 0 {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+// unpackLanguage 解析 mdhd 中的 15 位语言代码, 未知语言返回空串
+func unpackLanguage(code uint16) string {
+	b := []byte{
+		byte(code>>10&0x1f) + 0x60,
+		byte(code>>5&0x1f) + 0x60,
+		byte(code&0x1f) + 0x60,
+	}
+	for _, c := range b {
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+	return normalizeLanguage(string(b))
+}
+
+// scaleDuration 将时间刻度下的时长转换为 time.Duration
+func scaleDuration(duration, timescale uint64) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
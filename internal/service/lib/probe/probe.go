@@ -0,0 +1,125 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// ErrUnsupported 原生解析器不支持的媒体格式
+var ErrUnsupported = errors.New("不支持的媒体格式")
+
+// Source 可随机读取的媒体源
+type Source interface {
+	io.ReaderAt
+
+	// Size 媒体源总大小
+	Size() int64
+}
+
+// format 原生支持的媒体格式
+type format int
+
+const (
+	formatUnknown format = iota
+	formatMP4
+	formatMKV
+	formatFLAC
+	formatMP3
+)
+
+// Inspect 原生解析媒体源的元信息, 不依赖 ffmpeg
+//
+// 返回值中的封面图仅在媒体内嵌了封面时存在
+func Inspect(src Source) (ffmpeg.Music, []byte, error) {
+	head := make([]byte, 12)
+	if _, err := src.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return ffmpeg.Music{}, nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+
+	switch sniff(head) {
+	case formatMP4:
+		return inspectMP4(src)
+	case formatMKV:
+		return inspectMKV(src)
+	case formatFLAC:
+		return inspectFLAC(src)
+	case formatMP3:
+		return inspectMP3(src)
+	default:
+		return ffmpeg.Music{}, nil, ErrUnsupported
+	}
+}
+
+// InspectInfo 解析远程媒体的元信息
+//
+// 优先通过 Range 请求原生解析文件头, 失败时若 ffmpeg 可用则回退到 ffmpeg
+func InspectInfo(url string) (ffmpeg.Info, error) {
+	m, _, err := inspectUrl(url)
+	if err == nil {
+		return m.Info, nil
+	}
+	if !ffmpeg.Ready() {
+		return ffmpeg.Info{}, err
+	}
+	return ffmpeg.InspectInfo(url)
+}
+
+// InspectMusic 解析远程音乐的元信息和内嵌封面
+//
+// 优先通过 Range 请求原生解析文件头, 失败时若 ffmpeg 可用则回退到 ffmpeg
+func InspectMusic(url string) (ffmpeg.Music, []byte, error) {
+	m, pic, err := inspectUrl(url)
+	if err == nil {
+		return m, pic, nil
+	}
+	if !ffmpeg.Ready() {
+		return ffmpeg.Music{}, nil, err
+	}
+
+	if m, err = ffmpeg.InspectMusic(url); err != nil {
+		return ffmpeg.Music{}, nil, err
+	}
+	pic, err = ffmpeg.ExtractMusicCover(url)
+	return m, pic, err
+}
+
+// inspectUrl 通过 Range 请求原生解析远程媒体
+func inspectUrl(url string) (ffmpeg.Music, []byte, error) {
+	src, err := NewHttpSource(url)
+	if err != nil {
+		return ffmpeg.Music{}, nil, err
+	}
+	return Inspect(src)
+}
+
+// sniff 根据文件头识别媒体格式
+func sniff(head []byte) format {
+	switch {
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return formatMP4
+	case len(head) >= 4 && string(head[:4]) == "\x1a\x45\xdf\xa3":
+		return formatMKV
+	case len(head) >= 4 && string(head[:4]) == "fLaC":
+		return formatFLAC
+	case len(head) >= 3 && string(head[:3]) == "ID3":
+		return formatMP3
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0:
+		return formatMP3
+	}
+	return formatUnknown
+}
+
+// readAt 从媒体源指定位置读取 n 个字节
+func readAt(src Source, off int64, n int) ([]byte, error) {
+	if n < 0 || off < 0 || off+int64(n) > src.Size() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := src.ReadAt(buf, off); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf, nil
+}
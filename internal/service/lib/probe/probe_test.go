@@ -0,0 +1,325 @@
+package probe_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/service/lib/probe"
+	"github.com/syscc/Emby-Go/internal/service/music"
+	"github.com/syscc/Emby-Go/internal/util/mp4s"
+)
+
+// serve 启动支持 Range 请求的测试服务器
+func serve(t *testing.T, data []byte) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "media", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// inspect 通过 Range 请求解析测试数据, 并校验只读取了文件的一小部分
+func inspect(t *testing.T, data []byte) (ffmpeg.Music, []byte) {
+	src, err := probe.NewHttpSource(serve(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Size() != int64(len(data)) {
+		t.Fatalf("size: want %d, got %d", len(data), src.Size())
+	}
+	m, pic, err := probe.Inspect(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Fetched() > int64(len(data))/4 {
+		t.Fatalf("fetched too much: %d / %d", src.Fetched(), len(data))
+	}
+	return m, pic
+}
+
+func TestInspectMP4(t *testing.T) {
+	data := mp4s.GenWithTracks(time.Minute*95,
+		mp4s.Track{Kind: mp4s.TrackVideo, Codec: "hevc", Width: 3840, Height: 2160, FrameRate: 23.976, ColorTransfer: mp4s.TransferPQ},
+		mp4s.Track{Kind: mp4s.TrackAudio, Codec: "eac3", Language: "chi", Default: true, Channels: 6, SampleRate: 48000},
+		mp4s.Track{Kind: mp4s.TrackSubtitle, Codec: "mov_text", Language: "eng"},
+	)
+	// 追加一个较大的 free box, 模拟媒体数据
+	pad := make([]byte, 4*1024*1024)
+	binary.BigEndian.PutUint32(pad, uint32(len(pad)))
+	copy(pad[4:], "free")
+	data = append(data, pad...)
+
+	m, _ := inspect(t, data)
+	if m.Duration != time.Minute*95 {
+		t.Errorf("duration: %v", m.Duration)
+	}
+	v := m.Video
+	if v == nil || v.Codec != "hevc" || v.Width != 3840 || v.Height != 2160 || v.HDR != ffmpeg.HDR10 {
+		t.Fatalf("video: %+v", v)
+	}
+	if math.Abs(v.FrameRate-23.976) > 0.01 {
+		t.Errorf("framerate: %v", v.FrameRate)
+	}
+	if len(m.Audios) != 1 || m.Audios[0].Codec != "eac3" || m.Audios[0].Language != "chi" || m.Audios[0].Channels != 6 || m.Audios[0].SampleRate != 48000 {
+		t.Errorf("audios: %+v", m.Audios)
+	}
+	if len(m.Subtitles) != 1 || m.Subtitles[0].Language != "eng" {
+		t.Errorf("subtitles: %+v", m.Subtitles)
+	}
+}
+
+func TestInspectFLAC(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("fLaC")
+
+	// STREAMINFO: 44100Hz, 2 声道, 16bit, 共 3 分钟
+	info := make([]byte, 34)
+	samples := uint64(44100 * 180)
+	binary.BigEndian.PutUint64(info[10:], uint64(44100)<<44|uint64(1)<<41|uint64(15)<<36|samples)
+	writeFlacBlock(&buf, 0, false, info)
+
+	// VORBIS_COMMENT
+	var vc bytes.Buffer
+	writeLE32String(&vc, "test")
+	comments := []string{"TITLE=哥哥", "ARTIST=王栎鑫", "ALBUM=专辑", "TRACKNUMBER=3", "TRACKTOTAL=10", "DATE=2008"}
+	binary.Write(&vc, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		writeLE32String(&vc, c)
+	}
+	writeFlacBlock(&buf, 4, false, vc.Bytes())
+
+	// PICTURE: 正面封面
+	cover := []byte("\x89PNG fake cover")
+	var pb bytes.Buffer
+	binary.Write(&pb, binary.BigEndian, uint32(3))
+	writeBE32String(&pb, "image/png")
+	writeBE32String(&pb, "")
+	pb.Write(make([]byte, 16))
+	writeBE32String(&pb, string(cover))
+	writeFlacBlock(&buf, 6, true, pb.Bytes())
+
+	buf.Write(make([]byte, 2*1024*1024))
+
+	m, pic := inspect(t, buf.Bytes())
+	if m.Duration != time.Minute*3 {
+		t.Errorf("duration: %v", m.Duration)
+	}
+	if m.Title != "哥哥" || m.Artist != "王栎鑫" || m.Album != "专辑" || m.Date != "2008" {
+		t.Errorf("tags: %+v", m)
+	}
+	if m.Track != "3/10" {
+		t.Errorf("track: %s", m.Track)
+	}
+	if len(m.Audios) != 1 || m.Audios[0].Codec != "flac" || m.Audios[0].SampleRate != 44100 || m.Audios[0].Channels != 2 {
+		t.Errorf("audios: %+v", m.Audios)
+	}
+	if !bytes.Equal(pic, cover) {
+		t.Errorf("cover: %q", pic)
+	}
+}
+
+func TestInspectMP3(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "test.mp3")
+	cover := []byte("\xff\xd8 fake cover")
+	meta := ffmpeg.Music{Title: "苴却砚", Artist: "林霞", Album: "专辑", Date: "2020"}
+	meta.Duration = time.Minute * 4
+	if err := music.WriteFakeMP3(fp, meta, cover); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := probe.NewHttpSource(serve(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, pic, err := probe.Inspect(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != meta.Title || m.Artist != meta.Artist || m.Album != meta.Album {
+		t.Errorf("tags: %+v", m)
+	}
+	if d := m.Duration - meta.Duration; d < 0 || d > time.Second {
+		t.Errorf("duration: %v", m.Duration)
+	}
+	if len(m.Audios) != 1 || m.Audios[0].Codec != "mp3" || m.Audios[0].SampleRate != 8000 || m.Audios[0].Channels != 1 {
+		t.Errorf("audios: %+v", m.Audios)
+	}
+	if !bytes.Equal(pic, cover) {
+		t.Errorf("cover: %q", pic)
+	}
+}
+
+func TestInspectMKV(t *testing.T) {
+	ebml := func(id uint32, children ...[]byte) []byte {
+		var b bytes.Buffer
+		for s := 24; s >= 0; s -= 8 {
+			if id>>s != 0 {
+				b.WriteByte(byte(id >> s))
+			}
+		}
+		payload := bytes.Join(children, nil)
+		size := make([]byte, 8)
+		binary.BigEndian.PutUint64(size, uint64(len(payload)))
+		size[0] = 0x01
+		b.Write(size)
+		b.Write(payload)
+		return b.Bytes()
+	}
+	u := func(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+	f := func(v float64) []byte { return binary.BigEndian.AppendUint64(nil, math.Float64bits(v)) }
+	s := func(v string) []byte { return []byte(v) }
+
+	var buf bytes.Buffer
+	buf.Write(ebml(0x1a45dfa3, ebml(0x4282, s("matroska"))))
+	buf.Write(ebml(0x18538067,
+		ebml(0x1549a966, ebml(0x2ad7b1, u(1000000)), ebml(0x4489, f(float64(time.Hour/time.Millisecond)))),
+		ebml(0x1654ae6b,
+			ebml(0xae, ebml(0x83, u(1)), ebml(0x86, s("V_MPEG4/ISO/AVC")), ebml(0x23e383, u(41708333)),
+				ebml(0xe0, ebml(0xb0, u(1920)), ebml(0xba, u(1080)))),
+			ebml(0xae, ebml(0x83, u(2)), ebml(0x86, s("A_AC3")), ebml(0x22b59c, s("jpn")),
+				ebml(0xe1, ebml(0xb5, f(48000)), ebml(0x9f, u(6)))),
+			ebml(0xae, ebml(0x83, u(17)), ebml(0x86, s("S_TEXT/ASS")), ebml(0x22b59c, s("chi")),
+				ebml(0x88, u(0)), ebml(0x55aa, u(1))),
+		),
+		ebml(0x1f43b675, make([]byte, 2*1024*1024)),
+	))
+
+	m, _ := inspect(t, buf.Bytes())
+	if m.Duration != time.Hour {
+		t.Errorf("duration: %v", m.Duration)
+	}
+	if v := m.Video; v == nil || v.Codec != "h264" || v.Width != 1920 || v.Height != 1080 || math.Abs(v.FrameRate-23.976) > 0.01 {
+		t.Errorf("video: %+v", v)
+	}
+	if len(m.Audios) != 1 || m.Audios[0].Codec != "ac3" || m.Audios[0].Language != "jpn" || m.Audios[0].Channels != 6 {
+		t.Errorf("audios: %+v", m.Audios)
+	}
+	if len(m.Subtitles) != 1 || m.Subtitles[0].Codec != "ass" || m.Subtitles[0].Default || !m.Subtitles[0].Forced {
+		t.Errorf("subtitles: %+v", m.Subtitles)
+	}
+}
+
+func TestInspectUnsupported(t *testing.T) {
+	data := []byte(strings.Repeat("RIFF not supported", 100))
+	src, err := probe.NewHttpSource(serve(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := probe.Inspect(src); err != probe.ErrUnsupported {
+		t.Fatalf("want ErrUnsupported, got %v", err)
+	}
+}
+
+func TestInspectMP4Esds(t *testing.T) {
+	data := mp4s.GenWithTracks(time.Minute,
+		mp4s.Track{Kind: mp4s.TrackVideo, Codec: "h264", Width: 1920, Height: 1080, FrameRate: 25},
+		mp4s.Track{Kind: mp4s.TrackAudio, Codec: "aac", Channels: 2, SampleRate: 48000},
+	)
+	pad := make([]byte, 1024*1024)
+	binary.BigEndian.PutUint32(pad, uint32(len(pad)))
+	copy(pad[4:], "free")
+	data = append(data, pad...)
+
+	// esds 内容: ES_Descriptor 标志位之后为可选的 URL, 再之后是 DecoderConfigDescriptor
+	cases := []struct {
+		name string
+		es   []byte
+		want string
+	}{
+		{"mp3", []byte{0x03, 0x08, 0x00, 0x01, 0x00, 0x04, 0x03, 0x6b, 0x15, 0x00}, "mp3"},
+		{"url", []byte{0x03, 0x0a, 0x00, 0x01, 0x40, 0x01, 'u', 0x04, 0x03, 0xa6, 0x15, 0x00}, "eac3"},
+		{"url length overflow", []byte{0x03, 0x04, 0x00, 0x01, 0x40, 0xff}, "aac"},
+		{"url length missing", []byte{0x03, 0x03, 0x00, 0x01, 0x40}, "aac"},
+		{"descriptor length overflow", []byte{0x03, 0xff, 0xff, 0xff, 0x7f, 0x00}, "aac"},
+		{"truncated", []byte{0x03}, "aac"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, _ := inspect(t, withEsds(t, data, append(make([]byte, 4), c.es...)))
+			if len(m.Audios) != 1 || m.Audios[0].Codec != c.want {
+				t.Errorf("audios: %+v, want codec %s", m.Audios, c.want)
+			}
+		})
+	}
+}
+
+// withEsds 在 mp4a 样本描述的末尾插入 esds box, 并修正所有上层 box 的大小
+func withEsds(t *testing.T, data, esds []byte) []byte {
+	box := make([]byte, 8, 8+len(esds))
+	binary.BigEndian.PutUint32(box, uint32(8+len(esds)))
+	copy(box[4:], "esds")
+	box = append(box, esds...)
+
+	// 按层级查找 mp4a box, 记录路径上每个 box 的起始位置
+	var parents []int
+	var find func(start, end int) int
+	find = func(start, end int) int {
+		for off := start; off+8 <= end; {
+			size := int(binary.BigEndian.Uint32(data[off:]))
+			if size < 8 || off+size > end {
+				return -1
+			}
+			switch string(data[off+4 : off+8]) {
+			case "mp4a":
+				parents = append(parents, off)
+				return off + size
+			case "moov", "trak", "mdia", "minf", "stbl", "stsd":
+				header := 8
+				if string(data[off+4:off+8]) == "stsd" {
+					header = 16
+				}
+				parents = append(parents, off)
+				if pos := find(off+header, off+size); pos != -1 {
+					return pos
+				}
+				parents = parents[:len(parents)-1]
+			}
+			off += size
+		}
+		return -1
+	}
+	pos := find(0, len(data))
+	if pos == -1 {
+		t.Fatal("mp4a box not found")
+	}
+
+	out := append(append(append([]byte{}, data[:pos]...), box...), data[pos:]...)
+	for _, off := range parents {
+		binary.BigEndian.PutUint32(out[off:], binary.BigEndian.Uint32(out[off:])+uint32(len(box)))
+	}
+	return out
+}
+
+// writeFlacBlock 写入一个 flac 元数据块
+func writeFlacBlock(buf *bytes.Buffer, typ byte, last bool, data []byte) {
+	if last {
+		typ |= 0x80
+	}
+	buf.Write([]byte{typ, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))})
+	buf.Write(data)
+}
+
+// writeLE32String 写入小端长度前缀的字符串
+func writeLE32String(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// writeBE32String 写入大端长度前缀的字符串
+func writeBE32String(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
@@ -0,0 +1,161 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/syscc/Emby-Go/internal/constant"
+	"github.com/syscc/Emby-Go/internal/util/https"
+)
+
+// blockSize 每次 Range 请求读取的最小块大小
+const blockSize = 64 * 1024
+
+// ErrRangeUnsupported 远程服务器不支持 Range 请求
+var ErrRangeUnsupported = errors.New("远程服务器不支持 Range 请求")
+
+// HttpSource 通过 Range 请求按需读取远程文件, 已读取的块会被缓存
+type HttpSource struct {
+	url  string
+	size int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+
+	// fetched 记录实际从远程读取的字节数
+	fetched int64
+}
+
+// NewHttpSource 初始化远程文件源, 会发起一次 Range 请求获取文件总大小
+func NewHttpSource(url string) (*HttpSource, error) {
+	s := &HttpSource{url: url, blocks: make(map[int64][]byte)}
+
+	// 读取首个块的同时, 从 Content-Range 中解析文件总大小
+	data, total, err := s.fetch(0, blockSize-1)
+	if err != nil {
+		return nil, err
+	}
+	if total <= 0 {
+		return nil, ErrRangeUnsupported
+	}
+	s.size = total
+	s.blocks[0] = data
+	return s, nil
+}
+
+// Size 远程文件总大小
+func (s *HttpSource) Size() int64 {
+	return s.size
+}
+
+// Fetched 实际从远程读取的字节数
+func (s *HttpSource) Fetched() int64 {
+	return atomic.LoadInt64(&s.fetched)
+}
+
+// ReadAt 实现 io.ReaderAt, 连续缺失的块合并为一次 Range 请求
+func (s *HttpSource) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("非法的读取偏移")
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > s.size {
+		end = s.size
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, last := off/blockSize, (end-1)/blockSize
+	for idx := first; idx <= last; {
+		if _, ok := s.blocks[idx]; ok {
+			idx++
+			continue
+		}
+
+		missEnd := idx
+		for missEnd+1 <= last {
+			if _, ok := s.blocks[missEnd+1]; ok {
+				break
+			}
+			missEnd++
+		}
+
+		rangeEnd := min((missEnd+1)*blockSize, s.size) - 1
+		data, _, err := s.fetch(idx*blockSize, rangeEnd)
+		if err != nil {
+			return 0, err
+		}
+		for i := idx; i <= missEnd; i++ {
+			lo := (i - idx) * blockSize
+			hi := min(lo+blockSize, int64(len(data)))
+			if lo >= hi {
+				return 0, io.ErrUnexpectedEOF
+			}
+			s.blocks[i] = data[lo:hi]
+		}
+		idx = missEnd + 1
+	}
+
+	n := 0
+	for idx := first; idx <= last; idx++ {
+		block := s.blocks[idx]
+		lo := int64(0)
+		if idx == first {
+			lo = off - idx*blockSize
+		}
+		if lo >= int64(len(block)) {
+			break
+		}
+		n += copy(p[n:], block[lo:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch 请求远程文件的 [start, end] 区间, 返回数据和 Content-Range 中的文件总大小
+func (s *HttpSource) fetch(start, end int64) ([]byte, int64, error) {
+	resp, err := https.Get(s.url).
+		AddHeader("User-Agent", constant.CommonDlUserAgent).
+		AddHeader("Range", fmt.Sprintf("bytes=%d-%d", start, end)).
+		Do()
+	if err != nil {
+		return nil, 0, fmt.Errorf("请求远程文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		if https.IsSuccessCode(resp.StatusCode) {
+			return nil, 0, ErrRangeUnsupported
+		}
+		return nil, 0, fmt.Errorf("请求远程文件失败, 响应状态: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, end-start+1))
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取远程文件失败: %w", err)
+	}
+	atomic.AddInt64(&s.fetched, int64(len(data)))
+
+	// Content-Range: bytes 0-65535/123456
+	var total int64
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if idx := strings.LastIndex(cr, "/"); idx != -1 {
+			total, _ = strconv.ParseInt(cr[idx+1:], 10, 64)
+		}
+	}
+	return data, total, nil
+}
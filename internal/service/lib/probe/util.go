@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strings"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// lyricsTitleReg 从 lrc 歌词的 ti 属性中提取标题
+var lyricsTitleReg = regexp.MustCompile(`(?m)^\[ti:(.*?)\]`)
+
+// setTransfer 根据色彩传输特性 (ISO/IEC 23091-2) 设置视频的 HDR 信息
+func setTransfer(v *ffmpeg.VideoStream, transfer int) {
+	switch transfer {
+	case 16:
+		v.ColorTransfer = "smpte2084"
+		if v.HDR == "" {
+			v.HDR = ffmpeg.HDR10
+		}
+	case 18:
+		v.ColorTransfer = "arib-std-b67"
+		if v.HDR == "" {
+			v.HDR = ffmpeg.HLG
+		}
+	}
+}
+
+// setDoviRecord 解析杜比视界配置记录 (dvcC/dvvC)
+func setDoviRecord(v *ffmpeg.VideoStream, record []byte) {
+	if len(record) < 4 {
+		return
+	}
+	bits := binary.BigEndian.Uint16(record[2:])
+	v.DVProfile = int(bits >> 9 & 0x7f)
+	v.DVLevel = int(bits >> 3 & 0x3f)
+	v.HDR = ffmpeg.DolbyVision
+}
+
+// normalizeLanguage 规范化语言代码, 未知语言返回空串
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "und" || len(lang) != 3 {
+		return ""
+	}
+	return lang
+}
+
+// applyVorbisComments 将 Vorbis 风格的标签写入音乐元信息
+//
+// FLAC 和 Matroska 均使用这种键值对形式的标签, 键不区分大小写
+func applyVorbisComments(m *ffmpeg.Music, comments map[string]string) {
+	get := func(keys ...string) string {
+		for _, k := range keys {
+			if v := strings.TrimSpace(comments[k]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	m.Title = get("TITLE")
+	m.Artist = get("ARTIST", "ALBUMARTIST", "ALBUM ARTIST")
+	m.Album = get("ALBUM")
+	m.Date = get("DATE", "DATE_RELEASED", "YEAR")
+	m.Genre = get("GENRE")
+	m.Comment = get("COMMENT", "DESCRIPTION")
+	m.Lyrics = get("LYRICS", "UNSYNCEDLYRICS")
+	m.Track = joinPart(get("TRACKNUMBER", "PART_NUMBER"), get("TRACKTOTAL", "TOTALTRACKS", "TOTAL_PARTS"))
+	m.Disc = joinPart(get("DISCNUMBER"), get("DISCTOTAL", "TOTALDISCS"))
+	fillTitleFromLyrics(m)
+}
+
+// joinPart 拼接编号和总数, 如 1/12
+func joinPart(n, total string) string {
+	if n == "" || strings.Contains(n, "/") || total == "" {
+		return n
+	}
+	return n + "/" + total
+}
+
+// fillTitleFromLyrics 标题为空时尝试从歌词的 ti 属性中提取
+func fillTitleFromLyrics(m *ffmpeg.Music) {
+	if m.Title != "" {
+		return
+	}
+	if res := lyricsTitleReg.FindStringSubmatch(m.Lyrics); len(res) == 2 {
+		m.Title = strings.TrimSpace(res[1])
+	}
+}
@@ -0,0 +1,85 @@
+package subtitle
+
+import (
+	"regexp"
+	"strings"
+)
+
+// assOverrideReg 匹配 ass 文本中的样式覆盖标签, 如 {\pos(1,2)\fs20}
+var assOverrideReg = regexp.MustCompile(`\{[^}]*\}`)
+
+// assDrawingReg 匹配 ass 的绘图模式标签, 绘图指令不是文本
+var assDrawingReg = regexp.MustCompile(`\\p[1-9]`)
+
+// defaultASSFields 缺少 Format 行时使用的默认字段顺序
+var defaultASSFields = []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+
+// parseASS 解析 ass/ssa 字幕中 [Events] 段的 Dialogue 行
+func parseASS(text string) []Cue {
+	var cues []Cue
+	inEvents := false
+	fields := defaultASSFields
+
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			fields = fields[:0:0]
+			for f := range strings.SplitSeq(value, ",") {
+				fields = append(fields, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			if cue, ok := parseDialogue(value, fields); ok {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues
+}
+
+// parseDialogue 按 Format 定义的字段解析一行 Dialogue, Text 字段始终是最后一个字段
+func parseDialogue(value string, fields []string) (Cue, bool) {
+	parts := strings.SplitN(value, ",", len(fields))
+	if len(parts) != len(fields) {
+		return Cue{}, false
+	}
+
+	var cue Cue
+	var startOk, endOk bool
+	for i, f := range fields {
+		switch f {
+		case "start":
+			cue.Start, startOk = parseTimestamp(parts[i])
+		case "end":
+			cue.End, endOk = parseTimestamp(parts[i])
+		case "text":
+			cue.Text = cleanASSText(parts[i])
+		}
+	}
+	if !startOk || !endOk || cue.Text == "" {
+		return Cue{}, false
+	}
+	return cue, true
+}
+
+// cleanASSText 去除 ass 文本中的样式标签, 并转换换行符
+func cleanASSText(text string) string {
+	if assDrawingReg.MatchString(text) {
+		return ""
+	}
+	text = assOverrideReg.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+	return strings.TrimSpace(text)
+}
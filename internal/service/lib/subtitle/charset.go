@@ -0,0 +1,97 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 支持的字幕编码
+const (
+	CharsetAuto  = "auto"
+	CharsetUTF8  = "utf-8"
+	CharsetUTF16 = "utf-16"
+	CharsetGBK   = "gbk"
+	CharsetBig5  = "big5"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// Decode 将指定编码的字幕转换为 UTF-8
+//
+// charset 为空时视为 UTF-8, 为 auto 时自动识别编码
+func Decode(data []byte, charset string) ([]byte, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == CharsetAuto {
+		charset = DetectCharset(data)
+	}
+
+	var enc encoding.Encoding
+	switch charset {
+	case "", CharsetUTF8, "utf8":
+		return bytes.TrimPrefix(data, utf8BOM), nil
+	case CharsetUTF16:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case CharsetGBK, "gb2312", "gb18030":
+		enc = simplifiedchinese.GB18030
+	case CharsetBig5:
+		enc = traditionalchinese.Big5
+	default:
+		return nil, fmt.Errorf("不支持的字幕编码: %s", charset)
+	}
+
+	res, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("字幕编码转换失败: %w", err)
+	}
+	return res, nil
+}
+
+// DetectCharset 识别字幕的编码
+//
+// 带 BOM 或合法的 UTF-8 文本直接返回对应编码,
+// 否则按双字节的分布特征在 GBK 和 Big5 之间选择, 无法区分时视为 GBK
+func DetectCharset(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return CharsetUTF8
+	case bytes.HasPrefix(data, utf16LEBOM), bytes.HasPrefix(data, utf16BEBOM):
+		return CharsetUTF16
+	case utf8.Valid(data):
+		return CharsetUTF8
+	}
+
+	gbScore, big5Score := 0, 0
+	for i := 0; i+1 < len(data); i++ {
+		lead, trail := data[i], data[i+1]
+		if lead < 0x81 || lead == 0xFF {
+			continue
+		}
+		i++
+		switch {
+		case trail >= 0x40 && trail <= 0x7E:
+			// GB2312 的次字节不会落在 ASCII 区间, Big5 的常用字则大量使用
+			big5Score++
+		case lead >= 0xA4 && lead <= 0xAF:
+			// Big5 的常用字起始区, 在 GB2312 中是假名、制表符等非汉字区
+			big5Score++
+		case lead >= 0xC7 && lead <= 0xF7:
+			// GB2312 的汉字区, 在 Big5 中是罕用字区
+			gbScore++
+		}
+	}
+	if big5Score > gbScore {
+		return CharsetBig5
+	}
+	return CharsetGBK
+}
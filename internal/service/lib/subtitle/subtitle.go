@@ -0,0 +1,213 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported 不支持转换的字幕格式
+var ErrUnsupported = errors.New("不支持的字幕格式")
+
+// 支持转换的字幕格式
+const (
+	FormatSRT = "srt"
+	FormatASS = "ass"
+	FormatSSA = "ssa"
+	FormatVTT = "vtt"
+)
+
+// Cue 一条字幕
+type Cue struct {
+	Start time.Duration // 开始时间
+	End   time.Duration // 结束时间
+	Text  string        // 字幕文本, 多行使用 \n 分隔
+}
+
+// fontTagReg 匹配 srt 中 WebVTT 不支持的 font 标签
+var fontTagReg = regexp.MustCompile(`(?i)</?font[^>]*>`)
+
+// FormatOf 根据文件名或 emby 字幕地址判断字幕格式, 无法识别时返回空串
+func FormatOf(name string) string {
+	if idx := strings.IndexAny(name, "?#"); idx != -1 {
+		name = name[:idx]
+	}
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")); ext {
+	case FormatSRT, FormatASS, FormatSSA, FormatVTT:
+		return ext
+	case "subrip":
+		return FormatSRT
+	case "webvtt":
+		return FormatVTT
+	default:
+		return ""
+	}
+}
+
+// Parse 解析 UTF-8 编码的字幕文本
+func Parse(data []byte, format string) ([]Cue, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+
+	var cues []Cue
+	switch format {
+	case FormatSRT, FormatVTT:
+		cues = parseBlocks(string(data))
+	case FormatASS, FormatSSA:
+		cues = parseASS(string(data))
+	default:
+		return nil, ErrUnsupported
+	}
+	slices.SortStableFunc(cues, func(a, b Cue) int {
+		return int(a.Start - b.Start)
+	})
+	return cues, nil
+}
+
+// Shift 将字幕时间轴整体偏移 offset
+//
+// 偏移后完全处于 0 之前的字幕会被丢弃
+func Shift(cues []Cue, offset time.Duration) []Cue {
+	if offset == 0 {
+		return cues
+	}
+	res := cues[:0]
+	for _, cue := range cues {
+		cue.Start, cue.End = cue.Start+offset, cue.End+offset
+		if cue.End <= 0 {
+			continue
+		}
+		cue.Start = max(cue.Start, 0)
+		res = append(res, cue)
+	}
+	return res
+}
+
+// WriteVTT 将字幕序列化为 WebVTT 文本
+func WriteVTT(cues []Cue) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		text := strings.TrimSpace(cue.Text)
+		if text == "" {
+			continue
+		}
+		// WebVTT 中文本不能出现空行以及时间分隔符
+		text = strings.ReplaceAll(text, "-->", "->")
+		lines := slices.DeleteFunc(strings.Split(text, "\n"), func(l string) bool {
+			return strings.TrimSpace(l) == ""
+		})
+		fmt.Fprintf(&buf, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start), formatTimestamp(cue.End), strings.Join(lines, "\n"))
+	}
+	return buf.Bytes()
+}
+
+// ToVTT 将任意编码的字幕转换为 WebVTT
+//
+// charset 为 Decode 支持的编码, offset 为时间轴偏移
+func ToVTT(data []byte, format, charset string, offset time.Duration) ([]byte, error) {
+	text, err := Decode(data, charset)
+	if err != nil {
+		return nil, err
+	}
+	cues, err := Parse(text, format)
+	if err != nil {
+		return nil, err
+	}
+	return WriteVTT(Shift(cues, offset)), nil
+}
+
+// parseBlocks 解析以空行分隔, 带有 "-->" 时间行的字幕 (srt/vtt)
+func parseBlocks(text string) []Cue {
+	var cues []Cue
+	for block := range strings.SplitSeq(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		idx := slices.IndexFunc(lines, func(l string) bool { return strings.Contains(l, "-->") })
+		// 序号、WEBVTT 头、NOTE、STYLE 等没有时间行的块直接忽略
+		if idx == -1 {
+			continue
+		}
+
+		startStr, endStr, _ := strings.Cut(lines[idx], "-->")
+		start, ok1 := parseTimestamp(startStr)
+		// vtt 的时间行后可能跟有位置设置
+		end, ok2 := parseTimestamp(firstField(endStr))
+		if !ok1 || !ok2 {
+			continue
+		}
+		body := strings.Join(lines[idx+1:], "\n")
+		cues = append(cues, Cue{Start: start, End: end, Text: fontTagReg.ReplaceAllString(body, "")})
+	}
+	return cues
+}
+
+// parseTimestamp 解析 hh:mm:ss,mmm / hh:mm:ss.mmm / mm:ss.mmm 格式的时间
+//
+// 小数部分按位数换算, 同时兼容 ass 的百分秒
+func parseTimestamp(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", "."))
+	main, frac, _ := strings.Cut(s, ".")
+
+	parts := strings.Split(main, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var d time.Duration
+	for _, p := range parts {
+		n, ok := atoi(p)
+		if !ok {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+
+	if frac != "" {
+		n, ok := atoi(frac)
+		if !ok {
+			return 0, false
+		}
+		unit := time.Second
+		for range len(frac) {
+			unit /= 10
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// formatTimestamp 将时间格式化为 WebVTT 时间戳 hh:mm:ss.mmm
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// atoi 解析非负整数, 不允许出现符号
+func atoi(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// firstField 返回字符串中第一个非空白字段
+func firstField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
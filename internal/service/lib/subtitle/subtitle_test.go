@@ -0,0 +1,103 @@
+package subtitle_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/subtitle"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const srtText = "\ufeff1\r\n00:00:01,500 --> 00:00:03,000\r\n<font color=\"#fff\">你好</font>\r\n世界\r\n\r\n2\r\n00:00:04,000 --> 00:00:05,250\r\n<i>再见</i>\r\n"
+
+const assText = `[Script Info]
+Title: test
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize
+Style: Default,Arial,20
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:02.00,0:00:03.50,Default,,0,0,0,,{\pos(10,10)\fs20}第二句, 带逗号
+Dialogue: 0,0:00:00.50,0:00:01.00,Default,,0,0,0,,第一句\N换行\h空格
+Dialogue: 0,0:00:00.00,0:00:10.00,Default,,0,0,0,,{\p1}m 0 0 l 100 0 100 100{\p0}
+`
+
+func TestSRTToVTT(t *testing.T) {
+	vtt, err := subtitle.ToVTT([]byte(srtText), subtitle.FormatSRT, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\n你好\n世界\n\n00:00:04.000 --> 00:00:05.250\n<i>再见</i>\n"
+	if string(vtt) != want {
+		t.Errorf("vtt:\n%q\nwant:\n%q", vtt, want)
+	}
+}
+
+func TestASSToVTT(t *testing.T) {
+	vtt, err := subtitle.ToVTT([]byte(assText), subtitle.FormatASS, "", 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:01.000 --> 00:00:01.500\n第一句\n换行 空格\n\n00:00:02.500 --> 00:00:04.000\n第二句, 带逗号\n"
+	if string(vtt) != want {
+		t.Errorf("vtt:\n%q\nwant:\n%q", vtt, want)
+	}
+}
+
+func TestShift(t *testing.T) {
+	cues := []subtitle.Cue{
+		{Start: time.Second, End: 2 * time.Second, Text: "a"},
+		{Start: 3 * time.Second, End: 5 * time.Second, Text: "b"},
+	}
+	res := subtitle.Shift(cues, -4*time.Second)
+	if len(res) != 1 || res[0].Start != 0 || res[0].End != time.Second {
+		t.Errorf("shift: %+v", res)
+	}
+}
+
+func TestDecodeAuto(t *testing.T) {
+	text := "00:00:01,000 --> 00:00:02,000\n这是一段简体中文字幕，用于测试编码识别。"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(text)
+	if got := subtitle.DetectCharset([]byte(gbk)); got != subtitle.CharsetGBK {
+		t.Errorf("gbk detected as %s", got)
+	}
+
+	tw := "00:00:01,000 --> 00:00:02,000\n這是一段繁體中文字幕，我們用來測試編碼識別。"
+	big5, _ := traditionalchinese.Big5.NewEncoder().String(tw)
+	if got := subtitle.DetectCharset([]byte(big5)); got != subtitle.CharsetBig5 {
+		t.Errorf("big5 detected as %s", got)
+	}
+
+	decoded, err := subtitle.Decode([]byte(big5), subtitle.CharsetAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != tw {
+		t.Errorf("decoded: %s", decoded)
+	}
+	if got := subtitle.DetectCharset([]byte(text)); got != subtitle.CharsetUTF8 {
+		t.Errorf("utf-8 detected as %s", got)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"/电影/a.chs.ASS": subtitle.FormatASS,
+		"/Videos/1/2/Subtitles/3/0/Stream.srt?api_key=x": subtitle.FormatSRT,
+		"b.ssa":  subtitle.FormatSSA,
+		"c.sup":  "",
+		"Stream": "",
+	}
+	for name, want := range tests {
+		if got := subtitle.FormatOf(name); got != want {
+			t.Errorf("FormatOf(%s) = %s, want %s", name, got, want)
+		}
+	}
+	if strings.TrimSpace(string(subtitle.WriteVTT(nil))) != "WEBVTT" {
+		t.Error("empty vtt")
+	}
+}
@@ -0,0 +1,138 @@
+package m3u8
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/util/https"
+)
+
+// expireMargin 距离过期时间小于这个值的地址视为已过期
+//
+// 预留客户端下载分片的时间
+const expireMargin = time.Second * 30
+
+// minRefreshInterval 同一个 playlist 两次同步刷新的最小间隔
+//
+// 避免多个分片同时过期时重复请求 openlist
+const minRefreshInterval = time.Second * 10
+
+// probeInterval 无法解析过期时间的分片地址, 同一个 playlist 探测可用后在这个时间内不再重复探测
+const probeInterval = time.Minute
+
+// ExpireAt 解析签名地址中的过期时间
+//
+// 支持阿里云 OSS (v1/v4)、AWS S3 (v2/v4) 风格的签名参数, 无法解析时返回 false
+func ExpireAt(link string) (time.Time, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return time.Time{}, false
+	}
+	q := u.Query()
+
+	// 带有签名时间的签名, 过期参数是有效时长
+	relative := func(dateKey, expiresKey string) (time.Time, bool) {
+		date, err := time.Parse("20060102T150405Z", q.Get(dateKey))
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec, err := strconv.ParseInt(q.Get(expiresKey), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return date.Add(time.Duration(sec) * time.Second), true
+	}
+	if q.Has("x-oss-date") {
+		return relative("x-oss-date", "x-oss-expires")
+	}
+	if q.Has("X-Amz-Date") {
+		return relative("X-Amz-Date", "X-Amz-Expires")
+	}
+
+	// 过期参数是 unix 时间戳
+	for _, key := range []string{"x-oss-expires", "Expires"} {
+		if !q.Has(key) {
+			continue
+		}
+		sec, err := strconv.ParseInt(q.Get(key), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(sec, 0), true
+	}
+	return time.Time{}, false
+}
+
+// LinkProber 判断 playlist 中的分片地址是否已经过期
+//
+// 优先通过签名中的时间戳判断, 无法解析时请求远程地址确认,
+// 同一个 playlist 的分片地址在同一次刷新中生成, 探测可用后 interval 内不再重复探测
+type LinkProber struct {
+	interval time.Duration
+	probe    func(link string) bool
+
+	mu    sync.Mutex
+	alive map[string]time.Time // playlist key -> 最后一次探测可用的时间
+}
+
+// NewLinkProber 初始化分片地址探测器, probe 为 nil 时请求远程地址判断是否可用
+func NewLinkProber(interval time.Duration, probe func(link string) bool) *LinkProber {
+	if probe == nil {
+		probe = linkAlive
+	}
+	return &LinkProber{interval: interval, probe: probe, alive: make(map[string]time.Time)}
+}
+
+// Expired 判断 playlist key 中的分片地址 link 是否已经过期, probe 为 false 时只通过签名判断
+func (p *LinkProber) Expired(key, link string, probe bool) bool {
+	if at, ok := ExpireAt(link); ok {
+		return time.Now().Add(expireMargin).After(at)
+	}
+	if !probe {
+		return false
+	}
+
+	p.mu.Lock()
+	at, ok := p.alive[key]
+	p.mu.Unlock()
+	if ok && time.Since(at) < p.interval {
+		return false
+	}
+
+	alive := p.probe(link)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if alive {
+		p.alive[key] = time.Now()
+	} else {
+		// 刷新后的地址需要重新探测
+		delete(p.alive, key)
+	}
+	return !alive
+}
+
+// Sweep 移除超过 interval 的探测记录
+func (p *LinkProber) Sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, at := range p.alive {
+		if time.Since(at) >= p.interval {
+			delete(p.alive, key)
+		}
+	}
+}
+
+// linkAlive 请求远程地址, 判断地址是否可用
+//
+// 部分网盘的签名只对 GET 请求有效, 使用只请求 1 个字节的 GET 代替 HEAD
+func linkAlive(link string) bool {
+	resp, err := https.Get(link).AddHeader("Range", "bytes=0-0").Do()
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return !https.IsErrorCode(resp.StatusCode) || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable
+}
@@ -0,0 +1,83 @@
+package m3u8_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/m3u8"
+)
+
+func TestExpireAt(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want time.Time
+		ok   bool
+	}{
+		{"oss v1", "https://a.com/media-0.ts?x-oss-expires=1725537244&x-oss-signature-version=OSS2", time.Unix(1725537244, 0), true},
+		{"oss v4", "https://a.com/0.ts?x-oss-date=20240905T100000Z&x-oss-expires=3600", time.Date(2024, 9, 5, 11, 0, 0, 0, time.UTC), true},
+		{"s3 v2", "https://a.com/0.ts?Expires=1725537244&Signature=xx", time.Unix(1725537244, 0), true},
+		{"s3 v4", "https://a.com/0.ts?X-Amz-Date=20240905T100000Z&X-Amz-Expires=600", time.Date(2024, 9, 5, 10, 10, 0, 0, time.UTC), true},
+		{"no signature", "https://a.com/0.ts?token=abc", time.Time{}, false},
+		{"invalid", "https://a.com/0.ts?Expires=tomorrow", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m3u8.ExpireAt(tt.link)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("ExpireAt() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLinkProber(t *testing.T) {
+	probes, alive := 0, true
+	p := m3u8.NewLinkProber(time.Hour, func(string) bool {
+		probes++
+		return alive
+	})
+
+	// 可以解析签名的地址不需要探测
+	if !p.Expired("a", "https://a.com/0.ts?Expires=1725537244", true) || probes != 0 {
+		t.Fatalf("signed link: probes = %d", probes)
+	}
+	// 代理模式下不探测
+	if p.Expired("a", "https://a.com/0.ts?token=abc", false) || probes != 0 {
+		t.Fatalf("probe disabled: probes = %d", probes)
+	}
+
+	// 同一个 playlist 探测可用后不再重复探测
+	for i := 0; i < 3; i++ {
+		if p.Expired("a", "https://a.com/"+strconv.Itoa(i)+".ts?token=abc", true) {
+			t.Fatal("link should be alive")
+		}
+	}
+	if probes != 1 {
+		t.Fatalf("probes = %d, want 1", probes)
+	}
+
+	// 其他 playlist 单独探测, 探测失败后下次重新探测
+	alive = false
+	if !p.Expired("b", "https://a.com/0.ts?token=abc", true) {
+		t.Fatal("link should be expired")
+	}
+	alive = true
+	if p.Expired("b", "https://a.com/0.ts?token=abc", true) || probes != 3 {
+		t.Fatalf("probes = %d, want 3", probes)
+	}
+
+	// 记录过期后重新探测
+	p = m3u8.NewLinkProber(0, func(string) bool {
+		probes++
+		return true
+	})
+	probes = 0
+	p.Expired("a", "https://a.com/0.ts?token=abc", true)
+	p.Sweep()
+	p.Expired("a", "https://a.com/1.ts?token=abc", true)
+	if probes != 2 {
+		t.Fatalf("probes = %d, want 2", probes)
+	}
+}
@@ -1,34 +1,90 @@
 package m3u8
 
 import (
-	"sort"
+	"fmt"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/syscc/Emby-Go/internal/config"
 	"github.com/syscc/Emby-Go/internal/util/logs"
 	"github.com/syscc/Emby-Go/internal/util/urls"
 )
 
 const (
 
-	// MaxPlaylistNum 在内存中最多维护的 m3u8 列表个数
+	// MaxPlaylistNum 在内存中最多维护的 m3u8 列表个数, 未配置容量时使用
 	// 超出则淘汰最久没有读取的一个
 	MaxPlaylistNum = 10
 
 	// PreChanSize 预处理通道大小, 塞满时从头部开始淘汰
 	PreChanSize = 1000
+
+	// PersistFileName 持久化 playlist 的本地文件名
+	PersistFileName = "openlist-playlist-cache.json"
+
+	// maintainDuration goroutine 维护 playlist 的间隔
+	maintainDuration = time.Minute * 10
+	// stopUpdateDuration 超过这个时间未读, playlist 停止更新
+	stopUpdateDuration = maintainDuration + time.Minute
+	// removeDuration 超过这个时间未更新, playlist 被移除
+	removeDuration = time.Hour
 )
 
 func init() {
 	go loopMaintainPlaylist()
 }
 
+// store 内存中维护的 playlist
+var store = NewStore(MaxPlaylistNum)
+
+// tsProber 判断分片地址是否过期
+var tsProber = NewLinkProber(probeInterval, nil)
+
+// persistPath playlist 持久化路径, 未开启持久化时为 nil
+var persistPath atomic.Pointer[string]
+
+// Init 根据配置文件, 初始化 playlist 缓存容量, 并从磁盘中恢复持久化的 playlist
+func Init() error {
+	for _, info := range store.SetCapacity(config.C().VideoPreview.PlaylistCapacity) {
+		logs.Tip("playlist 被淘汰并从内存中移除, openlistPath: %s, templateId: %s", info.OpenlistPath, info.TemplateId)
+	}
+	if !config.C().VideoPreview.PlaylistPersist {
+		return nil
+	}
+
+	fp := filepath.Join(config.BasePath, PersistFileName)
+	n, err := store.Load(fp, func(info *Info) bool {
+		// 长时间未更新的 playlist 不再恢复
+		return !beforeNow(info.LastUpdate + removeDuration.Milliseconds())
+	})
+	if err != nil {
+		return fmt.Errorf("恢复 playlist 失败: %w", err)
+	}
+	persistPath.Store(&fp)
+	if n > 0 {
+		logs.Success("已从磁盘恢复 playlist 个数: %d", n)
+	}
+	return nil
+}
+
+// PlaylistStats 返回内存中 playlist 的统计信息
+func PlaylistStats() Stats {
+	return store.Stats()
+}
+
 // GetPlaylist 获取 m3u 播放列表, 返回 m3u 文本
 var GetPlaylist func(openlistPath, templateId string, proxy, main bool, routePrefix, clientApiKey string) (string, bool)
 
 // GetTsLink 获取 m3u 播放列表中的某个 ts 链接
 var GetTsLink func(openlistPath, templateId string, idx int) (string, bool)
 
+// RefreshTsLink 同步刷新播放列表, 返回最新的 ts 链接
+//
+// 用于分片地址过期时立即获取新地址, 短时间内重复调用不会重复请求 openlist
+var RefreshTsLink func(openlistPath, templateId string, idx int) (string, bool)
+
 // GetSubtitleLink 获取字幕链接
 var GetSubtitleLink func(openlistPath, templateId, subName string) (string, bool)
 
@@ -69,17 +125,8 @@ func PushPlaylistAsync(info Info) {
 //
 // 维护内存中的 m3u8 播放列表
 func loopMaintainPlaylist() {
-	// map 记录播放列表, 用于快速响应客户端
-	infoMap := map[string]*Info{}
-	// arr 记录播放列表, 便于实现淘汰机制
-	infoArr := make([]*Info, 0)
-
-	// maintainDuration goroutine 维护 playlist 的间隔
-	maintainDuration := time.Minute * 10
-	// stopUpdateTimeMillis 超过这个时间未读, playlist 停止更新
-	stopUpdateTimeMillis := (maintainDuration + time.Minute).Milliseconds()
-	// removeTimeMillis 超过这个时间未读, playlist 被移除
-	removeTimeMillis := time.Hour.Milliseconds()
+	stopUpdateTimeMillis := stopUpdateDuration.Milliseconds()
+	removeTimeMillis := removeDuration.Milliseconds()
 
 	// publicApiUpdateMutex 对外部暴露的 api 的内部实现中
 	// 如果涉及到更新的操作, 需要获取这个锁, 避免频繁请求 openlist
@@ -90,56 +137,64 @@ func loopMaintainPlaylist() {
 		logs.Error("playlist 更新失败, path: %s, template: %s, err: %v", info.OpenlistPath, info.TemplateId, err)
 	}
 
-	// calcMapKey 计算 info 在 map 中的 key
-	calcMapKey := func(info Info) string {
-		return info.OpenlistPath + info.TemplateId
+	// refresh 从 openlist 更新 info, 并记录统计信息
+	refresh := func(info *Info) error {
+		if err := info.UpdateContent(); err != nil {
+			return err
+		}
+		store.refreshes.Add(1)
+		return nil
 	}
 
-	// beforeNow 判断一个时间是不是在当前时间之前
-	beforeNow := func(millis int64) bool {
-		return millis < time.Now().UnixMilli()
+	// needUpdate 判断 info 是否已经停止更新
+	//
+	// 从磁盘恢复的 info 最后读取时间可能较新, 但地址已经过期, 需要同时判断更新时间
+	needUpdate := func(info *Info) bool {
+		return beforeNow(info.LastRead+stopUpdateTimeMillis) || beforeNow(info.LastUpdate+stopUpdateTimeMillis)
 	}
 
 	// queryInfo 查询内存中的 info 信息
 	//
-	// 如果内存中 map 已经能查询到 info 信息, 直接返回
+	// 如果内存中已经能查询到 info 信息, 直接返回
 	// 否则会等待预处理通道处理完毕后再次判断
-	queryInfo := func(openlistPath, templateId string) (info *Info) {
-		key := calcMapKey(Info{OpenlistPath: openlistPath, TemplateId: templateId})
-		var ok bool
-		info, ok = infoMap[key]
+	queryInfo := func(openlistPath, templateId string) *Info {
+		key := Key(openlistPath, templateId)
+		if _, ok := store.Peek(key); !ok {
+			// 等待预处理通道处理完毕
+			preChanHandlingGroup.Wait()
+		}
 
-		defer func() {
-			if info == nil {
-				return
-			}
-			// 如果当前 info 已经停止更新, 则手动触发更新
-			if beforeNow(info.LastRead + stopUpdateTimeMillis) {
-				publicApiUpdateMutex.Lock()
-				defer publicApiUpdateMutex.Unlock()
-				if beforeNow(info.LastRead + stopUpdateTimeMillis) {
-					if err := info.UpdateContent(); err != nil {
-						printErr(info, err)
-						info = nil
-					}
+		info, ok := store.Get(key)
+		if !ok {
+			return nil
+		}
+
+		// 如果当前 info 已经停止更新, 则手动触发更新
+		if needUpdate(info) {
+			publicApiUpdateMutex.Lock()
+			if needUpdate(info) {
+				if err := refresh(info); err != nil {
+					publicApiUpdateMutex.Unlock()
+					printErr(info, err)
+					return nil
 				}
 			}
-			// 更新最后读取时间
-			info.LastRead = time.Now().UnixMilli()
-		}()
-
-		if ok {
-			return
+			publicApiUpdateMutex.Unlock()
 		}
-
-		// 等待预处理通道处理完毕
-		preChanHandlingGroup.Wait()
-
-		info, ok = infoMap[key]
-		if ok {
-			return
+		// 更新最后读取时间
+		now := time.Now().UnixMilli()
+		store.Touch(info, now)
+
+		// 自适应码率模式下, 客户端随时可能切换清晰度
+		// 同一资源的其他清晰度一并标记为已读, 保持更新
+		if config.C().VideoPreview.Adaptive {
+			for _, sibling := range store.All() {
+				if sibling != info && sibling.OpenlistPath == info.OpenlistPath {
+					store.Touch(sibling, now)
+				}
+			}
 		}
-		return nil
+		return info
 	}
 
 	GetPlaylist = func(openlistPath, templateId string, proxy, main bool, routePrefix, clientApiKey string) (string, bool) {
@@ -161,6 +216,26 @@ func loopMaintainPlaylist() {
 		return info.GetTsLink(idx)
 	}
 
+	RefreshTsLink = func(openlistPath, templateId string, idx int) (string, bool) {
+		info, ok := store.Peek(Key(openlistPath, templateId))
+		if !ok {
+			return "", false
+		}
+
+		publicApiUpdateMutex.Lock()
+		// 其他请求刚刚刷新过, 直接使用最新地址
+		if beforeNow(info.LastUpdate + minRefreshInterval.Milliseconds()) {
+			if err := refresh(info); err != nil {
+				publicApiUpdateMutex.Unlock()
+				printErr(info, err)
+				return "", false
+			}
+		}
+		publicApiUpdateMutex.Unlock()
+		store.Touch(info, time.Now().UnixMilli())
+		return info.GetTsLink(idx)
+	}
+
 	GetSubtitleLink = func(openlistPath, templateId, subName string) (string, bool) {
 		info := queryInfo(openlistPath, templateId)
 		if info == nil {
@@ -175,18 +250,16 @@ func loopMaintainPlaylist() {
 		return "", false
 	}
 
-	// removeInfo 删除内存中的 info 信息
-	removeInfo := func(key string) {
-		info, ok := infoMap[key]
-		if !ok {
+	// persist 开启持久化时, 将内存中的 info 写入磁盘
+	//
+	// 客户端请求会在持有 publicApiUpdateMutex 时刷新 info, 序列化时需要持有同一个锁
+	persist := func() {
+		fp := persistPath.Load()
+		if fp == nil {
 			return
 		}
-		delete(infoMap, key)
-		for i, arrInfo := range infoArr {
-			if arrInfo == info {
-				infoArr = append(infoArr[:i], infoArr[i+1:]...)
-				break
-			}
+		if err := store.Save(*fp, &publicApiUpdateMutex); err != nil {
+			logs.Warn("playlist 持久化失败: %v", err)
 		}
 	}
 
@@ -194,16 +267,15 @@ func loopMaintainPlaylist() {
 	//
 	// 如果 lastRead 不满足条件, 被淘汰
 	updateAll := func() {
-		// 复制一份 arr
-		cpArr := append(([]*Info)(nil), infoArr...)
-		tot, active := len(cpArr), 0
+		infos := store.All()
+		tot, active := len(infos), 0
 
-		for _, info := range cpArr {
-			key := calcMapKey(Info{OpenlistPath: info.OpenlistPath, TemplateId: info.TemplateId})
+		for _, info := range infos {
+			key := Key(info.OpenlistPath, info.TemplateId)
 
 			// 长时间未读, 移除
 			if beforeNow(info.LastUpdate + removeTimeMillis) {
-				removeInfo(key)
+				store.Remove(key)
 				logs.Tip("playlist 长时间未被更新, 已移除, openlistPath: %s, templateId: %s", info.OpenlistPath, info.TemplateId)
 				tot--
 				continue
@@ -216,16 +288,20 @@ func loopMaintainPlaylist() {
 
 			// 如果更新失败, 移除
 			active++
-			if err := info.UpdateContent(); err != nil {
+			if err := refresh(info); err != nil {
 				printErr(info, err)
-				removeInfo(key)
+				store.Remove(key)
 				tot--
 				active--
 			}
 		}
 
-		if len(cpArr) > 0 {
-			logs.Progress("当前正在维护的 playlist 个数: %d, 活跃个数: %d", tot, active)
+		tsProber.Sweep()
+		if len(infos) > 0 {
+			stats := store.Stats()
+			logs.Progress("当前正在维护的 playlist 个数: %d/%d, 活跃个数: %d, 命中: %d, 未命中: %d, 刷新: %d, 淘汰: %d",
+				tot, stats.Capacity, active, stats.Hits, stats.Misses, stats.Refreshes, stats.Evictions)
+			persist()
 		}
 	}
 
@@ -234,41 +310,29 @@ func loopMaintainPlaylist() {
 		if preInfo.OpenlistPath == "" || preInfo.TemplateId == "" {
 			return
 		}
-		key := calcMapKey(preInfo)
+		key := Key(preInfo.OpenlistPath, preInfo.TemplateId)
 
 		// 如果内存已存在 key, 复用
-		info, exist := infoMap[key]
+		info, exist := store.Peek(key)
 		if !exist {
 			info = &preInfo
 		}
 
 		// 初始化 Info 信息, 并更新
-		if err := info.UpdateContent(); err != nil {
+		if err := refresh(info); err != nil {
 			printErr(info, err)
-			removeInfo(key)
+			store.Remove(key)
 			return
 		}
-		info.LastRead = time.Now().UnixMilli()
+		store.Touch(info, time.Now().UnixMilli())
 
-		// 维护到内存中
+		// 维护到内存中, 内存满时淘汰最久没有读取的 info
 		if !exist {
-			infoMap[key] = info
-			infoArr = append(infoArr, info)
-		}
-
-		if len(infoArr) <= MaxPlaylistNum {
-			return
-		}
-		// 内存满, 淘汰旧内存
-		sort.Slice(infoArr, func(i, j int) bool {
-			return infoArr[i].LastRead < infoArr[j].LastRead
-		})
-		toDeletes := make([]*Info, len(infoArr)-MaxPlaylistNum)
-		copy(toDeletes, infoArr)
-		for _, toDel := range toDeletes {
-			removeInfo(calcMapKey(Info{OpenlistPath: toDel.OpenlistPath, TemplateId: toDel.TemplateId}))
-			logs.Tip("playlist 被淘汰并从内存中移除, openlistPath: %s, templateId: %s", toDel.OpenlistPath, toDel.TemplateId)
+			for _, toDel := range store.Put(info) {
+				logs.Tip("playlist 被淘汰并从内存中移除, openlistPath: %s, templateId: %s", toDel.OpenlistPath, toDel.TemplateId)
+			}
 		}
+		persist()
 	}
 
 	// 定时维护一次内存中的数据
@@ -286,3 +350,8 @@ func loopMaintainPlaylist() {
 	}
 
 }
+
+// beforeNow 判断一个时间是不是在当前时间之前
+func beforeNow(millis int64) bool {
+	return millis < time.Now().UnixMilli()
+}
@@ -0,0 +1,137 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/emby"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+)
+
+// bitsPerPixel 估算转码码率时使用的每像素比特数 (按 25 帧计算)
+const bitsPerPixel = 0.07
+
+// Variant 多码率播放列表中的一个清晰度
+type Variant struct {
+	TemplateId string // 转码模板 id
+	Width      int    // 宽度
+	Height     int    // 高度
+	Bandwidth  int    // 估算的峰值码率, 单位: bps
+}
+
+// NewVariants 从 openlist 转码任务列表中筛选出未被忽略的清晰度
+//
+// 结果按分辨率从高到低排序
+func NewVariants(tasks []openlist.TranscodingVideoInfo, ignore func(templateId string) bool) []Variant {
+	res := make([]Variant, 0, len(tasks))
+	for _, task := range tasks {
+		if task.TemplateId == "" || task.Url == "" {
+			continue
+		}
+		if ignore != nil && ignore(task.TemplateId) {
+			continue
+		}
+		res = append(res, Variant{
+			TemplateId: task.TemplateId,
+			Width:      task.TemplateWidth,
+			Height:     task.TemplateHeight,
+			Bandwidth:  EstimateBandwidth(task.TemplateWidth, task.TemplateHeight),
+		})
+	}
+	slices.SortStableFunc(res, func(a, b Variant) int {
+		return b.Bandwidth - a.Bandwidth
+	})
+	return res
+}
+
+// EstimateBandwidth 根据分辨率估算转码资源的码率
+//
+// 网盘接口不返回码率信息, 无法获取分辨率时按 720p 估算
+func EstimateBandwidth(width, height int) int {
+	if width <= 0 || height <= 0 {
+		width, height = 1280, 720
+	}
+	return int(float64(width*height) * 25 * bitsPerPixel)
+}
+
+// MasterContent 生成多码率 m3u8 文本
+//
+// first 指定的清晰度会排在第一位, 作为客户端起播的清晰度;
+// uriMapper 将清晰度映射为对应的播放列表地址
+func MasterContent(variants []Variant, first string, uriMapper func(Variant) string) string {
+	sorted := append([]Variant(nil), variants...)
+	if idx := slices.IndexFunc(sorted, func(v Variant) bool { return v.TemplateId == first }); idx > 0 {
+		v := sorted[idx]
+		sorted = append(sorted[:idx], sorted[idx+1:]...)
+		sorted = append([]Variant{v}, sorted...)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString("#EXT-X-VERSION:3\n")
+	for _, v := range sorted {
+		sb.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", v.Bandwidth))
+		if v.Width > 0 && v.Height > 0 {
+			sb.WriteString(fmt.Sprintf(",RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		sb.WriteString(fmt.Sprintf(`,NAME="%s"`, v.TemplateId))
+		sb.WriteString("\n")
+		sb.WriteString(uriMapper(v))
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// FetchVariants 请求 openlist 获取资源所有可用的清晰度
+//
+// 配置中忽略的清晰度不会返回
+func FetchVariants(openlistPath string) ([]Variant, error) {
+	res := openlist.FetchFsOther(openlistPath, nil)
+	if res.Code != http.StatusOK {
+		return nil, errors.New("请求 openlist 失败: " + res.Msg)
+	}
+
+	variants := NewVariants(res.Data.VideoPreviewPlayInfo.LiveTranscodingTaskList, config.C().VideoPreview.IsTemplateIgnore)
+	if len(variants) == 0 {
+		return nil, errors.New("没有可用的转码清晰度")
+	}
+	return variants, nil
+}
+
+// ProxyMasterContent 生成指向本地代理的多码率 m3u8 文本
+//
+// 内存中还没有的清晰度会被加入到内存中维护, 确保客户端切换清晰度时能够立即获取到最新的播放列表,
+// 已经在维护的清晰度由读取和定时任务保持更新, 不重复刷新
+func ProxyMasterContent(openlistPath, templateId, routePrefix, clientApiKey string) (string, error) {
+	variants, err := FetchVariants(openlistPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range variants {
+		if _, ok := store.Peek(Key(openlistPath, v.TemplateId)); ok {
+			continue
+		}
+		PushPlaylistAsync(Info{OpenlistPath: openlistPath, TemplateId: v.TemplateId})
+	}
+
+	baseRoute := "proxy_playlist"
+	if routePrefix != "" {
+		baseRoute = routePrefix + "/" + baseRoute
+	}
+	return MasterContent(variants, templateId, func(v Variant) string {
+		u, _ := url.Parse(baseRoute)
+		q := u.Query()
+		q.Set("openlist_path", openlist.PathEncode(openlistPath))
+		q.Set("template_id", v.TemplateId)
+		q.Set(emby.QueryApiKeyName, clientApiKey)
+		q.Set("type", "main")
+		u.RawQuery = q.Encode()
+		return u.String()
+	}), nil
+}
@@ -0,0 +1,54 @@
+package m3u8_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/service/m3u8"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+)
+
+func TestNewVariants(t *testing.T) {
+	tasks := []openlist.TranscodingVideoInfo{
+		{TemplateId: "SD", TemplateWidth: 960, TemplateHeight: 540, Url: "sd"},
+		{TemplateId: "FHD", TemplateWidth: 1920, TemplateHeight: 1080, Url: "fhd"},
+		{TemplateId: "HD", TemplateWidth: 1280, TemplateHeight: 720, Url: "hd"},
+		{TemplateId: "QHD", TemplateWidth: 2560, TemplateHeight: 1440},
+		{TemplateId: "LD", TemplateWidth: 640, TemplateHeight: 360, Url: "ld"},
+	}
+	variants := m3u8.NewVariants(tasks, func(id string) bool { return id == "LD" })
+
+	ids := make([]string, len(variants))
+	for i, v := range variants {
+		ids[i] = v.TemplateId
+	}
+	if got := strings.Join(ids, ","); got != "FHD,HD,SD" {
+		t.Fatalf("variants: %s", got)
+	}
+	if variants[0].Bandwidth <= variants[1].Bandwidth || variants[1].Bandwidth <= variants[2].Bandwidth {
+		t.Errorf("bandwidth should decrease with resolution: %+v", variants)
+	}
+}
+
+func TestMasterContent(t *testing.T) {
+	variants := []m3u8.Variant{
+		{TemplateId: "FHD", Width: 1920, Height: 1080, Bandwidth: 3000000},
+		{TemplateId: "HD", Width: 1280, Height: 720, Bandwidth: 1500000},
+		{TemplateId: "SD", Bandwidth: 800000},
+	}
+	content := m3u8.MasterContent(variants, "HD", func(v m3u8.Variant) string {
+		return "proxy_playlist?template_id=" + v.TemplateId
+	})
+
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=1500000,RESOLUTION=1280x720,NAME="HD"
+proxy_playlist?template_id=HD
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1920x1080,NAME="FHD"
+proxy_playlist?template_id=FHD
+#EXT-X-STREAM-INF:BANDWIDTH=800000,NAME="SD"
+proxy_playlist?template_id=SD`
+	if content != want {
+		t.Errorf("master content:\n%s", content)
+	}
+}
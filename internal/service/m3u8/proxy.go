@@ -2,19 +2,30 @@ package m3u8
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/syscc/Emby-Go/internal/config"
 	"github.com/syscc/Emby-Go/internal/service/openlist"
 	"github.com/syscc/Emby-Go/internal/util/bytess"
 	"github.com/syscc/Emby-Go/internal/util/https"
 	"github.com/syscc/Emby-Go/internal/util/logs"
 	"github.com/syscc/Emby-Go/internal/util/strs"
+	"github.com/syscc/Emby-Go/internal/util/trys"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// segmentTryNum 代理 ts 分片的最大尝试次数
+	segmentTryNum = 3
+	// segmentTryInterval 代理 ts 分片的重试间隔
+	segmentTryInterval = time.Millisecond * 500
+)
+
 // baseCheck 对代理请求参数作基本校验
 func baseCheck(c *gin.Context) (ProxyParams, error) {
 	if c.Request.Method != http.MethodGet {
@@ -50,6 +61,17 @@ func ProxyPlaylist(c *gin.Context) {
 	}
 
 	routePrefix := https.ClientRequestHost(c.Request) + "/videos"
+
+	// 开启自适应码率时, 返回包含所有清晰度的多码率播放列表
+	if config.C().VideoPreview.Adaptive && params.Type != "main" {
+		master, err := ProxyMasterContent(params.OpenlistPath, params.TemplateId, routePrefix, params.ApiKey)
+		if err == nil {
+			okContent(master)
+			return
+		}
+		logs.Warn("生成多码率播放列表失败, 回退到单一清晰度: %v", err)
+	}
+
 	m3uContent, ok := GetPlaylist(params.OpenlistPath, params.TemplateId, true, true, routePrefix, params.ApiKey)
 	if ok {
 		okContent(m3uContent)
@@ -83,26 +105,74 @@ func ProxyTsLink(c *gin.Context) {
 		return
 	}
 
-	okRedirect := func(link string) {
-		logs.Success("重定向 ts: %s", link)
-		c.Redirect(http.StatusTemporaryRedirect, link)
+	tsLink, ok := GetTsLink(params.OpenlistPath, params.TemplateId, idx)
+	if !ok {
+		// 获取失败, 将当前请求的地址加入到预处理通道
+		PushPlaylistAsync(Info{OpenlistPath: params.OpenlistPath, TemplateId: params.TemplateId})
+		tsLink, ok = GetTsLink(params.OpenlistPath, params.TemplateId, idx)
+	}
+	if !ok {
+		c.String(http.StatusBadRequest, "获取不到 ts, 请检查日志")
+		return
 	}
 
-	tsLink, ok := GetTsLink(params.OpenlistPath, params.TemplateId, idx)
-	if ok {
-		okRedirect(tsLink)
+	// 代理模式下由请求结果判断地址是否可用, 无需额外探测
+	segmentProxy := config.C().VideoPreview.SegmentProxy
+	if tsProber.Expired(Key(params.OpenlistPath, params.TemplateId), tsLink, !segmentProxy) {
+		logs.Warn("ts 地址已过期, 同步刷新 playlist, path: %s, template: %s", params.OpenlistPath, params.TemplateId)
+		if link, ok := RefreshTsLink(params.OpenlistPath, params.TemplateId, idx); ok {
+			tsLink = link
+		}
+	}
+
+	if segmentProxy {
+		proxySegment(c, params, idx, tsLink)
 		return
 	}
 
-	// 获取失败, 将当前请求的地址加入到预处理通道
-	PushPlaylistAsync(Info{OpenlistPath: params.OpenlistPath, TemplateId: params.TemplateId})
+	logs.Success("重定向 ts: %s", tsLink)
+	c.Redirect(http.StatusTemporaryRedirect, tsLink)
+}
 
-	tsLink, ok = GetTsLink(params.OpenlistPath, params.TemplateId, idx)
-	if ok {
-		okRedirect(tsLink)
+// proxySegment 由本地代理 ts 分片的数据
+//
+// 远程请求失败时, 刷新 playlist 后重试
+func proxySegment(c *gin.Context, params ProxyParams, idx int, tsLink string) {
+	header := make(http.Header)
+	if rg := c.GetHeader("Range"); rg != "" {
+		header.Set("Range", rg)
+	}
+
+	var resp *http.Response
+	err := trys.Try(func() error {
+		var err error
+		resp, err = https.Get(tsLink).Header(header.Clone()).Do()
+		if err == nil && !https.IsErrorCode(resp.StatusCode) {
+			return nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("远程响应异常: %s", resp.Status)
+		}
+		if link, ok := RefreshTsLink(params.OpenlistPath, params.TemplateId, idx); ok {
+			tsLink = link
+		}
+		return err
+	}, segmentTryNum, segmentTryInterval)
+	if err != nil {
+		logs.Error("代理 ts 失败, path: %s, template: %s, idx: %d, err: %v", params.OpenlistPath, params.TemplateId, idx, err)
+		c.String(http.StatusBadGateway, "代理 ts 失败, 请检查日志")
 		return
 	}
-	c.String(http.StatusBadRequest, "获取不到 ts, 请检查日志")
+	defer resp.Body.Close()
+
+	https.CloneHeader(c.Writer, resp.Header)
+	c.Status(resp.StatusCode)
+	buf := bytess.CommonFixedBuffer()
+	defer buf.PutBack()
+	if _, err = io.CopyBuffer(c.Writer, resp.Body, buf.Bytes()); err != nil {
+		logs.Warn("代理 ts 中断, idx: %d, err: %v", idx, err)
+	}
 }
 
 // ProxySubtitle 代理字幕请求
@@ -0,0 +1,242 @@
+package m3u8
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats playlist 缓存的统计信息
+type Stats struct {
+	Size      int   // 当前缓存的 playlist 个数
+	Capacity  int   // 缓存容量
+	Hits      int64 // 客户端请求命中缓存的次数
+	Misses    int64 // 客户端请求未命中缓存的次数
+	Refreshes int64 // 从 openlist 成功刷新 playlist 的次数
+	Evictions int64 // 容量不足时淘汰 playlist 的次数
+}
+
+// Store 按最近读取顺序淘汰的 playlist 缓存
+//
+// 链表头部为最近读取的 playlist, 容量不足时从尾部开始淘汰
+type Store struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	refreshes atomic.Int64
+	evictions atomic.Int64
+}
+
+// NewStore 初始化一个 playlist 缓存, capacity 不大于 0 时使用 MaxPlaylistNum
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = MaxPlaylistNum
+	}
+	return &Store{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Key 计算 info 在缓存中的 key
+func Key(openlistPath, templateId string) string {
+	return openlistPath + templateId
+}
+
+// Peek 获取缓存中的 playlist, 不影响淘汰顺序和统计信息
+func (s *Store) Peek(key string) (*Info, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*Info), true
+}
+
+// Get 获取缓存中的 playlist, 并将其标记为最近读取
+func (s *Store) Get(key string) (*Info, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		s.misses.Add(1)
+		return nil, false
+	}
+	s.hits.Add(1)
+	s.ll.MoveToFront(e)
+	return e.Value.(*Info), true
+}
+
+// Touch 更新 playlist 的最后读取时间, 并将其标记为最近读取
+func (s *Store) Touch(info *Info, lastRead int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info.LastRead = lastRead
+	if e, ok := s.items[Key(info.OpenlistPath, info.TemplateId)]; ok && e.Value == info {
+		s.ll.MoveToFront(e)
+	}
+}
+
+// Put 将 playlist 放入缓存, 已存在相同 key 时覆盖
+//
+// 返回因容量不足被淘汰的 playlist
+func (s *Store) Put(info *Info) []*Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := Key(info.OpenlistPath, info.TemplateId)
+	if e, ok := s.items[key]; ok {
+		e.Value = info
+		s.ll.MoveToFront(e)
+		return nil
+	}
+	s.items[key] = s.ll.PushFront(info)
+	return s.evict()
+}
+
+// Remove 从缓存中移除 playlist
+func (s *Store) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.ll.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+// SetCapacity 修改缓存容量, capacity 不大于 0 时使用 MaxPlaylistNum
+//
+// 返回因容量不足被淘汰的 playlist
+func (s *Store) SetCapacity(capacity int) []*Info {
+	if capacity <= 0 {
+		capacity = MaxPlaylistNum
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+	return s.evict()
+}
+
+// All 按最近读取顺序返回缓存中的所有 playlist
+func (s *Store) All() []*Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*Info, 0, s.ll.Len())
+	for e := s.ll.Front(); e != nil; e = e.Next() {
+		res = append(res, e.Value.(*Info))
+	}
+	return res
+}
+
+// Len 返回缓存中的 playlist 个数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// Stats 返回缓存的统计信息
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	size, capacity := s.ll.Len(), s.capacity
+	s.mu.Unlock()
+	return Stats{
+		Size:      size,
+		Capacity:  capacity,
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Refreshes: s.refreshes.Load(),
+		Evictions: s.evictions.Load(),
+	}
+}
+
+// Save 将缓存中的 playlist 持久化到磁盘
+//
+// 按从旧到新的顺序写入, 便于 Load 时还原淘汰顺序
+//
+// playlist 会在刷新时被原地修改, lock 传入刷新时持有的锁, 序列化期间一并持有, 为 nil 时不加锁
+func (s *Store) Save(filePath string, lock sync.Locker) error {
+	s.mu.Lock()
+	infos := make([]*Info, 0, s.ll.Len())
+	for e := s.ll.Back(); e != nil; e = e.Prev() {
+		infos = append(infos, e.Value.(*Info))
+	}
+	s.mu.Unlock()
+
+	if lock != nil {
+		lock.Lock()
+	}
+	data, err := json.Marshal(infos)
+	if lock != nil {
+		lock.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("序列化 playlist 失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	tmp := filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, os.ModePerm); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, filePath); err != nil {
+		return fmt.Errorf("重命名文件失败: %w", err)
+	}
+	return nil
+}
+
+// Load 从磁盘中恢复持久化的 playlist, 文件不存在时不做处理
+//
+// keep 返回 false 的 playlist 会被丢弃, 返回成功恢复的个数
+func (s *Store) Load(filePath string, keep func(*Info) bool) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	var infos []*Info
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return 0, fmt.Errorf("解析 playlist 失败: %w", err)
+	}
+
+	cnt := 0
+	for _, info := range infos {
+		if info == nil || info.OpenlistPath == "" || info.TemplateId == "" {
+			continue
+		}
+		if keep != nil && !keep(info) {
+			continue
+		}
+		s.Put(info)
+		cnt++
+	}
+	return min(cnt, s.Len()), nil
+}
+
+// evict 淘汰超出容量的 playlist, 调用方需持有锁
+func (s *Store) evict() []*Info {
+	var evicted []*Info
+	for s.ll.Len() > s.capacity {
+		e := s.ll.Back()
+		info := e.Value.(*Info)
+		s.ll.Remove(e)
+		delete(s.items, Key(info.OpenlistPath, info.TemplateId))
+		s.evictions.Add(1)
+		evicted = append(evicted, info)
+	}
+	return evicted
+}
@@ -0,0 +1,129 @@
+package m3u8_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/service/m3u8"
+)
+
+func newInfo(path string) *m3u8.Info {
+	return &m3u8.Info{OpenlistPath: path, TemplateId: "FHD"}
+}
+
+func TestStoreEvictLeastRecentlyRead(t *testing.T) {
+	s := m3u8.NewStore(2)
+	s.Put(newInfo("/a"))
+	s.Put(newInfo("/b"))
+
+	// 读取 a 之后, b 成为最久没有读取的 playlist
+	if _, ok := s.Get(m3u8.Key("/a", "FHD")); !ok {
+		t.Fatal("a should be cached")
+	}
+	evicted := s.Put(newInfo("/c"))
+	if len(evicted) != 1 || evicted[0].OpenlistPath != "/b" {
+		t.Fatalf("evicted: %+v", evicted)
+	}
+	if _, ok := s.Peek(m3u8.Key("/b", "FHD")); ok {
+		t.Error("b should be evicted")
+	}
+
+	// 缩小容量时淘汰尾部
+	evicted = s.SetCapacity(1)
+	if len(evicted) != 1 || evicted[0].OpenlistPath != "/a" {
+		t.Fatalf("evicted after shrink: %+v", evicted)
+	}
+
+	if _, ok := s.Get(m3u8.Key("/x", "FHD")); ok {
+		t.Error("x should not be cached")
+	}
+	stats := s.Stats()
+	want := m3u8.Stats{Size: 1, Capacity: 1, Hits: 1, Misses: 1, Evictions: 2}
+	if stats != want {
+		t.Errorf("stats: %+v, want: %+v", stats, want)
+	}
+}
+
+func TestStoreTouch(t *testing.T) {
+	s := m3u8.NewStore(2)
+	a, b := newInfo("/a"), newInfo("/b")
+	s.Put(a)
+	s.Put(b)
+	s.Touch(a, 100)
+	if a.LastRead != 100 {
+		t.Errorf("last read: %d", a.LastRead)
+	}
+	if all := s.All(); all[0] != a || all[1] != b {
+		t.Errorf("order after touch: %s, %s", all[0].OpenlistPath, all[1].OpenlistPath)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), m3u8.PersistFileName)
+
+	s := m3u8.NewStore(3)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		info := newInfo(path)
+		info.RemoteBase = "https://remote" + path + "/"
+		info.HeadComments = []string{"#EXTM3U"}
+		info.RemoteTsInfos = []*m3u8.TsInfo{{Url: "0.ts", Comments: []string{"#EXTINF:10.000,"}}}
+		info.LastUpdate = 1
+		s.Put(info)
+	}
+	if err := s.Save(fp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := m3u8.NewStore(3)
+	n, err := loaded.Load(fp, func(info *m3u8.Info) bool { return info.OpenlistPath != "/b" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("loaded: %d", n)
+	}
+	all := loaded.All()
+	if all[0].OpenlistPath != "/c" || all[1].OpenlistPath != "/a" {
+		t.Errorf("order: %s, %s", all[0].OpenlistPath, all[1].OpenlistPath)
+	}
+	if link, ok := all[0].GetTsLink(0); !ok || link != "https://remote/c/0.ts" {
+		t.Errorf("ts link: %s", link)
+	}
+	if all[0].LastUpdate != 1 {
+		t.Errorf("last update: %d", all[0].LastUpdate)
+	}
+
+	// 文件不存在时不报错
+	if n, err := m3u8.NewStore(1).Load(filepath.Join(t.TempDir(), "none.json"), nil); err != nil || n != 0 {
+		t.Errorf("missing file: %d, %v", n, err)
+	}
+}
+
+func TestStoreSaveConcurrentUpdate(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), m3u8.PersistFileName)
+	s := m3u8.NewStore(1)
+	info := newInfo("/a")
+	s.Put(info)
+
+	// 模拟客户端请求在持有锁时原地刷新 info
+	var mu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			mu.Lock()
+			info.RemoteTsInfos = append(info.RemoteTsInfos[:0], &m3u8.TsInfo{Url: strconv.Itoa(i) + ".ts"})
+			info.HeadComments = append(info.HeadComments, "#EXTM3U")
+			info.LastUpdate = int64(i)
+			mu.Unlock()
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := s.Save(fp, &mu); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
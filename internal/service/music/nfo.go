@@ -0,0 +1,240 @@
+package music
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// generatedTime 程序生成的附属文件统一使用的修改时间
+//
+// 远程存在同名的真实文件时, 由于修改时间更早, 会被真实文件覆盖
+var generatedTime = time.Unix(0, 0)
+
+// fileLocks 同一专辑的多个曲目会并发合并同一个 nfo, 按文件路径加锁
+var fileLocks sync.Map
+
+// AlbumNFO emby 专辑元数据 (album.nfo)
+type AlbumNFO struct {
+	XMLName     xml.Name        `xml:"album"`
+	Title       string          `xml:"title"`
+	AlbumArtist string          `xml:"albumartist,omitempty"`
+	Year        string          `xml:"year,omitempty"`
+	Genre       string          `xml:"genre,omitempty"`
+	Tracks      []AlbumNFOTrack `xml:"track"`
+}
+
+// AlbumNFOTrack 专辑中的曲目
+type AlbumNFOTrack struct {
+	Disc     int    `xml:"disc,omitempty"`
+	Position int    `xml:"position,omitempty"`
+	Title    string `xml:"title"`
+	Duration string `xml:"duration,omitempty"`
+}
+
+// ArtistNFO emby 艺术家元数据 (artist.nfo)
+type ArtistNFO struct {
+	XMLName xml.Name         `xml:"artist"`
+	Name    string           `xml:"name"`
+	Albums  []ArtistNFOAlbum `xml:"album"`
+}
+
+// ArtistNFOAlbum 艺术家的专辑
+type ArtistNFOAlbum struct {
+	Title string `xml:"title"`
+	Year  string `xml:"year,omitempty"`
+}
+
+// MergeAlbumNFO 将曲目合并到专辑 nfo 中, 专辑 nfo 不存在时自动创建
+//
+// 已存在且不是由程序生成的 nfo 不会被修改, 返回值表示是否发生了写入
+func MergeAlbumNFO(filePath string, meta ffmpeg.Music) (bool, error) {
+	if strings.TrimSpace(meta.Album) == "" {
+		return false, nil
+	}
+
+	return mergeNFO(filePath, func(nfo *AlbumNFO) bool {
+		// 同一目录下存在多个专辑时, 只保留最先写入的专辑
+		if nfo.Title != "" && nfo.Title != meta.Album {
+			return false
+		}
+		nfo.Title = meta.Album
+		nfo.AlbumArtist = cmp.Or(nfo.AlbumArtist, meta.Artist)
+		nfo.Year = cmp.Or(nfo.Year, Year(meta.Date))
+		nfo.Genre = cmp.Or(nfo.Genre, meta.Genre)
+
+		track := AlbumNFOTrack{
+			Disc:     partNumber(meta.Disc),
+			Position: partNumber(meta.Track),
+			Title:    meta.Title,
+			Duration: formatDuration(meta.Duration),
+		}
+		idx := slices.IndexFunc(nfo.Tracks, func(t AlbumNFOTrack) bool {
+			if track.Position > 0 {
+				return t.Disc == track.Disc && t.Position == track.Position
+			}
+			return t.Title == track.Title
+		})
+		if idx == -1 {
+			nfo.Tracks = append(nfo.Tracks, track)
+		} else {
+			nfo.Tracks[idx] = track
+		}
+
+		slices.SortStableFunc(nfo.Tracks, func(a, b AlbumNFOTrack) int {
+			if a.Disc != b.Disc {
+				return a.Disc - b.Disc
+			}
+			return a.Position - b.Position
+		})
+		return true
+	})
+}
+
+// MergeArtistNFO 将专辑合并到艺术家 nfo 中, 艺术家 nfo 不存在时自动创建
+//
+// 曲目有多个艺术家时, 通过 artist 指定当前 nfo 所属的艺术家
+// 已存在且不是由程序生成的 nfo 不会被修改, 返回值表示是否发生了写入
+func MergeArtistNFO(filePath, artist string, meta ffmpeg.Music) (bool, error) {
+	if strings.TrimSpace(artist) == "" {
+		return false, nil
+	}
+
+	return mergeNFO(filePath, func(nfo *ArtistNFO) bool {
+		if nfo.Name != "" && nfo.Name != artist {
+			return false
+		}
+		nfo.Name = artist
+		if strings.TrimSpace(meta.Album) == "" {
+			return true
+		}
+		album := ArtistNFOAlbum{Title: meta.Album, Year: Year(meta.Date)}
+		idx := slices.IndexFunc(nfo.Albums, func(a ArtistNFOAlbum) bool { return a.Title == album.Title })
+		if idx == -1 {
+			nfo.Albums = append(nfo.Albums, album)
+		} else {
+			nfo.Albums[idx] = album
+		}
+		return true
+	})
+}
+
+// WriteLyrics 将歌词写入 lrc 文件, 歌词为空时不写入
+//
+// 已存在且不是由程序生成的 lrc 不会被覆盖, 返回值表示是否发生了写入
+func WriteLyrics(filePath, lyrics string) (bool, error) {
+	if strings.TrimSpace(lyrics) == "" {
+		return false, nil
+	}
+	if stat, err := os.Stat(filePath); err == nil && !IsGenerated(stat) {
+		return false, nil
+	}
+	return true, writeGenerated(filePath, []byte(lyrics))
+}
+
+// WriteCover 将专辑封面写入本地, 文件已存在时不重复写入
+//
+// 返回值表示是否发生了写入
+func WriteCover(filePath string, pic []byte) (bool, error) {
+	if len(pic) == 0 {
+		return false, nil
+	}
+
+	mu := lockFile(filePath)
+	defer mu.Unlock()
+	if _, err := os.Stat(filePath); err == nil {
+		return false, nil
+	}
+	return true, writeGenerated(filePath, pic)
+}
+
+// IsGenerated 判断本地文件是否是由程序生成的附属文件
+func IsGenerated(stat os.FileInfo) bool {
+	return stat.ModTime().Equal(generatedTime)
+}
+
+// Year 从发布日期中提取年份
+func Year(date string) string {
+	date = strings.TrimSpace(date)
+	if len(date) >= 4 {
+		if _, err := strconv.Atoi(date[:4]); err == nil {
+			return date[:4]
+		}
+	}
+	return ""
+}
+
+// mergeNFO 读取已存在的 nfo, 调用 merge 修改后重新写入
+//
+// merge 返回 false 时放弃写入
+func mergeNFO[T any](filePath string, merge func(*T) bool) (bool, error) {
+	mu := lockFile(filePath)
+	defer mu.Unlock()
+
+	nfo := new(T)
+	if stat, err := os.Stat(filePath); err == nil {
+		if !IsGenerated(stat) {
+			return false, nil
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return false, fmt.Errorf("读取 nfo 失败: %w", err)
+		}
+		// 解析失败时直接覆盖
+		if err := xml.Unmarshal(data, nfo); err != nil {
+			nfo = new(T)
+		}
+	}
+
+	if !merge(nfo) {
+		return false, nil
+	}
+	body, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("序列化 nfo 失败: %w", err)
+	}
+	return true, writeGenerated(filePath, append([]byte(xml.Header), body...))
+}
+
+// writeGenerated 写入附属文件, 并将修改时间设置为 generatedTime
+func writeGenerated(filePath string, data []byte) error {
+	if err := os.WriteFile(filePath, data, os.ModePerm); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Chtimes(filePath, generatedTime, generatedTime); err != nil {
+		return fmt.Errorf("设置文件修改时间失败: %w", err)
+	}
+	return nil
+}
+
+// lockFile 获取指定文件的互斥锁并加锁
+func lockFile(filePath string) *sync.Mutex {
+	v, _ := fileLocks.LoadOrStore(filePath, new(sync.Mutex))
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu
+}
+
+// partNumber 解析 "3/10" 形式的序号
+func partNumber(part string) int {
+	n, _, _ := strings.Cut(part, "/")
+	v, _ := strconv.Atoi(strings.TrimSpace(n))
+	return v
+}
+
+// formatDuration 将时长格式化为 m:ss
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	sec := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", sec/60, sec%60)
+}
@@ -0,0 +1,100 @@
+package music_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/service/music"
+)
+
+func TestMergeAlbumNFO(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "album.nfo")
+
+	track := func(title, no string, d time.Duration) ffmpeg.Music {
+		m := ffmpeg.Music{Title: title, Artist: "王栎鑫", Album: "专辑", Date: "2008-05-01", Track: no}
+		m.Duration = d
+		return m
+	}
+	for _, m := range []ffmpeg.Music{
+		track("第二首", "2/3", time.Second*200),
+		track("第一首", "1/3", time.Second*185),
+		track("第二首 (新)", "2/3", time.Second*201),
+	} {
+		ok, err := music.MergeAlbumNFO(fp, m)
+		if err != nil || !ok {
+			t.Fatalf("merge: %v %v", ok, err)
+		}
+	}
+
+	// 不同专辑不会合并到同一个 nfo 中
+	other := track("其他", "1", time.Second)
+	other.Album = "其他专辑"
+	if ok, _ := music.MergeAlbumNFO(fp, other); ok {
+		t.Error("other album should not be merged")
+	}
+
+	var nfo music.AlbumNFO
+	data, _ := os.ReadFile(fp)
+	if err := xml.Unmarshal(data, &nfo); err != nil {
+		t.Fatal(err)
+	}
+	if nfo.Title != "专辑" || nfo.AlbumArtist != "王栎鑫" || nfo.Year != "2008" {
+		t.Errorf("album: %+v", nfo)
+	}
+	if len(nfo.Tracks) != 2 {
+		t.Fatalf("tracks: %+v", nfo.Tracks)
+	}
+	if nfo.Tracks[0].Title != "第一首" || nfo.Tracks[0].Duration != "3:05" || nfo.Tracks[1].Title != "第二首 (新)" {
+		t.Errorf("tracks: %+v", nfo.Tracks)
+	}
+}
+
+func TestMergeArtistNFO(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "artist.nfo")
+	for _, album := range []string{"A", "B", "A"} {
+		if _, err := music.MergeArtistNFO(fp, "林霞", ffmpeg.Music{Artist: "林霞;降央卓玛", Album: album}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var nfo music.ArtistNFO
+	data, _ := os.ReadFile(fp)
+	if err := xml.Unmarshal(data, &nfo); err != nil {
+		t.Fatal(err)
+	}
+	if nfo.Name != "林霞" || len(nfo.Albums) != 2 {
+		t.Errorf("artist: %+v", nfo)
+	}
+}
+
+func TestGeneratedFileYieldsToRealFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// 真实的 nfo 和歌词不会被覆盖
+	nfo, lrc := filepath.Join(dir, "album.nfo"), filepath.Join(dir, "a.lrc")
+	os.WriteFile(nfo, []byte("<album><title>real</title></album>"), os.ModePerm)
+	os.WriteFile(lrc, []byte("real"), os.ModePerm)
+	if ok, err := music.MergeAlbumNFO(nfo, ffmpeg.Music{Album: "real", Title: "x"}); ok || err != nil {
+		t.Errorf("album nfo: %v %v", ok, err)
+	}
+	if ok, err := music.WriteLyrics(lrc, "[00:01.00]lyrics"); ok || err != nil {
+		t.Errorf("lyrics: %v %v", ok, err)
+	}
+
+	// 封面只写入一次
+	cover := filepath.Join(dir, "folder.jpg")
+	if ok, err := music.WriteCover(cover, []byte("first")); !ok || err != nil {
+		t.Fatalf("cover: %v %v", ok, err)
+	}
+	if ok, _ := music.WriteCover(cover, []byte("second")); ok {
+		t.Error("cover should be written once")
+	}
+	stat, err := os.Stat(cover)
+	if err != nil || !music.IsGenerated(stat) {
+		t.Errorf("cover should be marked as generated: %v", err)
+	}
+}
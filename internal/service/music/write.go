@@ -5,6 +5,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
 	"github.com/bogem/id3v2"
@@ -46,7 +47,7 @@ func WriteNFO(filePath string, meta ffmpeg.Music) error {
 	return enc.Encode(info)
 }
 
-// WriteFakeMP3 将音乐元数据写入一个本地虚假 mp3 文件中, 需要先初始化 ffmpeg
+// WriteFakeMP3 将音乐元数据写入一个本地虚假 mp3 文件中
 //
 // 可通过 d 参数指定生成音频的时长
 func WriteFakeMP3(filePath string, meta ffmpeg.Music, pic []byte) error {
@@ -88,13 +89,23 @@ func WriteFakeMP3(filePath string, meta ffmpeg.Music, pic []byte) error {
 		return fmt.Errorf("写入标签至缓冲区发生异常: %w", err)
 	}
 
-	silent, err := ffmpeg.GenSilentMP3Bytes(meta.Duration.Seconds())
-	if err != nil {
-		return fmt.Errorf("生成虚拟静音音频失败: %w", err)
-	}
-	if _, err := buf.Write(silent); err != nil {
+	if _, err := buf.Write(SilentMP3(meta.Duration)); err != nil {
 		return fmt.Errorf("写入虚拟静音音频至缓冲区发生异常: %w", err)
 	}
 
 	return os.WriteFile(filePath, buf.Bytes(), os.ModePerm)
 }
+
+// silentFrame MPEG-2.5 Layer III, 8kbps, 8000Hz, 单声道的静音帧
+//
+// side info 与主数据全部为 0, 解码结果即为静音, 每帧 576 个采样, 时长 72ms
+var silentFrame = append([]byte{0xff, 0xe3, 0x18, 0xc0}, make([]byte, 68)...)
+
+// silentFrameDuration 单个静音帧的时长
+const silentFrameDuration = time.Millisecond * 72
+
+// SilentMP3 生成指定时长的静音 mp3 数据, 不依赖 ffmpeg
+func SilentMP3(d time.Duration) []byte {
+	n := int((d + silentFrameDuration - 1) / silentFrameDuration)
+	return bytes.Repeat(silentFrame, max(n, 1))
+}
@@ -152,8 +152,8 @@ func FetchFsOther(path string, header http.Header) model.HttpRes[FsOther] {
 
 // Fetch 请求 openlist api, 响应封装在 v 指针指向的结构中
 func Fetch(uri, method string, header http.Header, body map[string]any, v any, closeConn bool) error {
-	host := config.C.Openlist.Host
-	token := config.C.Openlist.Token
+	host := config.C().Openlist.Host
+	token := config.C().Openlist.Token
 	if strs.AnyEmpty(host, token) {
 		return fmt.Errorf("openlist.host 或 openlist.token 配置为空")
 	}
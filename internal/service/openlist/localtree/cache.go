@@ -0,0 +1,162 @@
+package localtree
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+// CacheDirName 存放媒体解析缓存的本地目录名称
+const CacheDirName = "openlist-local-tree-cache"
+
+// CacheMaxAge 解析缓存超过该时长未被使用时会被清理
+const CacheMaxAge = time.Hour * 24 * 30
+
+// probeCache 全局媒体解析缓存, 未初始化时不缓存
+var probeCache *ProbeCache
+
+// ProbeCache 以 openlist 文件签名和大小为键, 在磁盘上缓存媒体解析结果
+//
+// 每个文件的解析结果单独存放为一个 json 文件, 文件未变更时不会重复解析
+type ProbeCache struct {
+	// dir 缓存根目录
+	dir string
+}
+
+// probeEntry 缓存在磁盘上的解析结果
+type probeEntry struct {
+	Music ffmpeg.Music `json:"music"`
+	Cover []byte       `json:"cover,omitempty"`
+}
+
+// NewProbeCache 初始化媒体解析缓存
+func NewProbeCache(dir string) (*ProbeCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("初始化缓存目录失败: %w", err)
+	}
+	return &ProbeCache{dir: dir}, nil
+}
+
+// ProbeKey 计算文件的缓存键
+//
+// openlist 未开启签名时, 使用文件路径代替签名
+func ProbeKey(task FileTask) string {
+	id := task.Sign
+	if id == "" {
+		id = task.Path
+	}
+	sum := sha1.Sum(fmt.Appendf(nil, "%s|%d", id, task.Size))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load 读取文件的解析缓存
+func (pc *ProbeCache) Load(task FileTask) (ffmpeg.Music, []byte, bool) {
+	if pc == nil {
+		return ffmpeg.Music{}, nil, false
+	}
+
+	fp := pc.path(ProbeKey(task))
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return ffmpeg.Music{}, nil, false
+	}
+	var e probeEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return ffmpeg.Music{}, nil, false
+	}
+
+	// 刷新修改时间, 避免仍在使用的缓存被清理
+	now := time.Now()
+	os.Chtimes(fp, now, now)
+	return e.Music, e.Cover, true
+}
+
+// Store 缓存文件的解析结果
+func (pc *ProbeCache) Store(task FileTask, m ffmpeg.Music, cover []byte) error {
+	if pc == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(probeEntry{Music: m, Cover: cover})
+	if err != nil {
+		return fmt.Errorf("序列化解析结果失败: %w", err)
+	}
+
+	fp := pc.path(ProbeKey(task))
+	if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+		return fmt.Errorf("初始化缓存目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名, 避免并发读取到不完整的内容
+	// 每次写入使用独立的临时文件, 同一个文件并发解析时不会互相覆盖
+	tmp, err := os.CreateTemp(filepath.Dir(fp), filepath.Base(fp)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), fp)
+}
+
+// Sweep 清理超过 maxAge 未被使用的缓存和残留的临时文件, 返回清理的文件数
+func (pc *ProbeCache) Sweep(maxAge time.Duration) (int, error) {
+	if pc == nil {
+		return 0, nil
+	}
+
+	deadline := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(pc.dir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 并发清理时文件可能已经不存在
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		name := d.Name()
+		if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			return nil
+		}
+		if err := os.Remove(fp); err == nil {
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("清理缓存失败: %w", err)
+	}
+	return removed, nil
+}
+
+// path 缓存键对应的磁盘路径, 按键的前两位分散到子目录中
+func (pc *ProbeCache) path(key string) string {
+	return filepath.Join(pc.dir, key[:2], key+".json")
+}
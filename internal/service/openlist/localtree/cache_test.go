@@ -0,0 +1,161 @@
+package localtree_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/service/openlist/localtree"
+)
+
+func TestProbeCache(t *testing.T) {
+	pc, err := localtree.NewProbeCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := localtree.FileTask{Path: "/音乐/test.flac", Sign: "abc=:0", Size: 1024}
+	if _, _, ok := pc.Load(task); ok {
+		t.Fatal("unexpected cache hit")
+	}
+
+	m := ffmpeg.Music{Title: "哥哥", Artist: "王栎鑫"}
+	m.Duration = time.Minute * 3
+	m.Audios = []ffmpeg.AudioStream{{Codec: "flac", Channels: 2, SampleRate: 44100}}
+	cover := []byte("fake cover")
+	if err := pc.Store(task, m, cover); err != nil {
+		t.Fatal(err)
+	}
+
+	got, pic, ok := pc.Load(task)
+	if !ok {
+		t.Fatal("cache miss")
+	}
+	if got.Title != m.Title || got.Duration != m.Duration || len(got.Audios) != 1 || got.Audios[0].SampleRate != 44100 {
+		t.Errorf("music: %+v", got)
+	}
+	if !bytes.Equal(pic, cover) {
+		t.Errorf("cover: %q", pic)
+	}
+
+	// 文件大小变更后缓存失效
+	changed := task
+	changed.Size = 2048
+	if _, _, ok := pc.Load(changed); ok {
+		t.Error("cache should miss after size changed")
+	}
+}
+
+func TestProbeKey(t *testing.T) {
+	a := localtree.FileTask{Path: "/a.mp4", Size: 1}
+	b := localtree.FileTask{Path: "/b.mp4", Size: 1}
+	if localtree.ProbeKey(a) == localtree.ProbeKey(b) {
+		t.Error("paths should produce different keys without sign")
+	}
+
+	a.Sign, b.Sign = "same", "same"
+	if localtree.ProbeKey(a) != localtree.ProbeKey(b) {
+		t.Error("sign should take precedence over path")
+	}
+}
+
+func TestProbeCacheStoreConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	pc, err := localtree.NewProbeCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := localtree.FileTask{Path: "/a.flac", Size: 1}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pc.Store(task, ffmpeg.Music{Title: "a"}, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, _, ok := pc.Load(task); !ok {
+		t.Fatal("cache miss")
+	}
+
+	// 只保留最终的缓存文件, 权限为 0644
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if len(files) != 1 {
+		t.Fatalf("files: %v", files)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0644 {
+		t.Errorf("perm: %o", perm)
+	}
+}
+
+func TestProbeCacheSweep(t *testing.T) {
+	dir := t.TempDir()
+	pc, err := localtree.NewProbeCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := localtree.FileTask{Path: "/fresh.flac", Size: 1}
+	stale := localtree.FileTask{Path: "/stale.flac", Size: 1}
+	used := localtree.FileTask{Path: "/used.flac", Size: 1}
+	for _, task := range []localtree.FileTask{fresh, stale, used} {
+		if err := pc.Store(task, ffmpeg.Music{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Now().Add(-time.Hour)
+	age := func(task localtree.FileTask) {
+		key := localtree.ProbeKey(task)
+		if err := os.Chtimes(filepath.Join(dir, key[:2], key+".json"), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age(stale)
+	age(used)
+
+	// 残留的临时文件
+	tmp := filepath.Join(dir, "ab", "leftover.json.123.tmp")
+	os.MkdirAll(filepath.Dir(tmp), 0755)
+	if err := os.WriteFile(tmp, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(tmp, old, old)
+
+	// 读取命中后刷新使用时间
+	if _, _, ok := pc.Load(used); !ok {
+		t.Fatal("cache miss")
+	}
+
+	removed, err := pc.Sweep(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed: %d", removed)
+	}
+	if _, _, ok := pc.Load(stale); ok {
+		t.Error("stale entry should be removed")
+	}
+	for _, task := range []localtree.FileTask{fresh, used} {
+		if _, _, ok := pc.Load(task); !ok {
+			t.Errorf("%s should be kept", task.Path)
+		}
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("leftover tmp should be removed")
+	}
+}
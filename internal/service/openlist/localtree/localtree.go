@@ -7,21 +7,40 @@ import (
 	"time"
 
 	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
 	"github.com/syscc/Emby-Go/internal/util/logs/colors"
 )
 
 // DirName 存放目录树的本地目录名称
 const DirName = "openlist-local-tree"
 
+// syncSignal 手动触发同步的信号, 同步进行中多次触发只会在结束后再同步一次
+var syncSignal = make(chan struct{}, 1)
+
 // Init 根据配置文件, 初始化本地目录树
 func Init() error {
 	// 判断配置是否开启
-	if !config.C.Openlist.LocalTreeGen.Enable {
+	ltg := config.C().Openlist.LocalTreeGen
+	if !ltg.Enable {
 		return nil
 	}
 
+	// 修改 ffmpeg 相关配置需要重启内核, 只在启动时初始化一次
+	if ltg.FFmpegEnable && ltg.FFmpegFallback {
+		if err := ffmpeg.AutoDownloadExec(config.BasePath); err != nil {
+			return fmt.Errorf("ffmpeg 初始化失败: %w", err)
+		}
+	}
+	ffmpeg.SetWorkers(ltg.FFmpegWorkers)
+
 	dirAbs := filepath.Join(config.BasePath, DirName)
 
+	pc, err := NewProbeCache(filepath.Join(config.BasePath, CacheDirName))
+	if err != nil {
+		return fmt.Errorf("初始化媒体解析缓存失败: %w", err)
+	}
+	probeCache = pc
+
 	s := NewSynchronizer(dirAbs, 30)
 	go startSync(s)
 
@@ -39,16 +58,40 @@ func startSync(s *Synchronizer) {
 			return
 		}
 		logf(colors.Green, "同步完成, 总数: %d, 新增: %d, 删除: %d, 耗时: %v", total, added, deleted, time.Since(start))
+
+		removed, err := probeCache.Sweep(CacheMaxAge)
+		if err != nil {
+			logf(colors.Yellow, "%v", err)
+		} else if removed > 0 {
+			logf(colors.Gray, "已清理过期的解析缓存: %d", removed)
+		}
 	}
 	doSync()
 
-	d := time.Minute * time.Duration(config.C.Openlist.LocalTreeGen.RefreshInterval)
+	d := time.Minute * time.Duration(config.C().Openlist.LocalTreeGen.RefreshInterval)
 	timer := time.NewTicker(d)
-	for range timer.C {
+	for {
+		select {
+		case <-timer.C:
+		case <-syncSignal:
+			logf(colors.Blue, "收到手动同步请求")
+		}
 		doSync()
 	}
 }
 
+// TriggerSync 立即触发一次目录树同步, 本地目录树未启用时返回 false
+func TriggerSync() bool {
+	if !config.C().Openlist.LocalTreeGen.Enable {
+		return false
+	}
+	select {
+	case syncSignal <- struct{}{}:
+	default:
+	}
+	return true
+}
+
 // logf 带上前缀的日志输出
 func logf(c colors.C, format string, v ...any) {
 	s := fmt.Sprintf(format, v...)
@@ -0,0 +1,219 @@
+package localtree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+)
+
+var (
+	// sidecarImageContainers 可作为海报的图片容器
+	sidecarImageContainers = map[string]struct{}{"jpg": {}, "jpeg": {}, "png": {}, "webp": {}}
+
+	// sidecarArtworkNames emby 可识别的海报文件名 (不含扩展名)
+	sidecarArtworkNames = map[string]struct{}{
+		"poster": {}, "fanart": {}, "folder": {}, "cover": {}, "banner": {}, "logo": {},
+		"clearlogo": {}, "clearart": {}, "thumb": {}, "landscape": {}, "backdrop": {}, "disc": {},
+	}
+
+	// sidecarArtworkSuffixes 与媒体同名的海报文件后缀, 如 movie-poster.jpg
+	sidecarArtworkSuffixes = []string{"-poster", "-fanart", "-thumb", "-landscape", "-banner", "-logo", "-clearlogo", "-clearart", "-backdrop"}
+
+	// backdropReg 多张背景图, 如 backdrop1.jpg, fanart2.jpg
+	backdropReg = regexp.MustCompile(`^(backdrop|fanart)\d+$`)
+
+	// episodeReg 从文件名中识别剧集的季和集, 如 S01E02
+	episodeReg = regexp.MustCompile(`(?i)S(\d{1,4})E(\d{1,4})`)
+)
+
+// IsSidecar 判断一个 openlist 文件是否属于刮削附属文件 (nfo 或海报)
+func IsSidecar(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(base)
+	container := strings.TrimPrefix(ext, ".")
+	if container == "nfo" {
+		return true
+	}
+	if _, ok := sidecarImageContainers[container]; !ok {
+		return false
+	}
+
+	name := strings.TrimSuffix(base, ext)
+	if _, ok := sidecarArtworkNames[name]; ok {
+		return true
+	}
+	if backdropReg.MatchString(name) {
+		return true
+	}
+	for _, suffix := range sidecarArtworkSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SidecarGenerator 写入文件时会额外生成附属文件的 TaskWriter
+//
+// 生成的附属文件需要计入快照, 防止被当作过期文件删除
+type SidecarGenerator interface {
+
+	// Sidecars 返回本地路径对应的文件必定会生成的附属文件路径, 缺失时会触发重新写入
+	Sidecars(localPath string) []string
+
+	// OptionalSidecars 返回本地路径对应的文件可能会生成的附属文件路径, 缺失时不会触发重新写入
+	OptionalSidecars(localPath string) []string
+}
+
+// 音乐库附属文件名称
+const (
+	AlbumNfoName   = "album.nfo"
+	ArtistNfoName  = "artist.nfo"
+	AlbumCoverName = "folder.jpg"
+)
+
+// artistSeparators 多个艺术家之间的分隔符
+var artistSeparators = regexp.MustCompile(`\s*[;/&,、]\s*`)
+
+// LrcPath 获取音乐文件同名 lrc 歌词的路径
+func LrcPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc"
+}
+
+// IsArtistDir 判断目录名是否与音乐的艺术家同名, 多个艺术家时匹配任意一个即可
+func IsArtistDir(dirName, artist string) bool {
+	dirName = strings.TrimSpace(dirName)
+	if dirName == "" || strings.TrimSpace(artist) == "" {
+		return false
+	}
+	if strings.EqualFold(dirName, strings.TrimSpace(artist)) {
+		return true
+	}
+	for _, a := range artistSeparators.Split(artist, -1) {
+		if strings.EqualFold(dirName, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// NfoPath 获取媒体文件同名 nfo 的路径
+func NfoPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".nfo"
+}
+
+// videoNfoStreams nfo 中的媒体流信息
+type videoNfoStreams struct {
+	Video    *videoNfoVideo     `xml:"video,omitempty"`
+	Audio    []videoNfoAudio    `xml:"audio,omitempty"`
+	Subtitle []videoNfoSubtitle `xml:"subtitle,omitempty"`
+}
+
+type videoNfoVideo struct {
+	Codec             string `xml:"codec,omitempty"`
+	Width             int    `xml:"width,omitempty"`
+	Height            int    `xml:"height,omitempty"`
+	FrameRate         string `xml:"framerate,omitempty"`
+	HdrType           string `xml:"hdrtype,omitempty"`
+	DurationInSeconds int    `xml:"durationinseconds,omitempty"`
+}
+
+type videoNfoAudio struct {
+	Codec    string `xml:"codec"`
+	Language string `xml:"language,omitempty"`
+	Channels int    `xml:"channels,omitempty"`
+}
+
+type videoNfoSubtitle struct {
+	Codec    string `xml:"codec,omitempty"`
+	Language string `xml:"language,omitempty"`
+}
+
+// VideoNFO 虚拟文件的简易元数据, 兼容 movie 和 episodedetails 两种根节点
+type VideoNFO struct {
+	XMLName xml.Name `xml:""`
+	Season  string   `xml:"season,omitempty"`
+	Episode string   `xml:"episode,omitempty"`
+	Runtime int      `xml:"runtime,omitempty"`
+	Streams struct {
+		Details videoNfoStreams `xml:"streamdetails"`
+	} `xml:"fileinfo"`
+}
+
+// NewVideoNFO 根据文件名和 ffmpeg 解析的元信息构造 nfo
+//
+// 文件名包含 SxxExx 时生成剧集 nfo, 否则生成电影 nfo
+func NewVideoNFO(path string, info ffmpeg.Info) VideoNFO {
+	nfo := VideoNFO{XMLName: xml.Name{Local: "movie"}}
+	if res := episodeReg.FindStringSubmatch(filepath.Base(path)); len(res) == 3 {
+		season, _ := strconv.Atoi(res[1])
+		episode, _ := strconv.Atoi(res[2])
+		nfo.XMLName.Local = "episodedetails"
+		nfo.Season = strconv.Itoa(season)
+		nfo.Episode = strconv.Itoa(episode)
+	}
+
+	if info.Duration > 0 {
+		nfo.Runtime = int(info.Duration.Round(time.Minute) / time.Minute)
+	}
+	if v := info.Video; v != nil {
+		nfo.Streams.Details.Video = &videoNfoVideo{
+			Codec:             v.Codec,
+			Width:             v.Width,
+			Height:            v.Height,
+			HdrType:           strings.ToLower(v.HDR),
+			DurationInSeconds: int(info.Duration / time.Second),
+		}
+		if v.FrameRate > 0 {
+			nfo.Streams.Details.Video.FrameRate = strconv.FormatFloat(v.FrameRate, 'f', -1, 64)
+		}
+	}
+	for _, a := range info.Audios {
+		nfo.Streams.Details.Audio = append(nfo.Streams.Details.Audio, videoNfoAudio{
+			Codec:    a.Codec,
+			Language: a.Language,
+			Channels: a.Channels,
+		})
+	}
+	for _, sub := range info.Subtitles {
+		nfo.Streams.Details.Subtitle = append(nfo.Streams.Details.Subtitle, videoNfoSubtitle{
+			Codec:    sub.Codec,
+			Language: sub.Language,
+		})
+	}
+	return nfo
+}
+
+// WriteVideoNFO 为虚拟文件写入简易 nfo
+//
+// 若 nfo 已存在 (如从 openlist 同步而来) 则不覆盖;
+// 生成的 nfo 修改时间置为零点, 保证 openlist 上后续出现的同名 nfo 能够覆盖它
+func WriteVideoNFO(filePath string, info ffmpeg.Info) (bool, error) {
+	if _, err := os.Stat(filePath); err == nil {
+		return false, nil
+	}
+
+	nfo := NewVideoNFO(filePath, info)
+	body, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("序列化 nfo 失败: %w", err)
+	}
+
+	body = append([]byte(xml.Header), body...)
+	if err = os.WriteFile(filePath, body, os.ModePerm); err != nil {
+		return false, fmt.Errorf("写入 nfo 失败: %w", err)
+	}
+
+	epoch := time.Unix(0, 0)
+	if err = os.Chtimes(filePath, epoch, epoch); err != nil {
+		return false, fmt.Errorf("设置 nfo 修改时间失败: %w", err)
+	}
+	return true, nil
+}
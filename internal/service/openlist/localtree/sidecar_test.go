@@ -0,0 +1,102 @@
+package localtree_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/service/openlist/localtree"
+)
+
+func TestIsSidecar(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/电影/流浪地球 (2019)/流浪地球 (2019).nfo", want: true},
+		{path: "/电影/流浪地球 (2019)/poster.jpg", want: true},
+		{path: "/电影/流浪地球 (2019)/Fanart.PNG", want: true},
+		{path: "/电影/流浪地球 (2019)/backdrop2.jpg", want: true},
+		{path: "/电影/流浪地球 (2019)/流浪地球 (2019)-poster.webp", want: true},
+		{path: "/电视剧/三体/Season 1/season01-poster.jpg", want: true},
+		{path: "/电影/流浪地球 (2019)/截图.jpg", want: false},
+		{path: "/电影/流浪地球 (2019)/poster.txt", want: false},
+		{path: "/电影/流浪地球 (2019)/流浪地球 (2019).mp4", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := localtree.IsSidecar(tt.path); got != tt.want {
+				t.Errorf("IsSidecar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteVideoNFO(t *testing.T) {
+	dir := t.TempDir()
+	info := ffmpeg.Info{
+		Duration:  time.Minute*42 + time.Second*10,
+		Video:     &ffmpeg.VideoStream{Codec: "hevc", Width: 3840, Height: 2160, HDR: ffmpeg.HDR10},
+		Audios:    []ffmpeg.AudioStream{{Codec: "eac3", Language: "chi", Channels: 6}, {Codec: "aac", Language: "eng", Channels: 2}},
+		Subtitles: []ffmpeg.SubtitleStream{{Codec: "subrip", Language: "chi"}},
+	}
+
+	fp := filepath.Join(dir, localtree.NfoPath("三体.S01E02.2160p.mkv"))
+	ok, err := localtree.WriteVideoNFO(fp, info)
+	if err != nil || !ok {
+		t.Fatalf("WriteVideoNFO() = %v, %v", ok, err)
+	}
+
+	body, err := os.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nfo localtree.VideoNFO
+	if err = xml.Unmarshal(body, &nfo); err != nil {
+		t.Fatal(err)
+	}
+	if nfo.XMLName.Local != "episodedetails" || nfo.Season != "1" || nfo.Episode != "2" {
+		t.Errorf("unexpected episode nfo: %s", body)
+	}
+	if nfo.Runtime != 42 || !strings.Contains(string(body), "<width>3840</width>") ||
+		!strings.Contains(string(body), "<hdrtype>hdr10</hdrtype>") ||
+		strings.Count(string(body), "<audio>") != 2 || strings.Count(string(body), "<subtitle>") != 1 {
+		t.Errorf("unexpected stream details: %s", body)
+	}
+
+	stat, err := os.Stat(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.ModTime().After(time.Unix(0, 0)) {
+		t.Errorf("generated nfo should be overwritable, modtime: %v", stat.ModTime())
+	}
+
+	// 已存在的 nfo 不覆盖
+	if ok, err = localtree.WriteVideoNFO(fp, info); err != nil || ok {
+		t.Errorf("WriteVideoNFO() on existing file = %v, %v", ok, err)
+	}
+}
+
+func TestIsArtistDir(t *testing.T) {
+	tests := []struct {
+		dir, artist string
+		want        bool
+	}{
+		{"王栎鑫", "王栎鑫", true},
+		{"Beyond", "beyond", true},
+		{"林霞", "林霞、降央卓玛", true},
+		{"降央卓玛", "林霞; 降央卓玛", true},
+		{"专辑", "王栎鑫", false},
+		{"王栎鑫", "", false},
+	}
+	for _, tt := range tests {
+		if got := localtree.IsArtistDir(tt.dir, tt.artist); got != tt.want {
+			t.Errorf("IsArtistDir(%q, %q) = %v, want %v", tt.dir, tt.artist, got, tt.want)
+		}
+	}
+}
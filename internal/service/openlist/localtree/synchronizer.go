@@ -70,7 +70,7 @@ func (s *Synchronizer) Sync() (total, added, deleted int, err error) {
 	s.toSyncTasks = make(chan []FileTask, 1024)
 	okTaskChan := make(chan FileTask, 1024)
 	s.eg, s.ctx = errgroup.WithContext(context.Background())
-	s.threadsSem = make(chan struct{}, config.C.Openlist.LocalTreeGen.Threads)
+	s.threadsSem = make(chan struct{}, config.C().Openlist.LocalTreeGen.Threads)
 	s.hasScanFinish, s.hasScanTotal = 0, 0
 
 	// 读取根目录放置到任务通道中
@@ -230,20 +230,36 @@ func (s *Synchronizer) handleSyncTasks(okTaskChan chan<- FileTask) {
 			return nil
 		}
 
-		// 获取适配容器的 writer
+		// 获取适配容器的 writer, 刮削附属文件始终下载源文件
 		writer := LoadTaskWriter(task.Container)
+		if config.C().Openlist.LocalTreeGen.SidecarEnable && IsSidecar(task.Path) {
+			writer = &rw
+		}
 
 		// 将 openlist 路径转换为本地磁盘相应路径
 		task.LocalPath = writer.Path(task.Path)
 		localAbsPath := filepath.Join(s.baseDir, strings.TrimPrefix(task.LocalPath, "/"))
 
+		// 记录需要额外生成的附属文件
+		sidecarMissing := false
+		if g, ok := writer.(SidecarGenerator); ok {
+			task.Sidecars = g.Sidecars(task.LocalPath)
+			for _, sc := range task.Sidecars {
+				if _, err := os.Stat(filepath.Join(s.baseDir, strings.TrimPrefix(sc, "/"))); err != nil {
+					sidecarMissing = true
+				}
+			}
+			task.Sidecars = append(task.Sidecars, g.OptionalSidecars(task.LocalPath)...)
+		}
+
 		// 如果路径被目录占用, 则删除目录
 		stat, err := os.Stat(localAbsPath)
 		if err == nil {
 			if !stat.IsDir() {
 				// 文件已存在
 				// 根据本地文件的修改时间和远程文件的修改时间判断文件是否发生变更
-				if stat.ModTime().After(task.Modified) {
+				// 附属文件缺失时需要重新生成
+				if stat.ModTime().After(task.Modified) && !sidecarMissing {
 					return nil
 				}
 			}
@@ -271,11 +287,11 @@ func (s *Synchronizer) handleSyncTasks(okTaskChan chan<- FileTask) {
 				defer atomic.AddInt64(&s.hasScanFinish, 1)
 
 				// 根据用户配置忽略特定文件和目录
-				cfg := config.C.Openlist.LocalTreeGen
+				cfg := config.C().Openlist.LocalTreeGen
 				if !cfg.IsValidPrefix(task.Path) {
 					continue
 				}
-				if !task.IsDir && cfg.IsIgnore(task.Container) {
+				if !task.IsDir && cfg.IsIgnore(task.Container) && !(cfg.SidecarEnable && IsSidecar(task.Path)) {
 					continue
 				}
 
@@ -354,6 +370,13 @@ func (s *Synchronizer) updateLocalTree(okTaskChan <-chan FileTask, total, added,
 			if _, exists := s.snapshot.Check(cleanLocalPath); !exists {
 				*added++
 			}
+
+			// 已生成的附属文件计入快照, 避免被删除
+			for _, sc := range task.Sidecars {
+				if _, err := os.Stat(filepath.Join(s.baseDir, strings.TrimPrefix(sc, "/"))); err == nil {
+					current.Put(urls.TransferSlash(sc), false)
+				}
+			}
 		}
 	}
 
@@ -369,7 +392,7 @@ func (s *Synchronizer) updateLocalTree(okTaskChan <-chan FileTask, total, added,
 		toDelete = append(toDelete, filepath.Join(s.baseDir, path))
 	}
 
-	maxCount := config.C.Openlist.LocalTreeGen.AutoRemoveMaxCount
+	maxCount := config.C().Openlist.LocalTreeGen.AutoRemoveMaxCount
 	if len(toDelete) > maxCount {
 		logf(colors.Yellow, "过期文件数量 [%d] 超出最大限制 [%d], 跳过删除操作", len(toDelete), maxCount)
 		return
@@ -1,11 +1,13 @@
 package localtree
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -14,6 +16,7 @@ import (
 	"github.com/syscc/Emby-Go/internal/config"
 	"github.com/syscc/Emby-Go/internal/constant"
 	"github.com/syscc/Emby-Go/internal/service/lib/ffmpeg"
+	"github.com/syscc/Emby-Go/internal/service/lib/probe"
 	"github.com/syscc/Emby-Go/internal/service/music"
 	"github.com/syscc/Emby-Go/internal/service/openlist"
 	"github.com/syscc/Emby-Go/internal/util/bytess"
@@ -42,8 +45,14 @@ type FileTask struct {
 	// Sign openlist 文件签名
 	Sign string
 
+	// Size 文件大小
+	Size int64
+
 	// Modified 文件的最后修改时间
 	Modified time.Time
+
+	// Sidecars 写入文件时额外生成的附属文件本地路径, 如虚拟文件的 nfo
+	Sidecars []string
 }
 
 func FsGetTask(prefix string, info openlist.FsGet) FileTask {
@@ -55,6 +64,7 @@ func FsGetTask(prefix string, info openlist.FsGet) FileTask {
 		IsDir:     info.IsDir,
 		Sign:      info.Sign,
 		Container: container,
+		Size:      info.Size,
 		Modified:  info.Modified,
 	}
 }
@@ -79,7 +89,7 @@ var (
 
 // LoadTaskWriter 根据文件容器加载 TaskWriter
 func LoadTaskWriter(container string) TaskWriter {
-	cfg := config.C.Openlist.LocalTreeGen
+	cfg := config.C().Openlist.LocalTreeGen
 	if cfg.IsVirtual(container) {
 		return &vw
 	}
@@ -101,24 +111,44 @@ func (vw *VirtualWriter) Path(path string) string {
 	return path
 }
 
+// Sidecars 开启附属文件同步和 ffmpeg 辅助时, 虚拟文件会额外生成同名 nfo
+func (vw *VirtualWriter) Sidecars(localPath string) []string {
+	cfg := config.C().Openlist.LocalTreeGen
+	if !cfg.SidecarEnable || !cfg.FFmpegEnable {
+		return nil
+	}
+	return []string{NfoPath(localPath)}
+}
+
+// OptionalSidecars 虚拟文件没有可选的附属文件
+func (vw *VirtualWriter) OptionalSidecars(localPath string) []string {
+	return nil
+}
+
 // Write 将文件信息写入到本地文件系统中
 func (vw *VirtualWriter) Write(task FileTask, localPath string) error {
 	// 默认写入时长 3 小时
 	dftDuration := time.Hour * 3
-	if !config.C.Openlist.LocalTreeGen.FFmpegEnable {
+	if !config.C().Openlist.LocalTreeGen.FFmpegEnable {
 		return os.WriteFile(localPath, mp4s.GenWithDuration(dftDuration), os.ModePerm)
 	}
 
-	var info ffmpeg.Info
-	err := trys.Try(func() (err error) {
-		info, err = ffmpeg.InspectInfo(getRealDownloadUrl(task))
-		return
-	}, 3, time.Second)
+	m, _, _, err := inspectCached(task, func(url string) (ffmpeg.Music, []byte, error) {
+		info, err := probe.InspectInfo(url)
+		return ffmpeg.Music{Info: info}, nil, err
+	})
 	if err != nil {
-		return fmt.Errorf("调用 ffmpeg 失败: %w", err)
+		return fmt.Errorf("解析媒体信息失败: %w", err)
+	}
+	info := m.Info
+	if info.Duration <= 0 {
+		// 无法原生解析且未开启 ffmpeg 回退, 使用默认时长
+		logf(colors.Yellow, "无法解析媒体信息, 使用默认时长生成虚拟文件 [%s]", filepath.Base(task.Path))
+		info.Duration = dftDuration
 	}
 
-	if err := os.WriteFile(localPath, mp4s.GenWithDuration(info.Duration), os.ModePerm); err != nil {
+	tracks := VirtualTracks(info)
+	if err := os.WriteFile(localPath, mp4s.GenWithTracks(info.Duration, tracks...), os.ModePerm); err != nil {
 		return err
 	}
 
@@ -126,10 +156,98 @@ func (vw *VirtualWriter) Write(task FileTask, localPath string) error {
 	if err != nil {
 		abs = localPath
 	}
-	logf(colors.Gray, "生成虚拟文件 [%s]: [时长: %v]", abs, info.Duration)
+	logf(colors.Gray, "生成虚拟文件 [%s]: [时长: %v] [轨道数: %d]", abs, info.Duration, len(tracks))
+
+	if !config.C().Openlist.LocalTreeGen.SidecarEnable {
+		return nil
+	}
+	ok, err := WriteVideoNFO(NfoPath(localPath), info)
+	if err != nil {
+		return fmt.Errorf("生成虚拟文件 nfo 失败 [%s]: %w", abs, err)
+	}
+	if ok {
+		logf(colors.Gray, "生成虚拟文件 nfo [%s]", NfoPath(abs))
+	}
 	return nil
 }
 
+// inspectCached 解析远程媒体的元信息, 文件未变更时直接使用缓存结果
+//
+// 格式不受支持时 unsupported 为 true, 此时不返回错误也不写入缓存
+func inspectCached(task FileTask, inspect func(url string) (ffmpeg.Music, []byte, error)) (m ffmpeg.Music, pic []byte, unsupported bool, err error) {
+	if m, pic, ok := probeCache.Load(task); ok {
+		return m, pic, false, nil
+	}
+
+	err = trys.Try(func() (err error) {
+		m, pic, err = inspect(getRealDownloadUrl(task))
+		if unsupported = probeUnsupported(err); unsupported {
+			return nil
+		}
+		return
+	}, 3, time.Second)
+	if err != nil || unsupported {
+		return
+	}
+
+	if err := probeCache.Store(task, m, pic); err != nil {
+		logf(colors.Yellow, "缓存媒体解析结果失败 [%s]: %v", filepath.Base(task.Path), err)
+	}
+	return
+}
+
+// probeUnsupported 判断是否是无法重试的解析错误 (格式不支持或远程不支持 Range)
+func probeUnsupported(err error) bool {
+	return errors.Is(err, probe.ErrUnsupported) || errors.Is(err, probe.ErrRangeUnsupported)
+}
+
+// VirtualTracks 将 ffmpeg 解析的媒体流转换为虚拟 mp4 的轨道描述
+func VirtualTracks(info ffmpeg.Info) []mp4s.Track {
+	tracks := make([]mp4s.Track, 0, 1+len(info.Audios)+len(info.Subtitles))
+
+	if v := info.Video; v != nil {
+		t := mp4s.Track{
+			Kind:      mp4s.TrackVideo,
+			Codec:     v.Codec,
+			Default:   true,
+			Width:     v.Width,
+			Height:    v.Height,
+			FrameRate: v.FrameRate,
+			DVProfile: v.DVProfile,
+			DVLevel:   v.DVLevel,
+		}
+		switch v.ColorTransfer {
+		case "smpte2084":
+			t.ColorTransfer = mp4s.TransferPQ
+		case "arib-std-b67":
+			t.ColorTransfer = mp4s.TransferHLG
+		}
+		tracks = append(tracks, t)
+	}
+
+	for _, a := range info.Audios {
+		tracks = append(tracks, mp4s.Track{
+			Kind:       mp4s.TrackAudio,
+			Codec:      a.Codec,
+			Language:   a.Language,
+			Default:    a.Default,
+			Channels:   a.Channels,
+			SampleRate: a.SampleRate,
+		})
+	}
+
+	for _, s := range info.Subtitles {
+		tracks = append(tracks, mp4s.Track{
+			Kind:     mp4s.TrackSubtitle,
+			Codec:    s.Codec,
+			Language: s.Language,
+			Default:  s.Default,
+		})
+	}
+
+	return tracks
+}
+
 // StrmWriter 写文件对应的 openlist strm 文件
 type StrmWriter struct{}
 
@@ -142,7 +260,7 @@ func (sw *StrmWriter) OpenlistPath(task FileTask) string {
 
 	return fmt.Sprintf(
 		"%s/d/%s?sign=%s",
-		config.C.Openlist.Host,
+		config.C().Openlist.Host,
 		strings.Join(segs, "/"),
 		task.Sign,
 	)
@@ -181,32 +299,24 @@ func (mw *MusicWriter) Path(path string) string {
 
 // Write 将文件信息写入到本地文件系统中
 func (mw *MusicWriter) Write(task FileTask, localPath string) error {
-	if !config.C.Openlist.LocalTreeGen.FFmpegEnable {
+	if !config.C().Openlist.LocalTreeGen.FFmpegEnable {
 		// 必须开启 ffmpeg 才能生成, 改用 strm 替代
 		return sw.Write(task, localPath)
 	}
 
-	var meta ffmpeg.Music
-	err := trys.Try(func() (err error) {
-		meta, err = ffmpeg.InspectMusic(getRealDownloadUrl(task))
-		return
-	}, 3, time.Second)
+	meta, pic, unsupported, err := inspectCached(task, probe.InspectMusic)
 	if err != nil {
 		return fmt.Errorf("提取音乐元数据失败 [%s]: %w", filepath.Base(task.Path), err)
 	}
+	if unsupported {
+		// 无法原生解析且未开启 ffmpeg 回退, 改用 strm 替代
+		logf(colors.Yellow, "无法解析音乐元数据, 改用 strm 替代 [%s]", filepath.Base(task.Path))
+		return sw.Write(task, localPath)
+	}
 	if meta.Duration == 0 {
 		meta.Duration = time.Second
 	}
 
-	var pic []byte
-	err = trys.Try(func() (err error) {
-		pic, err = ffmpeg.ExtractMusicCover(getRealDownloadUrl(task))
-		return
-	}, 3, time.Second)
-	if err != nil {
-		return fmt.Errorf("提取音乐封面失败 [%s]: %w", filepath.Base(task.Path), err)
-	}
-
 	if err := music.WriteFakeMP3(localPath, meta, pic); err != nil {
 		return err
 	}
@@ -216,6 +326,75 @@ func (mw *MusicWriter) Write(task FileTask, localPath string) error {
 		abs = localPath
 	}
 	logf(colors.Gray, "生成音乐虚拟文件 [%s]: [标题: %s] [艺术家: %s] [时长: %v]", abs, meta.Title, meta.Artist, meta.Duration)
+
+	if !config.C().Openlist.LocalTreeGen.SidecarEnable {
+		return nil
+	}
+	return mw.writeLibrary(abs, meta, pic)
+}
+
+// Sidecars 音乐的附属文件取决于标签内容, 均为可选
+func (mw *MusicWriter) Sidecars(localPath string) []string {
+	return nil
+}
+
+// OptionalSidecars 开启附属文件同步和 ffmpeg 辅助时, 音乐会额外生成
+// 同名 lrc 歌词, 以及所在专辑目录的 album.nfo、folder.jpg 和艺术家目录的 artist.nfo
+func (mw *MusicWriter) OptionalSidecars(localPath string) []string {
+	cfg := config.C().Openlist.LocalTreeGen
+	if !cfg.SidecarEnable || !cfg.FFmpegEnable {
+		return nil
+	}
+
+	albumDir := path.Dir(localPath)
+	res := []string{
+		LrcPath(localPath),
+		path.Join(albumDir, AlbumNfoName),
+		path.Join(albumDir, AlbumCoverName),
+	}
+	if artistDir := path.Dir(albumDir); artistDir != "/" && artistDir != "." {
+		res = append(res, path.Join(artistDir, ArtistNfoName))
+	}
+	return res
+}
+
+// writeLibrary 根据音乐标签生成歌词、专辑和艺术家附属文件, 供 emby 音乐库直接读取
+func (mw *MusicWriter) writeLibrary(localPath string, meta ffmpeg.Music, pic []byte) error {
+	albumDir := filepath.Dir(localPath)
+
+	if ok, err := music.WriteLyrics(LrcPath(localPath), meta.Lyrics); err != nil {
+		return fmt.Errorf("生成歌词失败 [%s]: %w", localPath, err)
+	} else if ok {
+		logf(colors.Gray, "生成歌词 [%s]", LrcPath(localPath))
+	}
+
+	albumNfo := filepath.Join(albumDir, AlbumNfoName)
+	if ok, err := music.MergeAlbumNFO(albumNfo, meta); err != nil {
+		return fmt.Errorf("生成专辑 nfo 失败 [%s]: %w", albumNfo, err)
+	} else if ok {
+		logf(colors.Gray, "更新专辑 nfo [%s]: [专辑: %s]", albumNfo, meta.Album)
+	}
+
+	// 专辑目录内只写入一次封面
+	cover := filepath.Join(albumDir, AlbumCoverName)
+	if ok, err := music.WriteCover(cover, pic); err != nil {
+		return fmt.Errorf("生成专辑封面失败 [%s]: %w", cover, err)
+	} else if ok {
+		logf(colors.Gray, "生成专辑封面 [%s]", cover)
+	}
+
+	// 专辑目录的上级目录与艺术家同名时, 视为艺术家目录
+	artistDir := filepath.Dir(albumDir)
+	artist := filepath.Base(artistDir)
+	if !IsArtistDir(artist, meta.Artist) {
+		return nil
+	}
+	artistNfo := filepath.Join(artistDir, ArtistNfoName)
+	if ok, err := music.MergeArtistNFO(artistNfo, artist, meta); err != nil {
+		return fmt.Errorf("生成艺术家 nfo 失败 [%s]: %w", artistNfo, err)
+	} else if ok {
+		logf(colors.Gray, "更新艺术家 nfo [%s]: [艺术家: %s]", artistNfo, artist)
+	}
 	return nil
 }
 
@@ -281,7 +460,7 @@ func getRealDownloadUrl(task FileTask) string {
 		AddHeader("User-Agent", constant.CommonDlUserAgent).
 		DoRedirect()
 	if err != nil {
-		logs.Warn("获取真实下载链接失败: %w", err)
+		logs.Warn("获取真实下载链接失败: %v", err)
 		return openlistUrl
 	}
 	defer resp.Body.Close()
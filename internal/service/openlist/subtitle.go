@@ -1,5 +1,25 @@
 package openlist
 
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+
+	// dirListTTL 视频所在目录文件列表的缓存时长
+	//
+	// 客户端播放时会多次请求 PlaybackInfo, 同一季的剧集也位于同一个目录
+	dirListTTL = time.Minute
+
+	// dirListMaxNum 最多缓存的目录个数
+	dirListMaxNum = 256
+)
+
 var (
 
 	// langDisplayNames 将 openlist 的字幕代码转换成对应名称
@@ -8,8 +28,33 @@ var (
 		"eng": "English",
 		"jpn": "日本語",
 	}
+
+	// subtitleExts 外挂字幕文件的扩展名
+	subtitleExts = map[string]struct{}{
+		"srt": {}, "ass": {}, "ssa": {}, "vtt": {},
+	}
+
+	// dirLists 视频所在目录的文件列表缓存
+	dirLists = struct {
+		sync.Mutex
+		m map[string]dirList
+	}{m: make(map[string]dirList)}
 )
 
+// dirList 缓存的目录文件列表
+type dirList struct {
+	entries []FsGet
+	at      time.Time
+}
+
+// SiblingSubtitle 与视频位于同一目录的外挂字幕文件
+type SiblingSubtitle struct {
+	Path string // 字幕在 openlist 中的绝对路径
+	Name string // 字幕文件名
+	Lang string // 文件名中的语言标记, 如 movie.chi.forced.srt 中的 chi, 没有时为空
+	Ext  string // 小写的扩展名, 不含 "."
+}
+
 // SubLangDisplayName 将 lang 转换成对应名称
 func SubLangDisplayName(lang string) string {
 	if name, ok := langDisplayNames[lang]; ok {
@@ -17,3 +62,78 @@ func SubLangDisplayName(lang string) string {
 	}
 	return lang
 }
+
+// FindSiblingSubtitles 从视频所在目录的文件列表中, 找出与视频同名的外挂字幕
+//
+// 字幕文件名需要以视频去除扩展名后的名称开头, 如 movie.mkv 对应 movie.srt、movie.chi.ass
+func FindSiblingSubtitles(videoPath string, entries []FsGet) []SiblingSubtitle {
+	dir, videoName := path.Split(videoPath)
+	stem := strings.TrimSuffix(videoName, path.Ext(videoName))
+	if stem == "" {
+		return nil
+	}
+
+	var res []SiblingSubtitle
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(entry.Name), "."))
+		if _, ok := subtitleExts[ext]; !ok {
+			continue
+		}
+		middle, ok := strings.CutPrefix(strings.TrimSuffix(entry.Name, path.Ext(entry.Name)), stem)
+		if !ok || (middle != "" && middle[0] != '.') {
+			continue
+		}
+		lang, _, _ := strings.Cut(strings.TrimPrefix(middle, "."), ".")
+		res = append(res, SiblingSubtitle{
+			Path: path.Join(dir, entry.Name),
+			Name: entry.Name,
+			Lang: lang,
+			Ext:  ext,
+		})
+	}
+	return res
+}
+
+// FetchSiblingSubtitles 请求 openlist 列出视频所在目录, 返回与视频同名的外挂字幕
+//
+// 目录的文件列表会缓存 dirListTTL, 期间不会重复请求 openlist
+func FetchSiblingSubtitles(videoPath string) ([]SiblingSubtitle, error) {
+	entries, err := listDirCached(path.Dir(videoPath))
+	if err != nil {
+		return nil, err
+	}
+	return FindSiblingSubtitles(videoPath, entries), nil
+}
+
+// listDirCached 列出目录中的文件, 优先使用未过期的缓存
+func listDirCached(dir string) ([]FsGet, error) {
+	dirLists.Lock()
+	l, ok := dirLists.m[dir]
+	dirLists.Unlock()
+	if ok && time.Since(l.at) < dirListTTL {
+		return l.entries, nil
+	}
+
+	res := FetchFsList(dir, nil)
+	if res.Code != http.StatusOK {
+		return nil, errors.New("请求 openlist 失败: " + res.Msg)
+	}
+
+	dirLists.Lock()
+	defer dirLists.Unlock()
+	if len(dirLists.m) >= dirListMaxNum {
+		// 缓存已满, 先移除过期的目录
+		for k, v := range dirLists.m {
+			if time.Since(v.at) >= dirListTTL {
+				delete(dirLists.m, k)
+			}
+		}
+	}
+	if len(dirLists.m) < dirListMaxNum {
+		dirLists.m[dir] = dirList{entries: res.Data.Content, at: time.Now()}
+	}
+	return res.Data.Content, nil
+}
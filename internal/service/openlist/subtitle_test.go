@@ -0,0 +1,73 @@
+package openlist_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/config"
+	"github.com/syscc/Emby-Go/internal/service/openlist"
+)
+
+func TestFindSiblingSubtitles(t *testing.T) {
+	entries := []openlist.FsGet{
+		{Name: "Movie.2024.mkv"},
+		{Name: "Movie.2024.srt"},
+		{Name: "Movie.2024.chi.ASS"},
+		{Name: "Movie.2024.eng.forced.vtt"},
+		{Name: "Movie.2024-extras.srt"},
+		{Name: "Movie.2024.nfo"},
+		{Name: "Other.srt"},
+		{Name: "Movie.2024.sub.srt", IsDir: true},
+	}
+
+	got := openlist.FindSiblingSubtitles("/电影/Movie.2024.mkv", entries)
+	want := []openlist.SiblingSubtitle{
+		{Path: "/电影/Movie.2024.srt", Name: "Movie.2024.srt", Lang: "", Ext: "srt"},
+		{Path: "/电影/Movie.2024.chi.ASS", Name: "Movie.2024.chi.ASS", Lang: "chi", Ext: "ass"},
+		{Path: "/电影/Movie.2024.eng.forced.vtt", Name: "Movie.2024.eng.forced.vtt", Lang: "eng", Ext: "vtt"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindSiblingSubtitles() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFetchSiblingSubtitlesCache(t *testing.T) {
+	var lists atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists.Add(1)
+		content := []any{
+			map[string]any{"name": "S01E01.mkv"},
+			map[string]any{"name": "S01E01.chi.srt"},
+			map[string]any{"name": "S01E02.mkv"},
+			map[string]any{"name": "S01E02.ass"},
+		}
+		json.NewEncoder(w).Encode(map[string]any{"code": 200, "data": map[string]any{"content": content}})
+	}))
+	defer ts.Close()
+	config.Set(&config.Config{Openlist: &config.Openlist{Host: ts.URL, Token: "token"}})
+
+	// 同一目录下的视频只请求一次 openlist
+	for _, video := range []string{"/剧集/S01/S01E01.mkv", "/剧集/S01/S01E02.mkv", "/剧集/S01/S01E01.mkv"} {
+		subs, err := openlist.FetchSiblingSubtitles(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(subs) != 1 {
+			t.Fatalf("%s: subs = %+v", video, subs)
+		}
+	}
+	if n := lists.Load(); n != 1 {
+		t.Errorf("fs/list requests = %d, want 1", n)
+	}
+
+	if _, err := openlist.FetchSiblingSubtitles("/剧集/S02/S02E01.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if n := lists.Load(); n != 2 {
+		t.Errorf("fs/list requests = %d, want 2", n)
+	}
+}
@@ -22,21 +22,41 @@ type OpenlistPathRes struct {
 
 	// Range 遍历所有 Openlist 根路径生成的子路径
 	Range func() ([]string, error)
+
+	// Steps 路径转换的每个步骤, 与日志中输出的转换过程一致
+	Steps []Step
+}
+
+// Step 路径转换的一个步骤
+type Step struct {
+	// Name 步骤名称
+	Name string `json:"name"`
+
+	// Path 该步骤转换后的路径
+	Path string `json:"path"`
 }
 
 // Emby2Openlist Emby 资源路径转 Openlist 资源路径
 func Emby2Openlist(embyPath string) OpenlistPathRes {
 	pathRoutes := strings.Builder{}
 	pathRoutes.WriteString("[")
-	pathRoutes.WriteString("\n【原始路径】 => " + embyPath)
+	var steps []Step
+	addStep := func(name, p string) {
+		if len(steps) > 0 {
+			pathRoutes.WriteString("\n")
+		}
+		pathRoutes.WriteString("\n【" + name + "】 => " + p)
+		steps = append(steps, Step{Name: name, Path: p})
+	}
+	addStep("原始路径", embyPath)
 
 	embyPath = urls.Unescape(embyPath)
-	pathRoutes.WriteString("\n\n【URL 解码】 => " + embyPath)
+	addStep("URL 解码", embyPath)
 
 	embyPath = urls.TransferSlash(embyPath)
-	pathRoutes.WriteString("\n\n【Windows 反斜杠转换】 => " + embyPath)
+	addStep("Windows 反斜杠转换", embyPath)
 
-	embyMount := config.C.Emby.MountPath
+	embyMount := config.C().Emby.MountPath
 	
 	// 支持多个挂载路径，使用逗号或分号分隔
 	mountPaths := strings.FieldsFunc(embyMount, func(r rune) bool {
@@ -55,7 +75,7 @@ func Emby2Openlist(embyPath string) OpenlistPathRes {
 		// 如果匹配成功，移除前缀
 		if strings.HasPrefix(embyPath, mount) {
 			openlistFilePath = strings.TrimPrefix(embyPath, mount)
-			pathRoutes.WriteString("\n\n【移除 mount-path (" + mount + ")】 => " + openlistFilePath)
+			addStep("移除 mount-path ("+mount+")", openlistFilePath)
 			matched = true
 			break
 		}
@@ -72,12 +92,12 @@ func Emby2Openlist(embyPath string) OpenlistPathRes {
 		// 但通常必须移除挂载点才能对应到 OpenList 的路径
 		// 让我们暂且设为 embyPath，后续 mapEmby2Openlist 可能会处理
 		openlistFilePath = embyPath
-		pathRoutes.WriteString("\n\n【未匹配 mount-path】 => " + openlistFilePath)
+		addStep("未匹配 mount-path", openlistFilePath)
 	}
 
-	if mapPath, ok := config.C.Path.MapEmby2Openlist(openlistFilePath); ok {
+	if mapPath, ok := config.C().Path.MapEmby2Openlist(openlistFilePath); ok {
 		openlistFilePath = mapPath
-		pathRoutes.WriteString("\n\n【命中 emby2openlist 映射】 => " + openlistFilePath)
+		addStep("命中 emby2openlist 映射", openlistFilePath)
 	}
 	pathRoutes.WriteString("\n]")
 	logs.Tip("embyPath 转换路径: %s", pathRoutes.String())
@@ -108,6 +128,7 @@ func Emby2Openlist(embyPath string) OpenlistPathRes {
 		Success: true,
 		Path:    openlistFilePath,
 		Range:   rangeFunc,
+		Steps:   steps,
 	}
 }
 
@@ -0,0 +1,89 @@
+package encrypts
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id 哈希参数, 参考 OWASP 推荐的最低配置
+const (
+	argon2Memory  uint32 = 19 * 1024 // 内存开销, 单位: KiB
+	argon2Time    uint32 = 2         // 迭代次数
+	argon2Threads uint8  = 1         // 并行度
+	argon2KeyLen  uint32 = 32        // 哈希长度
+	argon2SaltLen        = 16        // 盐长度
+)
+
+// argon2Prefix argon2id 哈希的版本前缀
+const argon2Prefix = "$argon2id$"
+
+// legacyMd5Reg 匹配旧版本无盐 md5 密码哈希
+var legacyMd5Reg = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ErrUnknownHash 无法识别的密码哈希格式
+var ErrUnknownHash = errors.New("无法识别的密码哈希格式")
+
+// HashPassword 使用 argon2id 计算密码哈希
+//
+// 返回值为带版本和参数的格式: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("生成随机盐失败: %v", err)
+	}
+	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// VerifyPassword 校验密码与哈希是否匹配
+//
+// 同时支持旧版本的 md5 哈希, needRehash 为 true 表示哈希使用了旧的格式或参数,
+// 校验通过后应使用 HashPassword 重新计算并保存
+func VerifyPassword(encoded, password string) (ok, needRehash bool, err error) {
+	if legacyMd5Reg.MatchString(encoded) {
+		ok = subtle.ConstantTimeCompare([]byte(encoded), []byte(Md5Hash(password))) == 1
+		return ok, true, nil
+	}
+	if !strings.HasPrefix(encoded, argon2Prefix) {
+		return false, false, ErrUnknownHash
+	}
+
+	parts := strings.Split(strings.TrimPrefix(encoded, argon2Prefix), "$")
+	if len(parts) != 4 {
+		return false, false, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, false, ErrUnknownHash
+	}
+
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	ok = subtle.ConstantTimeCompare(got, want) == 1
+	needRehash = version != argon2.Version || memory != argon2Memory || iterations != argon2Time ||
+		threads != argon2Threads || uint32(len(want)) != argon2KeyLen
+	return ok, needRehash, nil
+}
@@ -0,0 +1,66 @@
+package encrypts_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPassword(t *testing.T) {
+	h1, err := encrypts.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, _ := encrypts.HashPassword("secret")
+	if !strings.HasPrefix(h1, "$argon2id$v=19$") || h1 == h2 {
+		t.Fatalf("哈希格式错误或没有加盐: %s, %s", h1, h2)
+	}
+
+	ok, rehash, err := encrypts.VerifyPassword(h1, "secret")
+	if err != nil || !ok || rehash {
+		t.Errorf("VerifyPassword(正确密码) = %v, %v, %v", ok, rehash, err)
+	}
+	if ok, _, _ := encrypts.VerifyPassword(h1, "wrong"); ok {
+		t.Error("错误密码不应校验通过")
+	}
+}
+
+func TestVerifyPasswordLegacy(t *testing.T) {
+	legacy := encrypts.Md5Hash("secret")
+	ok, rehash, err := encrypts.VerifyPassword(legacy, "secret")
+	if err != nil || !ok || !rehash {
+		t.Errorf("VerifyPassword(md5) = %v, %v, %v", ok, rehash, err)
+	}
+	if ok, _, _ := encrypts.VerifyPassword(legacy, "wrong"); ok {
+		t.Error("错误密码不应校验通过")
+	}
+}
+
+func TestVerifyPasswordRehash(t *testing.T) {
+	// 使用旧参数 (t=1) 生成的哈希
+	salt := []byte("saltsaltsaltsalt")
+	hash := argon2.IDKey([]byte("secret"), salt, 1, 19*1024, 1, 32)
+	old := fmt.Sprintf("$argon2id$v=19$m=19456,t=1,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+
+	ok, rehash, err := encrypts.VerifyPassword(old, "secret")
+	if err != nil || !ok || !rehash {
+		t.Errorf("VerifyPassword(旧参数) = %v, %v, %v", ok, rehash, err)
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	for _, encoded := range []string{
+		"plain",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+		"$argon2id$v=19$m=19456$c2FsdA$aGFzaA",
+	} {
+		if _, _, err := encrypts.VerifyPassword(encoded, "secret"); err == nil {
+			t.Errorf("VerifyPassword(%q) 应返回错误", encoded)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package encrypts_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
+)
+
+// rfcSecret RFC 6238 附录 B 中 SHA1 测试向量使用的密钥
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 附录 B 给出的是 8 位验证码, 6 位验证码取其后 6 位
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		got, err := encrypts.TOTPCode(rfcSecret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := encrypts.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, _ := encrypts.TOTPCode(secret, now)
+
+	step, ok := encrypts.ValidateTOTP(secret, code, now)
+	if !ok || step != now.Unix()/30 {
+		t.Fatalf("ValidateTOTP(当前验证码) = %d, %v", step, ok)
+	}
+	if _, ok := encrypts.ValidateTOTP(secret, code[:3]+" "+code[3:], now.Add(30*time.Second)); !ok {
+		t.Error("应允许一个周期的时钟误差和空格")
+	}
+	if _, ok := encrypts.ValidateTOTP(secret, code, now.Add(90*time.Second)); ok {
+		t.Error("超出误差范围的验证码不应校验通过")
+	}
+	if _, ok := encrypts.ValidateTOTP(strings.ToLower(rfcSecret), "287082", time.Unix(59, 0)); !ok {
+		t.Error("应兼容小写的密钥")
+	}
+	if _, ok := encrypts.ValidateTOTP(secret, "abcdef", now); ok {
+		t.Error("非法验证码不应校验通过")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := encrypts.TOTPURI("Go-Emby", "admin", "ABC")
+	want := "otpauth://totp/Go-Emby:admin?digits=6&issuer=Go-Emby&period=30&secret=ABC"
+	if uri != want {
+		t.Errorf("TOTPURI = %s, want %s", uri, want)
+	}
+}
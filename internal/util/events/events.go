@@ -0,0 +1,158 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Prefix 事件行前缀, 管理进程通过该前缀从内核的标准输出中识别事件,
+// 内核也通过相同的格式从标准输入中读取管理进程发送的命令
+const Prefix = "@@go-emby-event "
+
+// TypePlaybackStopped 停止播放事件
+const TypePlaybackStopped = "playback-stopped"
+
+// TypePlaylistStats 管理进程发送给内核的命令, 内核以同名事件上报 playlist 缓存的统计信息
+const TypePlaylistStats = "playlist-stats"
+
+// PlaylistStats 内核上报给管理进程的 playlist 缓存统计信息
+type PlaylistStats struct {
+	Size      int   `json:"size"`      // 当前缓存的 playlist 个数
+	Capacity  int   `json:"capacity"`  // 缓存容量
+	Hits      int64 `json:"hits"`      // 客户端请求命中缓存的次数
+	Misses    int64 `json:"misses"`    // 客户端请求未命中缓存的次数
+	Refreshes int64 `json:"refreshes"` // 从 openlist 成功刷新 playlist 的次数
+	Evictions int64 `json:"evictions"` // 容量不足时淘汰 playlist 的次数
+}
+
+// TypeSyncLocalTree 管理进程发送给内核的命令, 立即同步一次 openlist 本地目录树
+const TypeSyncLocalTree = "sync-local-tree"
+
+// TypeReloadConfig 管理进程发送给内核的命令, 重新加载配置文件
+const TypeReloadConfig = "reload-config"
+
+// TypeConfigReloaded 内核处理完 TypeReloadConfig 命令后上报的结果
+const TypeConfigReloaded = "config-reloaded"
+
+// TypeConfigCheck 内核以校验模式启动时上报的配置校验结果
+const TypeConfigCheck = "config-check"
+
+// TypePathTrace 内核以路径测试模式启动时上报的路径转换过程
+const TypePathTrace = "path-trace"
+
+// PlaybackStopped 内核上报给管理进程的停止播放事件
+type PlaybackStopped struct {
+	UserName      string // emby 用户名, 不同服务器之间通过用户名匹配用户
+	ItemId        string // 停止播放的 item id
+	PositionTicks int64  // 停止时的播放位置
+}
+
+// ConfigReload 内核上报给管理进程的重新加载配置结果
+type ConfigReload struct {
+	Restart []string `json:"restart"` // 需要重启内核才能生效的配置项, 不为空时内核继续使用原配置
+	Error   string   `json:"error"`   // 配置校验失败时的错误信息, 内核继续使用原配置
+}
+
+// 配置校验项的结果级别
+const (
+	CheckOK    = "ok"
+	CheckWarn  = "warn"  // 不影响启动, 但部分功能可能无法正常工作
+	CheckError = "error" // 内核无法启动或无法正常代理
+)
+
+// CheckItem 单个配置校验项的结果
+type CheckItem struct {
+	Name    string `json:"name"`    // 校验项, 如 config / emby / openlist / path
+	Level   string `json:"level"`   // ok / warn / error
+	Message string `json:"message"` // 校验结果说明
+}
+
+// ConfigCheck 内核上报给管理进程的配置校验结果
+type ConfigCheck struct {
+	Items []CheckItem `json:"items"`
+}
+
+// Output 事件输出目标
+var Output io.Writer = os.Stdout
+
+// Emit 输出一个事件, payload 序列化为 json
+func Emit(typ string, payload any) error {
+	return Write(Output, typ, payload)
+}
+
+// Write 向 w 写入一个事件, payload 序列化为 json
+func Write(w io.Writer, typ string, payload any) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s%s %s\n", Prefix, typ, bytes)
+	return err
+}
+
+// Listen 逐行读取 r 中的事件交给 handle 处理, 忽略非事件行, r 读取结束时返回
+func Listen(r io.Reader, handle func(typ string, payload []byte)) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if typ, payload, ok := Parse(sc.Text()); ok {
+			handle(typ, payload)
+		}
+	}
+}
+
+// Parse 从一行输出中解析事件, 不是事件行时 ok 返回 false
+func Parse(line string) (typ string, payload []byte, ok bool) {
+	idx := strings.Index(line, Prefix)
+	if idx == -1 {
+		return "", nil, false
+	}
+	typ, data, ok := strings.Cut(strings.TrimSpace(line[idx+len(Prefix):]), " ")
+	if !ok || typ == "" {
+		return "", nil, false
+	}
+	return typ, []byte(data), true
+}
+
+// PathTrace 内核上报给管理进程的路径转换过程
+type PathTrace struct {
+	Input      string          `json:"input"`      // 测试的 emby item id 或路径
+	ItemName   string          `json:"itemName"`   // 输入 item id 时对应的 item 名称
+	EmbyPath   string          `json:"embyPath"`   // emby 中的资源路径
+	Steps      []PathStep      `json:"steps"`      // 路径转换的每个步骤
+	Candidates []PathCandidate `json:"candidates"` // 按重定向时的尝试顺序排列的 openlist 路径
+	Error      string          `json:"error"`      // 无法完成测试时的错误信息
+}
+
+// PathStep 路径转换的一个步骤
+type PathStep struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// PathCandidate 一个候选的 openlist 路径及其 fs/get 请求结果
+type PathCandidate struct {
+	Path    string `json:"path"`
+	Source  string `json:"source"`  // mapped: 路径转换结果; range: 遍历 openlist 根目录生成
+	Exists  bool   `json:"exists"`  // fs/get 是否成功
+	Used    bool   `json:"used"`    // 重定向时是否会使用该路径, 即第一个存在的路径
+	Message string `json:"message"` // fs/get 的错误信息或文件信息
+}
+
+// Add 添加一个校验项
+func (c *ConfigCheck) Add(name, level, format string, args ...any) {
+	c.Items = append(c.Items, CheckItem{Name: name, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasError 是否存在 error 级别的校验项
+func (c *ConfigCheck) HasError() bool {
+	for _, it := range c.Items {
+		if it.Level == CheckError {
+			return true
+		}
+	}
+	return false
+}
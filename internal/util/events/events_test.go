@@ -0,0 +1,67 @@
+package events_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/syscc/Emby-Go/internal/util/events"
+)
+
+func TestEmitParse(t *testing.T) {
+	buf := new(bytes.Buffer)
+	events.Output = buf
+
+	want := events.PlaylistStats{Size: 3, Capacity: 10, Hits: 42, Misses: 3, Refreshes: 7, Evictions: 1}
+	if err := events.Emit(events.TypePlaylistStats, want); err != nil {
+		t.Fatal(err)
+	}
+
+	typ, payload, ok := events.Parse("2025-01-01 " + buf.String())
+	if !ok || typ != events.TypePlaylistStats {
+		t.Fatalf("Parse() = %q, %v", typ, ok)
+	}
+	var got events.PlaylistStats
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, _, ok := events.Parse("[INFO] 普通日志"); ok {
+		t.Error("普通日志不应被识别为事件")
+	}
+}
+
+func TestWriteListen(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("普通输入\n")
+	if err := events.Write(buf, events.TypeSyncLocalTree, struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	events.Listen(buf, func(typ string, payload []byte) {
+		got = append(got, typ+" "+string(payload))
+	})
+	if len(got) != 1 || got[0] != events.TypeSyncLocalTree+" {}" {
+		t.Errorf("Listen() = %q", got)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	var c events.ConfigCheck
+	c.Add("emby", events.CheckOK, "Emby 可以访问: %s", "test")
+	c.Add("path", events.CheckWarn, "路径不存在")
+	if c.HasError() {
+		t.Error("没有 error 级别的校验项时 HasError() 应为 false")
+	}
+	c.Add("openlist", events.CheckError, "无法访问")
+	if !c.HasError() {
+		t.Error("存在 error 级别的校验项时 HasError() 应为 true")
+	}
+	if c.Items[0].Message != "Emby 可以访问: test" {
+		t.Errorf("Message = %q", c.Items[0].Message)
+	}
+}
@@ -1,5 +1,7 @@
 package colors
 
+import "sync/atomic"
+
 type C string
 
 // 日志颜色输出常量
@@ -21,10 +23,11 @@ type Enabler interface {
 	EnableColor() bool
 }
 
-var enabler Enabler
+// enabler 颜色输出控制器, 重新加载配置时会被替换
+var enabler atomic.Pointer[Enabler]
 
 // SetEnabler 设置颜色输出控制器
-func SetEnabler(e Enabler) { enabler = e }
+func SetEnabler(e Enabler) { enabler.Store(&e) }
 
 // ToBlue 将字符串转成蓝色
 func ToBlue(str string) string {
@@ -60,7 +63,7 @@ func ToGray(str string) string {
 //
 // 如果用户关闭了颜色输出, 则直接返回原字符串
 func WrapColor(color C, str string) string {
-	if enabler != nil && !enabler.EnableColor() {
+	if e := enabler.Load(); e != nil && !(*e).EnableColor() {
 		return str
 	}
 	return string(color) + str + reset
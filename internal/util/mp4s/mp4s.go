@@ -3,11 +3,20 @@ package mp4s
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"time"
 )
 
 // GenWithDuration 生成指定时长的 mp4 视频数据
 func GenWithDuration(d time.Duration) []byte {
+	return GenWithTracks(d)
+}
+
+// GenWithTracks 生成指定时长的 mp4 视频数据, 并为每条轨道写入 trak/stsd 描述
+//
+// 生成的文件不包含任何媒体数据, 仅供 emby 探测媒体流信息;
+// 未指定轨道时写入一条不含描述的伪视频轨道
+func GenWithTracks(d time.Duration, tracks ...Track) []byte {
 	var buf bytes.Buffer
 
 	writeBox(&buf, "ftyp", func(b *bytes.Buffer) {
@@ -17,6 +26,11 @@ func GenWithDuration(d time.Duration) []byte {
 		b.WriteString("iso2")
 	})
 
+	nextTrackId := uint32(len(tracks) + 1)
+	if len(tracks) == 0 {
+		nextTrackId = 2
+	}
+
 	writeBox(&buf, "moov", func(moov *bytes.Buffer) {
 		// mvhd (全局 movie duration)
 		writeBox(moov, "mvhd", func(b *bytes.Buffer) {
@@ -29,70 +43,288 @@ func GenWithDuration(d time.Duration) []byte {
 			binary.Write(b, binary.BigEndian, uint32(0x00010000))       // rate 1.0
 			binary.Write(b, binary.BigEndian, uint16(0x0100))           // volume 1.0
 			b.Write(make([]byte, 10))                                   // reserved
-			binary.Write(b, binary.BigEndian, [9]uint32{
-				0x00010000, 0, 0,
-				0, 0x00010000, 0,
-				0, 0, 0x40000000,
-			}) // unity matrix
-			b.Write(make([]byte, 24))                    // pre-defined
-			binary.Write(b, binary.BigEndian, uint32(2)) // next track ID
+			binary.Write(b, binary.BigEndian, unityMatrix)              // unity matrix
+			b.Write(make([]byte, 24))                                   // pre-defined
+			binary.Write(b, binary.BigEndian, nextTrackId)              // next track ID
+		})
+
+		if len(tracks) == 0 {
+			writeFakeTrak(moov, d)
+			return
+		}
+
+		for i, t := range tracks {
+			writeTrak(moov, uint32(i+1), d, t)
+		}
+	})
+
+	return buf.Bytes()
+}
+
+// unityMatrix 单位变换矩阵
+var unityMatrix = [9]uint32{
+	0x00010000, 0, 0,
+	0, 0x00010000, 0,
+	0, 0, 0x40000000,
+}
+
+// writeFakeTrak 写入一条只包含时长的伪视频轨道
+func writeFakeTrak(moov *bytes.Buffer, d time.Duration) {
+	writeBox(moov, "trak", func(trak *bytes.Buffer) {
+		// tkhd (track header)
+		writeBox(trak, "tkhd", func(b *bytes.Buffer) {
+			b.WriteByte(0x00)
+			b.Write([]byte{0x00, 0x00, 0x07})                           // flags: track enabled, in movie, in preview
+			b.Write(make([]byte, 4))                                    // creation_time
+			b.Write(make([]byte, 4))                                    // modification_time
+			binary.Write(b, binary.BigEndian, uint32(1))                // track_ID
+			b.Write(make([]byte, 4))                                    // reserved
+			binary.Write(b, binary.BigEndian, uint32(d.Milliseconds())) // duration
+			b.Write(make([]byte, 8))                                    // reserved
+			binary.Write(b, binary.BigEndian, uint16(0))                // layer
+			binary.Write(b, binary.BigEndian, uint16(0))                // alternate group
+			binary.Write(b, binary.BigEndian, uint16(0))                // volume
+			b.Write([]byte{0x00, 0x00})                                 // reserved
+			binary.Write(b, binary.BigEndian, unityMatrix)              // matrix
+			binary.Write(b, binary.BigEndian, uint32(0))                // width
+			binary.Write(b, binary.BigEndian, uint32(0))                // height
+		})
+
+		// mdia
+		writeBox(trak, "mdia", func(mdia *bytes.Buffer) {
+			writeMdhd(mdia, 1000, d, "")
+
+			// hdlr (handler type: vide)
+			writeHdlr(mdia, "vide", "Fake Video Handler")
+
+			// minf（可选，不加也能解析）
+		})
+	})
+}
+
+// writeTrak 写入一条带有 stsd 描述的轨道
+func writeTrak(moov *bytes.Buffer, id uint32, d time.Duration, t Track) {
+	writeBox(moov, "trak", func(trak *bytes.Buffer) {
+		// tkhd (track header)
+		writeBox(trak, "tkhd", func(b *bytes.Buffer) {
+			// flags: in movie, in preview; 默认轨道额外标记为 enabled
+			flags := byte(0x06)
+			if t.Kind == TrackVideo || t.Default {
+				flags |= 0x01
+			}
+
+			var group, volume uint16
+			switch t.Kind {
+			case TrackAudio:
+				group, volume = 1, 0x0100
+			case TrackSubtitle:
+				group = 2
+			}
+
+			b.WriteByte(0x00)
+			b.Write([]byte{0x00, 0x00, flags})
+			b.Write(make([]byte, 4))                                    // creation_time
+			b.Write(make([]byte, 4))                                    // modification_time
+			binary.Write(b, binary.BigEndian, id)                       // track_ID
+			b.Write(make([]byte, 4))                                    // reserved
+			binary.Write(b, binary.BigEndian, uint32(d.Milliseconds())) // duration
+			b.Write(make([]byte, 8))                                    // reserved
+			binary.Write(b, binary.BigEndian, uint16(0))                // layer
+			binary.Write(b, binary.BigEndian, group)                    // alternate group
+			binary.Write(b, binary.BigEndian, volume)                   // volume
+			b.Write([]byte{0x00, 0x00})                                 // reserved
+			binary.Write(b, binary.BigEndian, unityMatrix)              // matrix
+			binary.Write(b, binary.BigEndian, uint32(t.Width)<<16)      // width 16.16
+			binary.Write(b, binary.BigEndian, uint32(t.Height)<<16)     // height 16.16
 		})
 
-		// trak (伪轨道)
-		writeBox(moov, "trak", func(trak *bytes.Buffer) {
-			// tkhd (track header)
-			writeBox(trak, "tkhd", func(b *bytes.Buffer) {
-				b.WriteByte(0x00)
-				b.Write([]byte{0x00, 0x00, 0x07})                           // flags: track enabled, in movie, in preview
-				b.Write(make([]byte, 4))                                    // creation_time
-				b.Write(make([]byte, 4))                                    // modification_time
-				binary.Write(b, binary.BigEndian, uint32(1))                // track_ID
-				b.Write(make([]byte, 4))                                    // reserved
-				binary.Write(b, binary.BigEndian, uint32(d.Milliseconds())) // duration
-				b.Write(make([]byte, 8))                                    // reserved
-				binary.Write(b, binary.BigEndian, uint16(0))                // layer
-				binary.Write(b, binary.BigEndian, uint16(0))                // alternate group
-				binary.Write(b, binary.BigEndian, uint16(0))                // volume
-				b.Write([]byte{0x00, 0x00})                                 // reserved
-				binary.Write(b, binary.BigEndian, [9]uint32{
-					0x00010000, 0, 0,
-					0, 0x00010000, 0,
-					0, 0, 0x40000000,
-				}) // matrix
-				binary.Write(b, binary.BigEndian, uint32(0)) // width
-				binary.Write(b, binary.BigEndian, uint32(0)) // height
+		timescale, delta := t.timescale()
+		writeBox(trak, "mdia", func(mdia *bytes.Buffer) {
+			writeMdhd(mdia, timescale, d, t.Language)
+			writeHdlr(mdia, t.handlerType(), t.handlerName())
+
+			writeBox(mdia, "minf", func(minf *bytes.Buffer) {
+				switch t.Kind {
+				case TrackVideo:
+					writeBox(minf, "vmhd", func(b *bytes.Buffer) {
+						b.Write([]byte{0x00, 0x00, 0x00, 0x01}) // version + flags
+						b.Write(make([]byte, 8))                // graphicsmode + opcolor
+					})
+				case TrackAudio:
+					writeBox(minf, "smhd", func(b *bytes.Buffer) {
+						b.Write(make([]byte, 8)) // version + flags + balance + reserved
+					})
+				default:
+					writeBox(minf, "nmhd", func(b *bytes.Buffer) {
+						b.Write(make([]byte, 4)) // version + flags
+					})
+				}
+
+				// dinf (数据自包含)
+				writeBox(minf, "dinf", func(dinf *bytes.Buffer) {
+					writeBox(dinf, "dref", func(b *bytes.Buffer) {
+						b.Write(make([]byte, 4))                     // version + flags
+						binary.Write(b, binary.BigEndian, uint32(1)) // entry_count
+						writeBox(b, "url ", func(url *bytes.Buffer) {
+							url.Write([]byte{0x00, 0x00, 0x00, 0x01}) // flags: self-contained
+						})
+					})
+				})
+
+				writeBox(minf, "stbl", func(stbl *bytes.Buffer) {
+					writeBox(stbl, "stsd", func(b *bytes.Buffer) {
+						b.Write(make([]byte, 4))                     // version + flags
+						binary.Write(b, binary.BigEndian, uint32(1)) // entry_count
+						writeSampleEntry(b, t)
+					})
+
+					// stts 通过帧数和帧间隔描述视频帧率
+					writeBox(stbl, "stts", func(b *bytes.Buffer) {
+						b.Write(make([]byte, 4)) // version + flags
+						if t.Kind != TrackVideo || t.FrameRate <= 0 {
+							binary.Write(b, binary.BigEndian, uint32(0))
+							return
+						}
+						frames := uint32(math.Min(d.Seconds()*t.FrameRate, math.MaxUint32))
+						binary.Write(b, binary.BigEndian, uint32(1)) // entry_count
+						binary.Write(b, binary.BigEndian, frames)    // sample_count
+						binary.Write(b, binary.BigEndian, delta)     // sample_delta
+					})
+
+					// 不包含任何媒体数据, 其余样本表均为空
+					writeBox(stbl, "stsc", func(b *bytes.Buffer) { b.Write(make([]byte, 8)) })
+					writeBox(stbl, "stsz", func(b *bytes.Buffer) { b.Write(make([]byte, 12)) })
+					writeBox(stbl, "stco", func(b *bytes.Buffer) { b.Write(make([]byte, 8)) })
+				})
 			})
+		})
+	})
+}
+
+// writeSampleEntry 根据轨道类型写入 stsd 中的样本描述
+func writeSampleEntry(parent *bytes.Buffer, t Track) {
+	writeBox(parent, t.sampleEntryType(), func(b *bytes.Buffer) {
+		b.Write(make([]byte, 6))                     // reserved
+		binary.Write(b, binary.BigEndian, uint16(1)) // data_reference_index
 
-			// mdia
-			writeBox(trak, "mdia", func(mdia *bytes.Buffer) {
-				// mdhd
-				writeBox(mdia, "mdhd", func(b *bytes.Buffer) {
-					b.WriteByte(0x00)
-					b.Write([]byte{0x00, 0x00, 0x00})
-					b.Write(make([]byte, 4))                                    // creation_time
-					b.Write(make([]byte, 4))                                    // modification_time
-					binary.Write(b, binary.BigEndian, uint32(1000))             // timescale
-					binary.Write(b, binary.BigEndian, uint32(d.Milliseconds())) // duration
-					binary.Write(b, binary.BigEndian, uint16(0x55c4))           // language = und (ISO-639-2/T code)
-					b.Write([]byte{0x00, 0x00})                                 // pre-defined
+		switch t.Kind {
+		case TrackVideo:
+			b.Write(make([]byte, 16))                             // pre_defined + reserved
+			binary.Write(b, binary.BigEndian, uint16(t.Width))    // width
+			binary.Write(b, binary.BigEndian, uint16(t.Height))   // height
+			binary.Write(b, binary.BigEndian, uint32(0x00480000)) // horizresolution 72 dpi
+			binary.Write(b, binary.BigEndian, uint32(0x00480000)) // vertresolution 72 dpi
+			b.Write(make([]byte, 4))                              // reserved
+			binary.Write(b, binary.BigEndian, uint16(1))          // frame_count
+			b.Write(make([]byte, 32))                             // compressorname
+			binary.Write(b, binary.BigEndian, uint16(0x0018))     // depth
+			binary.Write(b, binary.BigEndian, int16(-1))          // pre_defined
+
+			// colr (nclx) 描述 HDR 色彩信息
+			if t.ColorTransfer > 0 {
+				writeBox(b, "colr", func(colr *bytes.Buffer) {
+					colr.WriteString("nclx")
+					binary.Write(colr, binary.BigEndian, uint16(9))               // colour_primaries: BT.2020
+					binary.Write(colr, binary.BigEndian, uint16(t.ColorTransfer)) // transfer_characteristics
+					binary.Write(colr, binary.BigEndian, uint16(9))               // matrix_coefficients: BT.2020 NCL
+					colr.WriteByte(0x00)                                          // full_range_flag
 				})
+			}
 
-				// hdlr (handler type: vide)
-				writeBox(mdia, "hdlr", func(b *bytes.Buffer) {
-					b.Write([]byte{0x00, 0x00, 0x00, 0x00}) // version + flags
-					b.Write(make([]byte, 4))                // pre_defined
-					b.Write([]byte("vide"))                 // handler_type
-					b.Write(make([]byte, 12))               // reserved
-					b.WriteString("Fake Video Handler")     // name
-					b.WriteByte(0x00)                       // null terminator
+			// dvcC 描述杜比视界配置
+			if t.DVProfile > 0 {
+				writeBox(b, "dvcC", func(dv *bytes.Buffer) {
+					dv.Write([]byte{0x01, 0x00}) // dv_version_major + minor
+					// profile(7) + level(6) + rpu_present(1) + el_present(1) + bl_present(1)
+					bits := uint16(t.DVProfile&0x7f)<<9 | uint16(t.DVLevel&0x3f)<<3 | 0b101
+					binary.Write(dv, binary.BigEndian, bits)
+					dv.Write(make([]byte, 20)) // compatibility_id + reserved
 				})
+			}
+
+		case TrackAudio:
+			channels := t.Channels
+			if channels <= 0 {
+				channels = 2
+			}
+			sampleRate := t.SampleRate
+			if sampleRate > math.MaxUint16 {
+				// 16.16 定点数无法表示, 以 mdhd 的 timescale 为准
+				sampleRate = 0
+			}
+			b.Write(make([]byte, 8))                                  // reserved
+			binary.Write(b, binary.BigEndian, uint16(channels))       // channelcount
+			binary.Write(b, binary.BigEndian, uint16(16))             // samplesize
+			b.Write(make([]byte, 4))                                  // pre_defined + reserved
+			binary.Write(b, binary.BigEndian, uint32(sampleRate)<<16) // samplerate 16.16
 
-				// minf（可选，不加也能解析）
+		default:
+			binary.Write(b, binary.BigEndian, uint32(0)) // displayFlags
+			b.Write([]byte{0x01, 0xff})                  // horizontal + vertical justification
+			b.Write(make([]byte, 4))                     // background-color-rgba
+			b.Write(make([]byte, 8))                     // default text box
+			// style record: startChar, endChar, font-ID, face-style-flags, font-size, text-color-rgba
+			b.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x12, 0xff, 0xff, 0xff, 0xff})
+			writeBox(b, "ftab", func(ftab *bytes.Buffer) {
+				binary.Write(ftab, binary.BigEndian, uint16(1)) // entry-count
+				binary.Write(ftab, binary.BigEndian, uint16(1)) // font-ID
+				ftab.WriteByte(5)                               // font-name-length
+				ftab.WriteString("Serif")
 			})
-		})
+		}
 	})
+}
 
-	return buf.Bytes()
+// writeMdhd 写入 media header, 时长超出 32 位时使用 version 1
+func writeMdhd(parent *bytes.Buffer, timescale uint32, d time.Duration, language string) {
+	duration := uint64(d.Seconds() * float64(timescale))
+	writeBox(parent, "mdhd", func(b *bytes.Buffer) {
+		if duration > math.MaxUint32 {
+			b.WriteByte(0x01)
+			b.Write([]byte{0x00, 0x00, 0x00})
+			b.Write(make([]byte, 16)) // creation_time + modification_time
+			binary.Write(b, binary.BigEndian, timescale)
+			binary.Write(b, binary.BigEndian, duration)
+		} else {
+			b.WriteByte(0x00)
+			b.Write([]byte{0x00, 0x00, 0x00})
+			b.Write(make([]byte, 8)) // creation_time + modification_time
+			binary.Write(b, binary.BigEndian, timescale)
+			binary.Write(b, binary.BigEndian, uint32(duration))
+		}
+		binary.Write(b, binary.BigEndian, packLanguage(language)) // language (ISO-639-2/T code)
+		b.Write([]byte{0x00, 0x00})                               // pre-defined
+	})
+}
+
+// writeHdlr 写入 handler reference
+func writeHdlr(parent *bytes.Buffer, handlerType, name string) {
+	writeBox(parent, "hdlr", func(b *bytes.Buffer) {
+		b.Write([]byte{0x00, 0x00, 0x00, 0x00}) // version + flags
+		b.Write(make([]byte, 4))                // pre_defined
+		b.WriteString(handlerType)              // handler_type
+		b.Write(make([]byte, 12))               // reserved
+		b.WriteString(name)                     // name
+		b.WriteByte(0x00)                       // null terminator
+	})
+}
+
+// packLanguage 将三字母语言代码打包为 mdhd 中的 15 位表示, 无效时返回 und
+func packLanguage(lang string) uint16 {
+	const und = 0x55c4
+	if len(lang) != 3 {
+		return und
+	}
+
+	var res uint16
+	for i := 0; i < 3; i++ {
+		c := lang[i]
+		if c < 'a' || c > 'z' {
+			return und
+		}
+		res = res<<5 | uint16(c-0x60)
+	}
+	return res
 }
 
 func writeBox(parent *bytes.Buffer, boxType string, writePayload func(*bytes.Buffer)) {
@@ -1,6 +1,8 @@
 package mp4s_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"os"
 	"testing"
 	"time"
@@ -13,3 +15,51 @@ func TestGenWithDuration(t *testing.T) {
 	bytes := mp4s.GenWithDuration(d)
 	os.WriteFile("test.mp4", bytes, os.ModePerm)
 }
+
+func TestGenWithTracks(t *testing.T) {
+	d := time.Minute * 42
+	data := mp4s.GenWithTracks(d,
+		mp4s.Track{Kind: mp4s.TrackVideo, Codec: "hevc", Width: 3840, Height: 2160, FrameRate: 23.976, ColorTransfer: mp4s.TransferPQ},
+		mp4s.Track{Kind: mp4s.TrackAudio, Codec: "eac3", Language: "chi", Channels: 6, SampleRate: 48000, Default: true},
+		mp4s.Track{Kind: mp4s.TrackAudio, Codec: "aac", Language: "eng", Channels: 2, SampleRate: 48000},
+		mp4s.Track{Kind: mp4s.TrackSubtitle, Codec: "subrip", Language: "chi"},
+	)
+
+	// 按路径收集所有 box 类型
+	var walk func(b []byte, prefix string, out map[string]int)
+	walk = func(b []byte, prefix string, out map[string]int) {
+		for len(b) >= 8 {
+			size := int(binary.BigEndian.Uint32(b))
+			if size < 8 || size > len(b) {
+				t.Fatalf("invalid box size %d under [%s]", size, prefix)
+			}
+			path := prefix + "/" + string(b[4:8])
+			out[path]++
+			switch string(b[4:8]) {
+			case "moov", "trak", "mdia", "minf", "stbl", "dinf":
+				walk(b[8:size], path, out)
+			case "stsd":
+				walk(b[16:size], path, out)
+			}
+			b = b[size:]
+		}
+	}
+	boxes := make(map[string]int)
+	walk(data, "", boxes)
+
+	want := map[string]int{
+		"/moov/trak":                          4,
+		"/moov/trak/mdia/minf/stbl/stsd/hvc1": 1,
+		"/moov/trak/mdia/minf/stbl/stsd/ec-3": 1,
+		"/moov/trak/mdia/minf/stbl/stsd/mp4a": 1,
+		"/moov/trak/mdia/minf/stbl/stsd/tx3g": 1,
+	}
+	for path, n := range want {
+		if boxes[path] != n {
+			t.Errorf("box [%s] count = %d, want %d", path, boxes[path], n)
+		}
+	}
+	if !bytes.Contains(data, []byte("colr")) {
+		t.Error("hdr video track should contain colr box")
+	}
+}
@@ -0,0 +1,127 @@
+package mp4s
+
+import (
+	"math"
+	"strings"
+)
+
+// TrackKind 轨道类型
+type TrackKind int
+
+const (
+	TrackVideo TrackKind = iota
+	TrackAudio
+	TrackSubtitle
+)
+
+// 色彩传输特性 (ISO/IEC 23091-2)
+const (
+	TransferPQ  = 16 // SMPTE ST 2084, HDR10 / 杜比视界
+	TransferHLG = 18 // ARIB STD-B67
+)
+
+// Track 描述虚拟 mp4 中的一条媒体轨道
+type Track struct {
+	Kind     TrackKind
+	Codec    string // ffmpeg 编码名称, 如 h264, hevc, aac, eac3
+	Language string // ISO-639-2 三字母语言代码
+	Default  bool   // 是否是默认轨道
+
+	Width         int     // 视频宽度
+	Height        int     // 视频高度
+	FrameRate     float64 // 视频帧率
+	ColorTransfer int     // 视频色彩传输特性, 0 表示 SDR
+	DVProfile     int     // 杜比视界 profile, 0 表示不存在
+	DVLevel       int     // 杜比视界 level
+
+	Channels   int // 音频声道数
+	SampleRate int // 音频采样率
+}
+
+// videoSampleEntries ffmpeg 视频编码与 mp4 样本描述类型的映射
+var videoSampleEntries = map[string]string{
+	"h264":       "avc1",
+	"hevc":       "hvc1",
+	"av1":        "av01",
+	"vp9":        "vp09",
+	"vp8":        "vp08",
+	"mpeg4":      "mp4v",
+	"mpeg2video": "m2v1",
+	"vc1":        "vc-1",
+}
+
+// audioSampleEntries ffmpeg 音频编码与 mp4 样本描述类型的映射
+var audioSampleEntries = map[string]string{
+	"aac":    "mp4a",
+	"ac3":    "ac-3",
+	"eac3":   "ec-3",
+	"truehd": "mlpa",
+	"dts":    "dtsc",
+	"flac":   "fLaC",
+	"opus":   "Opus",
+	"mp3":    ".mp3",
+	"alac":   "alac",
+}
+
+// sampleEntryType 获取轨道在 stsd 中的样本描述类型
+//
+// 无法映射的视频编码按 avc1 处理, 音频编码按 mp4a 处理, 字幕统一使用 tx3g
+func (t Track) sampleEntryType() string {
+	codec := strings.ToLower(t.Codec)
+	switch t.Kind {
+	case TrackVideo:
+		if t.DVProfile > 0 && codec == "hevc" {
+			return "dvh1"
+		}
+		if e, ok := videoSampleEntries[codec]; ok {
+			return e
+		}
+		return "avc1"
+	case TrackAudio:
+		if e, ok := audioSampleEntries[codec]; ok {
+			return e
+		}
+		return "mp4a"
+	default:
+		return "tx3g"
+	}
+}
+
+// handlerType 获取轨道的 hdlr 类型
+func (t Track) handlerType() string {
+	switch t.Kind {
+	case TrackVideo:
+		return "vide"
+	case TrackAudio:
+		return "soun"
+	default:
+		return "sbtl"
+	}
+}
+
+// handlerName 获取轨道的 hdlr 名称
+func (t Track) handlerName() string {
+	switch t.Kind {
+	case TrackVideo:
+		return "VideoHandler"
+	case TrackAudio:
+		return "SoundHandler"
+	default:
+		return "SubtitleHandler"
+	}
+}
+
+// timescale 获取轨道的时间刻度以及视频帧间隔
+func (t Track) timescale() (timescale, delta uint32) {
+	switch t.Kind {
+	case TrackVideo:
+		if t.FrameRate > 0 {
+			return uint32(math.Round(t.FrameRate * 1000)), 1000
+		}
+	case TrackAudio:
+		if t.SampleRate > 0 {
+			return uint32(t.SampleRate), 0
+		}
+	}
+	return 1000, 0
+}
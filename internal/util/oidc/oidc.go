@@ -0,0 +1,298 @@
+// Package oidc 实现 WebUI 单点登录需要的 OpenID Connect 授权码流程
+//
+// 只支持 RS256/RS384/RS512 签名的 id_token, 覆盖了 Keycloak、Authentik、Authelia 等常见身份提供方的默认配置
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clockSkew 校验 id_token 有效期时允许的时钟误差
+const clockSkew = time.Minute
+
+// DefaultScopes 默认申请的 scope
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+// signingHashes 支持的 id_token 签名算法
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Config 身份提供方配置
+type Config struct {
+	Issuer       string   // 身份提供方地址, 通过 {Issuer}/.well-known/openid-configuration 自动发现接口
+	ClientID     string   // 客户端 id
+	ClientSecret string   // 客户端密钥
+	RedirectURL  string   // 登录成功后的回调地址
+	Scopes       []string // 申请的 scope, 为空时使用 DefaultScopes
+}
+
+// Provider 完成服务发现的身份提供方
+type Provider struct {
+	cfg                   Config
+	issuer                string
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	client                *http.Client
+}
+
+// Claims id_token 中的声明
+type Claims map[string]any
+
+// String 获取字符串类型的声明, 不存在或类型不匹配时返回空字符串
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings 获取字符串数组类型的声明, 单个字符串视为只有一个元素的数组
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// NewProvider 请求身份提供方的 discovery 文档, 初始化授权接口地址
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("issuer 和 client id 不能为空")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+	p := &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimRight(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("获取 oidc discovery 文档失败: %v", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("discovery 文档中的 issuer 不匹配: %s", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("discovery 文档缺少授权、token 或 jwks 接口地址")
+	}
+	p.issuer = doc.Issuer
+	p.authorizationEndpoint = doc.AuthorizationEndpoint
+	p.tokenEndpoint = doc.TokenEndpoint
+	p.jwksURI = doc.JwksURI
+	return p, nil
+}
+
+// RandomString 生成用于 state 和 nonce 的随机字符串
+func RandomString() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// AuthCodeURL 生成跳转到身份提供方登录页面的地址
+func (p *Provider) AuthCodeURL(state, nonce string) string {
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(p.cfg.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.authorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange 使用授权码换取 id_token, 并校验签名和声明
+func (p *Provider) Exchange(code, nonce string) (Claims, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.cfg.RedirectURL},
+		"client_id":    {p.cfg.ClientID},
+	}
+	req, err := http.NewRequest(http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 token 接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("解析 token 响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token 接口返回错误: %s %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+	if token.IdToken == "" {
+		return nil, errors.New("token 响应中没有 id_token")
+	}
+	return p.Verify(token.IdToken, nonce)
+}
+
+// Verify 校验 id_token 的签名、签发方、受众、有效期和 nonce, 返回其中的声明
+func (p *Provider) Verify(rawIDToken, nonce string) (Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id_token 格式错误")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("解析 id_token 头部失败: %v", err)
+	}
+	hash, ok := signingHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("不支持的 id_token 签名算法: %s", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("解析 id_token 签名失败: %v", err)
+	}
+	key, err := p.publicKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+		return nil, errors.New("id_token 签名校验失败")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("解析 id_token 声明失败: %v", err)
+	}
+	if claims.String("iss") != p.issuer {
+		return nil, fmt.Errorf("id_token 签发方不匹配: %s", claims.String("iss"))
+	}
+	audience := false
+	for _, aud := range claims.Strings("aud") {
+		audience = audience || aud == p.cfg.ClientID
+	}
+	if !audience {
+		return nil, errors.New("id_token 的受众不包含当前客户端")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Unix(int64(exp), 0).Add(clockSkew).Before(time.Now()) {
+		return nil, errors.New("id_token 已过期")
+	}
+	if nonce != "" && claims.String("nonce") != nonce {
+		return nil, errors.New("id_token 的 nonce 不匹配")
+	}
+	if claims.String("sub") == "" {
+		return nil, errors.New("id_token 缺少 sub 声明")
+	}
+	return claims, nil
+}
+
+// publicKey 从 jwks 接口获取签名公钥, kid 为空时要求 jwks 中只有一个 RSA 公钥
+func (p *Provider) publicKey(kid string) (*rsa.PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(p.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("获取 jwks 失败: %v", err)
+	}
+
+	var keys []*rsa.PublicKey
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (kid != "" && k.Kid != kid) {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys = append(keys, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())})
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("jwks 中没有找到唯一匹配的签名公钥, kid: %s", kid)
+	}
+	return keys[0], nil
+}
+
+// getJSON 请求 json 接口
+func (p *Provider) getJSON(u string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("错误的响应状态: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeSegment 解码 jwt 中 base64url 编码的 json 片段
+func decodeSegment(seg string, out any) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, out)
+}
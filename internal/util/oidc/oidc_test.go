@@ -0,0 +1,104 @@
+package oidc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syscc/Emby-Go/internal/util/oidc"
+	"github.com/syscc/Emby-Go/internal/util/oidc/oidctest"
+)
+
+const redirectURL = "http://127.0.0.1:8094/api/login/oidc/callback"
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+	idp := oidctest.NewServer("go-emby", "secret")
+	t.Cleanup(idp.Close)
+	p, err := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.URL,
+		ClientID:     "go-emby",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idp, p
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp, p := newProvider(t)
+	idp.SetClaims(map[string]any{"sub": "u-1", "preferred_username": "alice", "groups": []string{"media", "admins"}})
+
+	callback, err := idp.Authorize(p.AuthCodeURL("state-1", "nonce-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(callback.String(), redirectURL) || callback.Query().Get("state") != "state-1" {
+		t.Fatalf("回调地址错误: %s", callback)
+	}
+
+	claims, err := p.Exchange(callback.Query().Get("code"), "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.String("sub") != "u-1" || claims.String("preferred_username") != "alice" {
+		t.Errorf("claims = %v", claims)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[1] != "admins" {
+		t.Errorf("groups = %v", groups)
+	}
+
+	if _, err := p.Exchange(callback.Query().Get("code"), "nonce-1"); err == nil {
+		t.Error("授权码不应被重复使用")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	idp, p := newProvider(t)
+	callback, err := idp.Authorize(p.AuthCodeURL("state", "nonce-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(callback.Query().Get("code"), "nonce-2"); err == nil {
+		t.Error("nonce 不匹配时应校验失败")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp, p := newProvider(t)
+
+	if _, err := p.Verify(idp.Token("n", map[string]any{"sub": "u-1"}), "n"); err != nil {
+		t.Fatalf("合法的 id_token 校验失败: %v", err)
+	}
+
+	cases := map[string]string{
+		"其他客户端":  idp.Token("n", map[string]any{"sub": "u-1", "aud": "other"}),
+		"已过期":    idp.Token("n", map[string]any{"sub": "u-1", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"签发方错误":  idp.Token("n", map[string]any{"sub": "u-1", "iss": "https://evil.example.com"}),
+		"缺少 sub": idp.Token("n", nil),
+	}
+	for name, token := range cases {
+		if _, err := p.Verify(token, "n"); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+
+	// 篡改声明后签名不再匹配
+	token := idp.Token("n", map[string]any{"sub": "u-1"})
+	parts := strings.Split(token, ".")
+	forged := idp.Token("n", map[string]any{"sub": "admin"})
+	parts[1] = strings.Split(forged, ".")[1]
+	if _, err := p.Verify(strings.Join(parts, "."), "n"); err == nil {
+		t.Error("篡改声明后应校验失败")
+	}
+}
+
+func TestNewProviderIssuerMismatch(t *testing.T) {
+	idp := oidctest.NewServer("go-emby", "secret")
+	defer idp.Close()
+	if _, err := oidc.NewProvider(oidc.Config{Issuer: idp.URL + "/realms/other", ClientID: "go-emby"}); err == nil {
+		t.Error("discovery 地址错误时应返回错误")
+	}
+}
@@ -32,7 +32,7 @@ var currentCacheSize int64 = 0
 // DefaultExpired 默认的请求过期时间
 //
 // 可通过设置 "Expired" 响应头进行覆盖
-var DefaultExpired = func() time.Duration { return config.C.Cache.ExpiredDuration() }
+var DefaultExpired = func() time.Duration { return config.C().Cache.ExpiredDuration() }
 
 // cacheMap 存放缓存数据的 map
 var cacheMap = sync.Map{}
@@ -42,6 +42,10 @@ func initRulePatterns() {
 		// 重排序剧集
 		{constant.Reg_ShowEpisodes, emby.ResortEpisodes},
 
+		// 外挂字幕转换为 WebVTT
+		{constant.Reg_ProxyVtt, emby.ProxyVtt},
+		// 网盘中与视频同名的外挂字幕重定向到直链
+		{constant.Reg_ProxySiblingSubtitle, emby.ProxySiblingSubtitle},
 		// 字幕长时间缓存
 		{constant.Reg_VideoSubtitles, emby.ProxySubtitles},
 
@@ -19,9 +19,9 @@ func Listen() error {
 	initRulePatterns()
 
 	errChanHTTP, errChanHTTPS := make(chan error, 1), make(chan error, 1)
-	if !config.C.Ssl.Enable {
+	if !config.C().Ssl.Enable {
 		go listenHTTP(errChanHTTP)
-	} else if config.C.Ssl.SinglePort {
+	} else if config.C().Ssl.SinglePort {
 		go listenHTTPS(errChanHTTPS)
 	} else {
 		go listenHTTP(errChanHTTP)
@@ -42,7 +42,7 @@ func initRouter(r *gin.Engine) {
 	r.Use(referrerPolicySetter())
 	r.Use(emby.ApiKeyChecker())
 	r.Use(emby.DownloadStrategyChecker())
-	if config.C.Cache.Enable {
+	if config.C().Cache.Enable {
 		r.Use(cache.CacheableRouteMarker())
 		r.Use(cache.RequestCacher())
 	}
@@ -80,7 +80,7 @@ func listenHTTPS(errChan chan error) {
 	})
 	initRouter(r)
 	logs.Info("在端口【%s】上启动 HTTPS 服务", webport.HTTPS)
-	ssl := config.C.Ssl
+	ssl := config.C().Ssl
 
 	srv := &http.Server{
 		Addr:    "0.0.0.0:" + webport.HTTPS,
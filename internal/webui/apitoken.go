@@ -0,0 +1,69 @@
+package webui
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// handleListAPITokens 获取当前用户的 API token
+func handleListAPITokens(c *gin.Context) {
+	list, err := db.GetAPITokens(currentUser(c).ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"tokens": list, "scopes": db.Scopes})
+}
+
+// handleCreateAPIToken 为当前用户创建 API token, 明文 token 只在创建时返回一次
+func handleCreateAPIToken(c *gin.Context) {
+	var form struct {
+		Name          string
+		Scopes        []string
+		ExpiresInDays int // 有效天数, 0 表示永不过期
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if form.ExpiresInDays < 0 {
+		c.JSON(400, gin.H{"error": "invalid expiry days"})
+		return
+	}
+	var expiresAt time.Time
+	if form.ExpiresInDays > 0 {
+		expiresAt = time.Now().AddDate(0, 0, form.ExpiresInDays)
+	}
+
+	user := currentUser(c)
+	token, t, err := db.CreateAPIToken(user.ID, form.Name, form.Scopes, expiresAt)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditCreate, t.ID, nil, t)
+	logs.Info("WebUI 用户 %s 创建了 API token: %s, 权限: %s", user.Username, t.Name, t.Scopes)
+	c.JSON(200, gin.H{"token": token, "info": t})
+}
+
+// handleDeleteAPIToken 撤销当前用户的 API token
+func handleDeleteAPIToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user := currentUser(c)
+	t, err := db.GetAPIToken(user.ID, uint(id))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.DeleteAPIToken(user.ID, t.ID); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditDelete, t.ID, t, nil)
+	logs.Info("WebUI 用户 %s 撤销了 API token: %s", user.Username, t.Name)
+	c.Status(200)
+}
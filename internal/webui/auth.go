@@ -0,0 +1,430 @@
+package webui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/encrypts"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+const (
+	// loginWindow 单个 IP 登录尝试次数的统计窗口
+	loginWindow = 5 * time.Minute
+
+	// loginMaxAttempts 单个 IP 在统计窗口内最多允许的登录尝试次数
+	loginMaxAttempts = 10
+
+	// ctxUserKey 当前登录用户在 gin.Context 中的 key
+	ctxUserKey = "webui-user"
+
+	// ctxTokenKey 通过 API token 访问时, token 在 gin.Context 中的 key
+	ctxTokenKey = "webui-api-token"
+)
+
+// tokenScopes API token 可以访问的接口及需要的权限范围, key 格式: 请求方法 路由,
+// 不在表中的接口只能通过登录会话访问
+var tokenScopes = map[string]string{
+	"GET /api/servers":                    db.ScopeServersRead,
+	"GET /api/global-config":              db.ScopeServersRead,
+	"POST /api/servers":                   db.ScopeServersWrite,
+	"PUT /api/servers/:id":                db.ScopeServersWrite,
+	"DELETE /api/servers/:id":             db.ScopeServersWrite,
+	"POST /api/servers/:id/restart":       db.ScopeServersWrite,
+	"POST /api/servers/validate":          db.ScopeServersWrite,
+	"PUT /api/global-config":              db.ScopeServersWrite,
+	"POST /api/servers/:id/sync":          db.ScopeSync,
+	"POST /api/servers/:id/trace-path":    db.ScopeServersRead,
+	"GET /api/servers/:id/playlist-stats": db.ScopeServersRead,
+	"GET /api/logs":                       db.ScopeLogsRead,
+}
+
+// loginLimiter 按客户端 IP 限制登录频率
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+}
+
+var limiter = &loginLimiter{attempts: make(map[string][]time.Time)}
+
+// allow 记录一次登录尝试, 返回是否允许本次尝试
+func (l *loginLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, list := range l.attempts {
+		if len(list) > 0 && now.Sub(list[len(list)-1]) > loginWindow {
+			delete(l.attempts, key)
+		}
+	}
+
+	recent := l.attempts[ip][:0]
+	for _, t := range l.attempts[ip] {
+		if now.Sub(t) <= loginWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= loginMaxAttempts {
+		l.attempts[ip] = recent
+		return false
+	}
+	l.attempts[ip] = append(recent, now)
+	return true
+}
+
+// reset 登录成功后清除该 IP 的尝试记录
+func (l *loginLimiter) reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, ip)
+}
+
+// requestToken 从 Authorization 请求头中提取会话 token, 兼容 Bearer 前缀
+func requestToken(c *gin.Context) string {
+	token := strings.TrimSpace(c.GetHeader("Authorization"))
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
+
+// requireAuth 校验会话 token 或 API token, 通过后将当前用户保存到上下文中
+func requireAuth(c *gin.Context) {
+	token := requestToken(c)
+	if strings.HasPrefix(token, db.APITokenPrefix) {
+		requireAPIToken(c, token)
+		return
+	}
+	user, err := db.GetSessionUser(token)
+	if err != nil {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	c.Set(ctxUserKey, user)
+	c.Next()
+}
+
+// requireAPIToken 校验 API token 是否有效, 以及是否拥有访问当前接口的权限范围
+func requireAPIToken(c *gin.Context, token string) {
+	user, t, err := db.GetAPITokenUser(token)
+	if err != nil {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+	scope, ok := tokenScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok || !t.HasScope(scope) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "API token is not allowed to access this endpoint"})
+		return
+	}
+	c.Set(ctxUserKey, user)
+	c.Set(ctxTokenKey, t)
+	c.Next()
+}
+
+// requireRole 要求当前用户至少拥有 role 角色的权限, 需要在 requireAuth 之后使用
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := currentUser(c); user == nil || !user.HasRole(role) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Permission denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// audit 记录当前用户的数据变更, 记录失败只输出日志, 不影响请求结果
+//
+// 通过 API token 访问时, 操作人中会带上 token 的名称
+func audit(c *gin.Context, action string, targetID uint, before, after any) {
+	username := currentUser(c).Username
+	if t, ok := c.Get(ctxTokenKey); ok {
+		username = fmt.Sprintf("%s (API token: %s)", username, t.(*db.APIToken).Name)
+	}
+	if err := db.RecordAudit(username, action, targetID, before, after); err != nil {
+		logs.Warn("记录审计日志失败: %v", err)
+	}
+}
+
+// canViewSecrets 当前请求是否可以查看服务器的密钥
+//
+// 需要管理员权限, 通过 API token 访问时 token 还需要拥有 servers:write 权限范围
+func canViewSecrets(c *gin.Context) bool {
+	if !currentUser(c).HasRole(db.RoleAdmin) {
+		return false
+	}
+	if t, ok := c.Get(ctxTokenKey); ok {
+		return t.(*db.APIToken).HasScope(db.ScopeServersWrite)
+	}
+	return true
+}
+
+// currentUser 获取当前登录的用户
+func currentUser(c *gin.Context) *db.User {
+	user, _ := c.MustGet(ctxUserKey).(*db.User)
+	return user
+}
+
+// handleLogin 校验用户名密码和两步验证码并创建会话
+//
+// 用户启用了两步验证而请求中没有验证码时返回 401 和 totpRequired, 前端据此显示验证码输入框
+func handleLogin(c *gin.Context) {
+	var form struct {
+		Username string
+		Password string
+		Code     string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	ip := c.ClientIP()
+	if !limiter.allow(ip) {
+		logs.Warn("WebUI 登录尝试过于频繁: %s", ip)
+		c.JSON(429, gin.H{"error": "Too many login attempts, please try again later"})
+		return
+	}
+
+	user, err := db.Login(form.Username, form.Password, form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrUserLocked):
+			logs.Warn("WebUI 账号 %s 已锁定, 来源: %s", form.Username, ip)
+			c.JSON(423, gin.H{"error": "Account locked due to too many failed attempts"})
+		case errors.Is(err, db.ErrInvalidCredentials):
+			c.JSON(401, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, db.ErrTOTPRequired):
+			c.JSON(401, gin.H{"error": "Two-factor code required", "totpRequired": true})
+		case errors.Is(err, db.ErrTOTPInvalid):
+			c.JSON(401, gin.H{"error": "Invalid two-factor code", "totpRequired": true})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	limiter.reset(ip)
+
+	token, err := db.CreateSession(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"token": token, "username": user.Username})
+}
+
+// handleLogout 撤销当前会话
+func handleLogout(c *gin.Context) {
+	if err := db.DeleteSession(requestToken(c)); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(200)
+}
+
+// handleChangePassword 校验当前密码后修改密码, 撤销该用户的所有会话并为当前客户端签发新 token
+func handleChangePassword(c *gin.Context) {
+	var form struct {
+		CurrentPassword string
+		NewPassword     string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(form.NewPassword) == "" {
+		c.JSON(400, gin.H{"error": "new password is empty"})
+		return
+	}
+
+	user := currentUser(c)
+	if _, err := db.Authenticate(user.Username, form.CurrentPassword); err != nil {
+		c.JSON(400, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if err := db.UpdatePassword(user.Username, form.NewPassword); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := db.CreateSession(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"token": token})
+}
+
+// handleSetupTOTP 校验当前密码后为当前用户生成待确认的两步验证密钥
+func handleSetupTOTP(c *gin.Context) {
+	var form struct {
+		CurrentPassword string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	user := currentUser(c)
+	if _, err := db.Authenticate(user.Username, form.CurrentPassword); err != nil {
+		c.JSON(400, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	secret, err := db.SetupTOTP(user.ID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"secret": secret, "uri": encrypts.TOTPURI("Go-Emby", user.Username, secret)})
+}
+
+// handleEnableTOTP 使用验证器应用生成的验证码确认启用两步验证
+func handleEnableTOTP(c *gin.Context) {
+	var form struct {
+		Code string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	user := currentUser(c)
+	if err := db.EnableTOTP(user.ID, form.Code); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	logs.Info("WebUI 用户 %s 启用了两步验证", user.Username)
+	c.Status(200)
+}
+
+// handleDisableTOTP 校验当前密码和验证码后关闭当前用户的两步验证
+func handleDisableTOTP(c *gin.Context) {
+	var form struct {
+		CurrentPassword string
+		Code            string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	user := currentUser(c)
+	if _, err := db.Login(user.Username, form.CurrentPassword, form.Code); err != nil {
+		c.JSON(400, gin.H{"error": "Current password or two-factor code is incorrect"})
+		return
+	}
+	if err := db.DisableTOTP(user.ID); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	logs.Info("WebUI 用户 %s 关闭了两步验证", user.Username)
+	c.Status(200)
+}
+
+// userView 返回给前端的用户信息, 不包含密码哈希
+type userView struct {
+	ID          uint      `json:"ID"`
+	Username    string    `json:"Username"`
+	Role        string    `json:"Role"`
+	Provider    string    `json:"Provider"`
+	TOTPEnabled bool      `json:"TOTPEnabled"`
+	LockedUntil time.Time `json:"LockedUntil"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+}
+
+// handleListUsers 获取所有 WebUI 用户
+func handleListUsers(c *gin.Context) {
+	users, err := db.GetUsers()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	list := make([]userView, 0, len(users))
+	for _, u := range users {
+		list = append(list, userView{
+			ID:          u.ID,
+			Username:    u.Username,
+			Role:        u.Role,
+			Provider:    u.Provider,
+			TOTPEnabled: u.TOTPEnabled,
+			LockedUntil: u.LockedUntil,
+			CreatedAt:   u.CreatedAt,
+		})
+	}
+	c.JSON(200, list)
+}
+
+// handleAddUser 添加 WebUI 用户
+func handleAddUser(c *gin.Context) {
+	var form struct {
+		Username string
+		Password string
+		Role     string
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := db.AddUser(form.Username, form.Password, form.Role)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	logs.Info("用户 %s 添加了 WebUI 用户 %s, 角色: %s", currentUser(c).Username, user.Username, user.Role)
+	c.Status(200)
+}
+
+// handleUpdateUser 修改用户角色, Password 不为空时同时重置该用户的密码, ResetTOTP 为 true 时关闭该用户的两步验证
+func handleUpdateUser(c *gin.Context) {
+	var form struct {
+		Role      string
+		Password  string
+		ResetTOTP bool
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := db.GetUserById(uint(id))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.UpdateUserRole(user.ID, form.Role); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if form.Password != "" {
+		if err := db.UpdatePassword(user.Username, form.Password); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if form.ResetTOTP {
+		if err := db.DisableTOTP(user.ID); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		logs.Info("用户 %s 重置了 WebUI 用户 %s 的两步验证", currentUser(c).Username, user.Username)
+	}
+	logs.Info("用户 %s 修改了 WebUI 用户 %s, 角色: %s", currentUser(c).Username, user.Username, form.Role)
+	c.Status(200)
+}
+
+// handleDeleteUser 删除 WebUI 用户, 不能删除自己
+func handleDeleteUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if uint(id) == currentUser(c).ID {
+		c.JSON(400, gin.H{"error": "cannot delete the current user"})
+		return
+	}
+	if err := db.DeleteUser(uint(id)); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(200)
+}
@@ -0,0 +1,267 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/db"
+)
+
+// newAuthRouter 按 Start 中的分组方式注册测试路由, 接口返回 canViewSecrets 的结果
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.JSON(200, gin.H{"secrets": canViewSecrets(c)}) }
+
+	api := r.Group("/api")
+	api.POST("/login", handleLogin)
+	auth := api.Group("/", requireAuth, requireRole(db.RoleViewer))
+	operator := auth.Group("/", requireRole(db.RoleOperator))
+	admin := auth.Group("/", requireRole(db.RoleAdmin))
+
+	auth.POST("/logout", handleLogout)
+	auth.GET("/servers", ok)
+	auth.GET("/user", ok)
+	operator.POST("/servers/:id/restart", ok)
+	admin.POST("/servers", ok)
+	admin.GET("/users", ok)
+	return r
+}
+
+// initAuthDB 在临时目录中初始化数据库, 并为每个角色添加一个用户
+func initAuthDB(t *testing.T) map[string]*db.User {
+	t.Helper()
+	if err := db.Init(filepath.Join(t.TempDir(), "Go-Emby.db")); err != nil {
+		t.Fatal(err)
+	}
+	users := make(map[string]*db.User)
+	for _, role := range []string{db.RoleAdmin, db.RoleOperator, db.RoleViewer} {
+		user, err := db.AddUser(role, "secret", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[role] = user
+	}
+	return users
+}
+
+// doRequest 携带 token 发起请求, 返回响应
+func doRequest(r *gin.Engine, method, target, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// newSession 为用户创建会话 token
+func newSession(t *testing.T, user *db.User) string {
+	t.Helper()
+	token, err := db.CreateSession(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// newAPIToken 为用户创建拥有 scopes 权限范围的 API token
+func newAPIToken(t *testing.T, user *db.User, scopes ...string) string {
+	t.Helper()
+	token, _, err := db.CreateAPIToken(user.ID, "test", scopes, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRequireAuthSession(t *testing.T) {
+	users := initAuthDB(t)
+	r := newAuthRouter()
+	admin := users[db.RoleAdmin]
+
+	if w := doRequest(r, "GET", "/api/servers", "", nil); w.Code != 401 {
+		t.Errorf("no token: code = %d, want 401", w.Code)
+	}
+	if w := doRequest(r, "GET", "/api/servers", "invalid", nil); w.Code != 401 {
+		t.Errorf("invalid token: code = %d, want 401", w.Code)
+	}
+
+	token := newSession(t, admin)
+	if w := doRequest(r, "GET", "/api/servers", token, nil); w.Code != 200 {
+		t.Fatalf("valid session: code = %d, want 200", w.Code)
+	}
+
+	// 退出登录后会话被撤销
+	if w := doRequest(r, "POST", "/api/logout", token, nil); w.Code != 200 {
+		t.Fatalf("logout: code = %d", w.Code)
+	}
+	if w := doRequest(r, "GET", "/api/servers", token, nil); w.Code != 401 {
+		t.Errorf("revoked session: code = %d, want 401", w.Code)
+	}
+
+	// 过期的会话被拒绝
+	token = newSession(t, admin)
+	db.DB.Model(&db.Session{}).Where("user_id = ?", admin.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := doRequest(r, "GET", "/api/servers", token, nil); w.Code != 401 {
+		t.Errorf("expired session: code = %d, want 401", w.Code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	users := initAuthDB(t)
+	r := newAuthRouter()
+
+	tests := []struct {
+		role   string
+		method string
+		target string
+		want   int
+	}{
+		{db.RoleViewer, "GET", "/api/servers", 200},
+		{db.RoleViewer, "POST", "/api/servers/1/restart", 403},
+		{db.RoleViewer, "POST", "/api/servers", 403},
+		{db.RoleViewer, "GET", "/api/users", 403},
+		{db.RoleOperator, "POST", "/api/servers/1/restart", 200},
+		{db.RoleOperator, "POST", "/api/servers", 403},
+		{db.RoleAdmin, "POST", "/api/servers/1/restart", 200},
+		{db.RoleAdmin, "GET", "/api/users", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.method+" "+tt.target, func(t *testing.T) {
+			w := doRequest(r, tt.method, tt.target, newSession(t, users[tt.role]), nil)
+			if w.Code != tt.want {
+				t.Errorf("code = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAPIToken(t *testing.T) {
+	users := initAuthDB(t)
+	r := newAuthRouter()
+	admin := users[db.RoleAdmin]
+
+	read := newAPIToken(t, admin, db.ScopeServersRead)
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		target string
+		want   int
+	}{
+		{"has scope", read, "GET", "/api/servers", 200},
+		{"missing scope", read, "POST", "/api/servers", 403},
+		{"missing scope operator route", read, "POST", "/api/servers/1/restart", 403},
+		{"route not in tokenScopes", read, "GET", "/api/user", 403},
+		{"admin route not in tokenScopes", newAPIToken(t, admin, db.Scopes...), "GET", "/api/users", 403},
+		{"write scope", newAPIToken(t, admin, db.ScopeServersWrite), "POST", "/api/servers/1/restart", 200},
+		{"role still required", newAPIToken(t, users[db.RoleViewer], db.ScopeServersWrite), "POST", "/api/servers", 403},
+		{"unknown token", db.APITokenPrefix + "invalid", "GET", "/api/servers", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := doRequest(r, tt.method, tt.target, tt.token, nil); w.Code != tt.want {
+				t.Errorf("code = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	// 过期的 token 被拒绝
+	db.DB.Model(&db.APIToken{}).Where("user_id = ?", admin.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := doRequest(r, "GET", "/api/servers", read, nil); w.Code != 401 {
+		t.Errorf("expired token: code = %d, want 401", w.Code)
+	}
+}
+
+func TestCanViewSecrets(t *testing.T) {
+	users := initAuthDB(t)
+	r := newAuthRouter()
+	admin := users[db.RoleAdmin]
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"admin session", newSession(t, admin), true},
+		{"operator session", newSession(t, users[db.RoleOperator]), false},
+		{"viewer session", newSession(t, users[db.RoleViewer]), false},
+		{"read only token", newAPIToken(t, admin, db.ScopeServersRead), false},
+		{"write token", newAPIToken(t, admin, db.ScopeServersRead, db.ScopeServersWrite), true},
+		{"operator write token", newAPIToken(t, users[db.RoleOperator], db.ScopeServersRead, db.ScopeServersWrite), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(r, "GET", "/api/servers", tt.token, nil)
+			var res struct{ Secrets bool }
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != 200 {
+				t.Fatalf("code = %d, body = %s", w.Code, w.Body)
+			}
+			if res.Secrets != tt.want {
+				t.Errorf("canViewSecrets() = %v, want %v", res.Secrets, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := &loginLimiter{attempts: make(map[string][]time.Time)}
+	for i := 0; i < loginMaxAttempts; i++ {
+		if !l.allow("1.1.1.1") {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	if l.allow("1.1.1.1") {
+		t.Error("attempt over the limit should be rejected")
+	}
+	if !l.allow("2.2.2.2") {
+		t.Error("other ip should not be limited")
+	}
+
+	// 统计窗口之外的尝试不计数
+	past := time.Now().Add(-loginWindow - time.Second)
+	for i := range l.attempts["1.1.1.1"] {
+		l.attempts["1.1.1.1"][i] = past
+	}
+	if !l.allow("1.1.1.1") {
+		t.Error("attempts outside the window should expire")
+	}
+
+	l.reset("2.2.2.2")
+	if _, ok := l.attempts["2.2.2.2"]; ok {
+		t.Error("reset should clear attempts")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	initAuthDB(t)
+	r := newAuthRouter()
+	t.Cleanup(func() { limiter.reset("192.0.2.1") })
+
+	login := func(password string) int {
+		return doRequest(r, "POST", "/api/login", "", gin.H{"Username": db.RoleViewer, "Password": password}).Code
+	}
+	for i := 1; i < db.MaxFailedLogins; i++ {
+		if code := login("wrong"); code != 401 {
+			t.Fatalf("attempt %d: code = %d, want 401", i, code)
+		}
+	}
+	// 连续失败 5 次后账号被锁定, 锁定期间密码正确也不能登录
+	if code := login("wrong"); code != 423 {
+		t.Fatalf("attempt %d: code = %d, want 423", db.MaxFailedLogins, code)
+	}
+	if code := login("secret"); code != 423 {
+		t.Errorf("locked login: code = %d, want 423", code)
+	}
+}
@@ -0,0 +1,130 @@
+package webui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/manager"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+)
+
+// handleExportBackup 导出当前配置, 直接下载备份文件而不保存到备份目录
+func handleExportBackup(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := manager.WriteBackup(&buf); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	name := fmt.Sprintf("go-emby-backup-%s-export.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Data(200, "application/zip", buf.Bytes())
+}
+
+// handleImportBackup 校验上传的备份文件并恢复, 校验失败时不会修改当前配置
+func handleImportBackup(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if fh.Size > manager.MaxBackupSize {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("backup file exceeds %d MB", manager.MaxBackupSize>>20)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, manager.MaxBackupSize+1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	restoreBackup(c, fh.Filename, b)
+}
+
+// handleListBackups 获取备份目录中的备份
+func handleListBackups(c *gin.Context) {
+	list, err := manager.ListBackups()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, list)
+}
+
+// handleCreateBackup 立即创建一个手动备份
+func handleCreateBackup(c *gin.Context) {
+	info, err := manager.CreateBackup(manager.BackupManual)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditCreate, 0, nil, info)
+	logs.Info("用户 %s 创建了备份: %s", currentUser(c).Username, info.Name)
+	c.JSON(200, info)
+}
+
+// handleDownloadBackup 下载备份目录中的备份
+func handleDownloadBackup(c *gin.Context) {
+	fp, err := manager.BackupPath(c.Param("name"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(fp); err != nil {
+		c.JSON(404, gin.H{"error": "backup not found"})
+		return
+	}
+	c.FileAttachment(fp, c.Param("name"))
+}
+
+// handleRestoreBackup 使用备份目录中的备份恢复配置
+func handleRestoreBackup(c *gin.Context) {
+	fp, err := manager.BackupPath(c.Param("name"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "backup not found"})
+		return
+	}
+	restoreBackup(c, c.Param("name"), b)
+}
+
+// handleDeleteBackup 删除备份目录中的备份
+func handleDeleteBackup(c *gin.Context) {
+	name := c.Param("name")
+	if err := manager.DeleteBackup(name); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditDelete, 0, &manager.BackupInfo{Name: name}, nil)
+	logs.Info("用户 %s 删除了备份: %s", currentUser(c).Username, name)
+	c.Status(200)
+}
+
+// restoreBackup 校验备份内容后恢复配置并重启所有服务
+func restoreBackup(c *gin.Context, name string, b []byte) {
+	backup, err := manager.ReadBackup(b)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := manager.RestoreBackup(backup); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditRestore, 0, nil, &manager.BackupInfo{Name: name, CreatedAt: backup.Manifest.CreatedAt})
+	logs.Success("用户 %s 恢复了备份: %s, 备份版本: %s", currentUser(c).Username, name, backup.Manifest.AppVersion)
+	c.Status(200)
+}
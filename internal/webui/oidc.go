@@ -0,0 +1,226 @@
+package webui
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/db"
+	"github.com/syscc/Emby-Go/internal/util/logs"
+	"github.com/syscc/Emby-Go/internal/util/oidc"
+)
+
+const (
+	// oidcCallbackPath 单点登录回调地址
+	oidcCallbackPath = "/api/login/oidc/callback"
+
+	// oidcStateCookie 保存登录 state 的 cookie, 用于确认回调请求来自发起登录的浏览器
+	oidcStateCookie = "go_emby_oidc_state"
+
+	// oidcStateTTL 发起登录到完成回调的最长时间
+	oidcStateTTL = 10 * time.Minute
+)
+
+// oidcLogin 发起中的单点登录请求
+type oidcLogin struct {
+	nonce       string
+	redirectURL string
+	expires     time.Time
+}
+
+// oidcLogins 以 state 为 key 保存发起中的单点登录请求
+var oidcLogins = struct {
+	sync.Mutex
+	m map[string]oidcLogin
+}{m: make(map[string]oidcLogin)}
+
+// saveOIDCLogin 保存登录请求, 同时清理过期的请求
+func saveOIDCLogin(state string, login oidcLogin) {
+	oidcLogins.Lock()
+	defer oidcLogins.Unlock()
+	now := time.Now()
+	for key, l := range oidcLogins.m {
+		if l.expires.Before(now) {
+			delete(oidcLogins.m, key)
+		}
+	}
+	oidcLogins.m[state] = login
+}
+
+// takeOIDCLogin 取出并删除 state 对应的登录请求, 每个 state 只能使用一次
+func takeOIDCLogin(state string) (oidcLogin, bool) {
+	oidcLogins.Lock()
+	defer oidcLogins.Unlock()
+	login, ok := oidcLogins.m[state]
+	delete(oidcLogins.m, state)
+	if !ok || login.expires.Before(time.Now()) {
+		return oidcLogin{}, false
+	}
+	return login, true
+}
+
+// oidcRedirectURL 获取单点登录回调地址, 没有配置时根据请求的协议和 host 生成
+func oidcRedirectURL(c *gin.Context, cfg db.OIDCConfig) string {
+	if cfg.RedirectURL != "" {
+		return cfg.RedirectURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	return scheme + "://" + c.Request.Host + oidcCallbackPath
+}
+
+// newOIDCProvider 根据配置初始化身份提供方
+func newOIDCProvider(cfg db.OIDCConfig, redirectURL string) (*oidc.Provider, error) {
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(cfg.Scopes),
+	})
+}
+
+// oidcFail 单点登录失败, 跳转回登录页并在地址的 fragment 中携带错误信息
+func oidcFail(c *gin.Context, format string, args ...any) {
+	logs.Warn("WebUI 单点登录失败: "+format, args...)
+	c.Redirect(302, "/#sso-error="+url.QueryEscape("Single sign-on failed, please check the server logs"))
+}
+
+// handleOIDCLogin 跳转到身份提供方的登录页面
+func handleOIDCLogin(c *gin.Context) {
+	cfg, err := db.GetOIDCConfig()
+	if err != nil || !cfg.Enable {
+		c.JSON(404, gin.H{"error": "Single sign-on is not enabled"})
+		return
+	}
+	redirectURL := oidcRedirectURL(c, cfg)
+	provider, err := newOIDCProvider(cfg, redirectURL)
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+	state, err := oidc.RandomString()
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+
+	saveOIDCLogin(state, oidcLogin{nonce: nonce, redirectURL: redirectURL, expires: time.Now().Add(oidcStateTTL)})
+	secure := strings.HasPrefix(redirectURL, "https://")
+	// 身份提供方重定向回来属于跨站的顶层跳转, 需要 Lax 才会携带 cookie
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/login/oidc", "", secure, true)
+	c.Redirect(302, provider.AuthCodeURL(state, nonce))
+}
+
+// handleOIDCCallback 处理身份提供方的回调, 校验 id_token 后按声明映射角色并创建会话
+//
+// 会话 token 通过地址的 fragment 传给前端, 不会出现在服务器和反向代理的访问日志中
+func handleOIDCCallback(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		oidcFail(c, "身份提供方返回错误: %s %s", e, c.Query("error_description"))
+		return
+	}
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/login/oidc", "", false, true)
+	if state == "" || cookie != state {
+		oidcFail(c, "state 与发起登录的浏览器不匹配")
+		return
+	}
+	login, ok := takeOIDCLogin(state)
+	if !ok {
+		oidcFail(c, "登录请求不存在或已过期")
+		return
+	}
+
+	cfg, err := db.GetOIDCConfig()
+	if err != nil || !cfg.Enable {
+		oidcFail(c, "单点登录未启用")
+		return
+	}
+	provider, err := newOIDCProvider(cfg, login.redirectURL)
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+	claims, err := provider.Exchange(c.Query("code"), login.nonce)
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+
+	username := claims.String(cfg.UsernameClaim)
+	if username == "" {
+		username = claims.String("email")
+	}
+	if username == "" {
+		username = claims.String("sub")
+	}
+	role, ok := cfg.MapRole(claims.Strings(cfg.RoleClaim))
+	if !ok {
+		oidcFail(c, "用户 %s 的 %s 声明 %v 没有匹配的角色", username, cfg.RoleClaim, claims.Strings(cfg.RoleClaim))
+		return
+	}
+	user, err := db.UpsertOIDCUser(claims.String("sub"), username, role)
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+	token, err := db.CreateSession(user.ID)
+	if err != nil {
+		oidcFail(c, "%v", err)
+		return
+	}
+	logs.Info("WebUI 用户 %s 通过单点登录登录, 角色: %s, 来源: %s", user.Username, user.Role, c.ClientIP())
+	c.Redirect(302, "/#token="+url.QueryEscape(token))
+}
+
+// handleGetOIDCConfig 获取单点登录配置
+func handleGetOIDCConfig(c *gin.Context) {
+	cfg, err := db.GetOIDCConfig()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, cfg)
+}
+
+// handleUpdateOIDCConfig 保存单点登录配置, 启用时会先请求身份提供方的 discovery 文档确认配置可用
+func handleUpdateOIDCConfig(c *gin.Context) {
+	var cfg db.OIDCConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if cfg.Enable {
+		if _, err := newOIDCProvider(cfg, oidcRedirectURL(c, cfg)); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	before, _ := db.GetOIDCConfig()
+	if err := db.UpdateOIDCConfig(&cfg); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, db.AuditUpdate, cfg.ID, &before, &cfg)
+	c.Status(200)
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
 	"net/http"
 	"net/url"
@@ -11,11 +13,16 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/syscc/Emby-Go/internal/config"
 	"github.com/syscc/Emby-Go/internal/db"
 	"github.com/syscc/Emby-Go/internal/manager"
+	"github.com/syscc/Emby-Go/internal/service/lib/chapter"
+	"github.com/syscc/Emby-Go/internal/util/events"
+	"github.com/syscc/Emby-Go/internal/util/logs"
 )
 
 func trustedProxies() []string {
@@ -42,6 +49,57 @@ func trustedProxies() []string {
 //go:embed static/*
 var staticFS embed.FS
 
+// reloadAllServers 重新加载所有服务的配置, 使新的全局配置生效
+//
+// 等待所有服务加载完成, 返回内核校验新配置失败的服务及原因
+func reloadAllServers() error {
+	servers, _ := db.GetServers()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		rejected []string
+	)
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s db.EmbyServer) {
+			defer wg.Done()
+			err := manager.Reload(s.ID)
+			if err == nil {
+				return
+			}
+			logs.Error("[%s] 重新加载配置失败: %v", s.Name, err)
+			if errors.Is(err, manager.ErrConfigRejected) {
+				mu.Lock()
+				rejected = append(rejected, fmt.Sprintf("[%s] %v", s.Name, err))
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+	if len(rejected) > 0 {
+		return errors.New(strings.Join(rejected, "\n"))
+	}
+	return nil
+}
+
+// validateDeviceProfile 校验设备配置的名称和请求体
+func validateDeviceProfile(p db.DeviceProfile) error {
+	dp := config.DeviceProfile{Name: p.Name, Payload: p.Payload}
+	return dp.Init()
+}
+
+// validateSkipMarker 校验片头片尾时间
+func validateSkipMarker(m db.SkipMarker) error {
+	rule := config.SkipMarkerRule{
+		Series:       m.Series,
+		Season:       m.Season,
+		IntroStart:   m.IntroStart,
+		IntroEnd:     m.IntroEnd,
+		CreditsStart: m.CreditsStart,
+	}
+	return rule.Init()
+}
+
 func Start(port int) {
 	r := gin.Default()
 	_ = r.SetTrustedProxies(trustedProxies())
@@ -49,7 +107,11 @@ func Start(port int) {
 	api := r.Group("/api")
 	{
 		api.GET("/check-init", func(c *gin.Context) {
-			c.JSON(200, gin.H{"initialized": db.CheckInit()})
+			res := gin.H{"initialized": db.CheckInit(), "oidc": false}
+			if cfg, err := db.GetOIDCConfig(); err == nil && cfg.Enable {
+				res["oidc"], res["oidcName"] = true, cfg.Name
+			}
+			c.JSON(200, res)
 		})
 
 		api.POST("/setup", func(c *gin.Context) {
@@ -68,34 +130,18 @@ func Start(port int) {
 			c.Status(200)
 		})
 
-		api.POST("/login", func(c *gin.Context) {
-			var form struct {
-				Username string
-				Password string
-			}
-			if err := c.ShouldBindJSON(&form); err != nil {
-				c.JSON(400, gin.H{"error": err.Error()})
-				return
-			}
-			user, err := db.GetUser(form.Username, form.Password)
-			if err != nil {
-				c.JSON(401, gin.H{"error": "Invalid credentials"})
-				return
-			}
-			c.JSON(200, gin.H{"token": "dummy-token-" + strconv.Itoa(int(user.ID))})
-		})
+		api.POST("/login", handleLogin)
+		api.GET("/login/oidc", handleOIDCLogin)
+		api.GET("/login/oidc/callback", handleOIDCCallback)
 
 		// Protected routes
-		auth := api.Group("/", func(c *gin.Context) {
-			token := c.GetHeader("Authorization")
-			if token == "" {
-				c.AbortWithStatus(401)
-				return
-			}
-			c.Next()
-		})
+		auth := api.Group("/", requireAuth, requireRole(db.RoleViewer))
+		operator := auth.Group("/", requireRole(db.RoleOperator))
+		admin := auth.Group("/", requireRole(db.RoleAdmin))
+
+		auth.POST("/logout", handleLogout)
 
-		auth.GET("/config", func(c *gin.Context) {
+		admin.GET("/config", func(c *gin.Context) {
 			wd, _ := os.Getwd()
 			fp := filepath.Join(wd, "config.yml")
 			b, err := os.ReadFile(fp)
@@ -106,7 +152,7 @@ func Start(port int) {
 			c.JSON(200, gin.H{"path": fp, "content": string(b)})
 		})
 
-		auth.PUT("/config", func(c *gin.Context) {
+		admin.PUT("/config", func(c *gin.Context) {
 			var body struct {
 				Content string
 			}
@@ -123,7 +169,7 @@ func Start(port int) {
 			c.Status(200)
 		})
 
-		auth.GET("/global-config", func(c *gin.Context) {
+		admin.GET("/global-config", func(c *gin.Context) {
 			g, err := db.GetGlobalConfig()
 			if err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
@@ -131,23 +177,32 @@ func Start(port int) {
 			}
 			c.JSON(200, g)
 		})
-		auth.PUT("/global-config", func(c *gin.Context) {
+		admin.PUT("/global-config", func(c *gin.Context) {
 			var g db.GlobalConfig
 			if err := c.ShouldBindJSON(&g); err != nil {
 				c.JSON(400, gin.H{"error": err.Error()})
 				return
 			}
+			before, _ := db.GetGlobalConfig()
 			if err := db.UpdateGlobalConfig(&g); err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
-			servers, _ := db.GetServers()
-			for _, s := range servers {
-				_ = manager.Restart(s.ID)
+			audit(c, db.AuditUpdate, g.ID, &before, &g)
+			if err := reloadAllServers(); err != nil {
+				// 还原全局配置, 已经加载了新配置的服务重新加载原配置
+				if rerr := db.UpdateGlobalConfig(&before); rerr != nil {
+					logs.Error("还原全局配置失败: %v", rerr)
+				} else {
+					audit(c, db.AuditUpdate, g.ID, &g, &before)
+					go reloadAllServers()
+				}
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
 			}
 			c.Status(200)
 		})
-		auth.GET("/notification", func(c *gin.Context) {
+		admin.GET("/notification", func(c *gin.Context) {
 			g, err := db.GetGlobalConfig()
 			if err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
@@ -162,7 +217,7 @@ func Start(port int) {
 				"ContentKey":  g.NotifyContentKey,
 			})
 		})
-		auth.PUT("/notification", func(c *gin.Context) {
+		admin.PUT("/notification", func(c *gin.Context) {
 			var body struct {
 				Enable      bool
 				Url         string
@@ -180,6 +235,7 @@ func Start(port int) {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			before := g
 			g.NotifyEnable = body.Enable
 			g.NotifyUrl = body.Url
 			g.NotifyMethod = body.Method
@@ -190,9 +246,10 @@ func Start(port int) {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			audit(c, db.AuditUpdate, g.ID, &before, &g)
 			c.Status(200)
 		})
-		auth.POST("/notification/test", func(c *gin.Context) {
+		admin.POST("/notification/test", func(c *gin.Context) {
 			var body struct {
 				Title string
 				Text  string
@@ -227,11 +284,17 @@ func Start(port int) {
 		})
 		auth.GET("/servers", func(c *gin.Context) {
 			servers, _ := db.GetServers()
+			if !canViewSecrets(c) {
+				// 只能查看服务状态, 隐藏密钥
+				for i := range servers {
+					servers[i].EmbyToken, servers[i].OpenlistToken = "", ""
+				}
+			}
 			c.JSON(200, servers)
 		})
 
 		// Notifications list CRUD
-		auth.GET("/notifications", func(c *gin.Context) {
+		admin.GET("/notifications", func(c *gin.Context) {
 			list, err := db.GetNotifies()
 			if err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
@@ -239,7 +302,7 @@ func Start(port int) {
 			}
 			c.JSON(200, list)
 		})
-		auth.POST("/notifications", func(c *gin.Context) {
+		admin.POST("/notifications", func(c *gin.Context) {
 			var n db.Notify
 			if err := c.ShouldBindJSON(&n); err != nil {
 				c.JSON(400, gin.H{"error": err.Error()})
@@ -249,9 +312,10 @@ func Start(port int) {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			audit(c, db.AuditCreate, n.ID, nil, &n)
 			c.Status(200)
 		})
-		auth.PUT("/notifications/:id", func(c *gin.Context) {
+		admin.PUT("/notifications/:id", func(c *gin.Context) {
 			var n db.Notify
 			if err := c.ShouldBindJSON(&n); err != nil {
 				c.JSON(400, gin.H{"error": err.Error()})
@@ -259,21 +323,27 @@ func Start(port int) {
 			}
 			id, _ := strconv.Atoi(c.Param("id"))
 			n.ID = uint(id)
+			before, _ := db.GetNotify(n.ID)
 			if err := db.UpdateNotify(&n); err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			audit(c, db.AuditUpdate, n.ID, before, &n)
 			c.Status(200)
 		})
-		auth.DELETE("/notifications/:id", func(c *gin.Context) {
+		admin.DELETE("/notifications/:id", func(c *gin.Context) {
 			id, _ := strconv.Atoi(c.Param("id"))
+			before, _ := db.GetNotify(uint(id))
 			if err := db.DeleteNotify(uint(id)); err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			if before != nil {
+				audit(c, db.AuditDelete, before.ID, before, nil)
+			}
 			c.Status(200)
 		})
-		auth.POST("/notifications/test", func(c *gin.Context) {
+		admin.POST("/notifications/test", func(c *gin.Context) {
 			var body db.Notify
 			if err := c.ShouldBindJSON(&body); err != nil {
 				c.JSON(400, gin.H{"error": err.Error()})
@@ -328,7 +398,142 @@ func Start(port int) {
 			c.Status(200)
 		})
 
-		auth.POST("/servers", func(c *gin.Context) {
+		// Device profiles CRUD
+		admin.GET("/device-profiles", func(c *gin.Context) {
+			list, err := db.GetDeviceProfiles()
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, list)
+		})
+		admin.POST("/device-profiles", func(c *gin.Context) {
+			var p db.DeviceProfile
+			if err := c.ShouldBindJSON(&p); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateDeviceProfile(p); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := db.AddDeviceProfile(&p); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+		admin.PUT("/device-profiles/:id", func(c *gin.Context) {
+			var p db.DeviceProfile
+			if err := c.ShouldBindJSON(&p); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateDeviceProfile(p); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			id, _ := strconv.Atoi(c.Param("id"))
+			p.ID = uint(id)
+			if err := db.UpdateDeviceProfile(&p); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+		admin.DELETE("/device-profiles/:id", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			if err := db.DeleteDeviceProfile(uint(id)); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+
+		// Skip markers CRUD
+		admin.GET("/skip-markers", func(c *gin.Context) {
+			list, err := db.GetSkipMarkers()
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, list)
+		})
+		admin.POST("/skip-markers", func(c *gin.Context) {
+			var m db.SkipMarker
+			if err := c.ShouldBindJSON(&m); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateSkipMarker(m); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := db.AddSkipMarker(&m); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+		admin.PUT("/skip-markers/:id", func(c *gin.Context) {
+			var m db.SkipMarker
+			if err := c.ShouldBindJSON(&m); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateSkipMarker(m); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			id, _ := strconv.Atoi(c.Param("id"))
+			m.ID = uint(id)
+			if err := db.UpdateSkipMarker(&m); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+		admin.DELETE("/skip-markers/:id", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			if err := db.DeleteSkipMarker(uint(id)); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			go reloadAllServers()
+			c.Status(200)
+		})
+		// 解析章节文件, 识别片头片尾时间
+		admin.POST("/skip-markers/parse-chapters", func(c *gin.Context) {
+			var body struct {
+				Content string `json:"Content"`
+			}
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			chapters, err := chapter.Parse(body.Content)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			markers, ok := chapter.DetectMarkers(chapters)
+			if !ok {
+				c.JSON(400, gin.H{"error": "章节名称中没有识别到片头或片尾"})
+				return
+			}
+			c.JSON(200, gin.H{
+				"IntroStart":   int(markers.IntroStart.Seconds()),
+				"IntroEnd":     int(markers.IntroEnd.Seconds()),
+				"CreditsStart": int(markers.CreditsStart.Seconds()),
+			})
+		})
+
+		admin.POST("/servers", func(c *gin.Context) {
 			var s db.EmbyServer
 			if err := c.ShouldBindJSON(&s); err != nil {
 				c.JSON(400, gin.H{"error": err.Error()})
@@ -338,11 +543,26 @@ func Start(port int) {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
+			audit(c, db.AuditCreate, s.ID, nil, &s)
 			manager.Start(s)
 			c.Status(200)
 		})
 
-		auth.PUT("/servers/:id", func(c *gin.Context) {
+		admin.POST("/servers/validate", func(c *gin.Context) {
+			var s db.EmbyServer
+			if err := c.ShouldBindJSON(&s); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			res, err := manager.CheckServer(s)
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, gin.H{"valid": !res.HasError(), "items": res.Items})
+		})
+
+		admin.PUT("/servers/:id", func(c *gin.Context) {
 			id, _ := strconv.Atoi(c.Param("id"))
 			var s db.EmbyServer
 			if err := c.ShouldBindJSON(&s); err != nil {
@@ -350,21 +570,112 @@ func Start(port int) {
 				return
 			}
 			s.ID = uint(id)
+			before, _ := db.GetServer(s.ID)
 			if err := db.UpdateServer(&s); err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
-			manager.Restart(uint(id))
+			audit(c, db.AuditUpdate, s.ID, before, &s)
+			if err := manager.Reload(s.ID); err != nil {
+				if !errors.Is(err, manager.ErrConfigRejected) {
+					c.JSON(500, gin.H{"error": err.Error()})
+					return
+				}
+				// 还原为修改前的配置, 避免下次启动时加载校验失败的配置
+				if before != nil {
+					if rerr := db.UpdateServer(before); rerr != nil {
+						logs.Error("[%s] 还原服务器配置失败: %v", s.Name, rerr)
+					} else {
+						audit(c, db.AuditUpdate, s.ID, &s, before)
+					}
+				}
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
 			c.Status(200)
 		})
 
-		auth.DELETE("/servers/:id", func(c *gin.Context) {
+		admin.DELETE("/servers/:id", func(c *gin.Context) {
 			id, _ := strconv.Atoi(c.Param("id"))
+			before, _ := db.GetServer(uint(id))
 			manager.Stop(uint(id))
 			db.DeleteServer(uint(id))
+			if before != nil {
+				audit(c, db.AuditDelete, before.ID, before, nil)
+			}
 			c.Status(200)
 		})
 
+		operator.POST("/servers/:id/restart", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			s, err := db.GetServer(uint(id))
+			if err != nil {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			logs.Info("用户 %s 重启服务: %s", currentUser(c).Username, s.Name)
+			if err := manager.Restart(s.ID); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.Status(200)
+		})
+
+		auth.GET("/servers/:id/playlist-stats", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			stats, err := manager.PlaylistStats(uint(id))
+			if err != nil {
+				c.JSON(409, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, stats)
+		})
+
+		operator.POST("/servers/:id/sync", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			s, err := db.GetServer(uint(id))
+			if err != nil {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			if g, err := s.EffectiveConfig(); err != nil || !g.LTGEnable {
+				c.JSON(400, gin.H{"error": "本地目录树未启用"})
+				return
+			}
+			if err := manager.SendCommand(s.ID, events.TypeSyncLocalTree, struct{}{}); err != nil {
+				c.JSON(409, gin.H{"error": err.Error()})
+				return
+			}
+			logs.Info("用户 %s 触发本地目录树同步: %s", currentUser(c).Username, s.Name)
+			c.Status(200)
+		})
+
+		operator.POST("/servers/:id/trace-path", func(c *gin.Context) {
+			id, _ := strconv.Atoi(c.Param("id"))
+			s, err := db.GetServer(uint(id))
+			if err != nil {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			var body struct {
+				Input string // emby item id 或资源路径
+			}
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if body.Input = strings.TrimSpace(body.Input); body.Input == "" || len(body.Input) > 4096 {
+				c.JSON(400, gin.H{"error": "invalid item id or path"})
+				return
+			}
+			res, err := manager.TracePath(*s, body.Input)
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, res)
+		})
+
 		auth.GET("/logs", func(c *gin.Context) {
 			type LogLine struct {
 				Level   string `json:"Level"`
@@ -396,20 +707,46 @@ func Start(port int) {
 			c.JSON(200, list)
 		})
 
-		auth.POST("/user/password", func(c *gin.Context) {
-			var form struct {
-				Username    string
-				NewPassword string
-			}
-			if err := c.ShouldBindJSON(&form); err != nil {
-				c.JSON(400, gin.H{"error": err.Error()})
-				return
+		auth.GET("/user", func(c *gin.Context) {
+			user := currentUser(c)
+			c.JSON(200, gin.H{"username": user.Username, "role": user.Role, "provider": user.Provider, "totpEnabled": user.TOTPEnabled})
+		})
+		auth.POST("/user/password", handleChangePassword)
+		auth.POST("/user/totp/setup", handleSetupTOTP)
+		auth.POST("/user/totp/enable", handleEnableTOTP)
+		auth.POST("/user/totp/disable", handleDisableTOTP)
+		auth.GET("/tokens", handleListAPITokens)
+		auth.POST("/tokens", handleCreateAPIToken)
+		auth.DELETE("/tokens/:id", handleDeleteAPIToken)
+
+		// Users CRUD
+		admin.GET("/oidc-config", handleGetOIDCConfig)
+		admin.PUT("/oidc-config", handleUpdateOIDCConfig)
+		admin.GET("/users", handleListUsers)
+		admin.POST("/users", handleAddUser)
+		admin.PUT("/users/:id", handleUpdateUser)
+		admin.DELETE("/users/:id", handleDeleteUser)
+
+		// Backups
+		admin.GET("/backup/export", handleExportBackup)
+		admin.POST("/backup/import", handleImportBackup)
+		admin.GET("/backups", handleListBackups)
+		admin.POST("/backups", handleCreateBackup)
+		admin.GET("/backups/:name", handleDownloadBackup)
+		admin.POST("/backups/:name/restore", handleRestoreBackup)
+		admin.DELETE("/backups/:name", handleDeleteBackup)
+
+		admin.GET("/audit-logs", func(c *gin.Context) {
+			limit, _ := strconv.Atoi(c.DefaultQuery("limit", "200"))
+			if limit <= 0 || limit > 1000 {
+				limit = 200
 			}
-			if err := db.UpdatePassword(form.Username, form.NewPassword); err != nil {
+			list, err := db.GetAuditLogs(c.Query("target"), limit)
+			if err != nil {
 				c.JSON(500, gin.H{"error": err.Error()})
 				return
 			}
-			c.Status(200)
+			c.JSON(200, list)
 		})
 	}
 
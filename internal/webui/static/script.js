@@ -9,6 +9,8 @@ const translations = {
         password: "Password",
         newPassword: "New Password",
         newPassword: "New Password",
+        currentPassword: "Current Password",
+        passwordChanged: "Password updated, other sessions have been signed out",
         servers: "Emby Media Servers",
         addServer: "Add Emby Media Server",
         editServer: "Edit Emby Media Server",
@@ -26,6 +28,8 @@ const translations = {
         imagesQualityDesc: "Range 1–100; recommend 70–90",
         downloadStrategy: "Download strategy",
         downloadStrategyDesc: "403: disable; origin: proxy; direct: redirect",
+        watchSync: "Sync watched state across servers",
+        watchSyncDesc: "Match items by TMDB/IMDB/TVDB ids and users by name; requires Emby API key on every server",
         cacheEnable: "Enable cache",
         cacheExpired: "Cache expiration",
         cacheWhitelist: "Cache whitelist (regex per line)",
@@ -33,12 +37,25 @@ const translations = {
         vpEnable: "Video preview enable",
         vpContainers: "Video preview containers",
         vpIgnore: "Video preview ignore templates",
+        vpAdaptive: "Adaptive bitrate (multi-variant HLS)",
+        vpPlaylistCapacity: "Max playlists kept in memory",
+        vpPlaylistPersist: "Persist playlists to disk",
+        vpSegmentProxy: "Proxy HLS segments (retry on failure)",
+        subConvert: "Convert srt/ass/ssa subtitles to WebVTT",
+        subCharset: "Subtitle charset",
+        subOffset: "Subtitle offset (ms)",
+        progressThresholds: "Progress thresholds",
+        progressThresholdsDesc: "Each line: type=min-stopped,min-report (seconds); type is Movie, Episode, Audio... or default",
+        progressBackwardJump: "Suspicious backward jump (seconds, 0 = off)",
+        progressHistorySize: "Progress history per session",
         pathEmby2Openlist: "Path emby2openlist",
         logDisableColor: "Disable colored logs",
         strmPathMap: "STRM path-map",
         strmPathMapDesc: "Each line: from => to",
         ltgEnable: "Local tree gen enable",
         ltgFfmpeg: "FFmpeg enable",
+        ltgFfmpegFallback: "FFmpeg fallback (download ffmpeg)",
+        ltgFfmpegWorkers: "Concurrent ffmpeg processes",
         ltgVirtual: "Virtual containers",
         ltgStrm: "STRM containers",
         ltgMusic: "Music containers",
@@ -47,6 +64,7 @@ const translations = {
         ltgScanPrefixes: "Scan prefixes",
         ltgIgnoreContainers: "Ignore containers",
         ltgThreads: "Threads",
+        ltgSidecar: "Sync sidecars (nfo/artwork/lyrics)",
         sslEnable: "Enable HTTPS",
         sslSingle: "Single port",
         sslKey: "SSL Key",
@@ -56,6 +74,12 @@ const translations = {
         cancel: "Cancel",
         clear: "Clear",
         deleteConfirm: "Are you sure you want to delete this server?",
+        playlistStats: "Playlist cache stats",
+        playlistStatsSize: "Cached playlists",
+        playlistStatsHits: "Hits",
+        playlistStatsMisses: "Misses",
+        playlistStatsRefreshes: "Refreshes",
+        playlistStatsEvictions: "Evictions",
         port: "Go-Emby Port",
         mountPath: "Mount Path",
         mountPathDesc: "Multiple paths supported, separate by , or ;",
@@ -77,6 +101,100 @@ const translations = {
         errorLogs: "Error Logs",
         allLogs: "All Logs",
         allServers: "all",
+        auditLogs: "Audit Log",
+        allTargets: "All",
+        auditTime: "Time",
+        auditAction: "Action",
+        auditTarget: "Target",
+        auditChanges: "Changes",
+        webuiUsers: "WebUI Users",
+        role: "Role",
+        roleViewer: "Viewer (logs and status)",
+        roleOperator: "Operator (restart servers)",
+        roleAdmin: "Admin",
+        userPasswordDesc: "Leave empty to keep the current password when editing",
+        restart: "Restart",
+        restartConfirm: "Restart this server?",
+        deleteUserConfirm: "Delete this user?",
+        twoFactor: "Two-Factor Authentication",
+        totpCode: "Two-factor code",
+        totpSecret: "Secret",
+        totpSetupDesc: "Add this secret to an authenticator app, then enter the code it shows",
+        totpOpenApp: "Open in authenticator app",
+        totpEnable: "Enable",
+        totpConfirm: "Confirm",
+        totpDisable: "Disable",
+        totpEnabled: "Enabled, a code from your authenticator app is required at login",
+        totpDisabled: "Not enabled",
+        totpReset: "Reset two-factor authentication",
+        ssoManaged: "Signed in via single sign-on, password and two-factor authentication are managed by the identity provider",
+        sso: "Single Sign-On (OIDC)",
+        ssoEnable: "Enable single sign-on",
+        ssoName: "Button Name",
+        ssoRedirect: "Redirect URL",
+        ssoRedirectDesc: "Leave empty to use the current address + /api/login/oidc/callback",
+        ssoUsernameClaim: "Username Claim",
+        ssoRoleClaim: "Role Claim",
+        ssoRoleMapping: "Role Mapping",
+        ssoRoleMappingDesc: "One per line: claim value=viewer/operator/admin, the highest matched role wins",
+        ssoDefaultRole: "Default Role",
+        ssoDeny: "Deny login",
+        ssoLogin: "Sign in with",
+        sync: "Sync local tree",
+        syncStarted: "Sync started, check the logs for progress",
+        apiTokens: "API Tokens",
+        apiTokensDesc: "Use in scripts with the header Authorization: Bearer <token>, the token acts as you and is limited by your role and its scopes",
+        tokenName: "Token Name",
+        tokenScopes: "Scopes",
+        tokenExpiresDays: "Expires in (days, 0 = never)",
+        tokenCreate: "Create Token",
+        tokenCreated: "New token, copy it now, it will not be shown again",
+        tokenNever: "Never",
+        tokenExpires: "Expires",
+        tokenExpired: "Expired",
+        tokenLastUsed: "Last used",
+        tokenRevokeConfirm: "Revoke this token? Scripts using it will stop working",
+        pathTester: "Path Tester",
+        pathTesterHint: "Test how an Emby item or path is mapped to OpenList, using the saved configuration of the server. Paths are tried in order when redirecting, the first existing one is used.",
+        pathInputPlaceholder: "Emby item id or path, e.g. /mnt/cloud/movies/a.mkv",
+        pathTest: "Test",
+        pathTesting: "Testing...",
+        pathSteps: "Transformation steps",
+        pathCandidates: "OpenList fs/get results",
+        pathItem: "Item",
+        pathUsed: "Used for redirect",
+        pathNotFound: "None of the candidate paths exist in OpenList, check mount-path and emby2openlist mappings",
+        pathSource_mapped: "Mapped",
+        pathSource_range: "Root dir",
+        validate: "Validate",
+        validating: "Validating, this may take a few seconds...",
+        validateOk: "Validation passed, the configuration can be saved",
+        validateFailed: "Validation failed, the server will not work with this configuration",
+        serverOverrides: "Global config overrides",
+        serverOverridesDesc: "Tick a setting to override it for this server only, settings not ticked are inherited from the global config",
+        serverOverridesCount: "Overridden settings",
+        inherited: "Inherited",
+        overridden: "Overridden",
+        ovGroupLocalTree: "Local tree",
+        backups: "Backups",
+        backupEnable: "Scheduled backups",
+        backupInterval: "Backup interval (hours)",
+        backupRetention: "Scheduled backups to keep",
+        backupCreate: "Create Backup",
+        backupExport: "Export",
+        backupImport: "Import",
+        backupHint: "Backups contain servers, global config, notifications, device profiles, skip markers, custom JS/CSS and SSL certificates. WebUI users and tokens are not included. Restoring restarts all servers.",
+        backupEmpty: "No backups yet",
+        backupKind_auto: "Scheduled",
+        backupKind_manual: "Manual",
+        "backupKind_pre-restore": "Before restore",
+        backupRestoreConfirm: "Restore this backup? The current configuration will be replaced and all servers restarted. A backup of the current configuration is created first.",
+        backupDeleteConfirm: "Delete this backup?",
+        backupRestored: "Backup restored, servers are restarting",
+        "scope_servers:read": "Read servers and global config",
+        "scope_servers:write": "Manage servers and global config, restart servers",
+        scope_sync: "Trigger local tree sync",
+        "scope_logs:read": "Read logs",
         autoFast: "Refresh Interval: 1s",
         autoNormal: "Refresh Interval: 3s",
         time: "Time",
@@ -87,7 +205,21 @@ const translations = {
         success: "Success",
         setupComplete: "Setup complete. Please login.",
         appTitle: "Go-Emby",
-        projectAddress: "Project Address (GitHub)"
+        projectAddress: "Project Address (GitHub)",
+        deviceProfiles: "Device Profiles",
+        profileName: "Name",
+        profileMatch: "Match keywords (X-Emby-Client / User-Agent, comma separated, empty = fallback)",
+        profileSort: "Sort (ascending)",
+        profilePayload: "PlaybackInfo payload (JSON)",
+        profileFallback: "Fallback",
+        skipMarkers: "Skip Markers",
+        markerSeries: "Series name or Emby series id",
+        markerSeason: "Season (0 = all seasons)",
+        markerIntroStart: "Intro start (seconds)",
+        markerIntroEnd: "Intro end (seconds, 0 = none)",
+        markerCreditsStart: "Credits start (seconds, negative = before the end, 0 = none)",
+        markerImport: "Import from chapter file (OGM / ffmetadata / Matroska XML)",
+        allSeasons: "All seasons"
     },
     zh: {
         login: "登录",
@@ -96,6 +228,8 @@ const translations = {
         password: "密码",
         newPassword: "新密码",
         newPassword: "新密码",
+        currentPassword: "当前密码",
+        passwordChanged: "密码已修改，其他登录会话已失效",
         servers: "Emby媒体服务器",
         addServer: "添加Emby媒体服务器",
         editServer: "编辑Emby媒体服务器",
@@ -112,6 +246,8 @@ const translations = {
         imagesQualityDesc: "范围 1–100；建议 70–90",
         downloadStrategy: "下载策略",
         downloadStrategyDesc: "403: 禁用；origin: 代理；direct: 重定向直链",
+        watchSync: "多服务器同步观看状态",
+        watchSyncDesc: "通过 TMDB/IMDB/TVDB 外部 ID 匹配媒体、通过用户名匹配用户；每个服务器都需要配置 Emby API Key",
         cacheEnable: "启用缓存",
         cacheExpired: "缓存过期时间",
         cacheWhitelist: "缓存白名单（每行一个正则）",
@@ -119,12 +255,25 @@ const translations = {
         vpEnable: "开启转码资源获取",
         vpContainers: "转码资源容器列表",
         vpIgnore: "忽略转码清晰度",
+        vpAdaptive: "自适应码率（多清晰度 HLS）",
+        vpPlaylistCapacity: "内存中最多维护的播放列表数",
+        vpPlaylistPersist: "播放列表持久化到磁盘",
+        vpSegmentProxy: "代理 ts 分片（失败自动重试）",
+        subConvert: "外挂 srt/ass/ssa 字幕转换为 WebVTT",
+        subCharset: "字幕编码",
+        subOffset: "字幕时间轴偏移（毫秒）",
+        progressThresholds: "播放进度阈值",
+        progressThresholdsDesc: "每行一个：类型=停止阈值,上报阈值（秒）；类型为 Movie、Episode、Audio 等，default 为兜底",
+        progressBackwardJump: "可疑进度回跳阈值（秒，0 表示关闭）",
+        progressHistorySize: "每个会话保留的进度记录数",
         pathEmby2Openlist: "挂载路径映射",
         logDisableColor: "禁用彩色日志",
         strmPathMap: "STRM 路径映射",
         strmPathMapDesc: "每行一个映射：from => to",
         ltgEnable: "开启本地目录树生成",
         ltgFfmpeg: "开启 FFmpeg 辅助",
+        ltgFfmpegFallback: "FFmpeg 回退（需下载 ffmpeg）",
+        ltgFfmpegWorkers: "同时运行的 ffmpeg 进程数",
         ltgVirtual: "虚拟容器",
         ltgStrm: "STRM 容器",
         ltgMusic: "音乐容器",
@@ -133,6 +282,7 @@ const translations = {
         ltgScanPrefixes: "扫描前缀",
         ltgIgnoreContainers: "忽略容器",
         ltgThreads: "线程数",
+        ltgSidecar: "同步刮削附属文件（nfo/海报/歌词）",
         sslEnable: "启用 HTTPS",
         sslSingle: "单一端口",
         sslKey: "私钥文件",
@@ -142,6 +292,12 @@ const translations = {
         cancel: "取消",
         clear: "清空",
         deleteConfirm: "确定要删除此服务器吗？",
+        playlistStats: "转码列表缓存统计",
+        playlistStatsSize: "缓存的列表数",
+        playlistStatsHits: "命中次数",
+        playlistStatsMisses: "未命中次数",
+        playlistStatsRefreshes: "刷新次数",
+        playlistStatsEvictions: "淘汰次数",
         port: "Go-Emby 端口",
         mountPath: "挂载路径",
         mountPathDesc: "支持多个路径，使用逗号或分号分隔",
@@ -163,6 +319,100 @@ const translations = {
         errorLogs: "错误日志",
         allLogs: "全部日志",
         allServers: "all",
+        auditLogs: "审计日志",
+        allTargets: "全部",
+        auditTime: "时间",
+        auditAction: "操作",
+        auditTarget: "对象",
+        auditChanges: "变更内容",
+        webuiUsers: "后台用户",
+        role: "角色",
+        roleViewer: "只读（查看日志和状态）",
+        roleOperator: "运维（可重启服务）",
+        roleAdmin: "管理员",
+        userPasswordDesc: "编辑时留空表示不修改密码",
+        restart: "重启",
+        restartConfirm: "确定要重启此服务吗？",
+        deleteUserConfirm: "确定要删除此用户吗？",
+        twoFactor: "两步验证",
+        totpCode: "两步验证码",
+        totpSecret: "密钥",
+        totpSetupDesc: "将密钥添加到验证器应用中，然后输入应用显示的验证码",
+        totpOpenApp: "在验证器应用中打开",
+        totpEnable: "启用",
+        totpConfirm: "确认",
+        totpDisable: "关闭",
+        totpEnabled: "已启用，登录时需要输入验证器应用中的验证码",
+        totpDisabled: "未启用",
+        totpReset: "重置两步验证",
+        ssoManaged: "当前用户通过单点登录登录，密码和两步验证由身份提供方管理",
+        sso: "单点登录 (OIDC)",
+        ssoEnable: "启用单点登录",
+        ssoName: "按钮名称",
+        ssoRedirect: "回调地址",
+        ssoRedirectDesc: "留空则使用当前访问地址 + /api/login/oidc/callback",
+        ssoUsernameClaim: "用户名声明",
+        ssoRoleClaim: "角色声明",
+        ssoRoleMapping: "角色映射",
+        ssoRoleMappingDesc: "每行一个：声明值=viewer/operator/admin，匹配多个时使用权限最高的角色",
+        ssoDefaultRole: "默认角色",
+        ssoDeny: "拒绝登录",
+        ssoLogin: "登录方式：",
+        sync: "同步本地目录树",
+        syncStarted: "已开始同步，可在日志中查看进度",
+        apiTokens: "API Token",
+        apiTokensDesc: "脚本中通过请求头 Authorization: Bearer <token> 使用，token 以你的身份访问接口，同时受你的角色和 token 权限范围限制",
+        tokenName: "名称",
+        tokenScopes: "权限范围",
+        tokenExpiresDays: "有效天数 (0 表示永不过期)",
+        tokenCreate: "创建 Token",
+        tokenCreated: "新的 Token，请立即复制，之后将无法再次查看",
+        tokenNever: "永不过期",
+        tokenExpires: "过期时间",
+        tokenExpired: "已过期",
+        tokenLastUsed: "最后使用",
+        tokenRevokeConfirm: "确定要撤销此 Token 吗？使用它的脚本将无法继续访问",
+        pathTester: "路径测试",
+        pathTesterHint: "使用服务器当前保存的配置，测试 Emby item 或资源路径如何转换为 OpenList 路径。重定向时按顺序尝试以下路径，使用第一个存在的路径。",
+        pathInputPlaceholder: "Emby item id 或资源路径，例如 /mnt/cloud/movies/a.mkv",
+        pathTest: "测试",
+        pathTesting: "正在测试...",
+        pathSteps: "转换步骤",
+        pathCandidates: "OpenList fs/get 结果",
+        pathItem: "Item",
+        pathUsed: "重定向时使用",
+        pathNotFound: "所有候选路径在 OpenList 中都不存在，请检查 mount-path 和 emby2openlist 映射",
+        pathSource_mapped: "转换结果",
+        pathSource_range: "遍历根目录",
+        validate: "校验",
+        validating: "正在校验，可能需要几秒钟...",
+        validateOk: "校验通过，可以保存该配置",
+        validateFailed: "校验失败，使用该配置服务将无法正常工作",
+        serverOverrides: "覆盖全局配置",
+        serverOverridesDesc: "勾选的配置只对该服务器生效，未勾选的配置继承全局配置",
+        serverOverridesCount: "覆盖的配置",
+        inherited: "继承",
+        overridden: "覆盖",
+        ovGroupLocalTree: "本地目录树",
+        backups: "备份",
+        backupEnable: "定时备份",
+        backupInterval: "备份间隔 (小时)",
+        backupRetention: "保留的定时备份数量",
+        backupCreate: "立即备份",
+        backupExport: "导出",
+        backupImport: "导入",
+        backupHint: "备份包含服务器、全局配置、通知、设备配置、片头片尾、自定义脚本样式和 SSL 证书，不包含 WebUI 用户和 Token。恢复备份会重启所有服务器。",
+        backupEmpty: "暂无备份",
+        backupKind_auto: "定时",
+        backupKind_manual: "手动",
+        "backupKind_pre-restore": "恢复前",
+        backupRestoreConfirm: "确定要恢复此备份吗？当前配置将被替换并重启所有服务器，恢复前会先自动备份当前配置。",
+        backupDeleteConfirm: "确定要删除此备份吗？",
+        backupRestored: "备份已恢复，服务器正在重启",
+        "scope_servers:read": "查看服务器和全局配置",
+        "scope_servers:write": "管理服务器和全局配置、重启服务器",
+        scope_sync: "触发本地目录树同步",
+        "scope_logs:read": "查看日志",
         autoFast: "刷新时间：1秒",
         autoNormal: "刷新时间：3秒",
         time: "时间",
@@ -183,7 +433,21 @@ const translations = {
         notifyMethod: "请求方法",
         notifyContentType: "请求体类型",
         notifyTitleKey: "标题参数名",
-        notifyContentKey: "内容参数名"
+        notifyContentKey: "内容参数名",
+        deviceProfiles: "设备配置",
+        profileName: "配置名称",
+        profileMatch: "匹配关键字 (X-Emby-Client / User-Agent, 逗号分隔, 留空为兜底配置)",
+        profileSort: "排序 (升序匹配)",
+        profilePayload: "PlaybackInfo 请求体 (JSON)",
+        profileFallback: "兜底",
+        skipMarkers: "片头片尾",
+        markerSeries: "剧集名称或 Emby 剧集 ID",
+        markerSeason: "季号 (0 表示所有季)",
+        markerIntroStart: "片头开始 (秒)",
+        markerIntroEnd: "片头结束 (秒, 0 表示不设置)",
+        markerCreditsStart: "片尾开始 (秒, 负数表示距离结尾, 0 表示不设置)",
+        markerImport: "从章节文件导入 (OGM / ffmetadata / Matroska XML)",
+        allSeasons: "所有季"
     }
 };
 
@@ -224,6 +488,7 @@ const pages = document.querySelectorAll('.page');
 // Init
 async function init() {
     applyTranslations();
+    consumeLoginHash();
     checkInit();
     if (authToken) {
         showDashboard();
@@ -232,6 +497,20 @@ async function init() {
     }
 }
 
+// consumeLoginHash 读取单点登录回调跳转时地址 fragment 中的会话 token 或错误信息
+function consumeLoginHash() {
+    const params = new URLSearchParams(location.hash.slice(1));
+    if (!params.has('token') && !params.has('sso-error')) return;
+    if (params.has('token')) {
+        authToken = params.get('token');
+        localStorage.setItem('token', authToken);
+    }
+    if (params.has('sso-error')) {
+        authError.textContent = params.get('sso-error');
+    }
+    history.replaceState(null, '', location.pathname + location.search);
+}
+
 function applyTranslations() {
     document.querySelectorAll('[data-t]').forEach(el => {
         const key = el.dataset.t;
@@ -249,6 +528,10 @@ async function checkInit() {
         const res = await fetch(`${API_BASE}/check-init`);
         const data = await res.json();
 
+        const ssoBtn = document.getElementById('sso-login-btn');
+        ssoBtn.classList.toggle('hidden', !data.oidc);
+        document.getElementById('sso-login-text').textContent = `${t('ssoLogin')} ${data.oidcName || 'SSO'}`;
+
         // Reset title to appTitle first (it's handled by applyTranslations usually, but good to be safe)
         // But since we use data-t="appTitle", applyTranslations handles it.
 
@@ -284,12 +567,13 @@ authForm.addEventListener('submit', async (e) => {
     e.preventDefault();
     const username = document.getElementById('auth-username').value;
     const password = document.getElementById('auth-password').value;
+    const codeInput = document.getElementById('auth-code');
     const mode = authForm.dataset.mode || 'login';
 
     try {
         const res = await fetch(`${API_BASE}/${mode}`, {
             method: 'POST',
-            body: JSON.stringify({ Username: username, Password: password }),
+            body: JSON.stringify({ Username: username, Password: password, Code: codeInput.value.trim() }),
             headers: { 'Content-Type': 'application/json' }
         });
 
@@ -306,13 +590,25 @@ authForm.addEventListener('submit', async (e) => {
         } else {
             const err = await res.json();
             authError.textContent = err.error || 'Authentication failed';
+            if (err.totpRequired) {
+                codeInput.classList.remove('hidden');
+                codeInput.value = '';
+                codeInput.focus();
+            }
         }
     } catch (e) {
         authError.textContent = t('networkError');
     }
 });
 
-logoutBtn.addEventListener('click', () => {
+document.getElementById('sso-login-btn').addEventListener('click', () => {
+    location.href = `${API_BASE}/login/oidc`;
+});
+
+logoutBtn.addEventListener('click', async () => {
+    if (authToken) {
+        await fetch(`${API_BASE}/logout`, { method: 'POST', headers: { 'Authorization': authToken } }).catch(() => {});
+    }
     authToken = null;
     localStorage.removeItem('token');
     showAuth();
@@ -321,13 +617,37 @@ logoutBtn.addEventListener('click', () => {
 function showAuth() {
     authScreen.classList.remove('hidden');
     dashboard.classList.add('hidden');
+    const codeInput = document.getElementById('auth-code');
+    codeInput.value = '';
+    codeInput.classList.add('hidden');
+    document.getElementById('token-value').value = '';
+    document.getElementById('token-created').classList.add('hidden');
+}
+
+// Roles
+const roleLevels = { viewer: 1, operator: 2, admin: 3 };
+let currentRole = 'viewer';
+function hasRole(role) {
+    return (roleLevels[currentRole] || 0) >= roleLevels[role];
+}
+function applyRoleVisibility() {
+    document.querySelectorAll('[data-role]').forEach(el => {
+        el.classList.toggle('hidden', !hasRole(el.dataset.role));
+    });
 }
 
-function showDashboard() {
+async function showDashboard() {
     authScreen.classList.add('hidden');
     dashboard.classList.remove('hidden');
+    const res = await fetchAuthenticated(`${API_BASE}/user`);
+    if (!res) return;
+    const user = await res.json().catch(() => ({}));
+    currentRole = user.role || 'viewer';
+    applyRoleVisibility();
     loadServers();
-    loadNotifies();
+    if (hasRole('admin')) {
+        loadNotifies();
+    }
 }
 
 // Navigation
@@ -354,6 +674,29 @@ menuItems.forEach(item => {
         if (target === 'notify-page') {
             loadNotifies();
         }
+        if (target === 'profiles-page') {
+            loadProfiles();
+        }
+        if (target === 'markers-page') {
+            loadMarkers();
+        }
+        if (target === 'users-page') {
+            loadCurrentUser();
+            loadAPITokens();
+            if (hasRole('admin')) {
+                loadUsers();
+                loadOIDCConfig();
+            }
+        }
+        if (target === 'path-page') {
+            updatePathServerOptions();
+        }
+        if (target === 'backups-page') {
+            loadBackups();
+        }
+        if (target === 'audit-page') {
+            loadAuditLogs();
+        }
     });
 });
 
@@ -499,9 +842,13 @@ function renderServers() {
             <div class="server-info"><i class="fa-solid fa-globe"></i> ${t('port')}: ${s.HTTPPort}</div>
             <div class="server-info"><i class="fa-solid fa-link"></i> ${s.EmbyHost}</div>
             <div class="server-info"><i class="fa-solid fa-folder"></i> ${s.MountPath}</div>
+            ${Object.keys(s.Overrides || {}).length ? `<div class="server-info"><i class="fa-solid fa-sliders"></i> ${t('serverOverridesCount')}: ${Object.keys(s.Overrides).length}</div>` : ''}
             <div class="server-actions">
-                <button class="btn btn-sm btn-secondary" onclick="editServer(${s.ID})"><i class="fa-solid fa-pen"></i></button>
-                <button class="btn btn-sm btn-danger" onclick="deleteServer(${s.ID})"><i class="fa-solid fa-trash"></i></button>
+                <button class="btn btn-sm btn-secondary" title="${t('playlistStats')}" onclick="showPlaylistStats(${s.ID})"><i class="fa-solid fa-chart-simple"></i></button>
+                ${hasRole('operator') ? `<button class="btn btn-sm btn-secondary" title="${t('restart')}" onclick="restartServer(${s.ID})"><i class="fa-solid fa-rotate-right"></i></button>
+                <button class="btn btn-sm btn-secondary" title="${t('sync')}" onclick="syncServer(${s.ID})"><i class="fa-solid fa-folder-tree"></i></button>` : ''}
+                ${hasRole('admin') ? `<button class="btn btn-sm btn-secondary" onclick="editServer(${s.ID})"><i class="fa-solid fa-pen"></i></button>
+                <button class="btn btn-sm btn-danger" onclick="deleteServer(${s.ID})"><i class="fa-solid fa-trash"></i></button>` : ''}
             </div>
         `;
         list.appendChild(card);
@@ -533,6 +880,7 @@ function showServerModal(id = null) {
             document.getElementById('server-dl-cache-unit').value = dl.replace(/\d+/,'') || 'm';
             document.getElementById('server-dl-cache-ignore').value = s.DirectLinkCacheIgnore || '';
             document.getElementById('server-dl-cache-mode').value = s.DirectLinkCacheIgnoreMode || '0';
+            renderServerOverrides(s.Overrides);
         }
     } else {
         document.getElementById('modal-title').textContent = t('addServer');
@@ -542,24 +890,165 @@ function showServerModal(id = null) {
         document.getElementById('server-dl-cache-mode').value = '0';
         // Explicitly set checkboxes to false
         document.getElementById('server-internal-redirect').checked = false;
+        renderServerOverrides();
+    }
+}
+
+// 可以按服务器覆盖的全局配置, key 与全局配置的字段名称一致
+const SERVER_OVERRIDES = [
+    { key: 'EpisodesUnplayPrior', label: 'episodesUnplayPrior', type: 'bool' },
+    { key: 'ProxyErrorStrategy', label: 'proxyErrorStrategy', type: 'select', options: ['origin', 'reject'] },
+    { key: 'DownloadStrategy', label: 'downloadStrategy', type: 'select', options: ['403', 'origin', 'direct'] },
+    { key: 'CacheEnable', label: 'cacheEnable', type: 'bool' },
+    { key: 'VideoPreviewEnable', label: 'vpEnable', type: 'bool' },
+    { key: 'VideoPreviewContainers', label: 'vpContainers', type: 'text' },
+    { key: 'VideoPreviewIgnoreTemplateIds', label: 'vpIgnore', type: 'text' },
+    { key: 'VideoPreviewAdaptive', label: 'vpAdaptive', type: 'bool' },
+    { key: 'VideoPreviewPlaylistCapacity', label: 'vpPlaylistCapacity', type: 'number' },
+    { key: 'VideoPreviewPlaylistPersist', label: 'vpPlaylistPersist', type: 'bool' },
+    { key: 'VideoPreviewSegmentProxy', label: 'vpSegmentProxy', type: 'bool' },
+    { key: 'PathEmby2Openlist', label: 'pathEmby2Openlist', type: 'lines' },
+    { key: 'LTGEnable', label: 'ltgEnable', type: 'bool', group: 'ovGroupLocalTree' },
+    { key: 'LTGFFmpegEnable', label: 'ltgFfmpeg', type: 'bool', group: 'ovGroupLocalTree' },
+    { key: 'LTGFFmpegFallback', label: 'ltgFfmpegFallback', type: 'bool', group: 'ovGroupLocalTree' },
+    { key: 'LTGFFmpegWorkers', label: 'ltgFfmpegWorkers', type: 'number', group: 'ovGroupLocalTree' },
+    { key: 'LTGVirtualContainers', label: 'ltgVirtual', type: 'text', group: 'ovGroupLocalTree' },
+    { key: 'LTGStrmContainers', label: 'ltgStrm', type: 'text', group: 'ovGroupLocalTree' },
+    { key: 'LTGMusicContainers', label: 'ltgMusic', type: 'text', group: 'ovGroupLocalTree' },
+    { key: 'LTGAutoRemoveMaxCount', label: 'ltgAutoRemove', type: 'number', group: 'ovGroupLocalTree' },
+    { key: 'LTGRefreshInterval', label: 'ltgRefresh', type: 'number', group: 'ovGroupLocalTree' },
+    { key: 'LTGScanPrefixes', label: 'ltgScanPrefixes', type: 'lines', group: 'ovGroupLocalTree' },
+    { key: 'LTGIgnoreContainers', label: 'ltgIgnoreContainers', type: 'text', group: 'ovGroupLocalTree' },
+    { key: 'LTGThreads', label: 'ltgThreads', type: 'number', group: 'ovGroupLocalTree' },
+    { key: 'LTGSidecarEnable', label: 'ltgSidecar', type: 'bool', group: 'ovGroupLocalTree' },
+];
+
+function overrideInput(o) {
+    switch (o.type) {
+        case 'bool': return '<input type="checkbox" class="override-input">';
+        case 'select': return `<select class="override-input">${o.options.map(v => `<option value="${v}">${v}</option>`).join('')}</select>`;
+        case 'number': return '<input type="number" class="override-input">';
+        case 'lines': return '<textarea class="override-input" style="min-height:60px"></textarea>';
+        default: return '<input type="text" class="override-input">';
+    }
+}
+
+function setOverrideValue(row, o, value) {
+    const input = row.querySelector('.override-input');
+    if (o.type === 'bool') {
+        input.checked = !!value;
+    } else {
+        input.value = value ?? '';
     }
 }
 
+function updateOverrideState(row, o, globals) {
+    const overridden = row.querySelector('.override-toggle').checked;
+    row.querySelector('.override-input').disabled = !overridden;
+    row.querySelector('.override-state').textContent = t(overridden ? 'overridden' : 'inherited');
+    row.classList.toggle('overridden', overridden);
+    if (!overridden) setOverrideValue(row, o, globals[o.key]);
+    const count = document.querySelectorAll('#server-overrides .override-toggle:checked').length;
+    document.getElementById('server-overrides-count').textContent = count || '';
+}
+
+// renderServerOverrides 显示服务器覆盖的配置, 未覆盖的配置显示当前的全局配置
+async function renderServerOverrides(overrides = {}) {
+    const box = document.getElementById('server-overrides');
+    box.innerHTML = '';
+    document.getElementById('server-overrides-count').textContent = '';
+    const res = await fetchAuthenticated(`${API_BASE}/global-config`);
+    const globals = res && res.ok ? await res.json() : {};
+    overrides = overrides || {};
+    SERVER_OVERRIDES.forEach(o => {
+        const row = document.createElement('div');
+        row.className = 'override-row';
+        row.dataset.key = o.key;
+        row.innerHTML = `
+            <input type="checkbox" class="override-toggle" id="override-${o.key}">
+            <label for="override-${o.key}">${o.group ? `${t(o.group)}: ` : ''}${t(o.label)}</label>
+            <div class="override-value">${overrideInput(o)}</div>
+            <span class="badge override-state"></span>
+        `;
+        const toggle = row.querySelector('.override-toggle');
+        toggle.checked = o.key in overrides;
+        toggle.addEventListener('change', () => updateOverrideState(row, o, globals));
+        box.appendChild(row);
+        setOverrideValue(row, o, toggle.checked ? overrides[o.key] : globals[o.key]);
+        updateOverrideState(row, o, globals);
+    });
+}
+
+// serverOverridesData 获取勾选覆盖的配置
+function serverOverridesData() {
+    const out = {};
+    SERVER_OVERRIDES.forEach(o => {
+        const row = document.querySelector(`#server-overrides .override-row[data-key="${o.key}"]`);
+        if (!row || !row.querySelector('.override-toggle').checked) return;
+        const input = row.querySelector('.override-input');
+        if (o.type === 'bool') {
+            out[o.key] = input.checked;
+        } else if (o.type === 'number') {
+            out[o.key] = parseInt(input.value || '0');
+        } else {
+            out[o.key] = input.value.trim();
+        }
+    });
+    return out;
+}
+
 function closeServerModal() {
     document.getElementById('server-modal').classList.remove('active');
+    document.getElementById('server-check').classList.add('hidden');
 }
 
 window.editServer = showServerModal;
+window.restartServer = async (id) => {
+    if (!confirm(t('restartConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/servers/${id}/restart`, { method: 'POST' });
+    if (res && res.ok) {
+        alert(t('success'));
+    } else if (res) {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+};
+
+window.showPlaylistStats = async (id) => {
+    const res = await fetchAuthenticated(`${API_BASE}/servers/${id}/playlist-stats`);
+    if (!res) return;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+        alert(data.error || t('networkError'));
+        return;
+    }
+    alert([
+        `${t('playlistStatsSize')}: ${data.size} / ${data.capacity}`,
+        `${t('playlistStatsHits')}: ${data.hits}`,
+        `${t('playlistStatsMisses')}: ${data.misses}`,
+        `${t('playlistStatsRefreshes')}: ${data.refreshes}`,
+        `${t('playlistStatsEvictions')}: ${data.evictions}`,
+    ].join('\n'));
+};
+
+window.syncServer = async (id) => {
+    const res = await fetchAuthenticated(`${API_BASE}/servers/${id}/sync`, { method: 'POST' });
+    if (res && res.ok) {
+        alert(t('syncStarted'));
+    } else if (res) {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+};
+
 window.deleteServer = async (id) => {
     if (!confirm(t('deleteConfirm'))) return;
     await fetchAuthenticated(`${API_BASE}/servers/${id}`, { method: 'DELETE' });
     loadServers();
 };
 
-document.getElementById('server-form').addEventListener('submit', async (e) => {
-    e.preventDefault();
-    const id = document.getElementById('server-id').value;
-    const data = {
+function serverFormData() {
+    return {
         Name: document.getElementById('server-name').value,
         HTTPPort: parseInt(document.getElementById('server-port').value),
         EmbyHost: document.getElementById('server-emby-host').value,
@@ -572,8 +1061,46 @@ document.getElementById('server-form').addEventListener('submit', async (e) => {
         DirectLinkCacheExpired: `${document.getElementById('server-dl-cache-value').value}${document.getElementById('server-dl-cache-unit').value}`,
         DirectLinkCacheIgnore: document.getElementById('server-dl-cache-ignore').value,
         DirectLinkCacheIgnoreMode: parseInt(document.getElementById('server-dl-cache-mode').value || '0'),
+        Overrides: serverOverridesData(),
         DisableProxy: false
     };
+}
+
+window.validateServer = async () => {
+    const box = document.getElementById('server-check');
+    const btn = document.getElementById('server-validate');
+    const data = serverFormData();
+    data.ID = parseInt(document.getElementById('server-id').value || '0');
+    box.classList.remove('hidden');
+    box.innerHTML = `<div class="check-item">${t('validating')}</div>`;
+    btn.disabled = true;
+    try {
+        const res = await fetchAuthenticated(`${API_BASE}/servers/validate`, {
+            method: 'POST',
+            body: JSON.stringify(data),
+            headers: { 'Content-Type': 'application/json' }
+        });
+        if (!res) return;
+        const result = await res.json().catch(() => ({}));
+        if (!res.ok) {
+            box.innerHTML = `<div class="check-item check-error">${escapeHtml(result.error || t('networkError'))}</div>`;
+            return;
+        }
+        const icons = { ok: 'fa-circle-check', warn: 'fa-triangle-exclamation', error: 'fa-circle-xmark' };
+        box.innerHTML = `<div class="check-item ${result.valid ? 'check-ok' : 'check-error'}"><strong>${t(result.valid ? 'validateOk' : 'validateFailed')}</strong></div>` +
+            (result.items || []).map(it => `
+                <div class="check-item check-${escapeHtml(it.level)}">
+                    <i class="fa-solid ${icons[it.level] || 'fa-circle-info'}"></i> <code>${escapeHtml(it.name)}</code> ${escapeHtml(it.message)}
+                </div>`).join('');
+    } finally {
+        btn.disabled = false;
+    }
+};
+
+document.getElementById('server-form').addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const id = document.getElementById('server-id').value;
+    const data = serverFormData();
 
     const method = id ? 'PUT' : 'POST';
     const url = id ? `${API_BASE}/servers/${id}` : `${API_BASE}/servers`;
@@ -585,6 +1112,10 @@ document.getElementById('server-form').addEventListener('submit', async (e) => {
     });
 
     if (res) {
+        if (!res.ok) {
+            const result = await res.json().catch(() => ({}));
+            alert(result.error || t('networkError'));
+        }
         closeServerModal();
         loadServers();
     }
@@ -620,6 +1151,207 @@ window.deleteNotify = async (id) => {
     }
 };
 
+// Device Profiles
+let deviceProfiles = [];
+async function loadProfiles() {
+    try {
+        const res = await fetchAuthenticated(`${API_BASE}/device-profiles`);
+        if (!res) return;
+        deviceProfiles = await res.json();
+    } catch (e) {
+        deviceProfiles = [];
+    }
+    renderProfiles();
+}
+function renderProfiles() {
+    const container = document.getElementById('profile-list');
+    if (!container) return;
+    container.innerHTML = '';
+    (deviceProfiles || []).forEach(p => {
+        const card = document.createElement('div');
+        card.className = 'card server-card';
+        card.innerHTML = `
+            <h3>${p.Name}</h3>
+            <div class="server-info"><i class="fa-solid fa-magnifying-glass"></i> ${p.Match || t('profileFallback')}</div>
+            <div class="server-info"><i class="fa-solid fa-arrow-down-1-9"></i> ${p.Sort}</div>
+            <div class="server-actions">
+                <button class="btn btn-sm btn-secondary" onclick="editProfile(${p.ID})"><i class="fa-solid fa-pen"></i></button>
+                <button class="btn btn-sm btn-danger" onclick="deleteProfile(${p.ID})"><i class="fa-solid fa-trash"></i></button>
+            </div>
+        `;
+        container.appendChild(card);
+    });
+}
+function showProfileModal() {
+    document.getElementById('pm-id').value = '';
+    document.getElementById('pm-name').value = '';
+    document.getElementById('pm-match').value = '';
+    document.getElementById('pm-sort').value = '0';
+    document.getElementById('pm-payload').value = '';
+    document.getElementById('profile-modal').classList.remove('hidden');
+}
+function closeProfileModal() {
+    document.getElementById('profile-modal').classList.add('hidden');
+}
+document.getElementById('profile-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const body = {
+        ID: parseInt(document.getElementById('pm-id').value || '0'),
+        Name: document.getElementById('pm-name').value.trim(),
+        Match: document.getElementById('pm-match').value.trim(),
+        Sort: parseInt(document.getElementById('pm-sort').value || '0'),
+        Payload: document.getElementById('pm-payload').value.trim(),
+    };
+    const isEdit = body.ID > 0;
+    const url = isEdit ? `${API_BASE}/device-profiles/${body.ID}` : `${API_BASE}/device-profiles`;
+    const res = await fetchAuthenticated(url, { method: isEdit ? 'PUT' : 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(body) });
+    if (!res) return;
+    if (res.ok) {
+        closeProfileModal();
+        loadProfiles();
+        alert(t('success'));
+    } else {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+});
+window.showProfileModal = showProfileModal;
+window.closeProfileModal = closeProfileModal;
+window.editProfile = (id) => {
+    const p = (deviceProfiles || []).find(x => x.ID === id);
+    if (!p) return;
+    document.getElementById('pm-id').value = p.ID;
+    document.getElementById('pm-name').value = p.Name || '';
+    document.getElementById('pm-match').value = p.Match || '';
+    document.getElementById('pm-sort').value = p.Sort || 0;
+    try {
+        document.getElementById('pm-payload').value = JSON.stringify(JSON.parse(p.Payload), null, 2);
+    } catch (e) {
+        document.getElementById('pm-payload').value = p.Payload || '';
+    }
+    document.getElementById('profile-modal').classList.remove('hidden');
+};
+window.deleteProfile = async (id) => {
+    if (!confirm(t('deleteConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/device-profiles/${id}`, { method: 'DELETE' });
+    if (res && res.ok) {
+        loadProfiles();
+    }
+};
+
+// Skip Markers
+let skipMarkers = [];
+function formatMarkerTime(sec) {
+    if (!sec) return '-';
+    const sign = sec < 0 ? '-' : '';
+    const abs = Math.abs(sec);
+    const m = Math.floor(abs / 60);
+    const s = String(abs % 60).padStart(2, '0');
+    return `${sign}${m}:${s}`;
+}
+async function loadMarkers() {
+    try {
+        const res = await fetchAuthenticated(`${API_BASE}/skip-markers`);
+        if (!res) return;
+        skipMarkers = await res.json();
+    } catch (e) {
+        skipMarkers = [];
+    }
+    renderMarkers();
+}
+function renderMarkers() {
+    const container = document.getElementById('marker-list');
+    if (!container) return;
+    container.innerHTML = '';
+    (skipMarkers || []).forEach(m => {
+        const card = document.createElement('div');
+        card.className = 'card server-card';
+        card.innerHTML = `
+            <h3>${m.Series} ${m.Season > 0 ? 'S' + m.Season : '(' + t('allSeasons') + ')'}</h3>
+            <div class="server-info"><i class="fa-solid fa-forward"></i> ${formatMarkerTime(m.IntroStart)} ~ ${formatMarkerTime(m.IntroEnd)}</div>
+            <div class="server-info"><i class="fa-solid fa-flag-checkered"></i> ${formatMarkerTime(m.CreditsStart)}</div>
+            <div class="server-actions">
+                <button class="btn btn-sm btn-secondary" onclick="editMarker(${m.ID})"><i class="fa-solid fa-pen"></i></button>
+                <button class="btn btn-sm btn-danger" onclick="deleteMarker(${m.ID})"><i class="fa-solid fa-trash"></i></button>
+            </div>
+        `;
+        container.appendChild(card);
+    });
+}
+function fillMarkerForm(m) {
+    document.getElementById('mm-id').value = m.ID || '';
+    document.getElementById('mm-series').value = m.Series || '';
+    document.getElementById('mm-season').value = m.Season || 0;
+    document.getElementById('mm-intro-start').value = m.IntroStart || 0;
+    document.getElementById('mm-intro-end').value = m.IntroEnd || 0;
+    document.getElementById('mm-credits-start').value = m.CreditsStart || 0;
+    document.getElementById('mm-chapter-file').value = '';
+}
+function showMarkerModal() {
+    fillMarkerForm({});
+    document.getElementById('marker-modal').classList.remove('hidden');
+}
+function closeMarkerModal() {
+    document.getElementById('marker-modal').classList.add('hidden');
+}
+document.getElementById('mm-chapter-file')?.addEventListener('change', async (e) => {
+    const file = e.target.files && e.target.files[0];
+    if (!file) return;
+    const content = await file.text();
+    const res = await fetchAuthenticated(`${API_BASE}/skip-markers/parse-chapters`, {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({ Content: content }),
+    });
+    if (!res) return;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+        alert(data.error || t('networkError'));
+        return;
+    }
+    document.getElementById('mm-intro-start').value = data.IntroStart || 0;
+    document.getElementById('mm-intro-end').value = data.IntroEnd || 0;
+    document.getElementById('mm-credits-start').value = data.CreditsStart || 0;
+});
+document.getElementById('marker-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const body = {
+        ID: parseInt(document.getElementById('mm-id').value || '0'),
+        Series: document.getElementById('mm-series').value.trim(),
+        Season: parseInt(document.getElementById('mm-season').value || '0'),
+        IntroStart: parseInt(document.getElementById('mm-intro-start').value || '0'),
+        IntroEnd: parseInt(document.getElementById('mm-intro-end').value || '0'),
+        CreditsStart: parseInt(document.getElementById('mm-credits-start').value || '0'),
+    };
+    const isEdit = body.ID > 0;
+    const url = isEdit ? `${API_BASE}/skip-markers/${body.ID}` : `${API_BASE}/skip-markers`;
+    const res = await fetchAuthenticated(url, { method: isEdit ? 'PUT' : 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(body) });
+    if (!res) return;
+    if (res.ok) {
+        closeMarkerModal();
+        loadMarkers();
+        alert(t('success'));
+    } else {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+});
+window.showMarkerModal = showMarkerModal;
+window.closeMarkerModal = closeMarkerModal;
+window.editMarker = (id) => {
+    const m = (skipMarkers || []).find(x => x.ID === id);
+    if (!m) return;
+    fillMarkerForm(m);
+    document.getElementById('marker-modal').classList.remove('hidden');
+};
+window.deleteMarker = async (id) => {
+    if (!confirm(t('deleteConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/skip-markers/${id}`, { method: 'DELETE' });
+    if (res && res.ok) {
+        loadMarkers();
+    }
+};
+
 // Logs
 function updateLogServerFilterOptions() {
     const serverSelect = document.getElementById('log-server-filter');
@@ -774,18 +1506,31 @@ async function loadGlobalConfig() {
     document.getElementById('g-proxy').value = g.ProxyErrorStrategy || 'origin';
     document.getElementById('g-images').value = g.ImagesQuality || 100;
     document.getElementById('g-download').value = g.DownloadStrategy || '403';
+    document.getElementById('g-watch-sync').checked = !!g.WatchSyncEnable;
     document.getElementById('g-cache-enable').checked = !!g.CacheEnable;
     document.getElementById('g-cache-expired').value = g.CacheExpired || '1d';
     document.getElementById('g-cache-whitelist').value = g.CacheWhiteList || '';
     document.getElementById('g-vp-enable').checked = !!g.VideoPreviewEnable;
     document.getElementById('g-vp-containers').value = g.VideoPreviewContainers || 'mp4,mkv';
     document.getElementById('g-vp-ignore').value = g.VideoPreviewIgnoreTemplateIds || 'LD,SD';
+    document.getElementById('g-vp-adaptive').checked = !!g.VideoPreviewAdaptive;
+    document.getElementById('g-vp-playlist-capacity').value = g.VideoPreviewPlaylistCapacity || 10;
+    document.getElementById('g-vp-playlist-persist').checked = !!g.VideoPreviewPlaylistPersist;
+    document.getElementById('g-vp-segment-proxy').checked = !!g.VideoPreviewSegmentProxy;
+    document.getElementById('g-sub-convert').checked = !!g.SubtitleConvert;
+    document.getElementById('g-sub-charset').value = g.SubtitleCharset || 'auto';
+    document.getElementById('g-sub-offset').value = g.SubtitleOffset || 0;
+    document.getElementById('g-progress-thresholds').value = g.ProgressThresholds || 'default=300,1';
+    document.getElementById('g-progress-backward-jump').value = g.ProgressBackwardJump || 0;
+    document.getElementById('g-progress-history-size').value = g.ProgressHistorySize || 10;
     document.getElementById('g-path').value = (g.PathEmby2Openlist || '').replace(/,/g, '\n');
     document.getElementById('g-log-disable').checked = !!g.LogDisableColor;
     document.getElementById('config-page').dataset.gid = g.ID;
     document.getElementById('g-strm-path').value = (g.StrmPathMap || '');
     document.getElementById('g-ltg-enable').checked = !!g.LTGEnable;
     document.getElementById('g-ltg-ffmpeg').checked = !!g.LTGFFmpegEnable;
+    document.getElementById('g-ltg-ffmpeg-fallback').checked = !!g.LTGFFmpegFallback;
+    document.getElementById('g-ltg-ffmpeg-workers').value = g.LTGFFmpegWorkers || 1;
     document.getElementById('g-ltg-virtual').value = g.LTGVirtualContainers || 'mp4,mkv';
     document.getElementById('g-ltg-strm').value = g.LTGStrmContainers || 'ts';
     document.getElementById('g-ltg-music').value = g.LTGMusicContainers || 'mp3,flac';
@@ -794,10 +1539,14 @@ async function loadGlobalConfig() {
     document.getElementById('g-ltg-scan').value = (g.LTGScanPrefixes || '');
     document.getElementById('g-ltg-ignore').value = g.LTGIgnoreContainers || 'jpg,jpeg,png,txt,nfo,md';
     document.getElementById('g-ltg-threads').value = g.LTGThreads || 8;
+    document.getElementById('g-ltg-sidecar').checked = !!g.LTGSidecarEnable;
     document.getElementById('g-ssl-enable').checked = !!g.SslEnable;
     document.getElementById('g-ssl-single').checked = !!g.SslSinglePort;
     document.getElementById('g-ssl-key').value = g.SslKey || '';
     document.getElementById('g-ssl-crt').value = g.SslCrt || '';
+    document.getElementById('g-backup-enable').checked = !!g.BackupEnable;
+    document.getElementById('g-backup-interval').value = g.BackupInterval || 24;
+    document.getElementById('g-backup-retention').value = g.BackupRetention || 7;
 }
 async function saveGlobalConfig() {
     const payload = {
@@ -807,18 +1556,31 @@ async function saveGlobalConfig() {
         ProxyErrorStrategy: document.getElementById('g-proxy').value,
         ImagesQuality: parseInt(document.getElementById('g-images').value || '100'),
         DownloadStrategy: document.getElementById('g-download').value,
+        WatchSyncEnable: document.getElementById('g-watch-sync').checked,
         CacheEnable: document.getElementById('g-cache-enable').checked,
         CacheExpired: document.getElementById('g-cache-expired').value,
         CacheWhiteList: document.getElementById('g-cache-whitelist').value.trim(),
         VideoPreviewEnable: document.getElementById('g-vp-enable').checked,
         VideoPreviewContainers: document.getElementById('g-vp-containers').value,
         VideoPreviewIgnoreTemplateIds: document.getElementById('g-vp-ignore').value,
+        VideoPreviewAdaptive: document.getElementById('g-vp-adaptive').checked,
+        VideoPreviewPlaylistCapacity: parseInt(document.getElementById('g-vp-playlist-capacity').value || '10'),
+        VideoPreviewPlaylistPersist: document.getElementById('g-vp-playlist-persist').checked,
+        VideoPreviewSegmentProxy: document.getElementById('g-vp-segment-proxy').checked,
+        SubtitleConvert: document.getElementById('g-sub-convert').checked,
+        SubtitleCharset: document.getElementById('g-sub-charset').value,
+        SubtitleOffset: parseInt(document.getElementById('g-sub-offset').value || '0'),
+        ProgressThresholds: document.getElementById('g-progress-thresholds').value.trim(),
+        ProgressBackwardJump: parseInt(document.getElementById('g-progress-backward-jump').value || '0'),
+        ProgressHistorySize: parseInt(document.getElementById('g-progress-history-size').value || '10'),
         PathEmby2Openlist: document.getElementById('g-path').value.trim(),
         LogDisableColor: document.getElementById('g-log-disable').checked
     };
     payload.StrmPathMap = document.getElementById('g-strm-path').value.trim();
     payload.LTGEnable = document.getElementById('g-ltg-enable').checked;
     payload.LTGFFmpegEnable = document.getElementById('g-ltg-ffmpeg').checked;
+    payload.LTGFFmpegFallback = document.getElementById('g-ltg-ffmpeg-fallback').checked;
+    payload.LTGFFmpegWorkers = parseInt(document.getElementById('g-ltg-ffmpeg-workers').value || '1');
     payload.LTGVirtualContainers = document.getElementById('g-ltg-virtual').value.trim();
     payload.LTGStrmContainers = document.getElementById('g-ltg-strm').value.trim();
     payload.LTGMusicContainers = document.getElementById('g-ltg-music').value.trim();
@@ -827,10 +1589,14 @@ async function saveGlobalConfig() {
     payload.LTGScanPrefixes = document.getElementById('g-ltg-scan').value.trim();
     payload.LTGIgnoreContainers = document.getElementById('g-ltg-ignore').value.trim();
     payload.LTGThreads = parseInt(document.getElementById('g-ltg-threads').value || '8');
+    payload.LTGSidecarEnable = document.getElementById('g-ltg-sidecar').checked;
     payload.SslEnable = document.getElementById('g-ssl-enable').checked;
     payload.SslSinglePort = document.getElementById('g-ssl-single').checked;
     payload.SslKey = document.getElementById('g-ssl-key').value.trim();
     payload.SslCrt = document.getElementById('g-ssl-crt').value.trim();
+    payload.BackupEnable = document.getElementById('g-backup-enable').checked;
+    payload.BackupInterval = parseInt(document.getElementById('g-backup-interval').value || '24');
+    payload.BackupRetention = parseInt(document.getElementById('g-backup-retention').value || '7');
     const res = await fetchAuthenticated(`${API_BASE}/global-config`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
@@ -838,10 +1604,486 @@ async function saveGlobalConfig() {
     });
     if (res && res.ok) {
         alert(t('success'));
+    } else if (res) {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+        loadGlobalConfig();
+    }
+}
+
+// Users
+async function loadCurrentUser() {
+    const res = await fetchAuthenticated(`${API_BASE}/user`);
+    if (!res || !res.ok) return;
+    const data = await res.json();
+    document.getElementById('cp-username').value = data.username || '';
+
+    // 单点登录用户的密码和两步验证由身份提供方管理
+    const sso = data.provider === 'oidc';
+    document.getElementById('change-password-form').closest('.card').classList.toggle('hidden', sso);
+    const totpCard = document.getElementById('totp-card');
+    totpCard.classList.toggle('hidden', sso);
+    renderTOTPState(!!data.totpEnabled);
+}
+
+// Two-factor authentication
+function renderTOTPState(enabled, secret, uri) {
+    const setup = !enabled && !!secret;
+    document.getElementById('totp-status').textContent = enabled ? t('totpEnabled') : t('totpDisabled');
+    document.getElementById('totp-setup').classList.toggle('hidden', !setup);
+    document.getElementById('totp-secret').value = secret || '';
+    document.getElementById('totp-uri').href = uri || '#';
+    document.getElementById('totp-code').value = '';
+    document.getElementById('totp-code-group').classList.toggle('hidden', !enabled && !setup);
+    document.getElementById('totp-setup-btn').classList.toggle('hidden', enabled || setup);
+    document.getElementById('totp-confirm-btn').classList.toggle('hidden', !setup);
+    document.getElementById('totp-disable-btn').classList.toggle('hidden', !enabled);
+    document.getElementById('totp-password').closest('.form-group').classList.toggle('hidden', setup);
+    if (!setup) document.getElementById('totp-password').value = '';
+}
+async function postTOTP(action, body) {
+    const res = await fetchAuthenticated(`${API_BASE}/user/totp/${action}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+    });
+    if (!res) return null;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+        alert(data.error || t('networkError'));
+        return null;
+    }
+    return data;
+}
+window.setupTOTP = async () => {
+    const data = await postTOTP('setup', { CurrentPassword: document.getElementById('totp-password').value });
+    if (data) renderTOTPState(false, data.secret, data.uri);
+};
+window.enableTOTP = async () => {
+    const data = await postTOTP('enable', { Code: document.getElementById('totp-code').value.trim() });
+    if (data) {
+        renderTOTPState(true);
+        alert(t('success'));
+    }
+};
+window.disableTOTP = async () => {
+    const data = await postTOTP('disable', {
+        CurrentPassword: document.getElementById('totp-password').value,
+        Code: document.getElementById('totp-code').value.trim(),
+    });
+    if (data) renderTOTPState(false);
+};
+document.getElementById('change-password-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const res = await fetchAuthenticated(`${API_BASE}/user/password`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            CurrentPassword: document.getElementById('cp-current').value,
+            NewPassword: document.getElementById('cp-password').value,
+        }),
+    });
+    if (!res) return;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+        alert(data.error || t('networkError'));
+        return;
+    }
+    authToken = data.token;
+    localStorage.setItem('token', authToken);
+    document.getElementById('cp-current').value = '';
+    document.getElementById('cp-password').value = '';
+    alert(t('passwordChanged'));
+});
+
+let webuiUsers = [];
+async function loadUsers() {
+    const res = await fetchAuthenticated(`${API_BASE}/users`);
+    if (!res || !res.ok) return;
+    webuiUsers = await res.json();
+    const container = document.getElementById('user-list');
+    container.innerHTML = '';
+    webuiUsers.forEach(u => {
+        const card = document.createElement('div');
+        card.className = 'card server-card';
+        const locked = u.LockedUntil && new Date(u.LockedUntil) > new Date();
+        card.innerHTML = `
+            <h3>${escapeHtml(u.Username)}${locked ? ' <i class="fa-solid fa-lock"></i>' : ''}${u.TOTPEnabled ? ` <i class="fa-solid fa-shield-halved" title="${t('twoFactor')}"></i>` : ''}${u.Provider ? ' <span class="badge">SSO</span>' : ''}</h3>
+            <div class="server-info"><i class="fa-solid fa-user-shield"></i> ${t('role' + u.Role.charAt(0).toUpperCase() + u.Role.slice(1))}</div>
+            <div class="server-actions">
+                <button class="btn btn-sm btn-secondary" onclick="editUser(${u.ID})"><i class="fa-solid fa-pen"></i></button>
+                <button class="btn btn-sm btn-danger" onclick="deleteUser(${u.ID})"><i class="fa-solid fa-trash"></i></button>
+            </div>
+        `;
+        container.appendChild(card);
+    });
+}
+function showUserModal() {
+    document.getElementById('um-id').value = '';
+    document.getElementById('um-username').value = '';
+    document.getElementById('um-username').readOnly = false;
+    document.getElementById('um-password').value = '';
+    document.getElementById('um-password').required = true;
+    document.getElementById('um-role').value = 'viewer';
+    document.getElementById('um-reset-totp').checked = false;
+    document.getElementById('um-reset-totp-group').classList.add('hidden');
+    document.getElementById('user-modal').classList.remove('hidden');
+}
+function closeUserModal() {
+    document.getElementById('user-modal').classList.add('hidden');
+}
+window.showUserModal = showUserModal;
+window.closeUserModal = closeUserModal;
+window.editUser = (id) => {
+    const u = webuiUsers.find(x => x.ID === id);
+    if (!u) return;
+    showUserModal();
+    document.getElementById('um-id').value = u.ID;
+    document.getElementById('um-username').value = u.Username;
+    document.getElementById('um-username').readOnly = true;
+    document.getElementById('um-password').required = false;
+    document.getElementById('um-role').value = u.Role;
+    document.getElementById('um-reset-totp-group').classList.toggle('hidden', !u.TOTPEnabled);
+};
+window.deleteUser = async (id) => {
+    if (!confirm(t('deleteUserConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/users/${id}`, { method: 'DELETE' });
+    if (res && !res.ok) {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+    loadUsers();
+};
+document.getElementById('user-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const id = document.getElementById('um-id').value;
+    const body = {
+        Username: document.getElementById('um-username').value.trim(),
+        Password: document.getElementById('um-password').value,
+        Role: document.getElementById('um-role').value,
+        ResetTOTP: document.getElementById('um-reset-totp').checked,
+    };
+    const res = await fetchAuthenticated(id ? `${API_BASE}/users/${id}` : `${API_BASE}/users`, {
+        method: id ? 'PUT' : 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+    });
+    if (!res) return;
+    if (res.ok) {
+        closeUserModal();
+        loadUsers();
+    } else {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+});
+
+// API tokens
+async function loadAPITokens() {
+    const res = await fetchAuthenticated(`${API_BASE}/tokens`);
+    if (!res || !res.ok) return;
+    const data = await res.json();
+
+    const scopes = document.getElementById('token-scopes');
+    if (!scopes.children.length) {
+        (data.scopes || []).forEach(scope => {
+            const label = document.createElement('label');
+            label.className = 'scope-option';
+            label.innerHTML = `<input type="checkbox" value="${escapeHtml(scope)}"> <code>${escapeHtml(scope)}</code> ${escapeHtml(t('scope_' + scope))}`;
+            scopes.appendChild(label);
+        });
+    }
+
+    const list = document.getElementById('token-list');
+    list.innerHTML = '';
+    (data.tokens || []).forEach(tk => {
+        const never = !tk.ExpiresAt || tk.ExpiresAt.startsWith('0001');
+        const expired = !never && new Date(tk.ExpiresAt) < new Date();
+        const used = tk.LastUsedAt && !tk.LastUsedAt.startsWith('0001');
+        const card = document.createElement('div');
+        card.className = 'card server-card';
+        card.innerHTML = `
+            <h3>${escapeHtml(tk.Name)}${expired ? ` <span class="badge">${t('tokenExpired')}</span>` : ''}</h3>
+            <div class="server-info"><i class="fa-solid fa-key"></i> ${escapeHtml(tk.Hint)}…</div>
+            <div class="server-info"><i class="fa-solid fa-list-check"></i> ${escapeHtml(tk.Scopes)}</div>
+            <div class="server-info"><i class="fa-solid fa-hourglass-end"></i> ${t('tokenExpires')}: ${never ? t('tokenNever') : new Date(tk.ExpiresAt).toLocaleString()}</div>
+            <div class="server-info"><i class="fa-solid fa-clock-rotate-left"></i> ${t('tokenLastUsed')}: ${used ? new Date(tk.LastUsedAt).toLocaleString() : '-'}</div>
+            <div class="server-actions">
+                <button class="btn btn-sm btn-danger" onclick="deleteAPIToken(${tk.ID})"><i class="fa-solid fa-trash"></i></button>
+            </div>
+        `;
+        list.appendChild(card);
+    });
+}
+window.deleteAPIToken = async (id) => {
+    if (!confirm(t('tokenRevokeConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/tokens/${id}`, { method: 'DELETE' });
+    if (res && !res.ok) {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+    loadAPITokens();
+};
+document.getElementById('token-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const scopes = [...document.querySelectorAll('#token-scopes input:checked')].map(el => el.value);
+    const res = await fetchAuthenticated(`${API_BASE}/tokens`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            Name: document.getElementById('token-name').value.trim(),
+            Scopes: scopes,
+            ExpiresInDays: parseInt(document.getElementById('token-expires').value || '0'),
+        }),
+    });
+    if (!res) return;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+        alert(data.error || t('networkError'));
+        return;
+    }
+    document.getElementById('token-value').value = data.token;
+    document.getElementById('token-created').classList.remove('hidden');
+    document.getElementById('token-name').value = '';
+    document.querySelectorAll('#token-scopes input').forEach(el => el.checked = false);
+    loadAPITokens();
+});
+
+// Single sign-on
+async function loadOIDCConfig() {
+    const res = await fetchAuthenticated(`${API_BASE}/oidc-config`);
+    if (!res || !res.ok) return;
+    const c = await res.json();
+    document.getElementById('oidc-enable').checked = !!c.Enable;
+    document.getElementById('oidc-name').value = c.Name || '';
+    document.getElementById('oidc-issuer').value = c.Issuer || '';
+    document.getElementById('oidc-client-id').value = c.ClientID || '';
+    document.getElementById('oidc-client-secret').value = c.ClientSecret || '';
+    document.getElementById('oidc-redirect').value = c.RedirectURL || '';
+    document.getElementById('oidc-scopes').value = c.Scopes || '';
+    document.getElementById('oidc-username-claim').value = c.UsernameClaim || '';
+    document.getElementById('oidc-role-claim').value = c.RoleClaim || '';
+    document.getElementById('oidc-role-mapping').value = c.RoleMapping || '';
+    document.getElementById('oidc-default-role').value = c.DefaultRole || '';
+}
+document.getElementById('oidc-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const payload = {
+        Enable: document.getElementById('oidc-enable').checked,
+        Name: document.getElementById('oidc-name').value.trim(),
+        Issuer: document.getElementById('oidc-issuer').value.trim(),
+        ClientID: document.getElementById('oidc-client-id').value.trim(),
+        ClientSecret: document.getElementById('oidc-client-secret').value,
+        RedirectURL: document.getElementById('oidc-redirect').value.trim(),
+        Scopes: document.getElementById('oidc-scopes').value.trim(),
+        UsernameClaim: document.getElementById('oidc-username-claim').value.trim(),
+        RoleClaim: document.getElementById('oidc-role-claim').value.trim(),
+        RoleMapping: document.getElementById('oidc-role-mapping').value.trim(),
+        DefaultRole: document.getElementById('oidc-default-role').value,
+    };
+    const res = await fetchAuthenticated(`${API_BASE}/oidc-config`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(payload),
+    });
+    if (!res) return;
+    if (res.ok) {
+        alert(t('success'));
+    } else {
+        const data = await res.json().catch(() => ({}));
+        alert(data.error || t('networkError'));
+    }
+});
+
+// Path Tester
+function updatePathServerOptions() {
+    const select = document.getElementById('path-server');
+    const current = select.value;
+    select.innerHTML = '';
+    (servers || []).forEach(s => {
+        const opt = document.createElement('option');
+        opt.value = s.ID;
+        opt.textContent = s.Name;
+        select.appendChild(opt);
+    });
+    if (Array.from(select.options).some(o => o.value === current)) select.value = current;
+}
+function renderPathTrace(r) {
+    let html = '';
+    if (r.itemName) {
+        html += `<div class="check-item"><strong>${t('pathItem')}:</strong> ${escapeHtml(r.itemName)} — <code>${escapeHtml(r.embyPath)}</code></div>`;
+    }
+    if ((r.steps || []).length) {
+        html += `<h3>${t('pathSteps')}</h3><ol class="path-steps">` +
+            r.steps.map(s => `<li><span class="badge">${escapeHtml(s.name)}</span> <code>${escapeHtml(s.path)}</code></li>`).join('') +
+            '</ol>';
+    }
+    if ((r.candidates || []).length) {
+        html += `<h3>${t('pathCandidates')}</h3>` + r.candidates.map(c => `
+            <div class="check-item check-${c.exists ? 'ok' : 'error'}">
+                <i class="fa-solid ${c.exists ? 'fa-circle-check' : 'fa-circle-xmark'}"></i>
+                <span class="badge">${t('pathSource_' + c.source)}</span> <code>${escapeHtml(c.path)}</code>
+                ${c.used ? `<strong>${t('pathUsed')}</strong>` : ''}
+                <div class="subtitle">${escapeHtml(c.message)}</div>
+            </div>`).join('');
+        if (!r.candidates.some(c => c.exists)) {
+            html += `<div class="check-item check-warn"><i class="fa-solid fa-triangle-exclamation"></i> ${t('pathNotFound')}</div>`;
+        }
+    }
+    if (r.error) {
+        html += `<div class="check-item check-error"><i class="fa-solid fa-circle-xmark"></i> ${escapeHtml(r.error)}</div>`;
     }
+    return html;
 }
+document.getElementById('path-form')?.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const id = document.getElementById('path-server').value;
+    const box = document.getElementById('path-result');
+    const btn = document.getElementById('path-submit');
+    if (!id) return;
+    box.className = 'check-result';
+    box.innerHTML = `<div class="check-item">${t('pathTesting')}</div>`;
+    btn.disabled = true;
+    try {
+        const res = await fetchAuthenticated(`${API_BASE}/servers/${id}/trace-path`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ Input: document.getElementById('path-input').value.trim() }),
+        });
+        if (!res) return;
+        const data = await res.json().catch(() => ({}));
+        box.innerHTML = res.ok ? renderPathTrace(data)
+            : `<div class="check-item check-error">${escapeHtml(data.error || t('networkError'))}</div>`;
+    } finally {
+        btn.disabled = false;
+    }
+});
+
+// Backups
+function formatSize(bytes) {
+    if (bytes < 1024) return `${bytes} B`;
+    if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+    return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+async function loadBackups() {
+    const res = await fetchAuthenticated(`${API_BASE}/backups`);
+    if (!res || !res.ok) return;
+    const list = await res.json();
+    const container = document.getElementById('backup-list');
+    container.innerHTML = '';
+    if (!list || !list.length) {
+        container.innerHTML = `<p class="page-hint">${t('backupEmpty')}</p>`;
+        return;
+    }
+    list.forEach(b => {
+        const name = encodeURIComponent(b.Name);
+        const card = document.createElement('div');
+        card.className = 'card server-card';
+        card.innerHTML = `
+            <h3>${new Date(b.CreatedAt).toLocaleString()} <span class="badge">${escapeHtml(t('backupKind_' + b.Kind))}</span></h3>
+            <div class="server-info"><i class="fa-solid fa-file-zipper"></i> ${escapeHtml(b.Name)}</div>
+            <div class="server-info"><i class="fa-solid fa-weight-hanging"></i> ${formatSize(b.Size)}</div>
+            <div class="server-actions">
+                <button class="btn btn-sm btn-secondary" onclick="downloadBackup('${name}')"><i class="fa-solid fa-download"></i></button>
+                <button class="btn btn-sm btn-primary" onclick="restoreBackup('${name}')"><i class="fa-solid fa-clock-rotate-left"></i></button>
+                <button class="btn btn-sm btn-danger" onclick="deleteBackup('${name}')"><i class="fa-solid fa-trash"></i></button>
+            </div>
+        `;
+        container.appendChild(card);
+    });
+}
+async function showBackupError(res) {
+    const data = await res.json().catch(() => ({}));
+    alert(data.error || t('networkError'));
+}
+async function saveBlob(res, fallbackName) {
+    const m = (res.headers.get('Content-Disposition') || '').match(/filename="?([^"]+)"?/);
+    const url = URL.createObjectURL(await res.blob());
+    const a = document.createElement('a');
+    a.href = url;
+    a.download = m ? m[1] : fallbackName;
+    a.click();
+    URL.revokeObjectURL(url);
+}
+window.createBackup = async () => {
+    const res = await fetchAuthenticated(`${API_BASE}/backups`, { method: 'POST' });
+    if (!res) return;
+    if (!res.ok) return showBackupError(res);
+    loadBackups();
+};
+window.exportBackup = async () => {
+    const res = await fetchAuthenticated(`${API_BASE}/backup/export`);
+    if (!res) return;
+    if (!res.ok) return showBackupError(res);
+    await saveBlob(res, 'go-emby-backup.zip');
+};
+window.importBackup = async (input) => {
+    const file = input.files[0];
+    input.value = '';
+    if (!file || !confirm(t('backupRestoreConfirm'))) return;
+    const form = new FormData();
+    form.append('file', file);
+    const res = await fetchAuthenticated(`${API_BASE}/backup/import`, { method: 'POST', body: form });
+    if (!res) return;
+    if (!res.ok) return showBackupError(res);
+    alert(t('backupRestored'));
+    loadBackups();
+};
+window.downloadBackup = async (name) => {
+    const res = await fetchAuthenticated(`${API_BASE}/backups/${name}`);
+    if (!res) return;
+    if (!res.ok) return showBackupError(res);
+    await saveBlob(res, decodeURIComponent(name));
+};
+window.restoreBackup = async (name) => {
+    if (!confirm(t('backupRestoreConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/backups/${name}/restore`, { method: 'POST' });
+    if (!res) return;
+    if (!res.ok) return showBackupError(res);
+    alert(t('backupRestored'));
+    loadBackups();
+};
+window.deleteBackup = async (name) => {
+    if (!confirm(t('backupDeleteConfirm'))) return;
+    const res = await fetchAuthenticated(`${API_BASE}/backups/${name}`, { method: 'DELETE' });
+    if (res && !res.ok) await showBackupError(res);
+    loadBackups();
+};
+
+// Audit Logs
+function formatAuditValue(v) {
+    if (v === null || v === undefined || v === '') return '∅';
+    return typeof v === 'object' ? JSON.stringify(v) : String(v);
+}
+async function loadAuditLogs() {
+    const target = document.getElementById('audit-target-filter').value;
+    const res = await fetchAuthenticated(`${API_BASE}/audit-logs?target=${encodeURIComponent(target)}`);
+    if (!res || !res.ok) return;
+    const list = await res.json();
+    const tbody = document.querySelector('#audit-table tbody');
+    tbody.innerHTML = '';
+    (list || []).forEach(l => {
+        let changes = [];
+        try { changes = JSON.parse(l.Changes || '[]'); } catch (e) { changes = []; }
+        const text = changes.map(ch => `${ch.Field}: ${formatAuditValue(ch.Before)} → ${formatAuditValue(ch.After)}`).join('\n');
+        const tr = document.createElement('tr');
+        tr.innerHTML = `
+            <td>${new Date(l.CreatedAt).toLocaleString()}</td>
+            <td>${escapeHtml(l.Username)}</td>
+            <td>${escapeHtml(l.Action)}</td>
+            <td>${escapeHtml(l.Target)} #${l.TargetID}</td>
+            <td class="audit-change">${escapeHtml(text)}</td>
+        `;
+        tbody.appendChild(tr);
+    });
+}
+window.loadAuditLogs = loadAuditLogs;
 
 // Helpers
+function escapeHtml(s) {
+    return String(s ?? '').replace(/[&<>"']/g, ch => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' }[ch]));
+}
+
 async function fetchAuthenticated(url, options = {}) {
     if (!options.headers) options.headers = {};
     options.headers['Authorization'] = authToken;
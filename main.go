@@ -18,7 +18,10 @@ import (
 	"github.com/syscc/Emby-Go/internal/constant"
 	"github.com/syscc/Emby-Go/internal/db"
 	"github.com/syscc/Emby-Go/internal/manager"
+	"github.com/syscc/Emby-Go/internal/service/configcheck"
+	"github.com/syscc/Emby-Go/internal/service/m3u8"
 	"github.com/syscc/Emby-Go/internal/service/openlist/localtree"
+	"github.com/syscc/Emby-Go/internal/util/events"
 	"github.com/syscc/Emby-Go/internal/util/logs"
 	"github.com/syscc/Emby-Go/internal/util/logs/colors"
 	"github.com/syscc/Emby-Go/internal/web"
@@ -35,12 +38,15 @@ func main() {
 	printVersion := flag.Bool("version", false, "查看程序版本")
 	dr := flag.String("dr", "./app", "程序数据根目录") // Default to ./app for local development
 	wp := flag.Int("p", 8090, "WebUI 管理后台端口")
+	resetPassword := flag.Bool("reset-password", false, "离线重置管理员密码后退出, 新密码从环境变量 reset_password 或标准输入读取")
 
 	// Kernel flags
 	kernelOnly := flag.Bool("kernel-only", false, "仅启动内核")
 	configPath := flag.String("config", "", "配置文件路径 (仅内核模式)")
 	httpPort := flag.Int("http-port", 8095, "HTTP 端口 (仅内核模式)")
 	httpsPort := flag.Int("https-port", 8094, "HTTPS 端口 (仅内核模式)")
+	checkOnly := flag.Bool("check", false, "校验配置并检查 emby 和 openlist 的连通性后退出 (仅内核模式)")
+	tracePath := flag.String("trace-path", "", "输出 emby item id 或资源路径的转换过程后退出 (仅内核模式)")
 
 	flag.Parse()
 
@@ -103,6 +109,15 @@ func main() {
 			log.Fatal("kernel-only mode requires -config")
 		}
 
+		if *checkOnly {
+			runConfigCheck(*configPath)
+			return
+		}
+		if *tracePath != "" {
+			runPathTrace(*configPath, *tracePath)
+			return
+		}
+
 		// Setup logs for kernel
 		serverDir := filepath.Dir(*configPath)
 		logDir := filepath.Join(serverDir, "log")
@@ -122,10 +137,18 @@ func main() {
 
 		printBanner()
 
+		logs.Info("正在初始化转码播放列表缓存...")
+		if err := m3u8.Init(); err != nil {
+			log.Fatal(colors.ToRed(err.Error()))
+		}
+
 		logs.Info("正在初始化本地目录树模块...")
 		if err := localtree.Init(); err != nil {
 			log.Fatal(colors.ToRed(err.Error()))
 		}
+		go events.Listen(os.Stdin, func(typ string, payload []byte) {
+			handleKernelCommand(*configPath, typ, payload)
+		})
 
 		logs.Info("正在启动服务...")
 		if err := web.Listen(); err != nil {
@@ -154,12 +177,26 @@ func main() {
 			log.Fatalf("Init DB failed: %v", err)
 		}
 
+		if *resetPassword {
+			password, err := readResetPassword()
+			if err != nil {
+				log.Fatalf("重置管理员密码失败: %v", err)
+			}
+			username, err := db.ResetAdminPassword(password)
+			if err != nil {
+				log.Fatalf("重置管理员密码失败: %v", err)
+			}
+			logs.Success("管理员 %s 的密码已重置, 所有登录会话已失效", username)
+			os.Exit(0)
+		}
+
 		// Start Manager (Load Proxies)
 		logs.Info("正在加载代理服务...")
 		if err := manager.LoadAll(); err != nil {
 			log.Printf("Load proxies failed: %v", err)
 		}
 		manager.StartHealthMonitor()
+		manager.StartBackupScheduler()
 
 		// Start WebUI
 		logs.Info("正在启动 WebUI 管理后台...")
@@ -170,6 +207,89 @@ func main() {
 	}
 }
 
+// handleKernelCommand 处理管理进程通过标准输入发送给内核的命令
+func handleKernelCommand(configPath, typ string, _ []byte) {
+	switch typ {
+	case events.TypePlaylistStats:
+		if err := events.Emit(events.TypePlaylistStats, events.PlaylistStats(m3u8.PlaylistStats())); err != nil {
+			logs.Error("上报 playlist 统计信息失败: %v", err)
+		}
+	case events.TypeSyncLocalTree:
+		if !localtree.TriggerSync() {
+			logs.Warn("本地目录树未启用, 忽略同步请求")
+		}
+	case events.TypeReloadConfig:
+		reloadKernelConfig(configPath)
+	}
+}
+
+// reloadKernelConfig 重新加载配置文件, 通过事件将结果上报给管理进程
+func reloadKernelConfig(configPath string) {
+	var res events.ConfigReload
+	fields, err := config.Reload(configPath)
+	switch {
+	case err != nil:
+		res.Error = err.Error()
+		logs.Error("重新加载配置失败, 继续使用原配置: %v", err)
+	case len(fields) > 0:
+		res.Restart = fields
+		logs.Info("配置项 %s 需要重启服务才能生效", strings.Join(fields, ", "))
+	default:
+		logs.Success("配置已重新加载")
+	}
+	if err := events.Emit(events.TypeConfigReloaded, res); err != nil {
+		logs.Error("上报配置加载结果失败: %v", err)
+	}
+}
+
+// runConfigCheck 加载配置并执行检查, 通过事件将结果上报给管理进程
+func runConfigCheck(configPath string) {
+	var res *events.ConfigCheck
+	if err := config.ReadFromFile(configPath); err != nil {
+		res = new(events.ConfigCheck)
+		res.Add(configcheck.NameConfig, events.CheckError, "%v", err)
+	} else {
+		res = configcheck.Run()
+	}
+	if err := events.Emit(events.TypeConfigCheck, res); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runPathTrace 加载配置并测试路径转换, 通过事件将结果上报给管理进程
+func runPathTrace(configPath, input string) {
+	res := &events.PathTrace{Input: input}
+	if err := config.ReadFromFile(configPath); err != nil {
+		res.Error = err.Error()
+	} else {
+		res = configcheck.TracePath(input)
+	}
+	if err := events.Emit(events.TypePathTrace, res); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readResetPassword 读取离线重置的新密码, 避免密码出现在命令行参数中
+//
+// 优先使用环境变量 reset_password, 否则从标准输入读取一行, 标准输入为终端时先输出提示
+func readResetPassword() (string, error) {
+	if v := os.Getenv("reset_password"); v != "" {
+		return v, nil
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "请输入新的管理员密码: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("读取新密码失败: %v", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("新密码不能为空")
+	}
+	return password, nil
+}
+
 func setLocalTZ() {
 	tz := os.Getenv("TZ")
 	if tz == "" {